@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// newAuditLogger creates the audit logger on the store, nil when the store keeps no audit trail
+func newAuditLogger(s store.Store, cfg config.Config) *audit.Logger {
+	as, ok := s.(store.AuditStore)
+	if !ok {
+		return nil
+	}
+	var key []byte
+	if cfg.Audit.HMACKey != "" {
+		key = []byte(cfg.Audit.HMACKey)
+	}
+	return audit.NewLogger(as, key)
+}
+
+// normalizePlanID accepts plan IDs with or without the "plan-" prefix
+func normalizePlanID(id string) string {
+	if id != audit.SystemChain && !strings.HasPrefix(id, "plan-") {
+		return "plan-" + id
+	}
+	return id
+}
+
+// auditStep records the outcome of an executed step on the plan's chain
+func auditStep(task *Task, step model.Step) {
+	data := map[string]interface{}{
+		"agent_id": step.AgentID,
+		"action":   step.Action,
+		"status":   step.Status,
+	}
+	if step.ApprovedBy != "" {
+		data["approved_by"] = step.ApprovedBy
+	}
+	if step.Error != "" {
+		data["error"] = step.Error
+	}
+	if outputs, err := json.Marshal(step.Outputs); err == nil && len(step.Outputs) > 0 {
+		data["outputs_hash"] = audit.HashContent(string(outputs))
+	}
+	audit.Log(task.ID, audit.TypeStep, audit.ActorFromContext(task.Ctx), step.ID,
+		fmt.Sprintf("Step %d (%s) %s", step.ID, step.Action, step.Status), data)
+}
+
+// auditApproval records an approval decision ("approved" or "rejected") for a step
+func auditApproval(planID string, step model.Step, actor, decision, reason string) {
+	data := map[string]interface{}{
+		"decision":       decision,
+		"action":         step.Action,
+		"assigned_group": step.AssignedGroup,
+	}
+	if step.ApprovedBy != "" {
+		data["approved_by"] = step.ApprovedBy
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	audit.Log(planID, audit.TypeApproval, actor, step.ID,
+		fmt.Sprintf("Step %d (%s) %s by %s", step.ID, step.Action, decision, actor), data)
+}
+
+// canAudit reports whether the user may read the audit trail
+func canAudit(cfg config.Config, user *iam.User) bool {
+	if user == nil {
+		return false
+	}
+	for _, g := range user.Groups {
+		if g == "root" || g == "admin" {
+			return true
+		}
+		for _, allowed := range cfg.Audit.AuditorGroups() {
+			if strings.EqualFold(g, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleAuditQuery serves GET /v1/audit?plan_id=&type=&actor=&since=&until=&limit=
+func handleAuditQuery(cfgMgr *config.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := audit.Default()
+		if logger == nil {
+			http.Error(w, "Audit trail not available", http.StatusNotImplemented)
+			return
+		}
+		if user, _ := iam.GetUserFromContext(r.Context()); !canAudit(cfgMgr.Get(), user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		q := r.URL.Query()
+		filter := audit.Filter{Type: q.Get("type"), Actor: q.Get("actor")}
+		if id := q.Get("plan_id"); id != "" {
+			filter.Chain = normalizePlanID(id)
+		}
+		for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			if v := q.Get(name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Invalid %s (RFC3339 expected)", name), http.StatusBadRequest)
+					return
+				}
+				*target = t
+			}
+		}
+		filter.Limit = 500
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = n
+		}
+
+		records, err := logger.Query(filter)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to query audit trail: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if records == nil {
+			records = []model.AuditRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	}
+}
+
+// handleAuditVerify serves GET /v1/audit/{plan_id}/verify
+func handleAuditVerify(cfgMgr *config.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := audit.Default()
+		if logger == nil {
+			http.Error(w, "Audit trail not available", http.StatusNotImplemented)
+			return
+		}
+		if user, _ := iam.GetUserFromContext(r.Context()); !canAudit(cfgMgr.Get(), user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		res, err := logger.Verify(normalizePlanID(chi.URLParam(r, "plan_id")))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to verify audit trail: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func newAuditCmd() *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect and verify the audit trail",
+	}
+
+	var all bool
+	verifyCmd := &cobra.Command{
+		Use:   "verify [plan-id]",
+		Short: "Verify the hash chain of a plan's audit trail (or all chains with --all)",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all && len(args) == 0 || !all && len(args) == 1 {
+				return nil
+			}
+			return fmt.Errorf("provide a plan-id or --all")
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			logger, closeFn, err := openAuditLogger()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer closeFn()
+
+			chains := args
+			if all {
+				if chains, err = logger.Chains(); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			failed := false
+			for _, chain := range chains {
+				res, err := logger.Verify(normalizePlanID(chain))
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				switch {
+				case res.Records == 0:
+					fmt.Printf("⚠️  %s: no audit records\n", res.Chain)
+					failed = failed || !all
+				case res.Valid:
+					fmt.Printf("✅ %s: %d records, chain intact\n", res.Chain, res.Records)
+				default:
+					fmt.Printf("❌ %s: chain broken at record %d of %d: %s\n", res.Chain, res.BrokenAt, res.Records, res.Reason)
+					failed = true
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+	verifyCmd.Flags().BoolVar(&all, "all", false, "Verify every chain in the store")
+
+	var recType string
+	var asJSON bool
+	listCmd := &cobra.Command{
+		Use:   "list <plan-id>",
+		Short: "Print the audit records of a plan",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger, closeFn, err := openAuditLogger()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer closeFn()
+
+			records, err := logger.Query(audit.Filter{Chain: normalizePlanID(args[0]), Type: recType})
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				_ = enc.Encode(records)
+				return
+			}
+			for _, rec := range records {
+				actor := rec.Actor
+				if actor == "" {
+					actor = "-"
+				}
+				fmt.Printf("%4d  %s  %-13s %-12s %s\n", rec.Seq, rec.Time.Format(time.RFC3339), rec.Type, actor, rec.Summary)
+			}
+		},
+	}
+	listCmd.Flags().StringVar(&recType, "type", "", "Only records of this type (llm_call, tool_call, step, approval, config_change, file_edit, interaction, rollback, export, import)")
+	listCmd.Flags().BoolVar(&asJSON, "json", false, "Print the full records as JSON")
+
+	auditCmd.AddCommand(verifyCmd, listCmd)
+	return auditCmd
+}
+
+// openAuditLogger opens the configured store for the audit commands, without the rest of the setup
+func openAuditLogger() (*audit.Logger, func(), error) {
+	s, cfg, closeFn, err := openStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	logger := newAuditLogger(s, cfg)
+	if logger == nil {
+		closeFn()
+		return nil, nil, fmt.Errorf("the configured store has no audit trail")
+	}
+	return logger, closeFn, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/bundle"
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/paths"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// maxBundleUpload bounds the size of a bundle uploaded to POST /v1/plans/import
+const maxBundleUpload = 1 << 30
+
+// bundleKey returns the configured bundle signing key (nil when bundles are unsigned)
+func bundleKey(cfg config.Config) []byte {
+	if cfg.Bundle.SigningKey == "" {
+		return nil
+	}
+	return []byte(cfg.Bundle.SigningKey)
+}
+
+// handlePlanExport downloads a plan as a bundle (GET /v1/plans/{id}/export)
+func handlePlanExport(cfgMgr *config.Manager, s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := normalizePlanID(chi.URLParam(r, "id"))
+		plan, err := s.GetPlan(id)
+		if err != nil {
+			http.Error(w, "Plan not found", http.StatusNotFound)
+			return
+		}
+		if user, ok := iam.GetUserFromContext(r.Context()); ok && !plan.VisibleTo(user.ID, user.Username, user.Groups) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		dir, err := paths.ResolvePath(".druppie", "plans", id)
+		if err != nil {
+			http.Error(w, "Project root not found", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.druppie.tar.gz"`, id))
+		manifest, err := bundle.Export(w, s, id, dir, bundleKey(cfgMgr.Get()))
+		if err != nil {
+			// Headers are gone once the archive started; this only reaches the client on early failures
+			http.Error(w, fmt.Sprintf("Failed to export plan: %v", err), http.StatusInternalServerError)
+			return
+		}
+		audit.Log(id, audit.TypeExport, audit.ActorFromContext(r.Context()), 0, "Exported plan bundle", map[string]interface{}{
+			"entries":   len(manifest.Entries),
+			"algorithm": manifest.Algorithm,
+		})
+	}
+}
+
+// handlePlanImport imports a bundle sent as the request body or as the multipart field
+// "file" (POST /v1/plans/import). The importing user becomes the creator of the plan.
+func handlePlanImport(cfgMgr *config.Manager, s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBundleUpload)
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Invalid file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+		plansDir, err := paths.ResolvePath(".druppie", "plans")
+		if err != nil {
+			http.Error(w, "Project root not found", http.StatusInternalServerError)
+			return
+		}
+
+		cfg := cfgMgr.Get()
+		opts := bundle.ImportOptions{Key: bundleKey(cfg), AllowUnsigned: cfg.Bundle.AllowUnsigned}
+		if user, ok := iam.GetUserFromContext(r.Context()); ok {
+			opts.CreatorID = user.Username
+		}
+		res, err := bundle.Import(body, s, plansDir, opts)
+		if errors.Is(err, bundle.ErrUnsigned) {
+			http.Error(w, fmt.Sprintf("Failed to import bundle: %v", err), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to import bundle: %v", err), http.StatusBadRequest)
+			return
+		}
+		logImport(res, audit.ActorFromContext(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// logImport records an import on the chain of the imported plan
+func logImport(res bundle.Result, actor string) {
+	summary := "Imported plan bundle"
+	if res.Rekeyed {
+		summary = fmt.Sprintf("Imported plan bundle of %s", res.OriginalID)
+	}
+	audit.Log(res.PlanID, audit.TypeImport, actor, 0, summary, map[string]interface{}{
+		"original_id": res.OriginalID,
+		"rekeyed":     res.Rekeyed,
+		"files":       res.Files,
+	})
+}
+
+func newPlanCmd() *cobra.Command {
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Export and import plans",
+	}
+
+	var output string
+	var allowUnsigned bool
+	exportCmd := &cobra.Command{
+		Use:   "export <plan-id>",
+		Short: "Write a plan with its logs, memory and files to a signed bundle",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, cfg, closeFn, err := openStore()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer closeFn()
+
+			id := normalizePlanID(args[0])
+			if output == "" {
+				output = id + ".druppie.tar.gz"
+			}
+			dir, err := paths.ResolvePath(".druppie", "plans", id)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			manifest, err := bundle.Export(f, s, id, dir, bundleKey(cfg))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(output)
+				fmt.Printf("Export failed: %v\n", err)
+				os.Exit(1)
+			}
+			audit.Log(id, audit.TypeExport, "", 0, "Exported plan bundle", map[string]interface{}{
+				"entries":   len(manifest.Entries),
+				"algorithm": manifest.Algorithm,
+			})
+			fmt.Printf("Exported %s to %s (%d entries, %s)\n", id, output, len(manifest.Entries), manifest.Algorithm)
+		},
+	}
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "Bundle file (default <plan-id>.druppie.tar.gz)")
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a plan bundle, under a new ID when the plan already exists",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, cfg, closeFn, err := openStore()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer closeFn()
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			plansDir, err := paths.ResolvePath(".druppie", "plans")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts := bundle.ImportOptions{Key: bundleKey(cfg), AllowUnsigned: cfg.Bundle.AllowUnsigned || allowUnsigned}
+			res, err := bundle.Import(f, s, plansDir, opts)
+			if errors.Is(err, bundle.ErrUnsigned) {
+				fmt.Printf("Import failed: %v, or pass --allow-unsigned\n", err)
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Printf("Import failed: %v\n", err)
+				os.Exit(1)
+			}
+			logImport(res, "")
+			if res.Rekeyed {
+				fmt.Printf("Imported %s as %s (ID was taken or invalid), %d files\n", res.OriginalID, res.PlanID, res.Files)
+			} else {
+				fmt.Printf("Imported %s, %d files\n", res.PlanID, res.Files)
+			}
+		},
+	}
+
+	importCmd.Flags().BoolVar(&allowUnsigned, "allow-unsigned", false, "Accept a bundle without signature, like bundle.allow_unsigned")
+
+	planCmd.AddCommand(exportCmd, importCmd)
+	return planCmd
+}
+
+// openStore opens the configured store and config for commands that run without the server
+// and installs the audit logger on the store
+func openStore() (store.Store, config.Config, func(), error) {
+	if err := paths.EnsureProjectRoot(); err != nil {
+		return nil, config.Config{}, nil, fmt.Errorf("root detection error: %w", err)
+	}
+	rootDir, err := paths.FindProjectRoot()
+	if err != nil {
+		return nil, config.Config{}, nil, err
+	}
+	s, err := store.New(filepath.Join(rootDir, ".druppie"), config.LoadStoreConfig(rootDir).Options())
+	if err != nil {
+		return nil, config.Config{}, nil, fmt.Errorf("store init error: %w", err)
+	}
+	closeFn := func() {}
+	if c, ok := s.(interface{ Close() error }); ok {
+		closeFn = func() { _ = c.Close() }
+	}
+	cfgMgr, err := config.NewManager(s)
+	if err != nil {
+		closeFn()
+		return nil, config.Config{}, nil, fmt.Errorf("config load error: %w", err)
+	}
+	cfg := cfgMgr.Get()
+	audit.SetDefault(newAuditLogger(s, cfg))
+	return s, cfg, closeFn, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/limiter"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// Limiter keys of the step slots
+const (
+	slotSteps          = "steps"
+	planSlotPrefix     = "plan:"
+	executorSlotPrefix = "executor:"
+)
+
+// stepLimits bounds the steps executed at the same time (general.limits)
+type stepLimits struct {
+	mu    sync.RWMutex
+	cfg   config.LimitsConfig
+	slots *limiter.Limiter
+}
+
+func newStepLimits() *stepLimits {
+	l := &stepLimits{}
+	l.slots = limiter.New(l.limit)
+	return l
+}
+
+// configure applies the limits; steps waiting for a slot are re-checked against them
+func (l *stepLimits) configure(cfg config.LimitsConfig) {
+	executors := make(map[string]int, len(cfg.Executors))
+	for name, n := range cfg.Executors {
+		executors[executorName(name)] = n
+	}
+	cfg.Executors = executors
+
+	l.mu.Lock()
+	l.cfg = cfg
+	l.mu.Unlock()
+	l.slots.Release() // Wake the steps that fit a raised limit
+}
+
+func (l *stepLimits) limit(key string) int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	switch {
+	case key == slotSteps:
+		return l.cfg.MaxSteps
+	case strings.HasPrefix(key, planSlotPrefix):
+		return l.cfg.MaxStepsPerPlan
+	case strings.HasPrefix(key, executorSlotPrefix):
+		return l.cfg.Executors[strings.TrimPrefix(key, executorSlotPrefix)]
+	}
+	return 0
+}
+
+// executorName normalizes an agent id or action like the dispatcher does
+func executorName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// stepSlots returns the slots a step of the plan needs: the global one, the one of the plan
+// and those of its executor, by agent id and action
+func stepSlots(planID string, step model.Step) []string {
+	keys := []string{slotSteps, planSlotPrefix + planID}
+	for _, name := range []string{step.AgentID, step.Action} {
+		if name == "" {
+			continue
+		}
+		key := executorSlotPrefix + executorName(name)
+		if key != keys[len(keys)-1] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// acquireSlots waits until the step may run under the configured limits and returns the
+// function that frees its slots. onQueued is called once when the step has to wait.
+func (tm *TaskManager) acquireSlots(task *Task, step model.Step, onQueued func()) (func(), error) {
+	tm.limits.configure(tm.loadConfig().General.Limits)
+	keys := stepSlots(task.ID, step)
+	if !tm.limits.slots.TryAcquire(keys...) {
+		if onQueued != nil {
+			onQueued()
+		}
+		tm.OutputChan <- fmt.Sprintf("[%s] Step %d (%s) queued, waiting for a free execution slot...", task.ID, step.ID, step.Action)
+		if err := tm.limits.slots.Acquire(task.Ctx, keys...); err != nil {
+			return nil, err
+		}
+	}
+	return func() { tm.limits.slots.Release(keys...) }, nil
+}
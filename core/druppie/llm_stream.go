@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+)
+
+// llmStream returns a stream handler that forwards the partial LLM output of a step: every chunk
+// goes to the live subscribers of the plan, complete lines go to the plan log. The returned flush
+// must be called when the step is done, to log the last partial line.
+func (tm *TaskManager) llmStream(task *Task, stepID int, agentID, action string) (llm.StreamHandler, func()) {
+	prefix := fmt.Sprintf("[%s] ✍️ ", task.ID)
+	if stepID > 0 {
+		prefix = fmt.Sprintf("[%s] ✍️ [Step %d] ", task.ID, stepID)
+	}
+	lines := &llm.LineWriter{OnLine: func(line string) {
+		tm.OutputChan <- prefix + line
+	}}
+
+	handler := func(chunk string) {
+		if tm.Events != nil {
+			tm.Events.PublishTransient(task.ID, PlanEvent{
+				Type:    PlanEventLLMOutput,
+				StepID:  stepID,
+				AgentID: agentID,
+				Action:  action,
+				Message: chunk,
+			})
+		}
+		lines.Write(chunk)
+	}
+	return handler, lines.Flush
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/builder"
 	"github.com/sjhoeksma/druppie/core/internal/config"
 	"github.com/sjhoeksma/druppie/core/internal/iam"
@@ -54,9 +56,45 @@ func getAuthContext(ctx context.Context, p iam.Provider, demo bool) context.Cont
 			return iam.ContextWithUser(ctx, u)
 		}
 	}
+
+	if kp, ok := p.(*iam.KeycloakProvider); ok {
+		if u, err := kp.VerifyToken(ctx, token); err == nil {
+			return iam.ContextWithUser(ctx, u)
+		}
+		// Access tokens are short lived, renew with the stored refresh token
+		refresh, _ := iam.LoadClientRefreshToken()
+		if refresh == "" {
+			return ctx
+		}
+		tok, u, err := kp.RefreshToken(ctx, refresh)
+		if err != nil {
+			fmt.Printf("Session expired: %v\n", err)
+			return ctx
+		}
+		_ = iam.SaveClientToken(tok.AccessToken)
+		if tok.RefreshToken != "" {
+			_ = iam.SaveClientRefreshToken(tok.RefreshToken)
+		}
+		return iam.ContextWithUser(ctx, u)
+	}
 	return ctx
 }
 
+// saveOIDCLogin stores the tokens of a keycloak login for later CLI calls
+func saveOIDCLogin(accessToken, refreshToken string, u *iam.User) {
+	if err := iam.SaveClientToken(accessToken); err != nil {
+		fmt.Printf("Failed to save session: %v\n", err)
+		os.Exit(1)
+	}
+	if refreshToken != "" {
+		if err := iam.SaveClientRefreshToken(refreshToken); err != nil {
+			fmt.Printf("Failed to save session: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Logged in as %s (Groups: %v)\n", u.Username, u.Groups)
+}
+
 var (
 	Version = "-.-.-"
 )
@@ -86,11 +124,14 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 	// Register commands
 	rootCmd.AddCommand(newGenerateCmd())
 	rootCmd.AddCommand(newCliCmd())
+	rootCmd.AddCommand(newStoreCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newPlanCmd())
 
 	// Helper to bootstrap dependencies
 	// Helper to bootstrap dependencies
 	// Returns ConfigManager to allow updates, and Builder Engine
-	setup := func(_ *cobra.Command) (*config.Manager, *registry.Registry, *router.Router, *planner.Planner, *mcp.Manager, builder.BuildEngine, iam.Provider, error) {
+	var setup setupFunc = func(_ *cobra.Command) (*config.Manager, *registry.Registry, *router.Router, *planner.Planner, *mcp.Manager, builder.BuildEngine, iam.Provider, error) {
 		// If demo flag is set, force IAM provider to demo via env var (handled by config manager loadEnv)
 		if demo {
 			os.Setenv("IAM_PROVIDER", "demo")
@@ -116,10 +157,15 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 		// Initialize Store (Central .druppie dir for all persistence)
 		storeDir := filepath.Join(rootDir, ".druppie")
-		druppieStore, err := store.NewFileStore(storeDir)
+		storeCfg := config.LoadStoreConfig(rootDir)
+		druppieStore, err := store.New(storeDir, storeCfg.Options())
 		if err != nil {
 			return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("store init error: %w", err)
 		}
+		if _, ok := druppieStore.(*store.SQLStore); ok {
+			// Route executor/build logs (written via the logging package) into the database as well
+			logging.SetBackend(druppieStore)
+		}
 
 		// Load Configuration from Store
 		cfgMgr, err := config.NewManager(druppieStore)
@@ -128,10 +174,13 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 		}
 		cfg := cfgMgr.Get()
 
+		// Tamper-evident audit trail of prompts, tool calls, approvals and edits
+		audit.SetDefault(newAuditLogger(druppieStore, cfg))
+
 		// Apply Overrides
 		if llmProviderOverride != "" {
 			fmt.Printf("Overriding LLM Provider to: %s\n", llmProviderOverride)
-			cfg.LLM.DefaultProvider = llmProviderOverride
+			cfg.LLM.DefaultProvider = config.ParseProviderChain(llmProviderOverride)
 		}
 		if buildProviderOverride != "" {
 			fmt.Printf("Overriding Build Provider to: %s\n", buildProviderOverride)
@@ -149,12 +198,26 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 		if err != nil {
 			return nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("llm init error: %w", err)
 		}
+		cacheDir := filepath.Join(storeDir, "cache", "llm")
+		if cfg.LLM.Cache.MemoryOnly {
+			cacheDir = ""
+		}
+		llmManager.UseCache(llm.NewResponseCache(cacheDir, cfg.LLM.Cache.MaxEntries, cfg.LLM.Cache.TTL()), cfg.LLM.Cache.Enabled)
 
 		// Initialize MCP Manager
 		mcpManager := mcp.NewManager(context.Background(), druppieStore, reg)
 
 		// Initialize Memory Manager
 		memManager := memory.NewManager(cfg.General.Memory.MaxWindowTokens, druppieStore)
+		if cfg.General.Memory.SummarizeAfter > 0 {
+			memManager.SummarizeAfter = cfg.General.Memory.SummarizeAfter
+		}
+		memManager.Summarizer = llmManager // Older turns are summarized instead of dropped
+		memManager.Embedder = llmManager   // Embedding provider of the config, local embeddings without one
+		memManager.Tokenizer = llmManager.Capabilities().Tokenizer
+		if err := configureLongTermMemory(memManager, cfg.General.Memory.LongTerm, storeDir); err != nil {
+			fmt.Printf("Warning: long-term memory disabled: %v\n", err)
+		}
 
 		r := router.NewRouter(llmManager, druppieStore, reg, debug)
 		p := planner.NewPlanner(llmManager, reg, druppieStore, mcpManager, memManager, cfg.General.MaxAgentSelection, debug)
@@ -179,30 +242,6 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 			tm := NewTaskManager(plannerService, mcpManager, buildEngine)
 			cfg := cfgMgr.Get()
 
-			// Reset any "running" plans to "pending" to handle unclean shutdowns
-			plans, err := plannerService.Store.ListPlans()
-			if err == nil {
-				for _, p := range plans {
-					modified := false
-					if p.Status == "running" {
-						p.Status = "pending"
-						modified = true
-					}
-					for i, s := range p.Steps {
-						if s.Status == "running" {
-							p.Steps[i].Status = "pending"
-							modified = true
-						}
-					}
-					if modified {
-						fmt.Printf("Resetting interrupted plan %s to pending state.\n", p.ID)
-						_ = plannerService.Store.SavePlan(p)
-					}
-				}
-			} else {
-				fmt.Printf("Warning: Failed to list plans for cleanup: %v\n", err)
-			}
-
 			// Start Cleanup Routine
 			// ---------------------------------------------------------
 			// Scheduler Implementation
@@ -300,6 +339,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 						// Save to store
 						_ = plannerService.Store.AppendRawLog(planID, msg)
+						tm.Events.Publish(planID, PlanEvent{Type: PlanEventLog, Message: msg})
 
 					case <-tm.TaskDoneChan:
 						// Just consume
@@ -307,6 +347,19 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 				}
 			}()
 
+			// Steps run in this process, or on workers when configured
+			if err := tm.useWorkers(context.Background(), cfg.Workers); err != nil {
+				fmt.Printf("Startup Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Plans interrupted by an unclean shutdown: reset, re-register or resume them
+			if res, err := tm.RecoverPlans(cfg.General.Recovery.AutoResume); err != nil {
+				fmt.Printf("Warning: Failed to list plans for recovery: %v\n", err)
+			} else if res.Reset+res.Resumed+res.Waiting > 0 {
+				fmt.Printf("Recovered plans: %d set to pending, %d resumed, %d waiting for input.\n", res.Reset, res.Resumed, res.Waiting)
+			}
+
 			r := chi.NewRouter()
 			// Custom Error-Only Logger (Silences 200 OKs)
 			r.Use(func(next http.Handler) http.Handler {
@@ -443,6 +496,8 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 			// API Routes
 			r.Route("/v1", func(r chi.Router) {
+				// EventSource cannot set headers: accept the token as query parameter for streams
+				r.Use(streamTokenMiddleware)
 				// Apply IAM Middleware to all v1 routes
 				r.Use(iamProvider.Middleware())
 				iamProvider.RegisterRoutes(r)
@@ -584,7 +639,8 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 							//tm.OutputChan <- fmt.Sprintf("[DEBUG] Loading plan %s. Steps found: %d", planID, len(currentPlan.Steps))
 							// 1. Build Context obtained from Memory
 							if plannerService.Memory != nil {
-								effectivePrompt = plannerService.Memory.GetContext(planID) + "\nRequest: " + req.Prompt
+								memCtx := plannerService.Memory.GetContext(ctx, planID, req.Prompt, plannerService.Memory.Scope(ctx, planID))
+								effectivePrompt = memCtx.String() + "\nRequest: " + req.Prompt
 							} else {
 								// Legacy simplistic history fallback (optional, but Memory should be available)
 								// ... keeping logic simple, relying on Memory primarily
@@ -620,9 +676,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						}
 
 						// Update Usage
-						currentPlan.TotalUsage.PromptTokens += usage.PromptTokens
-						currentPlan.TotalUsage.CompletionTokens += usage.CompletionTokens
-						currentPlan.TotalUsage.TotalTokens += usage.TotalTokens
+						currentPlan.TotalUsage.Add(usage)
 						currentPlan.CalculateCost()
 						_ = plannerService.Store.SavePlan(currentPlan) // Checkpoint usage immediately
 
@@ -718,9 +772,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 							// MERGE new steps into current plan
 							// Add planner usage to currentPlan TotalUsage
-							currentPlan.TotalUsage.PromptTokens += plannerUsage.PromptTokens
-							currentPlan.TotalUsage.CompletionTokens += plannerUsage.CompletionTokens
-							currentPlan.TotalUsage.TotalTokens += plannerUsage.TotalTokens
+							currentPlan.TotalUsage.Add(plannerUsage)
 
 							// Calculate cost
 							currentPlan.CalculateCost()
@@ -788,6 +840,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 								Status:  "completed",
 								Result:  resultText,
 							})
+							plannerService.Memory.ChargeUsage(&currentPlan)
 							currentPlan.CalculateCost()
 							_ = plannerService.Store.SavePlan(currentPlan)
 						}
@@ -807,11 +860,26 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						http.Error(w, "Invalid Config", http.StatusBadRequest)
 						return
 					}
+					oldCfg := cfgMgr.Get()
+					if newCfg.Audit.HMACKey == "" {
+						// Sanitized configs come back without the key; keep signing the chain with it
+						newCfg.Audit.HMACKey = oldCfg.Audit.HMACKey
+					}
+					if newCfg.Bundle.SigningKey == "" {
+						newCfg.Bundle.SigningKey = oldCfg.Bundle.SigningKey
+					}
 					// Update via manager
 					if err := cfgMgr.Update(newCfg); err != nil {
 						http.Error(w, fmt.Sprintf("Failed to update config: %v", err), http.StatusInternalServerError)
 						return
 					}
+					before, _ := json.Marshal(oldCfg.Sanitize())
+					after, _ := json.Marshal(newCfg.Sanitize())
+					audit.Log(audit.SystemChain, audit.TypeConfigChange, audit.ActorFromContext(r.Context()), 0, "Configuration updated", map[string]interface{}{
+						"before_hash": audit.HashContent(string(before)),
+						"after_hash":  audit.HashContent(string(after)),
+						"config":      string(after),
+					})
 					w.WriteHeader(http.StatusOK)
 				})
 
@@ -948,12 +1016,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 										allowed = true
 									}
 								} else if hasUser {
-									for _, g := range user.Groups {
-										if g == s.AssignedGroup || g == "root" || g == "admin" {
-											allowed = true
-											break
-										}
-									}
+									allowed = canApprove(cfgMgr.Get(), user, s.AssignedGroup)
 								}
 
 								if allowed {
@@ -986,6 +1049,11 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						return
 					}
 					_ = plannerService.Store.LogInteraction(req.PlanID, req.AgentID, req.Action, req.Result)
+					audit.Log(req.PlanID, audit.TypeInteraction, audit.ActorFromContext(r.Context()), 0, fmt.Sprintf("%s: %s", req.AgentID, req.Action), map[string]interface{}{
+						"agent_id":    req.AgentID,
+						"action":      req.Action,
+						"result_hash": audit.HashContent(req.Result),
+					})
 					w.WriteHeader(http.StatusOK)
 				})
 
@@ -1104,9 +1172,27 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 					tm.mu.Unlock()
 
 					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("ETag", planVersionTag(plan))
 					json.NewEncoder(w).Encode(plan)
 				})
 
+				// Live plan events (Server-Sent Events)
+				r.Get("/plans/{id}/events", tm.handlePlanEvents)
+
+				// Plan revision history, diff and rollback
+				r.Get("/plans/{id}/revisions", tm.handleListRevisions)
+				r.Get("/plans/{id}/revisions/diff", tm.handleDiffRevisions)
+				r.Get("/plans/{id}/revisions/{rev}", tm.handleGetRevision)
+				r.Post("/plans/{id}/revisions/{rev}/rollback", tm.handleRollback)
+
+				// Plan bundles for moving plans between environments
+				r.Get("/plans/{id}/export", handlePlanExport(cfgMgr, plannerService.Store))
+				r.Post("/plans/import", handlePlanImport(cfgMgr, plannerService.Store))
+
+				// Audit trail (admin, root and auditor groups)
+				r.Get("/audit", handleAuditQuery(cfgMgr))
+				r.Get("/audit/{plan_id}/verify", handleAuditVerify(cfgMgr))
+
 				// --- File Management for UI ---
 				r.Get("/plans/{id}/files", func(w http.ResponseWriter, r *http.Request) {
 					id := chi.URLParam(r, "id")
@@ -1173,6 +1259,9 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						return
 					}
 
+					if content, err := os.ReadFile(absPath); err == nil {
+						w.Header().Set("ETag", fileVersionTag(content))
+					}
 					http.ServeFile(w, r, absPath)
 				})
 
@@ -1205,10 +1294,31 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						return
 					}
 
-					if err := os.WriteFile(absPath, content, 0644); err != nil {
+					lock := fileLock(cleanTarget)
+					lock.Lock()
+					previous, readErr := os.ReadFile(absPath)
+					// With If-Match the edit only applies to the version the client loaded
+					if match := ifMatchTag(r); match != "" && (readErr != nil || `"`+match+`"` != fileVersionTag(previous)) {
+						lock.Unlock()
+						http.Error(w, "File was modified concurrently, reload and try again", http.StatusConflict)
+						return
+					}
+					err = os.WriteFile(absPath, content, 0644)
+					lock.Unlock()
+					if err != nil {
 						http.Error(w, "Failed write file", http.StatusInternalServerError)
 						return
 					}
+					edit := map[string]interface{}{
+						"path":         pathParam,
+						"content_hash": audit.HashContent(string(content)),
+						"size":         len(content),
+					}
+					if readErr == nil {
+						edit["previous_hash"] = audit.HashContent(string(previous))
+					}
+					audit.Log(id, audit.TypeFileEdit, audit.ActorFromContext(r.Context()), 0, "Edited "+pathParam, edit)
+					w.Header().Set("ETag", fileVersionTag(content))
 					w.Write([]byte("OK"))
 				})
 
@@ -1234,6 +1344,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						http.Error(w, "Failed to delete plan", http.StatusInternalServerError)
 						return
 					}
+					tm.forgetPlan(id)
 
 					w.WriteHeader(http.StatusOK)
 				})
@@ -1244,22 +1355,34 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						id = "plan-" + id
 					}
 
-					plan, err := plannerService.Store.GetPlan(id)
+					expected, err := ifMatchVersion(r)
 					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					if _, err := plannerService.Store.GetPlan(id); err != nil {
 						http.Error(w, "Plan not found", http.StatusNotFound)
 						return
 					}
 
 					// Update status to running and restart task
-					plan.Status = "running"
-					if err := plannerService.Store.SavePlan(plan); err != nil {
-						http.Error(w, "Failed to update plan", http.StatusInternalServerError)
+					plan, err := tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+						if err := expectVersion(p, expected); err != nil {
+							return err
+						}
+						p.Status = "running"
+						return nil
+					})
+					if err != nil {
+						writeUpdateError(w, err)
 						return
 					}
 
 					// Restart the task
 					tm.StartTask(context.Background(), plan)
 
+					w.Header().Set("ETag", planVersionTag(plan))
 					w.WriteHeader(http.StatusOK)
 				})
 
@@ -1269,25 +1392,40 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						id = "plan-" + id
 					}
 
+					expected, err := ifMatchVersion(r)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
 					// Stop the task via TaskManager but mark as finished/completed per user request
 					tm.FinishTask(id)
 
 					// Force update plan status to cancelled.
 					// Note: Finishtask runs async cancellation which also updates the plan.
 					// But we update here specifically to handle cases where the task wasn't running in memory.
-					plan, err := plannerService.Store.GetPlan(id)
-					if err == nil {
-						plan.Status = "cancelled"
-						// Also mark pending steps as cancelled here to handle non-running tasks
-						// or ensure consistency if TaskManager didn't catch it.
-						for i := range plan.Steps {
-							s := &plan.Steps[i]
-							if s.Status == "pending" || s.Status == "running" || s.Status == "waiting_input" {
-								s.Status = "cancelled"
-								s.Result = "Cancelled by user"
+					if _, err := plannerService.Store.GetPlan(id); err == nil {
+						plan, err := tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+							if err := expectVersion(p, expected); err != nil {
+								return err
+							}
+							p.Status = "cancelled"
+							// Also mark pending steps as cancelled here to handle non-running tasks
+							// or ensure consistency if TaskManager didn't catch it.
+							for i := range p.Steps {
+								s := &p.Steps[i]
+								if s.Status == "pending" || s.Status == "queued" || s.Status == "running" || s.Status == "waiting_input" {
+									s.Status = "cancelled"
+									s.Result = "Cancelled by user"
+								}
 							}
+							return nil
+						})
+						if err != nil {
+							writeUpdateError(w, err)
+							return
 						}
-						_ = plannerService.Store.SavePlan(plan)
+						w.Header().Set("ETag", planVersionTag(plan))
 					}
 
 					w.WriteHeader(http.StatusOK)
@@ -1297,52 +1435,70 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 				r.Post("/plans/{id}/groups/{group}", func(w http.ResponseWriter, r *http.Request) {
 					id := chi.URLParam(r, "id")
 					group := chi.URLParam(r, "group")
-
-					plan, err := plannerService.Store.GetPlan(id)
+					expected, err := ifMatchVersion(r)
 					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					if _, err := plannerService.Store.GetPlan(id); err != nil {
 						http.Error(w, "Plan not found", http.StatusNotFound)
 						return
 					}
 
-					// Check duplication
-					exists := false
-					for _, g := range plan.AllowedGroups {
-						if g == group {
-							exists = true
-							break
+					plan, err := tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+						if err := expectVersion(p, expected); err != nil {
+							return err
 						}
-					}
-					if !exists {
-						plan.AllowedGroups = append(plan.AllowedGroups, group)
-						if err := plannerService.Store.SavePlan(plan); err != nil {
-							http.Error(w, "Failed to update plan", http.StatusInternalServerError)
-							return
+						// Check duplication
+						for _, g := range p.AllowedGroups {
+							if g == group {
+								return nil
+							}
 						}
+						p.AllowedGroups = append(p.AllowedGroups, group)
+						return nil
+					})
+					if err != nil {
+						writeUpdateError(w, err)
+						return
 					}
+					w.Header().Set("ETag", planVersionTag(plan))
 					w.WriteHeader(http.StatusOK)
 				})
 
 				r.Delete("/plans/{id}/groups/{group}", func(w http.ResponseWriter, r *http.Request) {
 					id := chi.URLParam(r, "id")
 					group := chi.URLParam(r, "group")
-
-					plan, err := plannerService.Store.GetPlan(id)
+					expected, err := ifMatchVersion(r)
 					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					if _, err := plannerService.Store.GetPlan(id); err != nil {
 						http.Error(w, "Plan not found", http.StatusNotFound)
 						return
 					}
 
-					newGroups := []string{}
-					for _, g := range plan.AllowedGroups {
-						if g != group {
-							newGroups = append(newGroups, g)
+					plan, err := tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+						if err := expectVersion(p, expected); err != nil {
+							return err
 						}
-					}
-					plan.AllowedGroups = newGroups
-					if err := plannerService.Store.SavePlan(plan); err != nil {
-						http.Error(w, "Failed to update plan", http.StatusInternalServerError)
+						newGroups := []string{}
+						for _, g := range p.AllowedGroups {
+							if g != group {
+								newGroups = append(newGroups, g)
+							}
+						}
+						p.AllowedGroups = newGroups
+						return nil
+					})
+					if err != nil {
+						writeUpdateError(w, err)
 						return
 					}
+					w.Header().Set("ETag", planVersionTag(plan))
 					w.WriteHeader(http.StatusOK)
 				})
 
@@ -1448,21 +1604,20 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 					// Update Plan in Store
 					tm.mu.Lock()
-					plan, err := plannerService.Store.GetPlan(id)
-					if err == nil {
-						exists := false
-						for _, f := range plan.Files {
+					_, err = tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+						for _, f := range p.Files {
 							if f == filename {
-								exists = true
-								break
+								return nil
 							}
 						}
-						if !exists {
-							plan.Files = append(plan.Files, filename)
-							_ = plannerService.Store.SavePlan(plan)
-						}
-					}
+						p.Files = append(p.Files, filename)
+						return nil
+					})
 					tm.mu.Unlock()
+					if errors.Is(err, store.ErrConflict) {
+						writeUpdateError(w, err)
+						return
+					}
 
 					_ = plannerService.Store.LogInteraction(id, "System", "File Upload", fmt.Sprintf("Uploaded file: %s", filename))
 
@@ -1545,6 +1700,37 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 						http.Error(w, "Invalid request", http.StatusBadRequest)
 						return
 					}
+					expected, err := ifMatchVersion(r)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					// Steps held by the policy gate can only be answered by their approval group;
+					// the answer goes to the task with the signed-in user as approver
+					var decision *stepDecision
+					if plan, err := plannerService.Store.GetPlan(id); err == nil && req.Input != "/stop" {
+						for _, s := range plan.Steps {
+							if s.Status != "requires_approval" || s.AssignedGroup == "" {
+								continue
+							}
+							user, _ := iam.GetUserFromContext(r.Context())
+							if !canApprove(cfgMgr.Get(), user, s.AssignedGroup) {
+								http.Error(w, fmt.Sprintf("Forbidden: step %d requires approval of %s", s.ID, s.AssignedGroup), http.StatusForbidden)
+								return
+							}
+							if fields := strings.Fields(req.Input); len(fields) > 1 && (fields[0] == "/approve" || fields[0] == "/accept") {
+								http.Error(w, fmt.Sprintf("%s takes no arguments: the approver is the signed-in user", fields[0]), http.StatusBadRequest)
+								return
+							}
+							decision = &stepDecision{User: user, Input: req.Input}
+							break
+						}
+					}
+
+					audit.Log(id, audit.TypeInteraction, audit.ActorFromContext(r.Context()), 0, "User input", map[string]interface{}{
+						"input": req.Input,
+					})
 
 					// Find the task
 					// Note: TaskManager stores tasks by plan ID
@@ -1556,14 +1742,22 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 					if !ok {
 						// Task not active in memory. Try to resume from store if plan exists.
-						plan, err := plannerService.Store.GetPlan(id)
-						if err == nil {
+						if _, err := plannerService.Store.GetPlan(id); err == nil {
 							// Plan exists! Resume it.
 							tm.OutputChan <- fmt.Sprintf("[%s] Resuming inactive plan from store on input received...", id)
 
 							// Force status update to valid running state before starting
-							plan.Status = "running"
-							_ = plannerService.Store.SavePlan(plan)
+							plan, err := tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+								if err := expectVersion(p, expected); err != nil {
+									return err
+								}
+								p.Status = "running"
+								return nil
+							})
+							if err != nil {
+								writeUpdateError(w, err)
+								return
+							}
 
 							// Start the task
 							task = tm.StartTask(context.Background(), plan)
@@ -1574,10 +1768,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 							// Task not started yet - handle stop command directly
 							if req.Input == "/stop" {
-								plan, err := plannerService.Store.GetPlan(id)
-								if err == nil {
-									plan.Status = "stopped"
-									_ = plannerService.Store.SavePlan(plan)
+								if _, err := tm.setPlanStatus(id, "stopped"); err == nil {
 									w.WriteHeader(http.StatusOK)
 									return
 								}
@@ -1588,6 +1779,15 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 					}
 
 					// Send to task (Buffered channel allows immediate return)
+					if decision != nil {
+						select {
+						case task.Decisions <- *decision:
+							w.WriteHeader(http.StatusOK)
+						default:
+							http.Error(w, "Task input buffer full", http.StatusServiceUnavailable)
+						}
+						return
+					}
 					select {
 					case task.InputChan <- req.Input:
 						w.WriteHeader(http.StatusOK)
@@ -1626,10 +1826,12 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 			if port == "" {
 				port = "8080"
 			}
-			fmt.Printf("Providers: Git=[%s], IAM=[%s], LLM=[%s]\n",
+			rootDir, _ := paths.FindProjectRoot()
+			fmt.Printf("Providers: Git=[%s], IAM=[%s], LLM=[%s], Store=[%s]\n",
 				cfg.Git.Provider,
 				cfg.IAM.Provider,
 				cfg.LLM.DefaultProvider,
+				config.LoadStoreConfig(rootDir).Type,
 			)
 			fmt.Printf("Starting server on port %s...\n", port)
 			// Binds to 0.0.0.0 (all interfaces)
@@ -1682,21 +1884,37 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 
 	// runInteractiveLoop Removed
 
+	var loginDevice bool
 	var loginCmd = &cobra.Command{
 		Use:   "login",
-		Short: "Login to local provider",
+		Short: "Login to the configured IAM provider (local or keycloak)",
 		Run: func(cmd *cobra.Command, args []string) {
 			_, _, _, _, _, _, iamProv, err := setup(cmd)
 			if err != nil {
 				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
-			localProv, ok := iamProv.(*iam.LocalProvider)
-			if !ok {
-				fmt.Println("Error: Login only supported for 'local' IAM provider.")
+			localProv, isLocal := iamProv.(*iam.LocalProvider)
+			kcProv, isKeycloak := iamProv.(*iam.KeycloakProvider)
+			if !isLocal && !isKeycloak {
+				fmt.Println("Error: Login only supported for 'local' and 'keycloak' IAM providers.")
 				os.Exit(1)
 			}
 
+			// Browserless login against the identity provider
+			if isKeycloak && loginDevice {
+				tok, u, err := kcProv.DeviceToken(context.Background(), func(verificationURL, userCode string) {
+					fmt.Printf("Open %s in a browser and enter code: %s\n", verificationURL, userCode)
+					fmt.Println("Waiting for confirmation...")
+				})
+				if err != nil {
+					fmt.Printf("Login failed: %v\n", err)
+					os.Exit(1)
+				}
+				saveOIDCLogin(tok.AccessToken, tok.RefreshToken, u)
+				return
+			}
+
 			reader := bufio.NewReader(os.Stdin)
 			fmt.Print("Username: ")
 			user, _ := reader.ReadString('\n')
@@ -1711,6 +1929,16 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 			pass := string(bytePassword)
 			fmt.Println() // Print newline after password input
 
+			if isKeycloak {
+				tok, u, err := kcProv.PasswordToken(context.Background(), user, pass)
+				if err != nil {
+					fmt.Printf("Login failed: %v\n", err)
+					os.Exit(1)
+				}
+				saveOIDCLogin(tok.AccessToken, tok.RefreshToken, u)
+				return
+			}
+
 			token, u, err := localProv.Login(user, pass)
 			if err != nil {
 				fmt.Printf("Login failed: %v\n", err)
@@ -1724,6 +1952,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 			fmt.Printf("Logged in as %s (Groups: %v)\n", u.Username, u.Groups)
 		},
 	}
+	loginCmd.Flags().BoolVar(&loginDevice, "device", false, "Use the device authorization flow (keycloak only)")
 
 	var logoutCmd = &cobra.Command{
 		Use:   "logout",
@@ -1741,6 +1970,11 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 					_ = localProv.Logout(token)
 				}
 			}
+			if kcProv, ok := iamProv.(*iam.KeycloakProvider); ok {
+				if refresh, _ := iam.LoadClientRefreshToken(); refresh != "" {
+					_ = kcProv.RevokeToken(context.Background(), refresh)
+				}
+			}
 
 			_ = iam.ClearClientToken()
 			fmt.Println("Logged out.")
@@ -1783,7 +2017,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 			tm = NewTaskManager(planner, mcpManager, buildEngine)
 
 			fmt.Println("--- Druppie Chat (Async) ---")
-			effectiveProvider := cfg.LLM.DefaultProvider
+			effectiveProvider := cfg.LLM.DefaultProvider.String()
 			if llmProviderOverride != "" {
 				effectiveProvider = llmProviderOverride
 			}
@@ -2301,7 +2535,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 	mcpCmd.AddCommand(mcpDelCmd)
 
 	rootCmd.PersistentFlags().StringVar(&planID, "plan-id", "", "ID of an existing plan to resume or manage")
-	rootCmd.PersistentFlags().StringVar(&llmProviderOverride, "llm-provider", "", "Override default LLM provider")
+	rootCmd.PersistentFlags().StringVar(&llmProviderOverride, "llm-provider", "", "Override default LLM provider (comma separated for a fallback chain)")
 	rootCmd.PersistentFlags().StringVar(&buildProviderOverride, "build-provider", "", "Override default Build provider")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", true, "Enable debug mode (print raw LLM responses)")
 	rootCmd.PersistentFlags().BoolVar(&demo, "demo", false, "Enable demo mode (full admin access, no login)")
@@ -2315,6 +2549,7 @@ Use global flags like --plan-id to resume existing planning tasks or --llm-provi
 	rootCmd.AddCommand(resumeCmd)
 	rootCmd.AddCommand(mcpCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(newWorkerCmd(setup))
 
 	// Default to server mode
 	rootCmd.Run = serveCmd.Run
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/memory"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/paths"
+)
+
+// Documents larger than this are not remembered
+const maxRememberedDocument = 1 << 20
+
+// configureLongTermMemory attaches the vector store selected in the config to the memory manager
+func configureLongTermMemory(mem *memory.Manager, cfg config.LongTermConfig, storeDir string) error {
+	mem.RecallTopK = cfg.TopK
+	mem.MinRelevance = cfg.MinRelevance
+	switch strings.ToLower(cfg.Store) {
+	case "", "embedded", "file":
+		mem.Vectors = memory.NewFileVectorStore(filepath.Join(storeDir, "plans"))
+	case "qdrant":
+		if cfg.URL == "" {
+			return fmt.Errorf("qdrant store requires a url")
+		}
+		mem.Vectors = memory.NewQdrantVectorStore(cfg.URL, cfg.Collection, cfg.APIKey)
+	case "none", "disabled":
+		mem.Vectors = nil
+	default:
+		return fmt.Errorf("unknown long-term memory store: %s", cfg.Store)
+	}
+	return nil
+}
+
+// rememberStep adds the result of a completed step, and the documents it wrote to the
+// plan's files since started, to the long-term memory
+func (tm *TaskManager) rememberStep(task *Task, step model.Step, started time.Time) {
+	mem := tm.planner.Memory
+	if mem == nil || step.Status != "completed" {
+		return
+	}
+	source := fmt.Sprintf("step %d (%s/%s)", step.ID, step.AgentID, step.Action)
+	if result := strings.TrimSpace(step.Result); result != "" {
+		mem.RememberAsync(task.ID, memory.KindStepResult, source, fmt.Sprintf("%s: %s", source, result))
+	}
+
+	filesDir, err := paths.ResolvePath(".druppie", "plans", task.ID, "files")
+	if err != nil {
+		return
+	}
+	_ = filepath.Walk(filesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.ModTime().Before(started) || info.Size() > maxRememberedDocument {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".txt", ".json", ".yaml", ".yml", ".csv", ".html":
+		default:
+			return nil // Binary media and code are not remembered
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(filesDir, path)
+		mem.RememberAsync(task.ID, memory.KindDocument, filepath.ToSlash(rel), fmt.Sprintf("Document %s:\n%s", filepath.ToSlash(rel), data))
+		return nil
+	})
+}
+
+// rememberDecision adds an approval to the long-term memory
+func (tm *TaskManager) rememberDecision(planID string, step model.Step, actor, reason string) {
+	text := fmt.Sprintf("Step %d (%s) by %s was approved by %s", step.ID, step.Action, step.AgentID, actor)
+	if step.AssignedGroup != "" {
+		text += fmt.Sprintf(" for group %s", step.AssignedGroup)
+	}
+	if reason != "" {
+		text += ": " + reason
+	}
+	if step.Result != "" {
+		text += "\n" + step.Result
+	}
+	tm.planner.Memory.RememberAsync(planID, memory.KindDecision, fmt.Sprintf("approval step %d", step.ID), text)
+}
+
+// forgetPlan drops the long-term memory of a deleted plan
+func (tm *TaskManager) forgetPlan(planID string) {
+	if err := tm.planner.Memory.ForgetPlan(context.Background(), planID); err != nil {
+		fmt.Printf("[Memory] Failed to forget plan %s: %v\n", planID, err)
+	}
+}
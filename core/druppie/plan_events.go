@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// Plan event types pushed to live subscribers
+const (
+	PlanEventSnapshot     = "snapshot"      // Current state, sent on connect without Last-Event-ID
+	PlanEventPlanStatus   = "plan_status"   // Plan status changed
+	PlanEventStepStatus   = "step_status"   // Step added or its status changed
+	PlanEventLog          = "log"           // Log line of the plan
+	PlanEventCost         = "cost"          // Total cost or usage changed
+	PlanEventInputRequest = "input_request" // A step waits for user input or approval
+	PlanEventLLMOutput    = "llm_output"    // Partial LLM output of a running step (not replayed)
+)
+
+const (
+	planEventBuffer    = 500 // Events kept per plan for Last-Event-ID resume
+	planEventSubBuffer = 256 // Pending events per subscriber before it is dropped
+)
+
+// PlanEvent is a typed progress event of a plan
+type PlanEvent struct {
+	ID        int64             `json:"id"` // Sequence number within the plan (SSE id)
+	PlanID    string            `json:"plan_id"`
+	Type      string            `json:"type"`
+	Time      time.Time         `json:"time"`
+	StepID    int               `json:"step_id,omitempty"`
+	AgentID   string            `json:"agent_id,omitempty"`
+	Action    string            `json:"action,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	TotalCost float64           `json:"total_cost,omitempty"`
+	Usage     *model.TokenUsage `json:"usage,omitempty"`
+	Plan      interface{}       `json:"plan,omitempty"` // Snapshot only
+}
+
+// planSnapshot is the last state seen of a plan, used to derive change events
+type planSnapshot struct {
+	status string
+	cost   float64
+	tokens int
+	steps  map[int]string // step ID -> status
+}
+
+type planStream struct {
+	nextID int64
+	buffer []PlanEvent
+	subs   map[chan PlanEvent]struct{}
+	last   *planSnapshot
+	done   bool // The task of the plan ended: drop the stream when the last subscriber leaves
+}
+
+// EventHub is a per-plan publish/subscribe hub with a replay buffer.
+// Sequence numbers live in memory; after a restart clients get the buffered history again.
+type EventHub struct {
+	mu    sync.Mutex
+	plans map[string]*planStream
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{plans: make(map[string]*planStream)}
+}
+
+func (h *EventHub) stream(planID string) *planStream {
+	ps, ok := h.plans[planID]
+	if !ok {
+		ps = &planStream{subs: make(map[chan PlanEvent]struct{})}
+		h.plans[planID] = ps
+	}
+	return ps
+}
+
+// Publish assigns the next sequence number and fans the event out to subscribers
+func (h *EventHub) Publish(planID string, ev PlanEvent) {
+	if planID == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publishLocked(h.stream(planID), planID, ev)
+}
+
+// PublishTransient fans the event out to the current subscribers without keeping it for replay.
+// Used for high volume events (streamed tokens) that would push the history out of the buffer.
+func (h *EventHub) PublishTransient(planID string, ev PlanEvent) {
+	if planID == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ps, ok := h.plans[planID]
+	if !ok || len(ps.subs) == 0 {
+		return
+	}
+	ev.ID = ps.nextID // Resuming continues after the last durable event
+	ev.PlanID = planID
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for ch := range ps.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Subscriber is behind: skip the chunk, the full text follows in the log
+		}
+	}
+}
+
+func (h *EventHub) publishLocked(ps *planStream, planID string, ev PlanEvent) {
+	ps.nextID++
+	ev.ID = ps.nextID
+	ev.PlanID = planID
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	ps.buffer = append(ps.buffer, ev)
+	if len(ps.buffer) > planEventBuffer {
+		ps.buffer = ps.buffer[len(ps.buffer)-planEventBuffer:]
+	}
+
+	for ch := range ps.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Slow consumer: disconnect it, it resumes with Last-Event-ID
+			delete(ps.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the buffered events after lastID and a channel for new events.
+// lastID < 0 skips the backlog. The returned cancel func must be called when done.
+func (h *EventHub) Subscribe(planID string, lastID int64) ([]PlanEvent, <-chan PlanEvent, int64, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ps := h.stream(planID)
+	var backlog []PlanEvent
+	if lastID >= 0 {
+		// An ID beyond the sequence means the server restarted: replay everything we have
+		if lastID > ps.nextID {
+			lastID = 0
+		}
+		for _, ev := range ps.buffer {
+			if ev.ID > lastID {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+
+	ch := make(chan PlanEvent, planEventSubBuffer)
+	ps.subs[ch] = struct{}{}
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := ps.subs[ch]; ok {
+			delete(ps.subs, ch)
+			close(ch)
+		}
+		// Streams of finished plans, or of plans that had no events, are not kept
+		if len(ps.subs) == 0 && (ps.done || ps.nextID == 0) && h.plans[planID] == ps {
+			delete(h.plans, planID)
+		}
+	}
+	return backlog, ch, ps.nextID, cancel
+}
+
+// finish drops the stream of a plan whose task ended, or marks it to be dropped when its last
+// subscriber leaves. Clients that resume later get a snapshot instead of the history.
+func (h *EventHub) finish(planID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ps, ok := h.plans[planID]; ok {
+		if len(ps.subs) == 0 {
+			delete(h.plans, planID)
+			return
+		}
+		ps.done = true
+	}
+}
+
+// forget drops the stream of a deleted plan
+func (h *EventHub) forget(planID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ps, ok := h.plans[planID]; ok {
+		for ch := range ps.subs {
+			close(ch)
+		}
+		delete(h.plans, planID)
+	}
+}
+
+func snapshotOf(plan model.ExecutionPlan) *planSnapshot {
+	snap := &planSnapshot{
+		status: plan.Status,
+		cost:   plan.TotalCost,
+		tokens: plan.TotalUsage.TotalTokens,
+		steps:  make(map[int]string, len(plan.Steps)),
+	}
+	for _, s := range plan.Steps {
+		snap.steps[s.ID] = s.Status
+	}
+	return snap
+}
+
+// seen reports whether the hub already tracks the saved state of a plan
+func (h *EventHub) seen(planID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ps, ok := h.plans[planID]
+	return ok && ps.last != nil
+}
+
+// observePlan publishes the differences between the saved plan and the previously seen version.
+// stored is the version that was persisted before, used the first time a plan is seen.
+func (h *EventHub) observePlan(plan model.ExecutionPlan, stored *model.ExecutionPlan) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ps := h.stream(plan.ID)
+	prev := ps.last
+	if prev == nil {
+		prev = &planSnapshot{steps: map[int]string{}}
+		if stored != nil {
+			prev = snapshotOf(*stored)
+		}
+	}
+
+	if plan.Status != prev.status {
+		h.publishLocked(ps, plan.ID, PlanEvent{Type: PlanEventPlanStatus, Status: plan.Status})
+	}
+	for _, s := range plan.Steps {
+		if old, ok := prev.steps[s.ID]; ok && old == s.Status {
+			continue
+		}
+		h.publishLocked(ps, plan.ID, PlanEvent{
+			Type:    PlanEventStepStatus,
+			StepID:  s.ID,
+			AgentID: s.AgentID,
+			Action:  s.Action,
+			Status:  s.Status,
+		})
+		if s.Status == "waiting_input" || s.Status == "requires_approval" {
+			msg := s.Result
+			if s.Error != "" {
+				msg = s.Error
+			}
+			h.publishLocked(ps, plan.ID, PlanEvent{
+				Type:    PlanEventInputRequest,
+				StepID:  s.ID,
+				AgentID: s.AgentID,
+				Action:  s.Action,
+				Status:  s.AssignedGroup,
+				Message: msg,
+			})
+		}
+	}
+	if plan.TotalCost != prev.cost || plan.TotalUsage.TotalTokens != prev.tokens {
+		usage := plan.TotalUsage
+		h.publishLocked(ps, plan.ID, PlanEvent{Type: PlanEventCost, TotalCost: plan.TotalCost, Usage: &usage})
+	}
+	ps.last = snapshotOf(plan)
+}
+
+// eventStore decorates the store so every saved plan feeds the hub, whoever saves it
+type eventStore struct {
+	store.Store
+	hub *EventHub
+}
+
+func (s *eventStore) SavePlan(plan model.ExecutionPlan) error {
+	stored := s.unseen(plan.ID)
+	if err := s.Store.SavePlan(plan); err != nil {
+		return err
+	}
+	s.hub.observePlan(plan, stored)
+	return nil
+}
+
+func (s *eventStore) CompareAndSwapPlan(plan *model.ExecutionPlan) error {
+	stored := s.unseen(plan.ID)
+	if err := s.Store.CompareAndSwapPlan(plan); err != nil {
+		return err
+	}
+	s.hub.observePlan(*plan, stored)
+	return nil
+}
+
+// unseen returns the stored plan on its first save since startup, so the events compare with
+// it instead of reporting every step; nil once the hub has seen the plan
+func (s *eventStore) unseen(planID string) *model.ExecutionPlan {
+	if s.hub.seen(planID) {
+		return nil
+	}
+	if old, err := s.Store.GetPlan(planID); err == nil {
+		return &old
+	}
+	return nil
+}
+
+func (s *eventStore) DeletePlan(id string) error {
+	if err := s.Store.DeletePlan(id); err != nil {
+		return err
+	}
+	s.hub.forget(id)
+	return nil
+}
+
+// handlePlanEvents streams the events of a plan as Server-Sent Events (GET /v1/plans/{id}/events)
+func (tm *TaskManager) handlePlanEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !strings.HasPrefix(id, "plan-") {
+		id = "plan-" + id
+	}
+	plan, err := tm.planner.Store.GetPlan(id)
+	if err != nil {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnect, clients can also pass it explicitly
+	lastID := int64(-1)
+	lastRaw := r.Header.Get("Last-Event-ID")
+	if lastRaw == "" {
+		lastRaw = r.URL.Query().Get("last_event_id")
+	}
+	if lastRaw != "" {
+		if n, err := strconv.ParseInt(lastRaw, 10, 64); err == nil && n >= 0 {
+			lastID = n
+		}
+	}
+
+	backlog, events, currentID, cancel := tm.Events.Subscribe(id, lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	write := func(ev PlanEvent) error {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if lastID < 0 || lastID > currentID {
+		// Fresh client, or the stream was dropped since (finished plan, restart): current
+		// state first, resuming later continues after currentID
+		if err := write(PlanEvent{ID: currentID, PlanID: id, Type: PlanEventSnapshot, Time: time.Now(), Status: plan.Status, TotalCost: plan.TotalCost, Plan: plan}); err != nil {
+			return
+		}
+	}
+	for _, ev := range backlog {
+		if err := write(ev); err != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return // Dropped as slow consumer or plan deleted
+			}
+			if err := write(ev); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// streamTokenMiddleware copies the access_token query parameter into the Authorization header
+// for event stream requests, as browsers cannot set headers on an EventSource
+func streamTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" &&
+			strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// revisionStore returns the revision history of the plan store, if it keeps one
+func (tm *TaskManager) revisionStore() (store.RevisionStore, bool) {
+	s := tm.planner.Store
+	if es, ok := s.(*eventStore); ok {
+		s = es.Store
+	}
+	rs, ok := s.(store.RevisionStore)
+	return rs, ok
+}
+
+// revisionParam parses a revision number from the URL or query ("" when absent)
+func revisionParam(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid revision: %s", raw)
+	}
+	return n, nil
+}
+
+// handleListRevisions returns the revisions of a plan (GET /v1/plans/{id}/revisions)
+func (tm *TaskManager) handleListRevisions(w http.ResponseWriter, r *http.Request) {
+	rs, ok := tm.revisionStore()
+	if !ok {
+		http.Error(w, "Store keeps no plan revisions", http.StatusNotImplemented)
+		return
+	}
+	revisions, err := rs.ListRevisions(normalizePlanID(chi.URLParam(r, "id")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revisions == nil {
+		revisions = []model.PlanRevision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// handleGetRevision returns a revision including the plan (GET /v1/plans/{id}/revisions/{rev})
+func (tm *TaskManager) handleGetRevision(w http.ResponseWriter, r *http.Request) {
+	rs, ok := tm.revisionStore()
+	if !ok {
+		http.Error(w, "Store keeps no plan revisions", http.StatusNotImplemented)
+		return
+	}
+	number, err := revisionParam(chi.URLParam(r, "rev"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rev, err := rs.GetRevision(normalizePlanID(chi.URLParam(r, "id")), number)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// handleDiffRevisions compares two revisions (GET /v1/plans/{id}/revisions/diff?from=<n>&to=<m>).
+// to defaults to the newest revision, from to the one before to.
+func (tm *TaskManager) handleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	rs, ok := tm.revisionStore()
+	if !ok {
+		http.Error(w, "Store keeps no plan revisions", http.StatusNotImplemented)
+		return
+	}
+	id := normalizePlanID(chi.URLParam(r, "id"))
+	from, err := revisionParam(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := revisionParam(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to == 0 {
+		revisions, err := rs.ListRevisions(id)
+		if err != nil || len(revisions) == 0 {
+			http.Error(w, "Plan has no revisions", http.StatusNotFound)
+			return
+		}
+		to = revisions[len(revisions)-1].Number
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	if from <= 0 {
+		http.Error(w, "Nothing to compare with the first revision", http.StatusBadRequest)
+		return
+	}
+
+	before, err := rs.GetRevision(id, from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	after, err := rs.GetRevision(id, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	diff := model.DiffPlans(*before.Plan, *after.Plan)
+	diff.From, diff.To = from, to
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// rollbackStopTimeout bounds the wait for the task of a plan that is rolled back to stop
+const rollbackStopTimeout = 30 * time.Second
+
+// handleRollback restores a plan to an earlier revision and resumes it
+// (POST /v1/plans/{id}/revisions/{rev}/rollback). The running task is stopped first; the
+// restore is a compare-and-swap on the plan it stopped with.
+func (tm *TaskManager) handleRollback(w http.ResponseWriter, r *http.Request) {
+	rs, ok := tm.revisionStore()
+	if !ok {
+		http.Error(w, "Store keeps no plan revisions", http.StatusNotImplemented)
+		return
+	}
+	id := normalizePlanID(chi.URLParam(r, "id"))
+	number, err := revisionParam(chi.URLParam(r, "rev"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rev, err := rs.GetRevision(id, number)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Stop the running task, the restored plan runs in a new one
+	tm.mu.Lock()
+	task, running := tm.tasks[id]
+	if running {
+		task.Cancel()
+		delete(tm.tasks, id)
+	}
+	tm.mu.Unlock()
+	if running {
+		// The loop saves the plan once more on its way out
+		select {
+		case <-task.Done:
+		case <-time.After(rollbackStopTimeout):
+			http.Error(w, "Plan task did not stop, try again", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	current, err := tm.planner.Store.GetPlan(id)
+	if err != nil {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return
+	}
+	// A running task saved the plan while stopping, so If-Match only applies to a stopped plan
+	if !running {
+		if err := expectVersion(&current, expected); err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+	}
+	plan := restoreRevision(current, *rev.Plan)
+	plan.Version = current.Version
+	if err := tm.planner.Store.CompareAndSwapPlan(&plan); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+	diff := model.DiffPlans(current, plan)
+	audit.Log(id, audit.TypeRollback, audit.ActorFromContext(r.Context()), 0, fmt.Sprintf("Rolled back to revision %d", number), map[string]interface{}{
+		"revision":      number,
+		"steps_added":   len(diff.Added),
+		"steps_removed": len(diff.Removed),
+		"steps_changed": len(diff.Changed),
+	})
+	tm.OutputChan <- fmt.Sprintf("[%s] Rolled back to revision %d, resuming...", id, number)
+	tm.StartTask(context.Background(), plan)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// restoreRevision returns the plan of an earlier revision, ready to resume. The current
+// creator and access groups are kept, interrupted steps start again and the usage of the
+// steps that are dropped stays charged to the plan.
+func restoreRevision(current, restored model.ExecutionPlan) model.ExecutionPlan {
+	plan := restored
+	plan.ID = current.ID
+	plan.CreatorID = current.CreatorID
+	plan.AllowedGroups = current.AllowedGroups
+	plan.MemoryUsage = current.MemoryUsage
+	plan.PlanningUsage = current.PlanningUsage
+	plan.LastInteractionTotalCost = current.LastInteractionTotalCost
+
+	plan.Steps = make([]model.Step, len(restored.Steps))
+	copy(plan.Steps, restored.Steps)
+	resetInterruptedSteps(&plan, nil)
+
+	spent, kept := stepUsage(current.Steps), stepUsage(plan.Steps)
+	if spent.EstimatedCost > kept.EstimatedCost || spent.TotalTokens > kept.TotalTokens {
+		plan.PlanningUsage.PromptTokens += spent.PromptTokens - kept.PromptTokens
+		plan.PlanningUsage.CompletionTokens += spent.CompletionTokens - kept.CompletionTokens
+		plan.PlanningUsage.TotalTokens += spent.TotalTokens - kept.TotalTokens
+		plan.PlanningUsage.EstimatedCost += spent.EstimatedCost - kept.EstimatedCost
+	}
+	plan.CalculateCost()
+	plan.Status = "running"
+	return plan
+}
+
+func stepUsage(steps []model.Step) model.TokenUsage {
+	var total model.TokenUsage
+	for _, s := range steps {
+		if s.Usage != nil {
+			total.Add(*s.Usage)
+		}
+	}
+	return total
+}
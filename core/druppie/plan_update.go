@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// updatePlan applies mutate to the stored plan and saves it with compare-and-swap, retrying
+// on conflicts (see store.UpdatePlan). The memory usage since the last save is charged once,
+// however many attempts it takes, and the cost is recalculated.
+func (tm *TaskManager) updatePlan(id string, mutate func(*model.ExecutionPlan) error) (model.ExecutionPlan, error) {
+	mem := tm.planner.Memory
+	var memory model.TokenUsage
+	if mem != nil {
+		memory = mem.TakeUsage(id)
+	}
+	plan, err := store.UpdatePlan(tm.planner.Store, id, func(p *model.ExecutionPlan) error {
+		if err := mutate(p); err != nil {
+			return err
+		}
+		p.ChargeMemory(memory)
+		p.CalculateCost()
+		return nil
+	})
+	if err != nil {
+		mem.ReturnUsage(id, memory)
+	}
+	return plan, err
+}
+
+// saveTaskSteps writes the steps at the given indices of the task's plan into the stored plan,
+// keeping what others changed meanwhile (groups, files), and refreshes the task's copy. The
+// task's step slice is kept, so pointers into it stay valid.
+func (tm *TaskManager) saveTaskSteps(task *Task, indices ...int) error {
+	p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+		for _, idx := range indices {
+			if i := stepIndex(p, task.Plan.Steps[idx]); i != -1 {
+				p.Steps[i] = task.Plan.Steps[idx]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	local := task.Plan.Steps
+	*task.Plan = p
+	if len(local) == len(p.Steps) {
+		copy(local, p.Steps)
+		task.Plan.Steps = local
+	}
+	return nil
+}
+
+// setStepStatus persists the status of a step of the task and updates the task's copy
+func (tm *TaskManager) setStepStatus(task *Task, step *model.Step, status string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+		if k := stepIndex(p, *step); k != -1 {
+			p.Steps[k].Status = status
+		}
+		return nil
+	})
+	step.Status = status
+}
+
+// setPlanStatus persists the status of a plan
+func (tm *TaskManager) setPlanStatus(id, status string) (model.ExecutionPlan, error) {
+	return tm.updatePlan(id, func(p *model.ExecutionPlan) error {
+		p.Status = status
+		return nil
+	})
+}
+
+// errUnchanged aborts an updatePlan whose mutation found nothing to change, so the plan is
+// not saved
+var errUnchanged = errors.New("plan unchanged")
+
+// stepIndex returns the index of the step in the plan, -1 when the plan has none
+func stepIndex(plan *model.ExecutionPlan, step model.Step) int {
+	for i := range plan.Steps {
+		if sameStep(plan.Steps[i], step) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameStep reports whether a and b are the same step of a plan. A replanning step shares its
+// ID with a step next to it, so the ID alone does not tell them apart.
+func sameStep(a, b model.Step) bool {
+	return a.ID == b.ID && (a.Action == "replanning") == (b.Action == "replanning")
+}
+
+// planVersionTag formats the plan version as an ETag
+func planVersionTag(plan model.ExecutionPlan) string {
+	return fmt.Sprintf(`"%d"`, plan.Version)
+}
+
+// fileVersionTag formats the hash of a workspace file as an ETag
+func fileVersionTag(content []byte) string {
+	return `"` + audit.HashContent(string(content)) + `"`
+}
+
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*sync.Mutex) // Workspace file path -> lock
+)
+
+// fileLock returns the lock that serializes the edits of a workspace file, so the If-Match
+// check and the write of one edit are not interleaved with another edit
+func fileLock(path string) *sync.Mutex {
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+	l, ok := fileLocks[path]
+	if !ok {
+		l = &sync.Mutex{}
+		fileLocks[path] = l
+	}
+	return l
+}
+
+// ifMatchTag returns the entity tag of the If-Match header without its quotes and weak
+// prefix, empty without one or for "*"
+func ifMatchTag(r *http.Request) string {
+	raw := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+	if raw == "*" {
+		return ""
+	}
+	return raw
+}
+
+// ifMatchVersion returns the plan version a client expects from its If-Match header, -1 without one
+func ifMatchVersion(r *http.Request) (int64, error) {
+	raw := ifMatchTag(r)
+	if raw == "" {
+		return -1, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("invalid If-Match: %s", r.Header.Get("If-Match"))
+	}
+	return v, nil
+}
+
+// expectVersion fails with store.ErrConflict when the client edits an outdated copy of the plan
+func expectVersion(plan *model.ExecutionPlan, expected int64) error {
+	if expected >= 0 && plan.Version != expected {
+		return store.ErrConflict
+	}
+	return nil
+}
+
+// writeUpdateError answers a failed plan update, 409 Conflict when the plan changed concurrently
+func writeUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Plan was modified concurrently, reload and try again", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Failed to update plan", http.StatusInternalServerError)
+}
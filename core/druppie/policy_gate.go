@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig reads the current config from the store (zero config if unavailable)
+func (tm *TaskManager) loadConfig() config.Config {
+	var cfg config.Config
+	if cfgBytes, err := tm.planner.Store.LoadConfig(); err == nil {
+		_ = yaml.Unmarshal(cfgBytes, &cfg)
+	}
+	return cfg
+}
+
+// approverGroups expands an assigned group through the approval_groups config
+// (e.g. "security" -> ["security", "ciso", "group-admin"])
+func approverGroups(cfg config.Config, group string) []string {
+	groups := []string{group}
+	for _, g := range cfg.ApprovalGroups[strings.ToLower(group)] {
+		if !strings.EqualFold(g, group) {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// canApprove reports whether the user may approve a step assigned to group
+func canApprove(cfg config.Config, user *iam.User, group string) bool {
+	if user == nil {
+		return false
+	}
+	for _, g := range user.Groups {
+		if g == "root" || g == "admin" {
+			return true
+		}
+		for _, allowed := range approverGroups(cfg, group) {
+			if strings.EqualFold(g, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stepDecision is the answer of an authenticated user to a step that requires approval
+type stepDecision struct {
+	User  *iam.User
+	Input string // "/approve" or "/reject [reason]"
+}
+
+// requiredApprovals returns the groups that must approve a step: those of the policy gate, or
+// the group an executor assigned
+func requiredApprovals(step model.Step) []string {
+	if len(step.ApprovalGroups) > 0 {
+		return step.ApprovalGroups
+	}
+	if step.AssignedGroup != "" {
+		return []string{step.AssignedGroup}
+	}
+	return nil
+}
+
+// pendingApprovals returns the required groups that have not approved the step yet
+func pendingApprovals(step model.Step) []string {
+	var pending []string
+	for _, g := range requiredApprovals(step) {
+		if _, ok := step.Approvals[g]; !ok {
+			pending = append(pending, g)
+		}
+	}
+	return pending
+}
+
+// recordApprovals approves the step for every pending group the user may approve for and
+// returns those groups. ApprovedBy lists the approvers so far.
+func recordApprovals(cfg config.Config, step *model.Step, user *iam.User) []string {
+	var approved []string
+	for _, g := range pendingApprovals(*step) {
+		if !canApprove(cfg, user, g) {
+			continue
+		}
+		if step.Approvals == nil {
+			step.Approvals = make(map[string]string)
+		}
+		step.Approvals[g] = user.Username
+		approved = append(approved, g)
+	}
+
+	var approvers []string
+	seen := make(map[string]bool)
+	for _, g := range requiredApprovals(*step) {
+		if u, ok := step.Approvals[g]; ok && !seen[u] {
+			seen[u] = true
+			approvers = append(approvers, u)
+		}
+	}
+	step.ApprovedBy = strings.Join(approvers, ", ")
+	return approved
+}
+
+// approveStep records the approval of a step that requires approval by the user, who must be
+// allowed to approve for the assigned group. The step is released (pending) once every group
+// approved, otherwise the next group is assigned.
+func approveStep(cfg config.Config, step *model.Step, user *iam.User) ([]string, error) {
+	if user == nil {
+		return nil, fmt.Errorf("approval requires a signed-in user")
+	}
+	if !canApprove(cfg, user, step.AssignedGroup) {
+		return nil, fmt.Errorf("user %s may not approve for %s", user.Username, step.AssignedGroup)
+	}
+	approved := recordApprovals(cfg, step, user)
+	if pending := pendingApprovals(*step); len(pending) > 0 {
+		step.AssignedGroup = pending[0]
+	} else {
+		step.Status = "pending"
+	}
+	return approved, nil
+}
+
+// requesterInGroups checks whether the user running the plan (or, without a user, the plan's
+// allowed groups) belongs to one of the groups
+func requesterInGroups(task *Task, groups []string) bool {
+	var member []string
+	if user, ok := iam.GetUserFromContext(task.Ctx); ok {
+		member = user.Groups
+	} else {
+		member = task.Plan.AllowedGroups
+	}
+	for _, g := range member {
+		if g == "root" || g == "admin" {
+			return true
+		}
+		for _, required := range groups {
+			if strings.EqualFold(g, required) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// evaluateStepPolicy collects the findings for a step: the compliance rules of the registry
+// and the auth groups of the agent that executes it
+func (tm *TaskManager) evaluateStepPolicy(task *Task, step model.Step) policy.Decision {
+	var decision policy.Decision
+	reg := tm.planner.Registry
+	if reg == nil {
+		return decision
+	}
+
+	var agent *model.AgentDefinition
+	if a, err := reg.GetAgent(step.AgentID); err == nil {
+		agent = &a
+	}
+
+	decision, err := reg.Policy().Evaluate(task.Ctx, policy.Input(task.Plan, step, agent))
+	if err != nil {
+		// Fail closed: a policy that cannot be evaluated blocks the step
+		decision.Findings = append(decision.Findings, policy.Finding{
+			RuleID:   "policy_engine",
+			RuleName: "Policy Engine",
+			Effect:   policy.EffectDeny,
+			Message:  err.Error(),
+		})
+	}
+
+	// Restricted agents need the approval of their group when the requester is not a member
+	if agent != nil && len(agent.AuthGroups) > 0 && !requesterInGroups(task, agent.AuthGroups) {
+		decision.Findings = append(decision.Findings, policy.Finding{
+			RuleID:   "agent_auth_groups",
+			RuleName: "Agent Access",
+			Effect:   policy.EffectApprove,
+			Group:    agent.AuthGroups[0],
+			Message:  fmt.Sprintf("agent %s is restricted to groups %v", agent.ID, agent.AuthGroups),
+		})
+	}
+	return decision
+}
+
+// policyGate runs before a step is dispatched. It returns false when the step may not run:
+// it is then blocked (waiting_input with the violation as error) or moved to requires_approval
+// with AssignedGroup set to the first group that has not approved yet. The step runs once every
+// group approved. Decisions and the rules that fired are written to the plan log.
+func (tm *TaskManager) policyGate(task *Task, step *model.Step) bool {
+	decision := tm.evaluateStepPolicy(task, *step)
+	if len(decision.Findings) == 0 {
+		return true
+	}
+
+	for _, f := range decision.ByEffect(policy.EffectWarn) {
+		tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Policy warning for step %d (%s): %s", task.ID, step.ID, step.Action, f)
+	}
+
+	if denied := decision.ByEffect(policy.EffectDeny); len(denied) > 0 {
+		msgs := make([]string, len(denied))
+		for i, f := range denied {
+			msgs[i] = f.String()
+			tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Policy BLOCKED step %d (%s) [rule %s]: %s", task.ID, step.ID, step.Action, f.RuleID, f.Message)
+		}
+		step.Status = "waiting_input"
+		step.Error = fmt.Sprintf("blocked by compliance policy: %s", strings.Join(msgs, "; "))
+		step.Result = fmt.Sprintf("Error: %s", step.Error)
+		tm.persistStep(task, *step)
+		auditStep(task, *step)
+		return false
+	}
+
+	groups := decision.ApprovalGroups()
+	if len(groups) == 0 {
+		return true
+	}
+	step.ApprovalGroups = groups
+	if len(pendingApprovals(*step)) == 0 {
+		tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Policy approval for step %d (%s) granted by %s", task.ID, step.ID, step.Action, step.ApprovedBy)
+		return true
+	}
+
+	cfg := tm.loadConfig()
+	approvals := decision.ByEffect(policy.EffectApprove)
+	reasons := make([]string, len(approvals))
+	for i, f := range approvals {
+		reasons[i] = f.String()
+		tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Policy requires approval of %v for step %d (%s) [rule %s]: %s",
+			task.ID, approverGroups(cfg, f.Group), step.ID, step.Action, f.RuleID, f.Message)
+	}
+
+	// Auto-Pilot: the CLI user approves directly when authorized, like audit_request
+	if mode, _ := task.Ctx.Value("mode").(string); mode == "cli-autopilot" {
+		if user, ok := iam.GetUserFromContext(task.Ctx); ok {
+			if approved := recordApprovals(cfg, step, user); len(approved) > 0 {
+				tm.OutputChan <- fmt.Sprintf("[%s] ⚡️ Auto-Approving step %d (User '%s' authorized for %s)", task.ID, step.ID, user.Username, strings.Join(approved, ", "))
+				auditApproval(task.ID, *step, user.Username, "approved", "auto-approved in cli-autopilot: "+strings.Join(reasons, "; "))
+				tm.rememberDecision(task.ID, *step, user.Username, strings.Join(reasons, "; "))
+			}
+			if len(pendingApprovals(*step)) == 0 {
+				tm.persistStep(task, *step)
+				return true
+			}
+		}
+	}
+
+	// Every group approves in turn; AssignedGroup is the one asked now
+	pending := pendingApprovals(*step)
+	step.Status = "requires_approval"
+	step.AssignedGroup = pending[0]
+	step.Result = fmt.Sprintf("Approval required from %s: %s", strings.Join(pending, ", "), strings.Join(reasons, "; "))
+	tm.persistStep(task, *step)
+	auditStep(task, *step)
+	return false
+}
+
+// persistStep writes a single step of the task's plan to the store
+func (tm *TaskManager) persistStep(task *Task, step model.Step) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+		if i := stepIndex(p, step); i != -1 {
+			p.Steps[i] = step
+		}
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// RecoveryResult counts the plans handled by RecoverPlans
+type RecoveryResult struct {
+	Reset   int // Interrupted plans set to pending
+	Resumed int // Running plans started again
+	Waiting int // Plans waiting for input that have a task again
+}
+
+// RecoverPlans reconciles the stored plans with the live tasks after a (re)start. Plans left
+// "running" or "waiting_input" by a previous process have no task anymore: their interrupted
+// steps go back to pending, waiting plans get a task again so input reaches them, and running
+// plans are resumed when autoResume is set or wait as "pending" for a manual resume.
+func (tm *TaskManager) RecoverPlans(autoResume bool) (RecoveryResult, error) {
+	var res RecoveryResult
+	plans, err := tm.planner.Store.ListPlans()
+	if err != nil {
+		return res, err
+	}
+
+	for _, listed := range plans {
+		tm.mu.Lock()
+		_, live := tm.tasks[listed.ID]
+		tm.mu.Unlock()
+		if live {
+			continue
+		}
+
+		// Decided on the stored plan of each attempt, another process may change it meanwhile
+		held := tm.heldSteps(listed)
+		p, err := tm.updatePlan(listed.ID, func(p *model.ExecutionPlan) error {
+			interrupted := resetInterruptedSteps(p, held)
+			switch {
+			case p.Status == "waiting_input", p.Status == "running" && autoResume:
+			case p.Status == "running":
+				p.Status = "pending"
+			case !interrupted:
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("[Recovery] Failed to save plan %s: %v\n", listed.ID, err)
+			continue
+		}
+
+		switch p.Status {
+		case "waiting_input":
+			tm.OutputChan <- fmt.Sprintf("[%s] Server restarted, waiting for input again.", p.ID)
+			tm.StartTask(context.Background(), p)
+			res.Waiting++
+		case "running":
+			tm.OutputChan <- fmt.Sprintf("[%s] Server restarted, resuming plan...", p.ID)
+			tm.StartTask(context.Background(), p)
+			res.Resumed++
+		default:
+			tm.OutputChan <- fmt.Sprintf("[%s] Server restarted, plan interrupted. Resume it to continue.", p.ID)
+			res.Reset++
+		}
+	}
+	return res, nil
+}
+
+// heldSteps returns the running or queued steps of a plan whose job a worker holds a live lease on
+func (tm *TaskManager) heldSteps(plan model.ExecutionPlan) map[int]bool {
+	held := make(map[int]bool)
+	if tm.queue == nil {
+		return held
+	}
+	for _, s := range plan.Steps {
+		if s.Status != "running" && s.Status != "queued" {
+			continue
+		}
+		job, err := tm.queue.FindJob(plan.ID, s.ID)
+		if err == nil && job != nil && job.Status == model.JobLeased && job.LeaseUntil.After(time.Now()) {
+			held[s.ID] = true
+		}
+	}
+	return held
+}
+
+// resetInterruptedSteps sets steps that were running or queued when the process stopped back to
+// pending. Steps held by a worker keep running; the resumed task picks up their job.
+func resetInterruptedSteps(plan *model.ExecutionPlan, held map[int]bool) bool {
+	reset := false
+	for i := range plan.Steps {
+		if held[plan.Steps[i].ID] {
+			continue
+		}
+		if plan.Steps[i].Status == "running" || plan.Steps[i].Status == "queued" {
+			plan.Steps[i].Status = "pending"
+			reset = true
+		}
+	}
+	return reset
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/paths"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func newStoreCmd() *cobra.Command {
+	storeCmd := &cobra.Command{
+		Use:   "store",
+		Short: "Manage the persistence store",
+	}
+
+	var driver, dsn string
+	var overwrite bool
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Import existing .druppie/plans folders into the SQL store",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := migrateStore(driver, dsn, overwrite); err != nil {
+				fmt.Printf("Migration failed: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	migrateCmd.Flags().StringVar(&driver, "driver", "", "SQL driver (defaults to store.driver from config, then sqlite)")
+	migrateCmd.Flags().StringVar(&dsn, "dsn", "", "SQL data source (defaults to store.dsn from config, then .druppie/druppie.db)")
+	migrateCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Replace plans that already exist in the SQL store")
+
+	storeCmd.AddCommand(migrateCmd)
+	return storeCmd
+}
+
+func migrateStore(driver, dsn string, overwrite bool) error {
+	if err := paths.EnsureProjectRoot(); err != nil {
+		return fmt.Errorf("root detection error: %w", err)
+	}
+	rootDir, err := paths.FindProjectRoot()
+	if err != nil {
+		return err
+	}
+	storeDir := filepath.Join(rootDir, ".druppie")
+
+	// Target defaults to the configured SQL store, even when 'file' is still active
+	storeCfg := config.LoadStoreConfig(rootDir)
+	if driver == "" {
+		driver = storeCfg.Driver
+	}
+	if dsn == "" {
+		dsn = storeCfg.DSN
+	}
+
+	src, err := store.NewFileStore(storeDir)
+	if err != nil {
+		return err
+	}
+	dst, err := store.NewSQLStore(storeDir, driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	fmt.Printf("Importing plans from %s...\n", filepath.Join(storeDir, "plans"))
+	res, err := store.ImportFileStore(src, dst, overwrite, func(msg string) {
+		fmt.Println(" - " + msg)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done: %d imported, %d skipped, %d failed.\n", res.Plans, res.Skipped, res.Failed)
+	if storeCfg.Type != "sql" {
+		fmt.Println("Set 'store.type: sql' in config_default.yaml (or STORE_TYPE=sql) to start using the SQL store.")
+	}
+	return nil
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/builder"
 	"github.com/sjhoeksma/druppie/core/internal/config"
 	"github.com/sjhoeksma/druppie/core/internal/executor"
@@ -16,7 +18,9 @@ import (
 	"github.com/sjhoeksma/druppie/core/internal/mcp"
 	"github.com/sjhoeksma/druppie/core/internal/model"
 	"github.com/sjhoeksma/druppie/core/internal/planner"
+	"github.com/sjhoeksma/druppie/core/internal/store"
 	"github.com/sjhoeksma/druppie/core/internal/workflows"
+	"github.com/sjhoeksma/druppie/core/internal/workspace"
 	"gopkg.in/yaml.v2"
 )
 
@@ -32,6 +36,9 @@ const (
 	TaskStatusError        TaskStatus = "Error"
 )
 
+// paramsSchema is the answer of the create_repo self-healing: the fixed step params
+var paramsSchema = map[string]interface{}{"type": "object"}
+
 // TaskManager manages active planning tasks
 type TaskManager struct {
 	mu              sync.Mutex
@@ -42,15 +49,21 @@ type TaskManager struct {
 	dispatcher      *executor.Dispatcher
 	workflowManager *workflows.Manager
 	MCPManager      *mcp.Manager
+	Events          *EventHub          // Live plan events (SSE)
+	workspaces      *workspace.Manager // Git history of the plan workspaces, nil without git
+	queue           store.JobQueue     // Steps are executed by workers when set (see useWorkers)
+	limits          *stepLimits        // Concurrent step limits (general.limits)
 }
 
 type Task struct {
 	ID        string
 	Plan      *model.ExecutionPlan
 	Status    TaskStatus
-	InputChan chan string // Channel to receive user input (answers)
+	InputChan chan string       // Channel to receive user input (answers)
+	Decisions chan stepDecision // Answers of authenticated approvers to a step that requires approval
 	Ctx       context.Context
 	Cancel    context.CancelFunc
+	Done      chan struct{} // Closed when the task loop has returned, after its last save
 }
 
 func NewTaskManager(p *planner.Planner, mcpMgr *mcp.Manager, buildEngine builder.BuildEngine) *TaskManager {
@@ -63,6 +76,17 @@ func NewTaskManager(p *planner.Planner, mcpMgr *mcp.Manager, buildEngine builder
 		dispatcher:      executor.NewDispatcher(buildEngine, mcpMgr, p.GetLLM(), p.Registry),
 		workflowManager: workflows.NewManager(),
 		MCPManager:      mcpMgr,
+		Events:          NewEventHub(),
+		limits:          newStepLimits(),
+	}
+	if workspace.Available() {
+		tm.workspaces = workspace.NewManager()
+	}
+	// Publish plan changes, whichever component saves the plan
+	if es, ok := p.Store.(*eventStore); ok {
+		tm.Events = es.hub
+	} else {
+		p.Store = &eventStore{Store: p.Store, hub: tm.Events}
 	}
 	// TODO: Load persistent MCP servers from config or disk here?
 
@@ -81,14 +105,16 @@ func (tm *TaskManager) StartTask(ctx context.Context, plan model.ExecutionPlan)
 		}
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancel(audit.WithChain(ctx, plan.ID))
 	task := &Task{
 		ID:        plan.ID,
 		Plan:      &plan,
 		Status:    TaskStatusPending,
 		InputChan: make(chan string, 100), // Buffered to allow "type-ahead" or resume-with-input
+		Decisions: make(chan stepDecision, 10),
 		Ctx:       ctx,
 		Cancel:    cancel,
+		Done:      make(chan struct{}),
 	}
 	tm.tasks[plan.ID] = task
 
@@ -153,13 +179,14 @@ func (tm *TaskManager) SubmitInput(ctx context.Context, planID, input string) er
 
 	if !ok {
 		// Try to resume
-		plan, err := tm.planner.Store.GetPlan(planID)
-		if err != nil {
+		if _, err := tm.planner.Store.GetPlan(planID); err != nil {
 			return fmt.Errorf("plan not found: %s", planID)
 		}
 		tm.OutputChan <- fmt.Sprintf("[%s] Resuming plan for scheduled input...", planID)
-		plan.Status = "running"
-		_ = tm.planner.Store.SavePlan(plan)
+		plan, err := tm.setPlanStatus(planID, "running")
+		if err != nil {
+			return fmt.Errorf("failed to resume plan %s: %w", planID, err)
+		}
 
 		task = tm.StartTask(ctx, plan)
 	}
@@ -183,32 +210,40 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 		tm.TaskDoneChan <- task.ID
 		// Remove from active tasks map
 		tm.mu.Lock()
-		delete(tm.tasks, task.ID)
+		if tm.tasks[task.ID] == task {
+			delete(tm.tasks, task.ID)
+		}
 		tm.mu.Unlock()
+		tm.Events.finish(task.ID)
+		close(task.Done)
 	}()
 
 	task.Status = TaskStatusRunning
 
 	// Resurrect 'cancelled', 'skipped', or 'failed' steps to allow resume.
 	tm.mu.Lock()
-	if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-		modified := false
+	var resumed []string
+	if p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+		resumed = nil
 		for i := range p.Steps {
 			s := &p.Steps[i]
 			// Check for states that indicate a stopped/interrupted execution
 			if s.Status == "cancelled" || s.Status == "skipped" || s.Status == "stopped" || s.Status == "failed" || s.Status == "waiting_input" {
-				tm.OutputChan <- fmt.Sprintf("[%s] 🔄 Resuming step %d (%s) - Reset status to pending.", task.ID, s.ID, s.Action)
+				resumed = append(resumed, fmt.Sprintf("[%s] 🔄 Resuming step %d (%s) - Reset status to pending.", task.ID, s.ID, s.Action))
 				s.Status = "pending"
 				s.Result = "" // Clear previous interruptions (but maybe we lose history? acceptable for resume)
 				s.Error = ""
-				modified = true
 			}
 		}
-		if modified {
-			tm.updatePlanCost(&p)
-			_ = tm.planner.Store.SavePlan(p)
-			task.Plan = &p // Update local reference to fresh plan
+		if len(resumed) == 0 {
+			return errUnchanged
 		}
+		return nil
+	}); err == nil {
+		for _, msg := range resumed {
+			tm.OutputChan <- msg
+		}
+		task.Plan = &p // Update local reference to fresh plan
 	}
 	tm.mu.Unlock()
 
@@ -241,19 +276,20 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 				// Clear conflicting Pending steps to allow Workflow to build the plan definitively
 				// (We keep history)
 				tm.mu.Lock()
-				if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+				if p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 					newSteps := []model.Step{}
 					for _, s := range p.Steps {
 						if s.Status == "completed" || s.Status == "success" {
 							newSteps = append(newSteps, s)
 						}
 					}
-					if len(newSteps) != len(p.Steps) {
-						p.Steps = newSteps
-						tm.updatePlanCost(&p)
-						_ = tm.planner.Store.SavePlan(p)
-						task.Plan = &p
+					if len(newSteps) == len(p.Steps) {
+						return errUnchanged
 					}
+					p.Steps = newSteps
+					return nil
+				}); err == nil {
+					task.Plan = &p
 				}
 				tm.mu.Unlock()
 
@@ -270,8 +306,10 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 					}
 				}()
 
+				workflowStream, _ := tm.llmStream(task, 0, currentAgentID, "workflow") // CallLLM ends every call with a newline
+
 				wc := &workflows.WorkflowContext{
-					Ctx:        task.Ctx,
+					Ctx:        tm.dispatcher.AgentContext(task.Ctx, currentAgentID),
 					LLM:        tm.planner.GetLLM(),
 					Dispatcher: tm.dispatcher,
 					Store:      tm.planner.Store,
@@ -281,6 +319,10 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 					},
 					OutputChan: proxyLogChan,
 					InputChan:  task.InputChan,
+					Stream:     workflowStream,
+					AcquireSlots: func(step model.Step) (func(), error) {
+						return tm.acquireSlots(task, step, nil)
+					},
 					UpdateStatus: func(status string) {
 						tm.mu.Lock()
 						defer tm.mu.Unlock()
@@ -304,54 +346,49 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 						default:
 							return
 						}
-						if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+						_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 							p.Status = planStatus
-							tm.updatePlanCost(&p)
-							_ = tm.planner.Store.SavePlan(p)
-						}
+							return nil
+						})
 					},
 					UpdateTokenUsage: func(usage model.TokenUsage) {
 						tm.mu.Lock()
 						defer tm.mu.Unlock()
-						if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-							p.TotalUsage.PromptTokens += usage.PromptTokens
-							p.TotalUsage.CompletionTokens += usage.CompletionTokens
-							p.TotalUsage.TotalTokens += usage.TotalTokens
-							tm.updatePlanCost(&p)
-							_ = tm.planner.Store.SavePlan(p)
-							if task.Plan != nil {
-								task.Plan.TotalUsage = p.TotalUsage
-							}
+						p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+							p.TotalUsage.Add(usage)
+							return nil
+						})
+						if err == nil && task.Plan != nil {
+							task.Plan.TotalUsage = p.TotalUsage
 						}
 					},
 					AppendStep: func(s model.Step) int {
 						tm.mu.Lock()
 						defer tm.mu.Unlock()
-						storedPlan, err := tm.planner.Store.GetPlan(task.ID)
+						stepID := s.ID
+						storedPlan, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+							step := s
+							if step.ID == 0 {
+								step.ID = len(p.Steps) + 1
+							}
+							if step.Status == "" {
+								step.Status = "completed"
+							}
+							stepID = step.ID
+							for i, existing := range p.Steps {
+								if existing.ID == step.ID {
+									p.Steps[i] = step
+									return nil
+								}
+							}
+							p.Steps = append(p.Steps, step)
+							return nil
+						})
 						if err != nil {
 							return 0
 						}
-						if s.ID == 0 {
-							s.ID = len(storedPlan.Steps) + 1
-						}
-						if s.Status == "" {
-							s.Status = "completed"
-						}
-						found := false
-						for i, existing := range storedPlan.Steps {
-							if existing.ID == s.ID {
-								storedPlan.Steps[i] = s
-								found = true
-								break
-							}
-						}
-						if !found {
-							storedPlan.Steps = append(storedPlan.Steps, s)
-						}
-						tm.updatePlanCost(&storedPlan)
-						_ = tm.planner.Store.SavePlan(storedPlan)
 						task.Plan = &storedPlan
-						return s.ID
+						return stepID
 					},
 					FindCompletedStep: func(action string, paramKey string, paramValue interface{}) *model.Step {
 						tm.mu.Lock()
@@ -385,18 +422,10 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 				tm.mu.Lock()
 				if err != nil {
 					task.Status = TaskStatusError
-					if p, e := tm.planner.Store.GetPlan(task.ID); e == nil {
-						p.Status = "stopped"
-						tm.updatePlanCost(&p)
-						_ = tm.planner.Store.SavePlan(p)
-					}
+					_, _ = tm.setPlanStatus(task.ID, "stopped")
 				} else {
 					task.Status = TaskStatusCompleted
-					if p, e := tm.planner.Store.GetPlan(task.ID); e == nil {
-						p.Status = "completed"
-						tm.updatePlanCost(&p)
-						_ = tm.planner.Store.SavePlan(p)
-					}
+					_, _ = tm.setPlanStatus(task.ID, "completed")
 				}
 				tm.mu.Unlock()
 				return true // Handled
@@ -412,6 +441,15 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 	// --------------------------------------------
 	// --------------------------------------------
 
+	// Steps a worker still ran when the previous process stopped are left running by
+	// RecoverPlans; they are dispatched once more, which picks up their job
+	adopt := make(map[int]bool)
+	for _, s := range task.Plan.Steps {
+		if s.Status == "running" || s.Status == "queued" {
+			adopt[s.ID] = true
+		}
+	}
+
 	// Inner loop state
 	// In the original main.go, this loop continuously checks plan.Steps
 	for {
@@ -426,7 +464,7 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 				tm.OutputChan <- fmt.Sprintf("[%s] Task %s by user request.", task.ID, statusStr)
 
 				tm.mu.Lock()
-				if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+				_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 					p.Status = statusStr
 					// Mark active/pending steps as cancelled/completed
 					for i := range p.Steps {
@@ -435,14 +473,13 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 						case "running", "waiting_input":
 							s.Status = "cancelled"
 							s.Result = "Cancelled by user"
-						case "pending":
+						case "pending", "queued":
 							s.Status = "skipped"
 							s.Result = "Skipped due to cancellation"
 						}
 					}
-					tm.updatePlanCost(&p)
-					_ = tm.planner.Store.SavePlan(p)
-				}
+					return nil
+				})
 				tm.mu.Unlock()
 				return
 			}
@@ -451,17 +488,16 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 			task.Status = TaskStatusError
 
 			tm.mu.Lock()
-			if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+			_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 				p.Status = "stopped"
 				// Reset active steps to pending
 				for i := range p.Steps {
-					if p.Steps[i].Status == "running" || p.Steps[i].Status == "waiting_input" {
+					if p.Steps[i].Status == "running" || p.Steps[i].Status == "queued" || p.Steps[i].Status == "waiting_input" {
 						p.Steps[i].Status = "pending"
 					}
 				}
-				tm.updatePlanCost(&p)
-				_ = tm.planner.Store.SavePlan(p)
-			}
+				return nil
+			})
 			tm.mu.Unlock()
 			return
 		default:
@@ -488,12 +524,8 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 			// Set Status Waiting
 			task.Status = TaskStatusWaitingInput
 			tm.mu.Lock()
-			if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-				p.Status = "waiting_input"
-				// No specific step is waiting, just the plan
-				tm.updatePlanCost(&p)
-				_ = tm.planner.Store.SavePlan(p)
-			}
+			// No specific step is waiting, just the plan
+			_, _ = tm.setPlanStatus(task.ID, "waiting_input")
 			tm.mu.Unlock()
 
 			// Wait for input
@@ -507,12 +539,11 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 
 				// Update persistent plan
 				tm.mu.Lock()
-				if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+				_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 					p.LastInteractionTotalCost = task.Plan.TotalCost
 					p.Status = "running"
-					tm.updatePlanCost(&p)
-					_ = tm.planner.Store.SavePlan(p)
-				}
+					return nil
+				})
 				tm.mu.Unlock()
 				task.Status = TaskStatusRunning
 				continue // Restart loop
@@ -524,9 +555,9 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 		var batchIndices []int
 		var activeStep *model.Step
 
-		// 0. Priority: Check for steps already waiting for input (e.g. failed steps or resumed state)
+		// 0. Priority: Check for steps already waiting for input or approval (e.g. failed steps or resumed state)
 		for i := range task.Plan.Steps {
-			if task.Plan.Steps[i].Status == "waiting_input" {
+			if task.Plan.Steps[i].Status == "waiting_input" || task.Plan.Steps[i].Status == "requires_approval" {
 				activeStep = &task.Plan.Steps[i]
 				break
 			}
@@ -557,8 +588,10 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 
 		// Collect all runnable steps
 		for i := range task.Plan.Steps {
-			if task.Plan.Steps[i].Status == "pending" && isReady(task.Plan.Steps[i]) {
+			s := task.Plan.Steps[i]
+			if (s.Status == "pending" || adopt[s.ID] && (s.Status == "running" || s.Status == "queued")) && isReady(s) {
 				batchIndices = append(batchIndices, i)
+				delete(adopt, s.ID)
 			}
 		}
 
@@ -580,12 +613,7 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 
 				// Finalize Plan JSON status
 				tm.mu.Lock()
-				storedPlan, err := tm.planner.Store.GetPlan(task.ID)
-				if err == nil {
-					storedPlan.Status = "completed"
-					tm.updatePlanCost(&storedPlan)
-					_ = tm.planner.Store.SavePlan(storedPlan)
-				}
+				_, _ = tm.setPlanStatus(task.ID, "completed")
 				tm.mu.Unlock()
 
 				return
@@ -596,11 +624,7 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 			task.Status = TaskStatusError
 
 			tm.mu.Lock()
-			if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-				p.Status = "stopped"
-				tm.updatePlanCost(&p)
-				_ = tm.planner.Store.SavePlan(p)
-			}
+			_, _ = tm.setPlanStatus(task.ID, "stopped")
 			tm.mu.Unlock()
 			return
 		}
@@ -610,12 +634,35 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 			return
 		}
 
-		// 4. Process Batch
+		// 4. Policy Gate: every step is checked against the compliance policies before dispatch,
+		// with the parameters it will run with
+		if activeStep == nil {
+			gated := false
+			for _, idx := range batchIndices {
+				step := &task.Plan.Steps[idx]
+				if err := tm.resolveStepParams(task, step); err != nil {
+					step.Status = "waiting_input"
+					step.Error = err.Error()
+					step.Result = fmt.Sprintf("Error: %v", err)
+					tm.persistStep(task, *step)
+					gated = true
+					continue
+				}
+				if !tm.policyGate(task, step) {
+					gated = true
+				}
+			}
+			if gated {
+				// Blocked or approval required: the loop picks the step up as waiting
+				continue
+			}
+		}
+
+		// 5. Process Batch
 		// Check for interactive steps
 		for _, idx := range batchIndices {
 			step := &task.Plan.Steps[idx]
-			isReview := step.Action == "content_review" || step.Action == "draft_scenes" || step.Action == "audit_request" || step.Action == "review_and_governance" || step.Action == "review_governance"
-			if step.Action == "ask_questions" || isReview {
+			if isInteractive(step.Action) {
 				// Stop batching, prioritize this interactive step alone
 				batchIndices = []int{idx}
 				activeStep = step
@@ -636,23 +683,21 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 				go func(i int) {
 					defer execWG.Done()
 					step := &task.Plan.Steps[i]
+					started := time.Now()
 					tm.OutputChan <- fmt.Sprintf("[%s] Executing Step %d: %s (%s)", task.ID, step.ID, step.Action, step.AgentID)
 
-					// Update Status to Running & Persist
-					tm.mu.Lock()
-					if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-						// Find and update step in persistent copy
-						for k := range p.Steps {
-							if p.Steps[k].ID == step.ID {
-								p.Steps[k].Status = "running"
-								break
-							}
-						}
-						tm.updatePlanCost(&p)
-						_ = tm.planner.Store.SavePlan(p)
+					// Wait for a free execution slot (general.limits), the step is "queued" meanwhile
+					release, err := tm.acquireSlots(task, *step, func() {
+						tm.setStepStatus(task, step, "queued")
+					})
+					if err != nil {
+						tm.setStepStatus(task, step, "pending") // Stopped while queued
+						return
 					}
-					step.Status = "running" // Update local copy
-					tm.mu.Unlock()
+					defer release()
+
+					// Update Status to Running & Persist
+					tm.setStepStatus(task, step, "running")
 
 					// Execute Step Logic
 					// Try Executor Dispatcher first
@@ -662,52 +707,18 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 					var logBuffer []string
 					var logMu sync.Mutex
 
-					outputBridge := make(chan string)
-					var resultBuilder strings.Builder
+					outputBridge := make(chan executor.Event)
+					var result executor.StepResult
 					var msgWG sync.WaitGroup
 
 					msgWG.Add(1)
 					go func() {
 						defer msgWG.Done()
-						for msg := range outputBridge {
-							// Check if it's a result or a log
-							if strings.HasPrefix(msg, "RESULT_") {
-								// Result processing remains same
-								parts := strings.SplitN(msg, "=", 2)
-								if len(parts) == 2 {
-									key := strings.TrimPrefix(parts[0], "RESULT_")
-									switch key {
-									case "CONSOLE_OUTPUT":
-										resultBuilder.WriteString(parts[1] + "\n")
-									case "TOKEN_USAGE":
-										// Parse: "prompt,completion,total,[cost]"
-										usageParts := strings.Split(parts[1], ",")
-										if len(usageParts) >= 4 {
-											var prompt, completion, total int
-											var cost float64
-											fmt.Sscanf(usageParts[0], "%d", &prompt)
-											fmt.Sscanf(usageParts[1], "%d", &completion)
-											fmt.Sscanf(usageParts[2], "%d", &total)
-											fmt.Sscanf(usageParts[3], "%f", &cost)
-
-											// Initialize step usage if needed
-											if step.Usage == nil {
-												step.Usage = &model.TokenUsage{}
-											}
-											step.Usage.PromptTokens += prompt
-											step.Usage.CompletionTokens += completion
-											step.Usage.TotalTokens += total
-											step.Usage.EstimatedCost += cost
-										}
-									default:
-										resultBuilder.WriteString(fmt.Sprintf("%s: %s\n", key, parts[1]))
-									}
-								}
-								// Do NOT log result lines to console/logBuffer.
-								// They are internal protocol for result passing.
-							} else {
+						for ev := range outputBridge {
+							// Outputs, artifacts and usage are stored on the step, only log lines go to the console
+							if !result.Apply(ev) {
 								logMu.Lock()
-								logBuffer = append(logBuffer, msg)
+								logBuffer = append(logBuffer, ev.Message)
 								logMu.Unlock()
 							}
 						}
@@ -719,25 +730,22 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 
 						// Mark step as completed
 						tm.mu.Lock()
-						if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+						step.Status = "completed"
+						step.Result = "Plan Completed"
+						_, _ = tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 							// Mark step completed
-							for k := range p.Steps {
-								if p.Steps[k].ID == step.ID {
-									p.Steps[k].Status = "completed"
-									p.Steps[k].Result = "Plan Completed"
-									break
-								}
+							if k := stepIndex(p, *step); k != -1 {
+								p.Steps[k].Status = "completed"
+								p.Steps[k].Result = "Plan Completed"
 							}
 							// Mark plan completed
 							p.Status = "completed"
-							tm.updatePlanCost(&p)
-							_ = tm.planner.Store.SavePlan(p)
-							task.Plan = &p
-						}
+							return nil
+						})
 						task.Status = TaskStatusCompleted
 						tm.mu.Unlock()
 
-						outputBridge <- "RESULT_CONSOLE_Output=Plan Completed"
+						outputBridge <- executor.OutputEvent(executor.OutputConsole, "Plan Completed")
 						close(outputBridge)
 						return
 					}
@@ -748,6 +756,10 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 						exec, err = tm.dispatcher.GetExecutor(step.Action)
 					}
 
+					if err == nil && tm.queueable(*step) {
+						exec = &executor.QueuedExecutor{Queue: tm.queue, PlanID: task.ID}
+					}
+
 					var execErr error
 					if err == nil {
 						// Inject context info for executors (like file reader)
@@ -756,76 +768,48 @@ func (tm *TaskManager) runTaskLoop(task *Task) {
 						}
 						step.Params["plan_id"] = task.ID
 
-						// Parameter Variable Substitution
-						// Resolve ${VAR} using results from previous steps
-						// 1. Collect results map
-						resultsMap := make(map[string]string)
-						tm.mu.Lock()
-						currentP, _ := tm.planner.Store.GetPlan(task.ID)
-						tm.mu.Unlock()
-
-						for _, prevStep := range currentP.Steps {
-							if prevStep.ID < step.ID && prevStep.Status == "completed" {
-								// Parse result string (Key: Value\nKey:Value)
-								lines := strings.Split(prevStep.Result, "\n")
-								for _, line := range lines {
-									parts := strings.SplitN(line, ":", 2)
-									if len(parts) == 2 {
-										k := strings.TrimSpace(parts[0])
-										v := strings.TrimSpace(parts[1])
-										resultsMap[k] = v
-									}
+						// Parameter Variable Substitution; normally done by the policy gate already
+						execErr = tm.resolveStepParams(task, step)
+						if execErr == nil {
+							// Retry / Healing Loop
+							maxRetries := 0
+							if cfgBytes, err := tm.planner.Store.LoadConfig(); err == nil {
+								var cfg config.Config
+								if yaml.Unmarshal(cfgBytes, &cfg) == nil && cfg.LLM.Retries > 0 {
+									maxRetries = cfg.LLM.Retries
 								}
 							}
-						}
-
-						// Inject Context Variables
-						resultsMap["PLAN_ID"] = task.ID
 
-						// Replace in Params
-						for k, v := range step.Params {
-							if strVal, ok := v.(string); ok && strings.Contains(strVal, "${") {
-								for rk, rv := range resultsMap {
-									placeholder := "${" + rk + "}"
-									if strings.Contains(strVal, placeholder) {
-										strVal = strings.ReplaceAll(strVal, placeholder, rv)
+							for attempt := 0; attempt <= maxRetries; attempt++ {
+								// Inject LLM Logger into context
+								logCtx := llm.WithLogger(task.Ctx, func(msg string) {
+									select {
+									case outputBridge <- executor.LogEvent(msg):
+									default:
+										// Non-blocking drop if full, or log to stderr?
+										// Ideally outputBridge has buffer
 									}
+								})
+								stream, flushStream := tm.llmStream(task, step.ID, step.AgentID, step.Action)
+								logCtx = llm.WithStream(tm.dispatcher.AgentContext(logCtx, step.AgentID), stream)
+								if attempt > 0 {
+									// A retry needs a fresh answer, not the cached one that failed
+									logCtx = llm.WithCache(logCtx, false)
 								}
-								step.Params[k] = strVal
-							}
-						}
-						// Retry / Healing Loop
-						maxRetries := 0
-						if cfgBytes, err := tm.planner.Store.LoadConfig(); err == nil {
-							var cfg config.Config
-							if yaml.Unmarshal(cfgBytes, &cfg) == nil && cfg.LLM.Retries > 0 {
-								maxRetries = cfg.LLM.Retries
-							}
-						}
+								execErr = exec.Execute(executor.WithPlan(logCtx, task.Plan), *step, outputBridge)
+								flushStream()
 
-						for attempt := 0; attempt <= maxRetries; attempt++ {
-							// Inject LLM Logger into context
-							logCtx := llm.WithLogger(task.Ctx, func(msg string) {
-								select {
-								case outputBridge <- msg:
-								default:
-									// Non-blocking drop if full, or log to stderr?
-									// Ideally outputBridge has buffer
+								if execErr == nil {
+									break
 								}
-							})
-							execErr = exec.Execute(logCtx, *step, outputBridge)
 
-							if execErr == nil {
-								break
-							}
-
-							// Self-Healing Logic for create_repo
-							if attempt < maxRetries && step.Action == "create_repo" {
-								logMu.Lock()
-								logBuffer = append(logBuffer, fmt.Sprintf("⚠️ Step failed: %v. Attempting Self-Healing (%d/%d)...", execErr, attempt+1, maxRetries))
-								logMu.Unlock()
+								// Self-Healing Logic for create_repo
+								if attempt < maxRetries && step.Action == "create_repo" {
+									logMu.Lock()
+									logBuffer = append(logBuffer, fmt.Sprintf("⚠️ Step failed: %v. Attempting Self-Healing (%d/%d)...", execErr, attempt+1, maxRetries))
+									logMu.Unlock()
 
-								fixPrompt := fmt.Sprintf(`
+									fixPrompt := fmt.Sprintf(`
 The execution of 'create_repo' failed.
 Error: %v
 Current Params: %v
@@ -835,63 +819,50 @@ Return ONLY a valid JSON object representing the FIXED 'params' object.
 Do NOT return YAML or Markdown blocks.
 `, execErr, step.Params)
 
-								fixedJSON, usage, err := tm.planner.GetLLM().Generate(task.Ctx, fixPrompt, "You are a JSON repair agent. Output raw JSON only.")
-								if err != nil {
-									continue
-								}
+									var newParams map[string]interface{}
+									_, usage, err := llm.GenerateStructured(task.Ctx, tm.planner.GetLLM(), fixPrompt, "You are a JSON repair agent. Output raw JSON only.", paramsSchema, &newParams)
+									var structErr *llm.StructuredError
+									if err != nil && !errors.As(err, &structErr) {
+										continue
+									}
 
-								// Update Usage
-								if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-									p.TotalUsage.PromptTokens += usage.PromptTokens
-									p.TotalUsage.CompletionTokens += usage.CompletionTokens
-									p.TotalUsage.TotalTokens += usage.TotalTokens
-
-									// Also attribute usage to the step itself
-									for i := range p.Steps {
-										if p.Steps[i].ID == step.ID {
-											if p.Steps[i].Usage == nil {
-												p.Steps[i].Usage = &model.TokenUsage{}
+									// Update Usage
+									tm.mu.Lock()
+									if p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+										p.TotalUsage.Add(usage)
+
+										// Also attribute usage to the step itself
+										for i := range p.Steps {
+											if sameStep(p.Steps[i], *step) {
+												if p.Steps[i].Usage == nil {
+													p.Steps[i].Usage = &model.TokenUsage{}
+												}
+												p.Steps[i].Usage.Add(usage)
+												break
 											}
-											p.Steps[i].Usage.PromptTokens += usage.PromptTokens
-											p.Steps[i].Usage.CompletionTokens += usage.CompletionTokens
-											p.Steps[i].Usage.TotalTokens += usage.TotalTokens
-											break
 										}
-									}
-
-									tm.updatePlanCost(&p)
-									_ = tm.planner.Store.SavePlan(p)
-									if task.Plan != nil {
-										task.Plan.TotalUsage.PromptTokens += usage.PromptTokens
-										task.Plan.TotalUsage.CompletionTokens += usage.CompletionTokens
-										task.Plan.TotalUsage.TotalTokens += usage.TotalTokens
+										return nil
+									}); err == nil && task.Plan != nil {
+										task.Plan.TotalUsage = p.TotalUsage
 
 										// Update local step reference too
 										if step.Usage == nil {
 											step.Usage = &model.TokenUsage{}
 										}
-										step.Usage.PromptTokens += usage.PromptTokens
-										step.Usage.CompletionTokens += usage.CompletionTokens
-										step.Usage.TotalTokens += usage.TotalTokens
+										step.Usage.Add(usage)
 									}
+									tm.mu.Unlock()
+
+									if err == nil {
+										step.Params = newParams
+										step.Params["plan_id"] = task.ID // Ensure ID persists
+										logMu.Lock()
+										logBuffer = append(logBuffer, "✅ Parameters self-healed. Retrying...")
+										logMu.Unlock()
+									}
+								} else {
+									break
 								}
-
-								// Clean JSON
-								fixedJSON = strings.TrimSpace(fixedJSON)
-								fixedJSON = strings.TrimPrefix(fixedJSON, "```json")
-								fixedJSON = strings.TrimPrefix(fixedJSON, "```")
-								fixedJSON = strings.TrimSuffix(fixedJSON, "```")
-
-								var newParams map[string]interface{}
-								if err := json.Unmarshal([]byte(fixedJSON), &newParams); err == nil {
-									step.Params = newParams
-									step.Params["plan_id"] = task.ID // Ensure ID persists
-									logMu.Lock()
-									logBuffer = append(logBuffer, "✅ Parameters self-healed. Retrying...")
-									logMu.Unlock()
-								}
-							} else {
-								break
 							}
 						}
 					} else {
@@ -900,6 +871,13 @@ Do NOT return YAML or Markdown blocks.
 					close(outputBridge)
 					msgWG.Wait() // Wait for all logs to be processed
 
+					if result.Usage != nil {
+						if step.Usage == nil {
+							step.Usage = &model.TokenUsage{}
+						}
+						step.Usage.Add(*result.Usage)
+					}
+
 					if execErr != nil {
 						logMu.Lock()
 						logBuffer = append(logBuffer, fmt.Sprintf("[%s] Step %d Failed: %v", task.ID, step.ID, execErr))
@@ -919,9 +897,23 @@ Do NOT return YAML or Markdown blocks.
 						step.Error = execErr.Error()
 						step.Result = fmt.Sprintf("Error: %v", execErr)
 						tm.OutputChan <- fmt.Sprintf("[%s] Step %d paused on error. Waiting for user input...", task.ID, step.ID)
+					} else if result.InputRequest != "" {
+						// The executor needs an answer before the step can complete
+						step.Status = "waiting_input"
+						step.Outputs = result.Outputs
+						step.Result = result.InputRequest
+						if result.ApprovalGroup != "" {
+							// Routed by policy: only the assigned group can approve
+							step.Status = "requires_approval"
+							step.AssignedGroup = result.ApprovalGroup
+						}
+						tm.OutputChan <- fmt.Sprintf("[%s] Step %d requires input: %s", task.ID, step.ID, result.InputRequest)
 					} else {
 						step.Status = "completed"
-						if res := resultBuilder.String(); res != "" {
+						if len(result.Outputs) > 0 {
+							step.Outputs = result.Outputs
+						}
+						if res := result.Summary(); res != "" {
 							step.Result = res
 						}
 
@@ -940,12 +932,16 @@ Do NOT return YAML or Markdown blocks.
 							}
 						}
 					}
+					auditStep(task, *step)
+					tm.rememberStep(task, *step, started)
 				}(idx)
 			}
 			execWG.Wait()
 
-			tm.updatePlanCost(task.Plan)
-			_ = tm.planner.Store.SavePlan(*task.Plan)
+			if err := tm.saveTaskSteps(task, batchIndices...); err != nil {
+				tm.OutputChan <- fmt.Sprintf("[%s] Warning: failed to save step results: %v", task.ID, err)
+			}
+			tm.commitWorkspace(task, completedSteps(task.Plan, batchIndices)...)
 
 			// Check for auto-update triggers
 			lastIdx := len(task.Plan.Steps) - 1
@@ -953,7 +949,7 @@ Do NOT return YAML or Markdown blocks.
 			failedAny := false
 
 			for _, idx := range batchIndices {
-				if task.Plan.Steps[idx].Status == "waiting_input" {
+				if task.Plan.Steps[idx].Status == "waiting_input" || task.Plan.Steps[idx].Status == "requires_approval" {
 					failedAny = true
 				}
 				if idx == lastIdx && task.Plan.Steps[idx].Status == "completed" {
@@ -991,7 +987,7 @@ Do NOT return YAML or Markdown blocks.
 
 					// 5. Update the Replanning Step in the New Plan
 					// We need to find it (it should be there)
-					found := false
+					var replanned *model.Step
 					for i := len(updatedPlan.Steps) - 1; i >= 0; i-- {
 						// Look for the most recent replanning step Added by UpdatePlan
 						if updatedPlan.Steps[i].Action == "replanning" && updatedPlan.Steps[i].Status == "completed" {
@@ -1007,25 +1003,39 @@ Do NOT return YAML or Markdown blocks.
 								updatedPlan.PlanningUsage = model.TokenUsage{} // Safety floor
 							}
 
-							found = true
+							replanned = &updatedPlan.Steps[i]
 							break
 						}
 					}
 					// If for some reason UpdatePlan removed it (unlikely), we ignore.
-					if found {
+					if replanned != nil {
 						tm.OutputChan <- fmt.Sprintf("[%s] Replanning complete (Usage: %d tokens)", task.ID, deltaUsage.TotalTokens)
 					}
 
 					task.Plan = updatedPlan
-					tm.updatePlanCost(task.Plan) // Re-save with completed step
-					_ = tm.planner.Store.SavePlan(*task.Plan)
+					// Re-save with completed step
+					if p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+						if replanned != nil {
+							// The replanning step shares its ID with the step before it
+							for k := len(p.Steps) - 1; k >= 0; k-- {
+								if p.Steps[k].ID == replanned.ID && p.Steps[k].Action == "replanning" {
+									p.Steps[k] = *replanned
+									break
+								}
+							}
+							p.PlanningUsage = updatedPlan.PlanningUsage
+						}
+						return nil
+					}); err == nil {
+						task.Plan = &p
+					}
 
 					continue
 				} else {
 					// Mark failed
 					task.Plan.Steps[len(task.Plan.Steps)-1].Status = "failed"
 					task.Plan.Steps[len(task.Plan.Steps)-1].Result = fmt.Sprintf("Error: %v", err)
-					_ = tm.planner.Store.SavePlan(*task.Plan)
+					_ = tm.saveTaskSteps(task, len(task.Plan.Steps)-1)
 
 					tm.OutputChan <- fmt.Sprintf("[%s] Error updating plan: %v", task.ID, err)
 					return
@@ -1156,19 +1166,7 @@ Do NOT return YAML or Markdown blocks.
 
 						// Update params to reflect auto-resolution if needed
 						// Persist
-						tm.mu.Lock()
-						if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-							// Update step in persistent plan
-							for i := range p.Steps {
-								if p.Steps[i].ID == activeStep.ID {
-									p.Steps[i] = *activeStep
-									break
-								}
-							}
-							tm.updatePlanCost(&p)
-							_ = tm.planner.Store.SavePlan(p)
-						}
-						tm.mu.Unlock()
+						tm.persistStep(task, *activeStep)
 
 						// Continue Loop (Skip Waiting Input)
 						continue
@@ -1214,18 +1212,7 @@ Do NOT return YAML or Markdown blocks.
 				activeStep.Result = details.String()
 
 				// Persist
-				tm.mu.Lock()
-				if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-					for i := range p.Steps {
-						if p.Steps[i].ID == activeStep.ID {
-							p.Steps[i] = *activeStep
-							break
-						}
-					}
-					tm.updatePlanCost(&p)
-					_ = tm.planner.Store.SavePlan(p)
-				}
-				tm.mu.Unlock()
+				tm.persistStep(task, *activeStep)
 				continue
 			}
 		}
@@ -1234,28 +1221,44 @@ Do NOT return YAML or Markdown blocks.
 		task.Status = TaskStatusWaitingInput
 
 		tm.mu.Lock()
-		if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
+		stepStatus := "waiting_input"
+		if activeStep.Status == "requires_approval" {
+			stepStatus = "requires_approval"
+		}
+		if _, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
 			p.Status = "waiting_input"
 			// Update active step status
 			for i := range p.Steps {
-				if p.Steps[i].ID == activeStep.ID {
-					p.Steps[i].Status = "waiting_input"
+				if sameStep(p.Steps[i], *activeStep) {
+					p.Steps[i].Status = stepStatus
 					if activeStep.Result != "" {
 						p.Steps[i].Result = activeStep.Result // Persist error message if any
 					}
 					break
 				}
 			}
-			tm.updatePlanCost(&p)
-			_ = tm.planner.Store.SavePlan(p)
+			return nil
+		}); err == nil {
 			// Update local plan pointer
 			task.Plan.Status = "waiting_input"
-			activeStep.Status = "waiting_input"
+			activeStep.Status = stepStatus
 		}
 		tm.mu.Unlock()
 
 		// Send prompt to OutputChan
-		switch activeStep.Action {
+		action := activeStep.Action
+		if activeStep.Status == "requires_approval" {
+			action = "requires_approval"
+		}
+		switch action {
+		case "requires_approval":
+			cfg := tm.loadConfig()
+			approversJSON, _ := json.Marshal(approverGroups(cfg, activeStep.AssignedGroup))
+			tm.OutputChan <- fmt.Sprintf("\n[%s] 🛡️ POLICY APPROVAL REQUIRED for step %d (%s)", task.ID, activeStep.ID, activeStep.Action)
+			tm.OutputChan <- fmt.Sprintf("Reason: %s", activeStep.Result)
+			tm.OutputChan <- fmt.Sprintf("Approvers: %s", string(approversJSON))
+			tm.OutputChan <- "Options: '/approve' | '/reject [reason]'"
+
 		case "ask_questions", "ask-questions":
 			tm.OutputChan <- fmt.Sprintf("[%s] [%s] Input required: %s", task.ID, activeStep.AgentID, activeStep.Action)
 
@@ -1376,137 +1379,241 @@ Do NOT return YAML or Markdown blocks.
 		}
 
 		// WAIT FOR INPUT
+		var answer string
+		var approver *iam.User // Who answers; console input is answered by the user of the task
 		select {
 		case <-task.Ctx.Done():
 			return
-		case answer := <-task.InputChan:
-			// Process Answer
-			task.Status = TaskStatusRunning
+		case d := <-task.Decisions:
+			answer, approver = d.Input, d.User
+		case answer = <-task.InputChan:
+			if user, ok := iam.GetUserFromContext(task.Ctx); ok {
+				approver = user
+			}
+		}
+		// Process Answer
+		task.Status = TaskStatusRunning
 
-			tm.mu.Lock()
-			if p, err := tm.planner.Store.GetPlan(task.ID); err == nil {
-				p.Status = "running"
+		tm.mu.Lock()
+		if p, err := tm.updatePlan(task.ID, func(p *model.ExecutionPlan) error {
+			p.Status = "running"
+			// Update Cost Tracker on Interaction
+			p.LastInteractionTotalCost = p.TotalCost
+			return nil
+		}); err == nil {
+			task.Plan.LastInteractionTotalCost = p.LastInteractionTotalCost
+			task.Plan.Status = "running"
+			tm.OutputChan <- fmt.Sprintf("[%s] Status updated to RUNNING (Input received)", task.ID)
+		}
+		tm.mu.Unlock()
+		// 1. Identify which step to apply input to
+		activeStepIdx := -1
+		// Find index of activeStep in our local plan copy
+		for i := range task.Plan.Steps {
+			if &task.Plan.Steps[i] == activeStep {
+				activeStepIdx = i
+				break
+			}
+		}
 
-				// Update Cost Tracker on Interaction
-				p.LastInteractionTotalCost = p.TotalCost
-				task.Plan.LastInteractionTotalCost = p.TotalCost
+		if activeStepIdx == -1 && len(batchIndices) > 0 {
+			activeStepIdx = batchIndices[0]
+		}
+
+		if activeStepIdx == -1 {
+			tm.OutputChan <- fmt.Sprintf("[%s] Error: No active step to apply input to. Resetting state...", task.ID)
+			// If we are getting here repeatedly with "defaults", we are in an infinite loop.
+			// Stop the task.
+			task.Status = TaskStatusError
+			return
+		}
 
-				tm.updatePlanCost(&p)
-				_ = tm.planner.Store.SavePlan(p)
-				task.Plan.Status = "running"
-				tm.OutputChan <- fmt.Sprintf("[%s] Status updated to RUNNING (Input received)", task.ID)
+		// Policy approval: approve releases the step to the gate, reject fails it, anything
+		// else leaves it held
+		if activeStep.Status == "requires_approval" {
+			fields := strings.Fields(answer)
+			if len(fields) == 0 || (fields[0] != "/approve" && fields[0] != "/accept" && fields[0] != "/reject") {
+				tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Step %d awaits approval, answer with '/approve' or '/reject [reason]'", task.ID, activeStep.ID)
+				continue
 			}
-			tm.mu.Unlock()
-			// 1. Identify which step to apply input to
-			activeStepIdx := -1
-			// Find index of activeStep in our local plan copy
-			for i := range task.Plan.Steps {
-				if &task.Plan.Steps[i] == activeStep {
-					activeStepIdx = i
-					break
+			if fields[0] != "/reject" {
+				step := &task.Plan.Steps[activeStepIdx]
+				if len(fields) > 1 {
+					tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ '%s' takes no arguments: the approver is the signed-in user", task.ID, fields[0])
+					continue
 				}
+				groups, err := approveStep(tm.loadConfig(), step, approver)
+				if err != nil {
+					tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Approval of step %d refused: %v", task.ID, step.ID, err)
+					continue
+				}
+				tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Step %d approved by %s for %s", task.ID, step.ID, approver.Username, strings.Join(groups, ", "))
+				auditApproval(task.ID, *step, approver.Username, "approved", "")
+				tm.rememberDecision(task.ID, *step, approver.Username, "")
+				_ = tm.saveTaskSteps(task, activeStepIdx)
+				continue
 			}
 
-			if activeStepIdx == -1 && len(batchIndices) > 0 {
-				activeStepIdx = batchIndices[0]
-			}
-
-			if activeStepIdx == -1 {
-				tm.OutputChan <- fmt.Sprintf("[%s] Error: No active step to apply input to. Resetting state...", task.ID)
-				// If we are getting here repeatedly with "defaults", we are in an infinite loop.
-				// Stop the task.
-				task.Status = TaskStatusError
-				return
+			rejecter := audit.ActorFromContext(task.Ctx)
+			if approver != nil {
+				rejecter = approver.Username
 			}
+			reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(answer), "/reject"))
+			task.Plan.Steps[activeStepIdx].Status = "rejected"
+			task.Plan.Steps[activeStepIdx].Result = fmt.Sprintf("Rejected by approver: %s", reason)
+			tm.OutputChan <- fmt.Sprintf("[%s] 🛡️ Step %d rejected: %s", task.ID, activeStep.ID, reason)
+			auditApproval(task.ID, task.Plan.Steps[activeStepIdx], rejecter, "rejected", reason)
+			_ = tm.saveTaskSteps(task, activeStepIdx)
+			answer = fmt.Sprintf("Step %d (%s) was rejected by the %s approvers: %s. Adjust the plan to comply.", activeStep.ID, activeStep.Action, activeStep.AssignedGroup, reason)
+		}
 
-			// Logic duplication from original main.go
-			// Apply to plan
-			isAccept := answer == "/accept" || answer == "accept"
+		// Logic duplication from original main.go
+		// Apply to plan
+		isAccept := answer == "/accept" || answer == "accept"
 
-			if activeStep.Action == "ask_questions" && isAccept {
-				// Reconstruct defaults from assumptions
-				var assumptions []interface{}
-				if as, ok := activeStep.Params["assumptions"]; ok {
-					if listAs, isListAs := as.([]interface{}); isListAs {
-						assumptions = listAs
-					}
+		if activeStep.Action == "ask_questions" && isAccept {
+			// Reconstruct defaults from assumptions
+			var assumptions []interface{}
+			if as, ok := activeStep.Params["assumptions"]; ok {
+				if listAs, isListAs := as.([]interface{}); isListAs {
+					assumptions = listAs
 				}
-				var questions []interface{}
-				if qs, ok := activeStep.Params["questions"]; ok {
-					if list, isList := qs.([]interface{}); isList {
-						questions = list
-					} else {
-						questions = []interface{}{qs}
-					}
+			}
+			var questions []interface{}
+			if qs, ok := activeStep.Params["questions"]; ok {
+				if list, isList := qs.([]interface{}); isList {
+					questions = list
+				} else {
+					questions = []interface{}{qs}
 				}
-				var details strings.Builder
-				for i, q := range questions {
-					val := "Accepted Default"
-					if i < len(assumptions) {
-						val = fmt.Sprintf("%v", assumptions[i])
-					}
-					details.WriteString(fmt.Sprintf("%v: %v\n", q, val))
+			}
+			var details strings.Builder
+			for i, q := range questions {
+				val := "Accepted Default"
+				if i < len(assumptions) {
+					val = fmt.Sprintf("%v", assumptions[i])
 				}
-				answer = details.String()
+				details.WriteString(fmt.Sprintf("%v: %v\n", q, val))
 			}
+			answer = details.String()
+		}
 
-			if isAccept {
-				task.Plan.Steps[activeStepIdx].Status = "completed"
-				task.Plan.Steps[activeStepIdx].Result = answer
-				tm.updatePlanCost(task.Plan)
-				_ = tm.planner.Store.SavePlan(*task.Plan)
+		if isAccept {
+			task.Plan.Steps[activeStepIdx].Status = "completed"
+			task.Plan.Steps[activeStepIdx].Result = answer
+			_ = tm.saveTaskSteps(task, activeStepIdx)
+			tm.commitWorkspace(task, task.Plan.Steps[activeStepIdx])
 
-				if activeStepIdx == len(task.Plan.Steps)-1 {
-					tm.OutputChan <- fmt.Sprintf("[%s] Determining next steps...", task.ID)
-					// Use the actual answer (details) instead of a hardcoded string
-					updatedPlan, err := tm.planner.UpdatePlan(task.Ctx, task.Plan, answer)
-					if err == nil {
-						task.Plan = updatedPlan
-					}
+			if activeStepIdx == len(task.Plan.Steps)-1 {
+				tm.OutputChan <- fmt.Sprintf("[%s] Determining next steps...", task.ID)
+				// Use the actual answer (details) instead of a hardcoded string
+				updatedPlan, err := tm.planner.UpdatePlan(task.Ctx, task.Plan, answer)
+				if err == nil {
+					task.Plan = updatedPlan
 				}
-				continue
 			}
+			continue
+		}
 
-			// Feedback / Rejection
-			// If this was a review step, mark it as rejected so it's hidden from Kanban
-			// and cleaner in history.
-			if activeStep.Action == "content_review" || activeStep.Action == "draft_scenes" || strings.Contains(strings.ToLower(activeStep.Action), "review") {
-				task.Plan.Steps[activeStepIdx].Status = "rejected"
-				task.Plan.Steps[activeStepIdx].Result = fmt.Sprintf("Rejected by user: %s", answer)
-				tm.updatePlanCost(task.Plan)
-				_ = tm.planner.Store.SavePlan(*task.Plan)
+		// Feedback / Rejection
+		// If this was a review step, mark it as rejected so it's hidden from Kanban
+		// and cleaner in history.
+		if activeStep.Action == "content_review" || activeStep.Action == "draft_scenes" || strings.Contains(strings.ToLower(activeStep.Action), "review") {
+			task.Plan.Steps[activeStepIdx].Status = "rejected"
+			task.Plan.Steps[activeStepIdx].Result = fmt.Sprintf("Rejected by user: %s", answer)
+			_ = tm.saveTaskSteps(task, activeStepIdx)
+		}
+
+		// Standard update
+
+		// Detect if we are in a failed state and user wants to retry
+		isFailedStep := activeStep.Status == "waiting_input" && activeStep.Error != ""
+		if isFailedStep {
+			lowerAnswer := strings.ToLower(answer)
+			if strings.Contains(lowerAnswer, "retry") || strings.Contains(lowerAnswer, "fix") {
+				task.Plan.Steps[activeStepIdx].Status = "pending"
+				task.Plan.Steps[activeStepIdx].Error = ""
+				// Append hint to params? For now just retry.
+				tm.OutputChan <- fmt.Sprintf("[%s] Resetting step %d to PENDING via User Action.", task.ID, activeStep.ID)
+				_ = tm.saveTaskSteps(task, activeStepIdx)
+
+				// If "fix", we might want to try to use the input as params?
+				// But relying on "UpdatePlan" for a single step retry is hard.
+				// We will assume the user fixed the environment or config and wants a plain retry.
+				continue
 			}
+		}
 
-			// Standard update
-
-			// Detect if we are in a failed state and user wants to retry
-			isFailedStep := activeStep.Status == "waiting_input" && activeStep.Error != ""
-			if isFailedStep {
-				lowerAnswer := strings.ToLower(answer)
-				if strings.Contains(lowerAnswer, "retry") || strings.Contains(lowerAnswer, "fix") {
-					task.Plan.Steps[activeStepIdx].Status = "pending"
-					task.Plan.Steps[activeStepIdx].Error = ""
-					// Append hint to params? For now just retry.
-					tm.OutputChan <- fmt.Sprintf("[%s] Resetting step %d to PENDING via User Action.", task.ID, activeStep.ID)
-					tm.updatePlanCost(task.Plan)
-					_ = tm.planner.Store.SavePlan(*task.Plan)
-
-					// If "fix", we might want to try to use the input as params?
-					// But relying on "UpdatePlan" for a single step retry is hard.
-					// We will assume the user fixed the environment or config and wants a plain retry.
-					continue
+		tm.OutputChan <- fmt.Sprintf("[%s] [Planner] Processing feedback/input...", task.ID)
+		updatedPlan, err := tm.planner.UpdatePlan(task.Ctx, task.Plan, answer)
+		if err != nil {
+			tm.OutputChan <- fmt.Sprintf("[%s] Error updating: %v", task.ID, err)
+		} else {
+			task.Plan = updatedPlan
+		}
+	}
+}
+
+// resolveStepParams substitutes the references to earlier steps in the parameters of a step:
+// typed ${step.3.outputs.build_id} references and legacy ${VAR} placeholders
+func (tm *TaskManager) resolveStepParams(task *Task, step *model.Step) error {
+	tm.mu.Lock()
+	currentP, _ := tm.planner.Store.GetPlan(task.ID)
+	tm.mu.Unlock()
+
+	var resolveErr error
+	// 1. Typed references to earlier outputs: ${step.3.outputs.build_id}
+	if resolved, err := model.ResolveStepReferences(step.Params, currentP.Steps); err != nil {
+		resolveErr = fmt.Errorf("failed to resolve parameters: %w", err)
+	} else if params, ok := resolved.(map[string]interface{}); ok {
+		step.Params = params
+	}
+
+	// 2. Legacy ${VAR} placeholders, e.g. ${BUILD_ID} or ${PLAN_ID}
+	resultsMap := make(map[string]string)
+	for _, prevStep := range currentP.Steps {
+		if prevStep.ID < step.ID && prevStep.Status == "completed" {
+			// Plans from before typed outputs only have "Key: Value" result lines
+			lines := strings.Split(prevStep.Result, "\n")
+			for _, line := range lines {
+				parts := strings.SplitN(line, ":", 2)
+				if len(parts) == 2 {
+					k := strings.TrimSpace(parts[0])
+					v := strings.TrimSpace(parts[1])
+					resultsMap[k] = v
 				}
 			}
+			for k, v := range prevStep.Outputs {
+				resultsMap[strings.ToUpper(k)] = model.FormatOutputValue(v)
+			}
+		}
+	}
 
-			tm.OutputChan <- fmt.Sprintf("[%s] [Planner] Processing feedback/input...", task.ID)
-			updatedPlan, err := tm.planner.UpdatePlan(task.Ctx, task.Plan, answer)
-			if err != nil {
-				tm.OutputChan <- fmt.Sprintf("[%s] Error updating: %v", task.ID, err)
-			} else {
-				task.Plan = updatedPlan
+	// Inject Context Variables
+	resultsMap["PLAN_ID"] = task.ID
+
+	// Replace in Params
+	for k, v := range step.Params {
+		if strVal, ok := v.(string); ok && strings.Contains(strVal, "${") {
+			for rk, rv := range resultsMap {
+				placeholder := "${" + rk + "}"
+				if strings.Contains(strVal, placeholder) {
+					strVal = strings.ReplaceAll(strVal, placeholder, rv)
+				}
 			}
+			step.Params[k] = strVal
 		}
 	}
+	return resolveErr
+}
+
+// isInteractive reports whether an action asks the user, so it runs alone and in the server
+func isInteractive(action string) bool {
+	switch action {
+	case "ask_questions", "content_review", "draft_scenes", "audit_request", "review_and_governance", "review_governance":
+		return true
+	}
+	return false
 }
 
 func formatStepParams(params map[string]interface{}) string {
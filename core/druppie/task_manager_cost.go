@@ -10,6 +10,9 @@ func (tm *TaskManager) updatePlanCost(plan *model.ExecutionPlan) {
 		return
 	}
 
+	// Charge the memory summarization done for this plan since the last save
+	tm.planner.Memory.ChargeUsage(plan)
+
 	// CalculateCost now aggregates individual step costs
 	plan.CalculateCost()
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm/llmtest"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/planner"
+	"github.com/sjhoeksma/druppie/core/internal/registry"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// script holds the answers the fixtures of testdata/llm were recorded with
+var script = llmtest.Script{
+	"Write a haiku about bicycles": "Wheels hum on the road\nchains click through the morning mist\nthe hill falls behind",
+	"REPLAN":                       `{"steps": [{"step_id": 2, "agent_id": "planner", "action": "complete_plan", "params": {}}]}`,
+}
+
+// quietStore keeps the plan logs out of the .druppie directory of the project
+type quietStore struct {
+	*store.FileStore
+}
+
+func (quietStore) LogInteraction(planID, tag, input, output string) error {
+	return nil
+}
+
+func (quietStore) AppendRawLog(planID, message string) error {
+	return nil
+}
+
+// newTestTaskManager returns a task manager and a function returning its output so far
+func newTestTaskManager(t *testing.T) (*TaskManager, func() string) {
+	s, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.NewRegistry()
+	reg.Agents["planner"] = model.AgentDefinition{
+		ID:           "planner",
+		Type:         "system_agent",
+		Instructions: "You plan the steps for the goal: %goal% (%action%, language %language%).\n%agents%\n%tools%",
+		FinalActions: []string{"complete_plan"},
+	}
+	reg.Agents["writer"] = model.AgentDefinition{ID: "writer", Name: "Writer", Type: "execution_agent", Description: "Writes short texts"}
+
+	p := planner.NewPlanner(llmtest.Replay(t, "testdata/llm", script), reg, quietStore{s}, nil, nil, 3, false)
+	tm := NewTaskManager(p, nil, nil)
+	tm.workspaces = nil // No commits in the repository of the project
+	var mu sync.Mutex
+	var output strings.Builder
+	go func() {
+		for line := range tm.OutputChan {
+			mu.Lock()
+			output.WriteString(line + "\n")
+			mu.Unlock()
+		}
+	}()
+	return tm, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return output.String()
+	}
+}
+
+// The loop runs an agent_task step with tool calling, asks the planner for the next steps
+// and completes the plan with the complete_plan step it gets
+func TestRunTaskLoop(t *testing.T) {
+	tm, _ := newTestTaskManager(t)
+	plan := model.ExecutionPlan{
+		ID:             "plan-1",
+		Intent:         model.Intent{Prompt: "Write a haiku about bicycles", Action: "create_project", Language: "en"},
+		Status:         "running",
+		SelectedAgents: []string{"writer"},
+		Steps: []model.Step{
+			{ID: 1, AgentID: "writer", Action: "agent_task", Params: map[string]interface{}{"task": "Write a haiku about bicycles"}, Status: "pending"},
+		},
+	}
+	if err := tm.planner.Store.SavePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	task := tm.StartTask(context.Background(), plan)
+	select {
+	case <-task.Done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("task loop did not finish")
+	}
+
+	stored, err := tm.planner.Store.GetPlan("plan-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "completed" || task.Status != TaskStatusCompleted {
+		t.Fatalf("expected a completed plan, got %s (task %s): %+v", stored.Status, task.Status, stored.Steps)
+	}
+	var actions []string
+	for _, s := range stored.Steps {
+		actions = append(actions, s.Action)
+		if s.Status != "completed" {
+			t.Errorf("step %d (%s) is %s", s.ID, s.Action, s.Status)
+		}
+	}
+	if got := strings.Join(actions, ","); got != "agent_task,replanning,complete_plan" {
+		t.Fatalf("unexpected steps %s", got)
+	}
+	if !strings.Contains(stored.Steps[0].Result, "Wheels hum on the road") {
+		t.Errorf("expected the answer of the agent, got %q", stored.Steps[0].Result)
+	}
+}
+
+// Only '/reject' rejects a step held for approval, other answers leave it held
+func TestApprovalNeedsExplicitReject(t *testing.T) {
+	tm, output := newTestTaskManager(t)
+	plan := model.ExecutionPlan{
+		ID:             "plan-2",
+		Intent:         model.Intent{Prompt: "Publish the bicycle haiku", Action: "create_project", Language: "en"},
+		Status:         "running",
+		SelectedAgents: []string{"writer"},
+		Steps: []model.Step{
+			{ID: 1, AgentID: "writer", Action: "agent_task", Params: map[string]interface{}{"task": "Publish the haiku"}, Status: "requires_approval", AssignedGroup: "compliance"},
+		},
+	}
+	if err := tm.planner.Store.SavePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	task := tm.StartTask(context.Background(), plan)
+	for _, answer := range []string{"approve", "yes please", "/reject not before the review"} {
+		task.InputChan <- answer
+	}
+	select {
+	case <-task.Done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("task loop did not finish")
+	}
+
+	// The output is buffered, wait for the last line of the plan
+	out := output()
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(out, "Plan marked as complete") && time.Now().Before(deadline); out = output() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := strings.Count(out, "Step 1 awaits approval"); n != 2 {
+		t.Errorf("expected both answers before '/reject' to prompt again, got %d prompts:\n%s", n, out)
+	}
+	if !strings.Contains(out, "Step 1 rejected: not before the review\n") || strings.Count(out, "rejected") != 1 {
+		t.Errorf("expected step 1 rejected once, with the reason of '/reject':\n%s", out)
+	}
+	// The planner replaces the rejected step
+	if stored, err := tm.planner.Store.GetPlan("plan-2"); err != nil || stored.Status != "completed" {
+		t.Errorf("expected the replanned plan to complete, got %+v (%v)", stored, err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/sjhoeksma/druppie/core/internal/builder"
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/executor"
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/mcp"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/planner"
+	"github.com/sjhoeksma/druppie/core/internal/registry"
+	"github.com/sjhoeksma/druppie/core/internal/router"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// setupFunc bootstraps the services shared by the server and the worker
+type setupFunc func(*cobra.Command) (*config.Manager, *registry.Registry, *router.Router, *planner.Planner, *mcp.Manager, builder.BuildEngine, iam.Provider, error)
+
+// jobQueue returns the store as a job queue, if it is one
+func jobQueue(s store.Store) (store.JobQueue, bool) {
+	if es, ok := s.(*eventStore); ok {
+		s = es.Store
+	}
+	q, ok := s.(store.JobQueue)
+	return q, ok
+}
+
+// useWorkers switches step execution to workers as configured. In "queue" mode the steps go
+// to the SQL store and druppie worker processes execute them; "embedded" starts workers in
+// this process on a memory queue. Workflows, interactive steps and complete_plan stay local.
+func (tm *TaskManager) useWorkers(ctx context.Context, cfg config.WorkerConfig) error {
+	switch cfg.Mode {
+	case "", "local":
+		return nil
+	case "queue":
+		q, ok := jobQueue(tm.planner.Store)
+		if !ok {
+			return fmt.Errorf("worker mode queue requires the sql store")
+		}
+		tm.queue = q
+	case "embedded":
+		q := store.NewMemoryQueue()
+		tm.queue = q
+		for i := 1; i <= cfg.Workers(); i++ {
+			w := &executor.Worker{
+				ID:         fmt.Sprintf("embedded-%d", i),
+				Queue:      q,
+				Dispatcher: tm.dispatcher,
+				Plans:      tm.planner.Store,
+				Lease:      cfg.Lease(),
+				Logf: func(format string, args ...interface{}) {
+					tm.OutputChan <- fmt.Sprintf(format, args...)
+				},
+			}
+			go w.Run(ctx)
+		}
+	default:
+		return fmt.Errorf("unknown worker mode: %s", cfg.Mode)
+	}
+	return nil
+}
+
+// queueable reports whether a step goes to the workers: workflows, interactive steps and
+// complete_plan drive the plan and stay in the server
+func (tm *TaskManager) queueable(step model.Step) bool {
+	if tm.queue == nil || step.Action == "complete_plan" || isInteractive(step.Action) {
+		return false
+	}
+	if tm.workflowManager != nil {
+		if _, ok := tm.workflowManager.GetWorkflow(step.AgentID); ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newWorkerCmd(setup setupFunc) *cobra.Command {
+	var id string
+	var concurrency int
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Short: "Execute queued plan steps (workers.mode: queue)",
+		Long: `Leases plan steps queued by the API server in the SQL store, executes them and
+heartbeats the lease while they run. A step whose worker stops goes back on the queue.
+Run as many workers as needed; they share the store and the .druppie/plans workspaces.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgMgr, reg, _, plannerService, mcpManager, buildEngine, _, err := setup(cmd)
+			if err != nil {
+				fmt.Printf("Startup Error: %v\n", err)
+				os.Exit(1)
+			}
+			cfg := cfgMgr.Get()
+			q, ok := jobQueue(plannerService.Store)
+			if !ok {
+				fmt.Println("Error: workers require the sql store (store.type: sql)")
+				os.Exit(1)
+			}
+			if id == "" {
+				host, _ := os.Hostname()
+				id = fmt.Sprintf("%s-%d", host, os.Getpid())
+			}
+			if concurrency <= 0 {
+				concurrency = cfg.Workers.Workers()
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			dispatcher := executor.NewDispatcher(buildEngine, mcpManager, plannerService.GetLLM(), reg)
+			fmt.Printf("Worker %s started with %d slots (lease %s)\n", id, concurrency, cfg.Workers.Lease())
+			var wg sync.WaitGroup
+			for i := 1; i <= concurrency; i++ {
+				w := &executor.Worker{
+					ID:         fmt.Sprintf("%s/%d", id, i),
+					Queue:      q,
+					Dispatcher: dispatcher,
+					Plans:      plannerService.Store,
+					Lease:      cfg.Workers.Lease(),
+					Prepare:    mcpManager.EnsurePlanServer,
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.Run(ctx)
+				}()
+			}
+			wg.Wait()
+			fmt.Printf("Worker %s stopped\n", id)
+		},
+	}
+	cmd.Flags().StringVar(&id, "id", "", "Worker ID (default <hostname>-<pid>)")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0, "Steps executed at the same time (default workers.count)")
+	return cmd
+}
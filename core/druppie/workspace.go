@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/workspace"
+)
+
+// commitWorkspace commits the workspace of the plan after steps completed and, when
+// configured, pushes it to the plan's remote repository in the background
+func (tm *TaskManager) commitWorkspace(task *Task, steps ...model.Step) {
+	if tm.workspaces == nil || len(steps) == 0 {
+		return
+	}
+	cfg := tm.loadConfig().Git
+	if !cfg.CommitPlans {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hash, err := tm.workspaces.Commit(ctx, *task.Plan, workspace.CommitMessage(steps))
+	if err != nil {
+		tm.OutputChan <- fmt.Sprintf("[%s] Warning: failed to commit workspace: %v", task.ID, err)
+		return
+	}
+	if hash == "" {
+		return
+	}
+	tm.OutputChan <- fmt.Sprintf("[%s] Workspace committed (%s)", task.ID, hash)
+
+	if !cfg.Push || cfg.Provider == "" {
+		return
+	}
+	plan := *task.Plan
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := tm.workspaces.Push(ctx, cfg, plan); err != nil {
+			fmt.Printf("[Workspace] Failed to push plan %s: %v\n", plan.ID, err)
+		}
+	}()
+}
+
+// completedSteps returns the steps at the indices that completed
+func completedSteps(plan *model.ExecutionPlan, indices []int) []model.Step {
+	var steps []model.Step
+	for _, idx := range indices {
+		if plan.Steps[idx].Status == "completed" {
+			steps = append(steps, plan.Steps[idx])
+		}
+	}
+	return steps
+}
@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// Record types
+const (
+	TypeLLMCall      = "llm_call"      // Prompt sent to a provider and the hash of its response
+	TypeToolCall     = "tool_call"     // MCP tool invocation
+	TypeStep         = "step"          // Step finished (completed, failed, rejected)
+	TypeApproval     = "approval"      // Approval or rejection of a step, by a human or the policy gate
+	TypeConfigChange = "config_change" // Configuration updated
+	TypeFileEdit     = "file_edit"     // File of a plan workspace edited through the API
+	TypeInteraction  = "interaction"   // Interaction reported by an external client
+	TypeRollback     = "rollback"      // Plan restored to an earlier revision
+	TypeExport       = "export"        // Plan exported as a bundle
+	TypeImport       = "import"        // Plan imported from a bundle
+)
+
+// SystemChain collects the records that do not belong to a plan
+const SystemChain = "system"
+
+// Logger appends records to hash chains. Each record holds the hash of its predecessor, so
+// changing, removing or reordering a record breaks the chain from that point on.
+// With a key the hashes are HMAC-SHA256, so the chain cannot be rebuilt without the key.
+type Logger struct {
+	mu    sync.Mutex
+	store store.AuditStore
+	key   []byte
+	heads map[string]model.AuditRecord // Last record per chain, as far as this logger knows
+}
+
+// maxAppendAttempts bounds the retries of Append when other writers extend the same chain
+const maxAppendAttempts = 8
+
+func NewLogger(s store.AuditStore, key []byte) *Logger {
+	return &Logger{store: s, key: key, heads: make(map[string]model.AuditRecord)}
+}
+
+// Append links the record to the chain, computes its hash and persists it.
+// Chain, Seq, Time, PrevHash and Hash are filled in by the logger. When another writer (a
+// worker process, a second server) extended the chain since its head was read, the head is
+// read again and the record linked to it.
+func (l *Logger) Append(chain string, rec model.AuditRecord) (model.AuditRecord, error) {
+	if chain == "" {
+		chain = SystemChain
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		head, ok := l.heads[chain]
+		if !ok {
+			records, err := l.store.ListAudit(chain)
+			if err != nil {
+				return rec, fmt.Errorf("failed to load audit chain %s: %w", chain, err)
+			}
+			if len(records) > 0 {
+				head = records[len(records)-1]
+			}
+		}
+
+		rec.Chain = chain
+		rec.Seq = head.Seq + 1
+		rec.Time = time.Now().UTC()
+		rec.PrevHash = head.Hash
+		hash, err := l.hash(rec)
+		if err != nil {
+			return rec, err
+		}
+		rec.Hash = hash
+
+		err = l.store.AppendAudit(rec)
+		if err == nil {
+			l.heads[chain] = rec
+			return rec, nil
+		}
+		delete(l.heads, chain) // Stale or unknown: read it again
+		if !errors.Is(err, store.ErrAuditConflict) {
+			return rec, err
+		}
+		if attempt == maxAppendAttempts {
+			return rec, fmt.Errorf("audit chain %s: %w (gave up after %d attempts)", chain, err, attempt)
+		}
+	}
+}
+
+// hash computes the hash of a record over its JSON form without the Hash field
+func (l *Logger) hash(rec model.AuditRecord) (string, error) {
+	rec.Hash = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	if len(l.key) > 0 {
+		mac := hmac.New(sha256.New, l.key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyResult is the outcome of verifying one chain
+type VerifyResult struct {
+	Chain    string `json:"chain"`
+	Records  int    `json:"records"`
+	Valid    bool   `json:"valid"`
+	BrokenAt int64  `json:"broken_at,omitempty"` // Seq of the first invalid record
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes every hash of the chain and checks the links between the records
+func (l *Logger) Verify(chain string) (VerifyResult, error) {
+	res := VerifyResult{Chain: chain}
+	records, err := l.store.ListAudit(chain)
+	if err != nil {
+		// A corrupt line is a broken chain, not an I/O problem
+		if strings.Contains(err.Error(), "corrupt audit record") {
+			res.Records = len(records)
+			res.BrokenAt = int64(len(records) + 1)
+			res.Reason = err.Error()
+			return res, nil
+		}
+		return res, err
+	}
+	res.Records = len(records)
+
+	prev := ""
+	for i, rec := range records {
+		fail := func(reason string) (VerifyResult, error) {
+			res.BrokenAt = rec.Seq
+			if res.BrokenAt == 0 {
+				res.BrokenAt = int64(i + 1)
+			}
+			res.Reason = reason
+			return res, nil
+		}
+		if rec.Chain != chain {
+			return fail(fmt.Sprintf("record belongs to chain %q", rec.Chain))
+		}
+		if rec.Seq != int64(i+1) {
+			return fail(fmt.Sprintf("expected seq %d, found %d (record missing or reordered)", i+1, rec.Seq))
+		}
+		if rec.PrevHash != prev {
+			return fail("previous hash does not match (record missing, inserted or modified before it)")
+		}
+		hash, err := l.hash(rec)
+		if err != nil {
+			return res, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(rec.Hash)) {
+			return fail("hash mismatch (record modified)")
+		}
+		prev = rec.Hash
+	}
+	res.Valid = true
+	return res, nil
+}
+
+// Filter selects records for Query; empty fields match everything
+type Filter struct {
+	Chain string // Plan ID or SystemChain
+	Type  string
+	Actor string
+	Since time.Time
+	Until time.Time
+	Limit int // Most recent records when > 0
+}
+
+// Query returns the records matching the filter, ordered by time
+func (l *Logger) Query(f Filter) ([]model.AuditRecord, error) {
+	chains := []string{f.Chain}
+	if f.Chain == "" {
+		var err error
+		if chains, err = l.store.ListAuditChains(); err != nil {
+			return nil, err
+		}
+	}
+
+	var out []model.AuditRecord
+	for _, chain := range chains {
+		records, err := l.store.ListAudit(chain)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if f.Type != "" && rec.Type != f.Type {
+				continue
+			}
+			if f.Actor != "" && !strings.EqualFold(rec.Actor, f.Actor) {
+				continue
+			}
+			if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+				continue
+			}
+			if !f.Until.IsZero() && rec.Time.After(f.Until) {
+				continue
+			}
+			out = append(out, rec)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out, nil
+}
+
+// Chains lists the chains in the store
+func (l *Logger) Chains() ([]string, error) {
+	return l.store.ListAuditChains()
+}
+
+// HashContent returns the SHA-256 of content, used to reference large payloads (responses, files)
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger *Logger
+)
+
+// SetDefault installs the logger used by Log. Passing nil disables auditing.
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// Default returns the installed logger (nil when auditing is disabled)
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// Log appends a record to the default logger. Failures are reported on stdout, they never
+// interrupt the caller.
+func Log(chain, recType, actor string, stepID int, summary string, data map[string]interface{}) {
+	l := Default()
+	if l == nil {
+		return
+	}
+	if _, err := l.Append(chain, model.AuditRecord{Type: recType, Actor: actor, StepID: stepID, Summary: summary, Data: data}); err != nil {
+		fmt.Printf("[Audit] Failed to record %s for %s: %v\n", recType, chain, err)
+	}
+}
+
+type chainKey struct{}
+
+// WithChain marks the context as belonging to a plan, so LLM and tool calls are audited on its chain
+func WithChain(ctx context.Context, planID string) context.Context {
+	return context.WithValue(ctx, chainKey{}, planID)
+}
+
+// ChainFromContext returns the chain of the context, SystemChain when none is set
+func ChainFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(chainKey{}).(string); ok && id != "" {
+			return id
+		}
+	}
+	return SystemChain
+}
+
+// ActorFromContext returns the user of the context, "" when unknown
+func ActorFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if user, ok := iam.GetUserFromContext(ctx); ok && user != nil {
+			return user.Username
+		}
+	}
+	return ""
+}
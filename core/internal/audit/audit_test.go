@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// Two loggers on one store stand for the server and a worker process: each has its own
+// cached head, so every append after the other one wrote hits a stale head
+func TestAppendFollowsHeadOfOtherWriter(t *testing.T) {
+	s, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewLogger(s, []byte("key"))
+	worker := NewLogger(s, []byte("key"))
+
+	for i := 0; i < 5; i++ {
+		for _, l := range []*Logger{server, worker} {
+			if _, err := l.Append("plan-1", model.AuditRecord{Type: TypeStep, Summary: "step"}); err != nil {
+				t.Fatalf("append %d: %v", i, err)
+			}
+		}
+	}
+
+	res, err := server.Verify("plan-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Records != 10 {
+		t.Fatalf("expected a valid chain of 10 records, got %+v", res)
+	}
+}
+
+func TestAppendAuditRejectsFork(t *testing.T) {
+	s, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLogger(s, nil)
+	first, err := l.Append("plan-1", model.AuditRecord{Type: TypeStep})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append("plan-1", model.AuditRecord{Type: TypeStep}); err != nil {
+		t.Fatal(err)
+	}
+
+	fork := model.AuditRecord{Chain: "plan-1", Seq: 2, PrevHash: first.Hash, Hash: "forged"}
+	if err := s.AppendAudit(fork); err != store.ErrAuditConflict {
+		t.Fatalf("expected ErrAuditConflict for a second record 2, got %v", err)
+	}
+}
@@ -0,0 +1,518 @@
+// Package bundle moves plans between environments as a single signed archive: a tar.gz
+// with the plan, its interaction logs, memory, workspace files, builds manifest and cost summary
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+	"github.com/sjhoeksma/druppie/core/internal/workspace"
+)
+
+// Version of the bundle layout
+const Version = 1
+
+// Signature algorithms of the manifest
+const (
+	AlgorithmHMAC   = "hmac-sha256" // Signed with the configured key
+	AlgorithmSHA256 = "sha256"      // Unsigned, integrity only
+)
+
+// ErrUnsigned is returned by Import for a bundle without signature when unsigned bundles are
+// not allowed
+var ErrUnsigned = errors.New("bundle is not signed: configure bundle.signing_key, or set bundle.allow_unsigned")
+
+// Archive entries; workspace files are stored under workspace/
+const (
+	manifestEntry = "manifest.json"
+	planEntry     = "plan.json"
+	logsEntry     = "logs/execution.log"
+	memoryEntry   = "memory.json"
+	costEntry     = "cost.json"
+	buildsEntry   = "builds/manifest.json"
+	filesPrefix   = "workspace/"
+)
+
+// maxEntrySize bounds a single archive entry on import
+const maxEntrySize = 512 << 20
+
+// Manifest is the first entry of a bundle. It holds the SHA-256 of every other entry and
+// its signature covers the manifest itself.
+type Manifest struct {
+	Version   int               `json:"version"`
+	PlanID    string            `json:"plan_id"`
+	Created   time.Time         `json:"created"`
+	Entries   map[string]string `json:"entries"` // Archive path -> sha256
+	Algorithm string            `json:"algorithm"`
+	Signature string            `json:"signature"`
+}
+
+// digest returns the HMAC-SHA256 (with a key) or SHA-256 of the manifest without its signature
+func (m Manifest) digest(key []byte) string {
+	m.Signature = ""
+	data, _ := json.Marshal(m)
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Manifest) sign(key []byte) {
+	m.Algorithm = AlgorithmSHA256
+	if len(key) > 0 {
+		m.Algorithm = AlgorithmHMAC
+	}
+	m.Signature = m.digest(key)
+}
+
+// verify checks the signature. With a key configured only bundles signed with that key are accepted.
+func (m Manifest) verify(key []byte) error {
+	switch m.Algorithm {
+	case AlgorithmHMAC:
+		if len(key) == 0 {
+			return fmt.Errorf("bundle is signed, configure bundle.signing_key to verify it")
+		}
+	case AlgorithmSHA256:
+		if len(key) > 0 {
+			return fmt.Errorf("bundle is not signed and bundle.signing_key is configured")
+		}
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", m.Algorithm)
+	}
+	if !hmac.Equal([]byte(m.digest(key)), []byte(m.Signature)) {
+		return fmt.Errorf("bundle signature is invalid")
+	}
+	return nil
+}
+
+// CostSummary is the cost breakdown of the plan in cost.json
+type CostSummary struct {
+	TotalCost float64          `json:"total_cost"`
+	Total     model.TokenUsage `json:"total_usage"`
+	Planning  model.TokenUsage `json:"planning_usage"`
+	Memory    model.TokenUsage `json:"memory_usage"`
+	Steps     []StepCost       `json:"steps,omitempty"`
+}
+
+// StepCost is the usage of one step
+type StepCost struct {
+	StepID  int              `json:"step_id"`
+	AgentID string           `json:"agent_id"`
+	Action  string           `json:"action"`
+	Usage   model.TokenUsage `json:"usage"`
+}
+
+// Costs summarizes the usage of a plan
+func Costs(plan model.ExecutionPlan) CostSummary {
+	summary := CostSummary{
+		TotalCost: plan.TotalCost,
+		Planning:  plan.PlanningUsage,
+		Memory:    plan.MemoryUsage,
+	}
+	summary.Total.Add(plan.PlanningUsage)
+	summary.Total.Add(plan.MemoryUsage)
+	for _, s := range plan.Steps {
+		if s.Usage == nil {
+			continue
+		}
+		summary.Total.Add(*s.Usage)
+		summary.Steps = append(summary.Steps, StepCost{StepID: s.ID, AgentID: s.AgentID, Action: s.Action, Usage: *s.Usage})
+	}
+	return summary
+}
+
+// skipped reports whether a workspace path stays out of the bundle: state that is exported
+// through the store, git metadata and build output (covered by the builds manifest)
+func skipped(rel string, isDir bool) bool {
+	switch rel {
+	case "plan.json", workspace.SnapshotFile, "memory.json", "revisions.jsonl", "logs", "builds", "vector_store":
+		return true
+	}
+	name := path.Base(rel)
+	return isDir && (name == ".git" || name == "node_modules")
+}
+
+// entry is an archive entry, held in memory or read from a file when written
+type entry struct {
+	name string
+	data []byte
+	file string
+}
+
+// Export writes the plan with the workspace files in dir as a bundle to w. The manifest is
+// signed with key. Without a key it only carries a SHA-256 digest: that detects a damaged
+// bundle, not a forged one.
+func Export(w io.Writer, s store.Store, planID, dir string, key []byte) (Manifest, error) {
+	plan, err := s.GetPlan(planID)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var entries []entry
+	addJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		entries = append(entries, entry{name: name, data: data})
+		return nil
+	}
+	if err := addJSON(planEntry, plan); err != nil {
+		return Manifest{}, err
+	}
+	if err := addJSON(costEntry, Costs(plan)); err != nil {
+		return Manifest{}, err
+	}
+	if logs, err := s.GetLogs(planID); err == nil && logs != "" {
+		entries = append(entries, entry{name: logsEntry, data: []byte(logs)})
+	}
+	if data, err := s.LoadMemory(planID); err == nil && len(data) > 0 {
+		entries = append(entries, entry{name: memoryEntry, data: data})
+	}
+	if data, err := workspace.BuildsManifest(filepath.Join(dir, "builds")); err != nil {
+		return Manifest{}, fmt.Errorf("failed to read builds: %w", err)
+	} else if data != nil {
+		entries = append(entries, entry{name: buildsEntry, data: data})
+	}
+
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if skipped(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			entries = append(entries, entry{name: filesPrefix + rel, file: p})
+		}
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read workspace: %w", err)
+	}
+
+	manifest := Manifest{
+		Version: Version,
+		PlanID:  plan.ID,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Entries: make(map[string]string, len(entries)),
+	}
+	for _, e := range entries {
+		hash, err := e.hash()
+		if err != nil {
+			return Manifest{}, err
+		}
+		manifest.Entries[e.name] = hash
+	}
+	manifest.sign(key)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := writeEntry(tw, entry{name: manifestEntry, data: data}, manifest.Created); err != nil {
+		return Manifest{}, err
+	}
+	for _, e := range entries {
+		if err := writeEntry(tw, e, manifest.Created); err != nil {
+			return Manifest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, gz.Close()
+}
+
+func (e entry) hash() (string, error) {
+	h := sha256.New()
+	if e.file == "" {
+		h.Write(e.data)
+	} else {
+		f, err := os.Open(e.file)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeEntry(tw *tar.Writer, e entry, modTime time.Time) error {
+	var r io.Reader
+	size := int64(len(e.data))
+	if e.file == "" {
+		r = bytes.NewReader(e.data)
+	} else {
+		f, err := os.Open(e.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		r, size = f, info.Size()
+		modTime = info.ModTime()
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(tw, r, size)
+	return err
+}
+
+// Bundle is a verified bundle read into memory
+type Bundle struct {
+	Manifest Manifest
+	entries  map[string][]byte
+}
+
+// Read reads a bundle and verifies its signature and the hash of every entry
+func Read(r io.Reader, key []byte) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a bundle: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestEntry {
+		return nil, fmt.Errorf("not a bundle: %s missing", manifestEntry)
+	}
+	var b Bundle
+	if err := json.NewDecoder(io.LimitReader(tr, maxEntrySize)).Decode(&b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if b.Manifest.Version > Version {
+		return nil, fmt.Errorf("bundle version %d is newer than supported (%d)", b.Manifest.Version, Version)
+	}
+	if err := b.Manifest.verify(key); err != nil {
+		return nil, err
+	}
+
+	b.entries = make(map[string][]byte, len(b.Manifest.Entries))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		want, ok := b.Manifest.Entries[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("entry %s is not in the manifest", hdr.Name)
+		}
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("entry %s is too large", hdr.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, fmt.Errorf("entry %s does not match its hash", hdr.Name)
+		}
+		b.entries[hdr.Name] = data
+	}
+	for name := range b.Manifest.Entries {
+		if _, ok := b.entries[name]; !ok {
+			return nil, fmt.Errorf("entry %s is missing", name)
+		}
+	}
+	if _, ok := b.entries[planEntry]; !ok {
+		return nil, fmt.Errorf("bundle has no %s", planEntry)
+	}
+	return &b, nil
+}
+
+// Result describes an imported bundle
+type Result struct {
+	PlanID     string `json:"plan_id"`
+	OriginalID string `json:"original_id"`
+	Rekeyed    bool   `json:"rekeyed"` // Imported under a new ID because the original was taken
+	Files      int    `json:"files"`
+}
+
+// ImportOptions controls how a bundle is imported
+type ImportOptions struct {
+	Key           []byte // Signing key, see Read
+	AllowUnsigned bool   // Accept bundles without signature; anyone can forge those
+	CreatorID     string // New owner of the plan, empty keeps the creator of the bundle
+}
+
+// Import reads a bundle and stores its plan, logs and memory in s and its files in the
+// plan workspace under plansDir. A plan whose ID is taken, or is not of the plan-<n> form, is
+// imported under a new ID.
+// Plans that were running are imported as stopped, ready to resume.
+func Import(r io.Reader, s store.Store, plansDir string, opts ImportOptions) (Result, error) {
+	b, err := Read(r, opts.Key)
+	if err != nil {
+		return Result{}, err
+	}
+	if b.Manifest.Algorithm != AlgorithmHMAC && !opts.AllowUnsigned {
+		return Result{}, ErrUnsigned
+	}
+	var plan model.ExecutionPlan
+	if err := json.Unmarshal(b.entries[planEntry], &plan); err != nil {
+		return Result{}, fmt.Errorf("invalid plan: %w", err)
+	}
+	if plan.ID == "" {
+		return Result{}, fmt.Errorf("bundle plan has no ID")
+	}
+	res := Result{PlanID: plan.ID, OriginalID: plan.ID}
+
+	switch {
+	case !validPlanID.MatchString(plan.ID):
+		// The ID names the workspace and the store files: never trust one of another form.
+		// Only the plan is re-keyed, replacing an arbitrary string in the logs is not safe.
+		res.PlanID = newPlanID(s, plansDir)
+		res.Rekeyed = true
+		plan.ID = res.PlanID
+	case taken(s, plansDir, plan.ID):
+		res.PlanID = newPlanID(s, plansDir)
+		res.Rekeyed = true
+		if err := rekey(b, plan.ID, res.PlanID); err != nil {
+			return Result{}, err
+		}
+		if err := json.Unmarshal(b.entries[planEntry], &plan); err != nil {
+			return Result{}, fmt.Errorf("invalid plan: %w", err)
+		}
+	}
+	if plan.Status == "running" || plan.Status == "waiting_input" {
+		plan.Status = "stopped"
+	}
+	if opts.CreatorID != "" {
+		plan.CreatorID = opts.CreatorID
+	}
+
+	// Files go first, so a failed import leaves no plan behind
+	dir := filepath.Join(plansDir, res.PlanID)
+	for name, data := range b.entries {
+		var rel string
+		switch {
+		case strings.HasPrefix(name, filesPrefix):
+			rel = strings.TrimPrefix(name, filesPrefix)
+		case name == buildsEntry:
+			rel = name
+		default:
+			continue
+		}
+		target, err := workspacePath(dir, rel)
+		if err != nil {
+			return Result{}, err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return Result{}, err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return Result{}, fmt.Errorf("failed to write %s: %w", rel, err)
+		}
+		if name != buildsEntry {
+			res.Files++
+		}
+	}
+
+	if err := s.SavePlan(plan); err != nil {
+		return Result{}, fmt.Errorf("failed to save plan: %w", err)
+	}
+	if data, ok := b.entries[memoryEntry]; ok {
+		if err := s.SaveMemory(res.PlanID, data); err != nil {
+			return res, fmt.Errorf("failed to save memory: %w", err)
+		}
+	}
+	if logs, ok := b.entries[logsEntry]; ok && len(logs) > 0 {
+		// One raw block, so GetLogs renders the log unchanged
+		if err := s.AppendRawLog(res.PlanID, strings.TrimSuffix(string(logs), "\n")); err != nil {
+			return res, fmt.Errorf("failed to save logs: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// workspacePath resolves a relative bundle path inside dir, refusing paths that escape it
+func workspacePath(dir, rel string) (string, error) {
+	clean := path.Clean(rel)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid path in bundle: %s", rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// validPlanID matches the plan-<n> IDs of new plans (see newPlanID)
+var validPlanID = regexp.MustCompile(`^plan-[0-9]+$`)
+
+// taken reports whether the plan ID exists in the store or has a workspace
+func taken(s store.Store, plansDir, id string) bool {
+	if _, err := s.GetPlan(id); err == nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(plansDir, id))
+	return err == nil
+}
+
+// newPlanID returns a free plan ID in the plan-<unix time> form used for new plans
+func newPlanID(s store.Store, plansDir string) string {
+	for n := time.Now().Unix(); ; n++ {
+		id := fmt.Sprintf("plan-%d", n)
+		if !taken(s, plansDir, id) {
+			return id
+		}
+	}
+}
+
+// rekey replaces the plan ID in the plan, memory and logs of the bundle
+func rekey(b *Bundle, oldID, newID string) error {
+	re, err := regexp.Compile(regexp.QuoteMeta(oldID) + `\b`)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{planEntry, memoryEntry, logsEntry} {
+		if data, ok := b.entries[name]; ok {
+			b.entries[name] = re.ReplaceAll(data, []byte(newID))
+		}
+	}
+	return nil
+}
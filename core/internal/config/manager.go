@@ -1,9 +1,13 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync" // For thread-safe updates
+	"time"
 
 	"github.com/sjhoeksma/druppie/core/internal/store"
 	"gopkg.in/yaml.v3"
@@ -15,6 +19,10 @@ type Config struct {
 	Build          BuildConfig          `yaml:"build" json:"build"`
 	Git            GitConfig            `yaml:"git" json:"git"`
 	IAM            IAMConfig            `yaml:"iam" json:"iam"`
+	Store          StoreConfig          `yaml:"store" json:"store"`
+	Audit          AuditConfig          `yaml:"audit" json:"audit"`
+	Bundle         BundleConfig         `yaml:"bundle" json:"bundle"`
+	Workers        WorkerConfig         `yaml:"workers" json:"workers"`
 	ApprovalGroups map[string][]string  `yaml:"approval_groups" json:"approval_groups"`
 	General        GeneralConfig        `yaml:"general" json:"general"`
 	ScheduledJobs  []ScheduledJobConfig `yaml:"scheduled_jobs" json:"scheduled_jobs"`
@@ -34,13 +42,39 @@ type GeneralConfig struct {
 	CleanupDays       int                `yaml:"cleanup_days" json:"cleanup_days"`
 	MaxAgentSelection int                `yaml:"max_agent_selections" json:"max_agent_selections"`
 	Memory            MemoryConfig       `yaml:"memory" json:"memory"`
+	Recovery          RecoveryConfig     `yaml:"recovery" json:"recovery"`
+	Limits            LimitsConfig       `yaml:"limits" json:"limits"`
+}
+
+// LimitsConfig bounds the number of steps executed at the same time. Steps waiting for a
+// slot have the status "queued".
+type LimitsConfig struct {
+	MaxSteps        int            `yaml:"max_steps" json:"max_steps"`                   // Over all plans, 0 is unlimited
+	MaxStepsPerPlan int            `yaml:"max_steps_per_plan" json:"max_steps_per_plan"` // Per plan, 0 is unlimited
+	Executors       map[string]int `yaml:"executors" json:"executors"`                   // Slots per executor, by agent id or action (e.g. image_creator: 1)
+}
+
+// RecoveryConfig controls what happens at startup to plans interrupted by a restart
+type RecoveryConfig struct {
+	AutoResume bool `yaml:"auto_resume" json:"auto_resume"` // Resume plans that were running; otherwise they are set to pending
 }
 
 // ... (other types unchanged)
 
 type MemoryConfig struct {
-	MaxWindowTokens int `yaml:"max_window_tokens" json:"max_window_tokens"` // e.g. 128000
-	SummarizeAfter  int `yaml:"summarize_after" json:"summarize_after"`     // Turn count
+	MaxWindowTokens int            `yaml:"max_window_tokens" json:"max_window_tokens"` // e.g. 128000
+	SummarizeAfter  int            `yaml:"summarize_after" json:"summarize_after"`     // Turn count
+	LongTerm        LongTermConfig `yaml:"long_term" json:"long_term"`
+}
+
+// LongTermConfig selects the vector store of the long-term (semantic) memory
+type LongTermConfig struct {
+	Store        string  `yaml:"store" json:"store"`                                     // "embedded" (default, .druppie/plans/<id>/vector_store), "qdrant" or "none"
+	URL          string  `yaml:"url,omitempty" json:"url,omitempty"`                     // Qdrant URL, e.g. http://qdrant.data-system:6333
+	Collection   string  `yaml:"collection,omitempty" json:"collection,omitempty"`       // Qdrant collection (default druppie_memory)
+	APIKey       string  `yaml:"api_key,omitempty" json:"api_key,omitempty"`             // Qdrant API key
+	TopK         int     `yaml:"top_k,omitempty" json:"top_k,omitempty"`                 // Facts added to the context (default 5)
+	MinRelevance float64 `yaml:"min_relevance,omitempty" json:"min_relevance,omitempty"` // Minimum cosine similarity (default 0.2)
 }
 
 type IAMConfig struct {
@@ -49,17 +83,76 @@ type IAMConfig struct {
 }
 
 type KeycloakConfig struct {
-	URL          string `yaml:"url" json:"url"`
-	Realm        string `yaml:"realm" json:"realm"`
-	ClientID     string `yaml:"client_id" json:"client_id"`
-	ClientSecret string `yaml:"client_secret" json:"client_secret"`
+	URL          string   `yaml:"url" json:"url"`
+	Realm        string   `yaml:"realm" json:"realm"`
+	ClientID     string   `yaml:"client_id" json:"client_id"`
+	ClientSecret string   `yaml:"client_secret" json:"client_secret"`
+	Issuer       string   `yaml:"issuer" json:"issuer"`             // Optional: full issuer URL, overrides url/realm (any OIDC issuer)
+	RedirectURL  string   `yaml:"redirect_url" json:"redirect_url"` // Optional: UI callback, defaults to <host>/v1/iam/callback
+	Scopes       []string `yaml:"scopes" json:"scopes"`             // Defaults to openid, profile, email
+}
+
+type StoreConfig struct {
+	Type   string `yaml:"type" json:"type"`                         // "file" (default), "sql"
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"` // "sqlite" (default), "postgres"
+	DSN    string `yaml:"dsn,omitempty" json:"dsn,omitempty"`       // sqlite: defaults to .druppie/druppie.db
+}
+
+// Options converts the config section to the store package options
+func (c StoreConfig) Options() store.Options {
+	return store.Options{Type: c.Type, Driver: c.Driver, DSN: c.DSN}
+}
+
+type AuditConfig struct {
+	HMACKey string   `yaml:"hmac_key,omitempty" json:"hmac_key,omitempty"` // Signs the audit chain (HMAC-SHA256); plain SHA-256 when empty
+	Groups  []string `yaml:"groups,omitempty" json:"groups,omitempty"`     // Groups allowed to query the audit trail, besides admin/root (default "auditor")
+}
+
+// AuditorGroups returns the groups allowed to read the audit trail
+func (c AuditConfig) AuditorGroups() []string {
+	if len(c.Groups) == 0 {
+		return []string{"auditor"}
+	}
+	return c.Groups
+}
+
+// BundleConfig configures plan export/import bundles
+type BundleConfig struct {
+	SigningKey    string `yaml:"signing_key,omitempty" json:"signing_key,omitempty"` // Signs bundles (HMAC-SHA256); imports then require a bundle signed with the same key
+	AllowUnsigned bool   `yaml:"allow_unsigned" json:"allow_unsigned"`               // Accept unsigned bundles on import; the CLI also with --allow-unsigned
+}
+
+// WorkerConfig selects where plan steps are executed
+type WorkerConfig struct {
+	Mode         string `yaml:"mode" json:"mode"`                   // "local" (default, in the server), "queue" (druppie worker processes, sql store) or "embedded" (in-process workers on a memory queue)
+	Count        int    `yaml:"count" json:"count"`                 // Workers started in embedded mode, and the default concurrency of druppie worker
+	LeaseSeconds int    `yaml:"lease_seconds" json:"lease_seconds"` // A job goes back on the queue when its worker stops renewing the lease for this long
+}
+
+// Lease returns how long a worker holds a job without renewing it (default 60s)
+func (c WorkerConfig) Lease() time.Duration {
+	if c.LeaseSeconds <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.LeaseSeconds) * time.Second
+}
+
+// Workers returns the number of workers to start (default 2)
+func (c WorkerConfig) Workers() int {
+	if c.Count <= 0 {
+		return 2
+	}
+	return c.Count
 }
 
 type GitConfig struct {
-	Provider string `yaml:"provider" json:"provider"` // "gitea", "github", "gitlab"
-	URL      string `yaml:"url" json:"url"`           // e.g. "http://gitea-http.gitea.svc.cluster.local:3000"
-	User     string `yaml:"user" json:"user"`
-	Token    string `yaml:"token" json:"token"`
+	Provider    string `yaml:"provider" json:"provider"` // "gitea", "github", "gitlab"
+	URL         string `yaml:"url" json:"url"`           // e.g. "http://gitea-http.gitea.svc.cluster.local:3000"
+	User        string `yaml:"user" json:"user"`
+	Token       string `yaml:"token" json:"token"`
+	Owner       string `yaml:"owner,omitempty" json:"owner,omitempty"`               // Organization/group of the plan repositories (default: user)
+	CommitPlans bool   `yaml:"commit_plans,omitempty" json:"commit_plans,omitempty"` // Commit the plan workspace after every completed step
+	Push        bool   `yaml:"push,omitempty" json:"push,omitempty"`                 // Push plan workspaces to a repository per plan on the provider
 }
 
 type BuildConfig struct {
@@ -77,24 +170,139 @@ type BuildProviderConfig struct {
 }
 
 type LLMConfig struct {
-	DefaultProvider string                    `yaml:"default_provider" json:"default_provider"` // "gemini", "ollama", "lmstudio"
-	TimeoutSeconds  int                       `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
-	Retries         int                       `yaml:"retries,omitempty" json:"retries,omitempty"`
-	Providers       map[string]ProviderConfig `yaml:"providers" json:"providers"`
+	DefaultProvider   ProviderChain             `yaml:"default_provider" json:"default_provider"` // "gemini", or a fallback chain: [gemini, openrouter, ollama]
+	TimeoutSeconds    int                       `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+	Retries           int                       `yaml:"retries,omitempty" json:"retries,omitempty"`
+	EmbeddingProvider string                    `yaml:"embedding_provider,omitempty" json:"embedding_provider,omitempty"` // Provider used for embeddings, "local" for the offline embedder
+	CircuitBreaker    CircuitBreakerConfig      `yaml:"circuit_breaker,omitempty" json:"circuit_breaker,omitempty"`
+	Cache             CacheConfig               `yaml:"cache,omitempty" json:"cache,omitempty"`
+	Providers         map[string]ProviderConfig `yaml:"providers" json:"providers"`
+}
+
+// ProviderChain lists providers in order of preference; the next one is used when a provider
+// fails. It is written as a single name or a list.
+type ProviderChain []string
+
+// ParseProviderChain parses a comma separated list of providers (e.g. "gemini,ollama")
+func ParseProviderChain(s string) ProviderChain {
+	var chain ProviderChain
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			chain = append(chain, name)
+		}
+	}
+	return chain
+}
+
+// Primary returns the first provider of the chain, "" when empty
+func (c ProviderChain) Primary() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return c[0]
+}
+
+func (c ProviderChain) String() string {
+	return strings.Join(c, ",")
+}
+
+// UnmarshalYAML accepts a name or a list (yaml.v2 style, also used by yaml.v3)
+func (c *ProviderChain) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*c = ParseProviderChain(single)
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+// MarshalYAML writes a single provider as a plain name, as before chains existed
+func (c ProviderChain) MarshalYAML() (interface{}, error) {
+	if len(c) == 1 {
+		return c[0], nil
+	}
+	return []string(c), nil
+}
+
+func (c *ProviderChain) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*c = ParseProviderChain(single)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+func (c ProviderChain) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 {
+		return json.Marshal(c[0])
+	}
+	return json.Marshal([]string(c))
+}
+
+// CircuitBreakerConfig stops calling a failing provider for a while, so the next provider of
+// the chain answers right away
+type CircuitBreakerConfig struct {
+	Failures        int `yaml:"failures,omitempty" json:"failures,omitempty"`                 // Consecutive failed calls that open the circuit (default 3)
+	CooldownSeconds int `yaml:"cooldown_seconds,omitempty" json:"cooldown_seconds,omitempty"` // Time an open circuit waits before a probe call (default 60)
+}
+
+// CacheConfig caches LLM responses by model, system prompt and prompt. Agents opt in or out
+// with cache: true|false in their definition.
+type CacheConfig struct {
+	Enabled    bool `yaml:"enabled" json:"enabled"`                             // Cache calls of agents without a cache setting
+	MaxEntries int  `yaml:"max_entries,omitempty" json:"max_entries,omitempty"` // Responses kept in memory (default 1000)
+	TTLHours   int  `yaml:"ttl_hours,omitempty" json:"ttl_hours,omitempty"`     // Age after which a response is asked again, 0 keeps it
+	MemoryOnly bool `yaml:"memory_only,omitempty" json:"memory_only,omitempty"` // Don't persist responses under .druppie/cache
+}
+
+// TTL returns the maximum age of a cached response, 0 when responses don't expire
+func (c CacheConfig) TTL() time.Duration {
+	return time.Duration(c.TTLHours) * time.Hour
 }
 
 type ProviderConfig struct {
-	Type                    string  `yaml:"type" json:"type"` // "gemini", "ollama", "lmstudio"
-	APIKey                  string  `yaml:"api_key,omitempty" json:"api_key,omitempty"`
-	Model                   string  `yaml:"model,omitempty" json:"model,omitempty"` // Default model for this provider
-	URL                     string  `yaml:"url,omitempty" json:"url,omitempty"`     // For local LLMs
-	ProjectID               string  `yaml:"project_id,omitempty" json:"project_id,omitempty"`
-	ClientID                string  `yaml:"client_id,omitempty" json:"client_id,omitempty"`
-	ClientSecret            string  `yaml:"client_secret,omitempty" json:"client_secret,omitempty"`
-	PricePerPromptToken     float64 `yaml:"price_per_prompt_token,omitempty" json:"price_per_prompt_token,omitempty"`         // € per 1M tokens
-	PricePerCompletionToken float64 `yaml:"price_per_completion_token,omitempty" json:"price_per_completion_token,omitempty"` // € per 1M tokens
-	PricePerRequest         float64 `yaml:"price_per_request,omitempty" json:"price_per_request,omitempty"`                   // € per request (e.g. image)
-	PricePerWord            float64 `yaml:"price_per_word,omitempty" json:"price_per_word,omitempty"`                         // € per word (e.g. TTS)
+	Type                    string                 `yaml:"type" json:"type"` // "gemini", "ollama", "lmstudio", "openai", "anthropic", ...
+	APIKey                  string                 `yaml:"api_key,omitempty" json:"api_key,omitempty"`
+	Model                   string                 `yaml:"model,omitempty" json:"model,omitempty"` // Default model for this provider
+	URL                     string                 `yaml:"url,omitempty" json:"url,omitempty"`     // For local LLMs
+	ProjectID               string                 `yaml:"project_id,omitempty" json:"project_id,omitempty"`
+	ClientID                string                 `yaml:"client_id,omitempty" json:"client_id,omitempty"`
+	ClientSecret            string                 `yaml:"client_secret,omitempty" json:"client_secret,omitempty"`
+	PricePerPromptToken     float64                `yaml:"price_per_prompt_token,omitempty" json:"price_per_prompt_token,omitempty"`         // € per 1M tokens
+	PricePerCompletionToken float64                `yaml:"price_per_completion_token,omitempty" json:"price_per_completion_token,omitempty"` // € per 1M tokens
+	PricePerRequest         float64                `yaml:"price_per_request,omitempty" json:"price_per_request,omitempty"`                   // € per request (e.g. image)
+	PricePerWord            float64                `yaml:"price_per_word,omitempty" json:"price_per_word,omitempty"`                         // € per word (e.g. TTS)
+	Headers                 map[string]string      `yaml:"headers,omitempty" json:"headers,omitempty"`                                       // Extra request headers ("openai", "anthropic"); values expand ${ENV}
+	Deployment              string                 `yaml:"deployment,omitempty" json:"deployment,omitempty"`                                 // Azure OpenAI deployment name
+	APIVersion              string                 `yaml:"api_version,omitempty" json:"api_version,omitempty"`                               // Azure OpenAI api-version / Anthropic version
+	MaxTokens               int                    `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`                                 // Completion limit (required by Anthropic)
+	EmbeddingModel          string                 `yaml:"embedding_model,omitempty" json:"embedding_model,omitempty"`                       // Enables embeddings ("default" for the provider default); Azure: embedding deployment
+	PricePerEmbeddingToken  float64                `yaml:"price_per_embedding_token,omitempty" json:"price_per_embedding_token,omitempty"`   // € per 1M tokens
+	MaxConcurrent           int                    `yaml:"max_concurrent,omitempty" json:"max_concurrent,omitempty"`                         // Calls in flight at the same time, 0 is unlimited
+	Models                  map[string]ModelConfig `yaml:"models,omitempty" json:"models,omitempty"`                                         // Model name -> capabilities, overriding the built-in table
+	Fixtures                string                 `yaml:"fixtures,omitempty" json:"fixtures,omitempty"`                                     // "replay": directory of the recorded calls
+	Mode                    string                 `yaml:"mode,omitempty" json:"mode,omitempty"`                                             // "replay": replay (default), record or auto
+	Upstream                string                 `yaml:"upstream,omitempty" json:"upstream,omitempty"`                                     // "replay": provider that answers the calls to record
+	MinSimilarity           float64                `yaml:"min_similarity,omitempty" json:"min_similarity,omitempty"`                         // "replay": fuzzy match threshold (0-1), default 0.9
+}
+
+// ModelConfig describes the limits of a model. Unset fields come from the built-in table of
+// known models (see llm.LookupCapabilities).
+type ModelConfig struct {
+	ContextLength int    `yaml:"context_length,omitempty" json:"context_length,omitempty"` // Tokens of prompt and answer together
+	MaxOutput     int    `yaml:"max_output,omitempty" json:"max_output,omitempty"`         // Tokens of the answer
+	Tokenizer     string `yaml:"tokenizer,omitempty" json:"tokenizer,omitempty"`           // tiktoken vocabulary file, or a family: o200k, cl100k, gemini, claude, llama3, sentencepiece
 }
 
 // Manager handles concurrent access to the configuration
@@ -111,7 +319,7 @@ func NewManager(s store.Store) (*Manager, error) {
 		store: s,
 		config: &Config{
 			LLM: LLMConfig{
-				DefaultProvider: "ollama",
+				DefaultProvider: ProviderChain{"ollama"},
 				TimeoutSeconds:  120,
 				Retries:         3,
 				Providers: map[string]ProviderConfig{
@@ -154,8 +362,9 @@ func NewManager(s store.Store) (*Manager, error) {
 				},
 			},
 			Git: GitConfig{
-				Provider: "gitea",
-				URL:      "http://gitea-http.gitea.svc.cluster.local:3000",
+				Provider:    "gitea",
+				URL:         "http://gitea-http.gitea.svc.cluster.local:3000",
+				CommitPlans: true,
 			},
 			IAM: IAMConfig{
 				Provider: "local",
@@ -217,6 +426,13 @@ func (c Config) Clone() Config {
 	if c.LLM.Providers != nil {
 		newCfg.LLM.Providers = make(map[string]ProviderConfig, len(c.LLM.Providers))
 		for k, v := range c.LLM.Providers {
+			if v.Headers != nil {
+				headers := make(map[string]string, len(v.Headers))
+				for hk, hv := range v.Headers {
+					headers[hk] = hv
+				}
+				v.Headers = headers
+			}
 			newCfg.LLM.Providers[k] = v
 		}
 	}
@@ -232,6 +448,9 @@ func (c Config) Clone() Config {
 			newCfg.General.InternalCosts[k] = v
 		}
 	}
+	if c.Audit.Groups != nil {
+		newCfg.Audit.Groups = append([]string(nil), c.Audit.Groups...)
+	}
 	if c.ScheduledJobs != nil {
 		newCfg.ScheduledJobs = make([]ScheduledJobConfig, len(c.ScheduledJobs))
 		for i, job := range c.ScheduledJobs {
@@ -258,6 +477,12 @@ func (c Config) Sanitize() Config {
 		safe.LLM.Providers[name] = p
 	}
 	safe.IAM.Keycloak.ClientSecret = ""
+	safe.Audit.HMACKey = ""
+	safe.Bundle.SigningKey = ""
+	safe.General.Memory.LongTerm.APIKey = ""
+	if strings.Contains(safe.Store.DSN, "@") || strings.Contains(strings.ToLower(safe.Store.DSN), "password") {
+		safe.Store.DSN = "" // Contains credentials
+	}
 	return safe
 }
 
@@ -276,6 +501,43 @@ func (m *Manager) Update(newConfig Config) error {
 	return m.Save()
 }
 
+// LoadStoreConfig determines which store to open before any store (and thus the config in it) is available.
+// Priority: STORE_TYPE/STORE_DRIVER/STORE_DSN env vars, the store section of .druppie/config.yaml,
+// then the store section of core/config_default.yaml. Defaults to the file store.
+func LoadStoreConfig(rootDir string) StoreConfig {
+	var sc StoreConfig
+	for _, path := range []string{
+		filepath.Join(rootDir, ".druppie", "config.yaml"),
+		filepath.Join(rootDir, "core", "config_default.yaml"),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var partial struct {
+			Store StoreConfig `yaml:"store"`
+		}
+		if yaml.Unmarshal(data, &partial) == nil && partial.Store.Type != "" {
+			sc = partial.Store
+			break
+		}
+	}
+
+	if t := os.Getenv("STORE_TYPE"); t != "" {
+		sc.Type = t
+	}
+	if d := os.Getenv("STORE_DRIVER"); d != "" {
+		sc.Driver = d
+	}
+	if dsn := os.Getenv("STORE_DSN"); dsn != "" {
+		sc.DSN = dsn
+	}
+	if sc.Type == "" {
+		sc.Type = "file"
+	}
+	return sc
+}
+
 func (m *Manager) loadEnv() {
 	if key := os.Getenv("GEMINI_API_KEY"); key != "" {
 		// Update gemini provider in map if exists, or create it
@@ -288,8 +550,8 @@ func (m *Manager) loadEnv() {
 		m.config.LLM.Providers["gemini"] = p
 
 		// Set default if not set
-		if m.config.LLM.DefaultProvider == "" {
-			m.config.LLM.DefaultProvider = "gemini"
+		if len(m.config.LLM.DefaultProvider) == 0 {
+			m.config.LLM.DefaultProvider = ProviderChain{"gemini"}
 		}
 	}
 	if port := os.Getenv("PORT"); port != "" {
@@ -304,4 +566,35 @@ func (m *Manager) loadEnv() {
 	if iam := os.Getenv("IAM_PROVIDER"); iam != "" {
 		m.config.IAM.Provider = iam
 	}
+	if v := os.Getenv("KEYCLOAK_URL"); v != "" {
+		m.config.IAM.Keycloak.URL = v
+	}
+	if v := os.Getenv("KEYCLOAK_REALM"); v != "" {
+		m.config.IAM.Keycloak.Realm = v
+	}
+	if v := os.Getenv("KEYCLOAK_CLIENT_ID"); v != "" {
+		m.config.IAM.Keycloak.ClientID = v
+	}
+	if v := os.Getenv("KEYCLOAK_CLIENT_SECRET"); v != "" {
+		m.config.IAM.Keycloak.ClientSecret = v
+	}
+	if v := os.Getenv("OIDC_ISSUER"); v != "" {
+		m.config.IAM.Keycloak.Issuer = v
+	}
+	if v := os.Getenv("AUDIT_HMAC_KEY"); v != "" {
+		m.config.Audit.HMACKey = v
+	}
+	if v := os.Getenv("BUNDLE_SIGNING_KEY"); v != "" {
+		m.config.Bundle.SigningKey = v
+	}
+	if v := os.Getenv("WORKER_MODE"); v != "" {
+		m.config.Workers.Mode = v
+	}
+	if v := os.Getenv("QDRANT_URL"); v != "" {
+		m.config.General.Memory.LongTerm.Store = "qdrant"
+		m.config.General.Memory.LongTerm.URL = v
+	}
+	if v := os.Getenv("QDRANT_API_KEY"); v != "" {
+		m.config.General.Memory.LongTerm.APIKey = v
+	}
 }
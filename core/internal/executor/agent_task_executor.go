@@ -0,0 +1,250 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+	"github.com/sjhoeksma/druppie/core/internal/mcp"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/policy"
+	"github.com/sjhoeksma/druppie/core/internal/registry"
+)
+
+// MaxToolRounds limits the model/tool round trips of one agent task
+const MaxToolRounds = 12
+
+// AgentTaskExecutor lets the agent of a step solve a task with native function calling.
+// The model gets the MCP tools the agent may use (AgentDefinition.Tools) and calls them
+// until it returns its answer.
+type AgentTaskExecutor struct {
+	LLM        llm.Provider
+	MCPManager *mcp.Manager
+	Registry   *registry.Registry
+}
+
+func (e *AgentTaskExecutor) CanHandle(action string) bool {
+	return action == "agent_task"
+}
+
+func (e *AgentTaskExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	task := firstParam(step.Params, "task", "prompt", "description", "instruction")
+	if task == "" {
+		return fmt.Errorf("action 'agent_task' requires a 'task' parameter")
+	}
+
+	var agent model.AgentDefinition
+	if e.Registry != nil && step.AgentID != "" {
+		if a, err := e.Registry.GetAgent(step.AgentID); err == nil {
+			agent = a
+		}
+	}
+
+	provider, err := e.toolProvider(agent.Provider)
+	if err != nil {
+		return err
+	}
+
+	var tools []mcp.Tool
+	if e.MCPManager != nil {
+		tools = AllowedTools(agent, e.MCPManager.ListAllTools())
+	}
+	specs, toolNames := toolSpecs(tools)
+	outputChan <- LogEvent(fmt.Sprintf("[agent_task] Agent '%s' starts with %d tool(s)", step.AgentID, len(specs)))
+
+	systemPrompt := "You are an agent of the Druppie platform. Solve the task using the available tools when needed, then answer with a concise summary of what you did and the result."
+	if agent.Instructions != "" {
+		systemPrompt = agent.Instructions + "\n\n" + systemPrompt
+	}
+	if planID := firstParam(step.Params, "plan_id", "_plan_id"); planID != "" {
+		systemPrompt += fmt.Sprintf("\nThe files of this plan are in the workspace of plan %s; use relative paths.", planID)
+	}
+
+	var calls []map[string]interface{}
+	exec := func(ctx context.Context, call llm.ToolCall) (string, error) {
+		toolName, ok := toolNames[call.Name]
+		if !ok {
+			// The model may only call tools it was offered
+			outputChan <- LogEvent(fmt.Sprintf("[agent_task] Rejected call to tool '%s': not allowed for agent '%s'", call.Name, step.AgentID))
+			return "", fmt.Errorf("tool '%s' is not available to this agent", call.Name)
+		}
+		if err := e.checkToolCall(ctx, step, agent, toolName, call.Arguments, outputChan); err != nil {
+			return "", err
+		}
+		argsJSON, _ := json.Marshal(call.Arguments)
+		outputChan <- LogEvent(fmt.Sprintf("[agent_task] Calling %s %s", toolName, truncate(string(argsJSON), 200)))
+		calls = append(calls, map[string]interface{}{"tool": toolName, "arguments": call.Arguments})
+
+		result, err := e.MCPManager.ExecuteTool(ctx, toolName, call.Arguments)
+		if err != nil {
+			outputChan <- LogEvent(fmt.Sprintf("[agent_task] %s failed: %v", toolName, err))
+			return "", err
+		}
+		var texts []string
+		for _, content := range result.Content {
+			if content.Type == "text" {
+				texts = append(texts, content.Text)
+			} else {
+				texts = append(texts, fmt.Sprintf("[%s content]", content.Type))
+			}
+		}
+		text := strings.Join(texts, "\n")
+		if result.IsError {
+			outputChan <- LogEvent(fmt.Sprintf("[agent_task] %s returned an error: %s", toolName, truncate(text, 200)))
+			return "", fmt.Errorf("tool returned error: %s", text)
+		}
+		return text, nil
+	}
+
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: systemPrompt},
+		{Role: llm.RoleUser, Content: task},
+	}
+	answer, _, usage, err := llm.RunTools(ctx, provider, messages, specs, exec, MaxToolRounds)
+	outputChan <- UsageEvent(usage)
+	if err != nil {
+		return fmt.Errorf("agent task failed: %w", err)
+	}
+
+	if len(calls) > 0 {
+		outputChan <- OutputEvent("tool_calls", calls)
+	}
+	outputChan <- OutputEvent(OutputConsole, answer)
+	return nil
+}
+
+// checkToolCall evaluates a tool call of the model against the compliance policies, like a
+// planned step with the tool as action. A denied call is refused with the reason, so the model
+// can change its arguments. A call that needs approval fails the step: the approvers of the
+// step never saw the arguments the model picked.
+func (e *AgentTaskExecutor) checkToolCall(ctx context.Context, step model.Step, agent model.AgentDefinition, toolName string, args map[string]interface{}, outputChan chan<- Event) error {
+	if e.Registry == nil {
+		return nil
+	}
+	engine := e.Registry.Policy()
+	if len(engine.Rules()) == 0 {
+		return nil
+	}
+	var def *model.AgentDefinition
+	if agent.ID != "" {
+		def = &agent
+	}
+	call := model.Step{ID: step.ID, AgentID: step.AgentID, Action: toolName, Params: args}
+	decision, err := engine.Evaluate(ctx, policy.Input(PlanFromContext(ctx), call, def))
+	if err != nil {
+		// Fail closed, like the policy gate
+		return fmt.Errorf("compliance policies could not be evaluated: %w", err)
+	}
+
+	for _, f := range decision.ByEffect(policy.EffectWarn) {
+		outputChan <- LogEvent(fmt.Sprintf("[agent_task] Policy warning for %s: %s", toolName, f))
+	}
+	if denied := decision.ByEffect(policy.EffectDeny); len(denied) > 0 {
+		msgs := make([]string, len(denied))
+		for i, f := range denied {
+			msgs[i] = f.String()
+		}
+		outputChan <- LogEvent(fmt.Sprintf("[agent_task] Refused call to %s: %s", toolName, strings.Join(msgs, "; ")))
+		return fmt.Errorf("blocked by compliance policy: %s", strings.Join(msgs, "; "))
+	}
+	if groups := decision.ApprovalGroups(); len(groups) > 0 {
+		var reasons []string
+		for _, f := range decision.ByEffect(policy.EffectApprove) {
+			reasons = append(reasons, f.String())
+		}
+		return llm.AbortTools(fmt.Errorf("call to %s requires approval from %s: %s", toolName, strings.Join(groups, ", "), strings.Join(reasons, "; ")))
+	}
+	return nil
+}
+
+// toolProvider returns the (agent specific) provider with function calling support
+func (e *AgentTaskExecutor) toolProvider(name string) (llm.ToolProvider, error) {
+	if m, ok := e.LLM.(*llm.Manager); ok {
+		return m.ToolProviderFor(name)
+	}
+	if tp, ok := e.LLM.(llm.ToolProvider); ok {
+		return tp, nil
+	}
+	return nil, fmt.Errorf("llm provider does not support tool calling")
+}
+
+// AllowedTools filters the MCP tools on the Tools list of the agent. An entry matches a server
+// name, a tool name or "server__tool"; "*" allows every tool. Agents without tools get none.
+func AllowedTools(agent model.AgentDefinition, tools []mcp.Tool) []mcp.Tool {
+	allowed := make(map[string]bool, len(agent.Tools))
+	for _, t := range agent.Tools {
+		allowed[normalizeToolRef(t)] = true
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	var out []mcp.Tool
+	for _, t := range tools {
+		if allowed["*"] || allowed[normalizeToolRef(t.Server)] || allowed[normalizeToolRef(t.Name)] ||
+			allowed[normalizeToolRef(t.Server+"__"+t.Name)] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// normalizeToolRef compares references the way agent definitions store them (dashes become underscores)
+func normalizeToolRef(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "-", "_"))
+}
+
+var invalidFunctionChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// toolSpecs converts MCP tools to function declarations. Function names must match
+// [a-zA-Z0-9_-]{1,64}, so the returned map resolves them to the namespaced MCP tool name.
+func toolSpecs(tools []mcp.Tool) ([]llm.ToolSpec, map[string]string) {
+	sort.SliceStable(tools, func(i, j int) bool {
+		return tools[i].Server+"__"+tools[i].Name < tools[j].Server+"__"+tools[j].Name
+	})
+
+	specs := make([]llm.ToolSpec, 0, len(tools))
+	names := make(map[string]string, len(tools))
+	for _, t := range tools {
+		toolName := t.Name
+		if t.Server != "" {
+			toolName = t.Server + "__" + t.Name
+		}
+		fn := invalidFunctionChars.ReplaceAllString(toolName, "_")
+		if len(fn) > 64 {
+			fn = fn[:64]
+		}
+		for i := 2; names[fn] != ""; i++ {
+			suffix := fmt.Sprintf("_%d", i)
+			fn = fn[:min(len(fn), 64-len(suffix))] + suffix
+		}
+		names[fn] = toolName
+
+		var params map[string]interface{}
+		if data, err := json.Marshal(t.InputSchema); err == nil {
+			_ = json.Unmarshal(data, &params)
+		}
+		specs = append(specs, llm.ToolSpec{Name: fn, Description: t.Description, Parameters: params})
+	}
+	return specs, names
+}
+
+func firstParam(params map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := params[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/registry"
+)
+
+// oneCall asks for a single tool call, then answers
+type oneCall struct {
+	call  llm.ToolCall
+	turns int
+}
+
+func (p *oneCall) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.ToolSpec) (llm.Message, model.TokenUsage, error) {
+	p.turns++
+	if p.turns == 1 {
+		return llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{p.call}}, model.TokenUsage{}, nil
+	}
+	return llm.Message{Role: llm.RoleAssistant, Content: "done"}, model.TokenUsage{}, nil
+}
+
+// Tool calls of the model pass the compliance policies of the BIO & NIS2 document
+func TestAgentToolCallsPassPolicies(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := os.ReadFile(filepath.Join("..", "..", "..", "compliance", "bio_nis2.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "compliance"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "compliance", "bio_nis2.md"), policy, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := registry.LoadRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &AgentTaskExecutor{Registry: reg}
+	step := model.Step{ID: 3, AgentID: "developer", Action: "agent_task"}
+
+	tests := map[string]struct {
+		args   map[string]interface{}
+		result string // Reported to the model
+		err    string // Fails the step
+	}{
+		"EU":            {map[string]interface{}{"region": "westeurope", "data_classification": "BBN2"}, "ok", ""},
+		"non-EU public": {map[string]interface{}{"region": "us-east-1", "access_level": "public", "data_classification": "BBN2"}, "Error: blocked by compliance policy", ""},
+		"non-EU":        {map[string]interface{}{"region": "us-east-1", "data_classification": "BBN2"}, "", "call to gitea__create_repo requires approval from security"},
+	}
+	for name, tc := range tests {
+		p := &oneCall{call: llm.ToolCall{ID: "call-1", Name: "gitea__create_repo", Arguments: tc.args}}
+		events := make(chan Event, 10)
+		exec := func(ctx context.Context, call llm.ToolCall) (string, error) {
+			if err := e.checkToolCall(ctx, step, model.AgentDefinition{}, call.Name, call.Arguments, events); err != nil {
+				return "", err
+			}
+			return "ok", nil
+		}
+		answer, messages, _, err := llm.RunTools(context.Background(), p, nil, nil, exec, 5)
+
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) || p.turns != 1 {
+				t.Errorf("%s: expected the loop to end with %q, got %v after %d turns", name, tc.err, err, p.turns)
+			}
+			continue
+		}
+		if err != nil || answer != "done" {
+			t.Fatalf("%s: expected the model to answer, got %q, %v", name, answer, err)
+		}
+		if result := messages[1].Content; !strings.HasPrefix(result, tc.result) {
+			t.Errorf("%s: expected tool result %q, got %q", name, tc.result, result)
+		}
+	}
+}
@@ -34,9 +34,9 @@ func (e *ArchitectExecutor) CanHandle(action string) bool {
 		action == "intake" || action == "completion" || action == "iteration"
 }
 
-func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	action := step.Action
-	outputChan <- fmt.Sprintf("ArchitectExecutor: Executing '%s'...", action)
+	outputChan <- LogEvent(fmt.Sprintf("ArchitectExecutor: Executing '%s'...", action))
 
 	// Extract planID
 	planID := ""
@@ -46,7 +46,7 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 		planID = p
 	} else {
 		planID = "unknown_plan"
-		outputChan <- "Warning: plan_id not found in params"
+		outputChan <- LogEvent("Warning: plan_id not found in params")
 	}
 
 	var result string
@@ -128,7 +128,7 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 			}
 			if count > 0 {
 				userPrompt += fileContext.String()
-				outputChan <- fmt.Sprintf("ArchitectExecutor: Loaded %d existing artifacts into context.", count)
+				outputChan <- LogEvent(fmt.Sprintf("ArchitectExecutor: Loaded %d existing artifacts into context.", count))
 			}
 		}
 
@@ -137,7 +137,7 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 		}
 
 		// Debug Logging (User Request)
-		outputChan <- fmt.Sprintf("LLM Prompt Length: %d chars. Context keys: %v", len(userPrompt), getKeys(step.Params))
+		outputChan <- LogEvent(fmt.Sprintf("LLM Prompt Length: %d chars. Context keys: %v", len(userPrompt), getKeys(step.Params)))
 
 		var err error
 
@@ -151,7 +151,7 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 			result, usage, err = e.LLM.Generate(ctx, userPrompt, systemPrompt)
 		}
 		if err != nil {
-			outputChan <- fmt.Sprintf("LLM generation failed: %v. Using fallback.", err)
+			outputChan <- LogEvent(fmt.Sprintf("LLM generation failed: %v. Using fallback.", err))
 			result = e.getFallbackResult(action)
 		}
 	} else {
@@ -169,7 +169,7 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 
 	filesDir, _ := paths.ResolvePath(".druppie", "plans", planID, "files")
 	if err := os.MkdirAll(filesDir, 0755); err != nil {
-		outputChan <- fmt.Sprintf("Error creating files directory: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error creating files directory: %v", err))
 		return err
 	}
 
@@ -177,15 +177,15 @@ func (e *ArchitectExecutor) Execute(ctx context.Context, step model.Step, output
 	filePath := filepath.Join(filesDir, fileName)
 
 	if err := os.WriteFile(filePath, []byte(result), 0644); err != nil {
-		outputChan <- fmt.Sprintf("Error writing architecture file: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error writing architecture file: %v", err))
 		return err
 	}
 
-	outputChan <- fmt.Sprintf("Document saved to: %s", filePath)
+	outputChan <- LogEvent(fmt.Sprintf("Document saved to: %s", filePath))
 
-	// Report usage via special output format
+	// Report usage so it is attributed to the step
 	if usage.TotalTokens > 0 || usage.EstimatedCost > 0 {
-		outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+		outputChan <- UsageEvent(usage)
 	}
 
 	return nil
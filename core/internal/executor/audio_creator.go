@@ -25,7 +25,7 @@ func (e *AudioCreatorExecutor) CanHandle(action string) bool {
 	return action == "audio_creator" || action == "text_to_speech"
 }
 
-func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	// Extract Scene ID for naming
 	sceneID := fmt.Sprintf("%d", step.ID)
 	if sID, ok := step.Params["scene_id"]; ok {
@@ -38,7 +38,7 @@ func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		planID = p
 	}
 
-	outputChan <- fmt.Sprintf("🎙️ [Audio Creator] Processing Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🎙️ [Audio Creator] Processing Scene %s...", sceneID))
 
 	// Extract params
 	text := ""
@@ -55,7 +55,7 @@ func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		voice = fmt.Sprintf("%v", v)
 	}
 
-	outputChan <- fmt.Sprintf("   📝 Logic: Generating speech for \"%s\" (Voice: %s)", text, voice)
+	outputChan <- LogEvent(fmt.Sprintf("   📝 Logic: Generating speech for \"%s\" (Voice: %s)", text, voice))
 
 	// Try LLM Provider "text_to_speech"
 	if e.LLM != nil {
@@ -98,16 +98,16 @@ func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 				filename := fmt.Sprintf("audio_scene_%s%s", sceneID, ext)
 				if planID != "" {
 					if err := SaveAsset(planID, filename, resp); err == nil {
-						outputChan <- fmt.Sprintf("✅ [Audio Creator] Generated via Provider: %s", filename)
-						outputChan <- fmt.Sprintf("RESULT_AUDIO_FILE=%s", filename)
+						outputChan <- LogEvent(fmt.Sprintf("✅ [Audio Creator] Generated via Provider: %s", filename))
+						outputChan <- ArtifactEvent("audio_file", filename)
 						if usage.EstimatedCost > 0 || usage.TotalTokens > 0 {
-							outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+							outputChan <- UsageEvent(usage)
 						}
 						return nil
 					}
-					outputChan <- fmt.Sprintf("⚠️ Failed to save audio from provider: %v", err)
+					outputChan <- LogEvent(fmt.Sprintf("⚠️ Failed to save audio from provider: %v", err))
 				} else {
-					outputChan <- "⚠️ Plan ID missing, cannot save file."
+					outputChan <- LogEvent("⚠️ Plan ID missing, cannot save file.")
 				}
 			}
 		}
@@ -147,12 +147,12 @@ func (e *AudioCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		}
 	}
 
-	outputChan <- fmt.Sprintf("✅ [Audio Creator] Generated (Mock): %s (Duration: %s)", filename, durationStr)
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Audio Creator] Generated (Mock): %s (Duration: %s)", filename, durationStr))
 
 	// Return structural result
-	outputChan <- fmt.Sprintf("RESULT_DURATION=%s", durationStr)
-	outputChan <- fmt.Sprintf("RESULT_AUDIO_FILE=%s", filename)
-	outputChan <- "RESULT_TOKEN_USAGE=0,0,0,0.00100"
+	outputChan <- OutputEvent("duration", durationSeconds)
+	outputChan <- ArtifactEvent("audio_file", filename)
+	outputChan <- UsageEvent(model.TokenUsage{EstimatedCost: 0.001})
 
 	return nil
 }
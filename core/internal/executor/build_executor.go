@@ -23,8 +23,8 @@ func (e *BuildExecutor) CanHandle(action string) bool {
 	return action == "build_code"
 }
 
-func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- fmt.Sprintf("Starting build for Step %d...", step.ID)
+func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent(fmt.Sprintf("Starting build for Step %d...", step.ID))
 
 	// Extract params
 	repoURL, _ := step.Params["repo_url"].(string)
@@ -54,7 +54,7 @@ func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan
 		return err
 	}
 	if warning != "" {
-		outputChan <- warning
+		outputChan <- LogEvent(warning)
 	}
 
 	// Check if source directory exists and is not empty
@@ -73,7 +73,7 @@ func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan
 		defer wg.Done()
 		scanner := bufio.NewScanner(pr)
 		for scanner.Scan() {
-			outputChan <- scanner.Text()
+			outputChan <- LogEvent(scanner.Text())
 		}
 	}()
 
@@ -87,7 +87,7 @@ func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan
 		fileWriter := logging.NewLogWriter(planID)
 		writers = append(writers, fileWriter)
 	} else {
-		outputChan <- "Warning: No Plan ID provided. Build logs will only be visible here and not persisted."
+		outputChan <- LogEvent("Warning: No Plan ID provided. Build logs will only be visible here and not persisted.")
 	}
 
 	multiWriter := io.MultiWriter(writers...)
@@ -95,7 +95,7 @@ func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan
 	// Trigger Build
 	buildID, err := e.Builder.TriggerBuild(ctx, repoURL, commitHash, multiWriter)
 	if err != nil {
-		outputChan <- fmt.Sprintf("Build failed with error: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Build failed with error: %v", err))
 		pw.Close() // Close pipe to stop scanner
 		return err
 	}
@@ -104,8 +104,8 @@ func (e *BuildExecutor) Execute(ctx context.Context, step model.Step, outputChan
 	pw.Close()
 	wg.Wait()
 
-	outputChan <- fmt.Sprintf("Build triggered successfully. Build ID: %s", buildID)
-	outputChan <- fmt.Sprintf("RESULT_BUILD_ID=%s", buildID)
+	outputChan <- LogEvent(fmt.Sprintf("Build triggered successfully. Build ID: %s", buildID))
+	outputChan <- OutputEvent(OutputBuildID, buildID)
 
 	// Construct artifact path for next steps
 	// Convention: repoURL/../builds/buildID
@@ -29,9 +29,9 @@ func (e *BusinessAnalystExecutor) CanHandle(action string) bool {
 		action == "review" // Business Analyst review
 }
 
-func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	action := step.Action
-	outputChan <- fmt.Sprintf("BusinessAnalystExecutor: Executing '%s'...", action)
+	outputChan <- LogEvent(fmt.Sprintf("BusinessAnalystExecutor: Executing '%s'...", action))
 
 	// Extract planID
 	planID := ""
@@ -41,7 +41,7 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 		planID = p
 	} else {
 		planID = "unknown_plan"
-		outputChan <- "Warning: plan_id not found in params"
+		outputChan <- LogEvent("Warning: plan_id not found in params")
 	}
 
 	var result string
@@ -106,7 +106,7 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 			}
 			if count > 0 {
 				userPrompt += fileContext.String()
-				outputChan <- fmt.Sprintf("BusinessAnalystExecutor: Loaded %d existing artifacts into context.", count)
+				outputChan <- LogEvent(fmt.Sprintf("BusinessAnalystExecutor: Loaded %d existing artifacts into context.", count))
 			}
 		}
 
@@ -115,7 +115,7 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 		}
 
 		// Debug Logging
-		outputChan <- fmt.Sprintf("LLM Prompt Length: %d chars.", len(userPrompt))
+		outputChan <- LogEvent(fmt.Sprintf("LLM Prompt Length: %d chars.", len(userPrompt)))
 
 		var err error
 
@@ -129,7 +129,7 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 			result, usage, err = e.LLM.Generate(ctx, userPrompt, systemPrompt)
 		}
 		if err != nil {
-			outputChan <- fmt.Sprintf("LLM generation failed: %v. Using fallback.", err)
+			outputChan <- LogEvent(fmt.Sprintf("LLM generation failed: %v. Using fallback.", err))
 			result = e.getFallbackResult(action)
 		}
 	} else {
@@ -145,7 +145,7 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 
 	filesDir, _ := paths.ResolvePath(".druppie", "plans", planID, "files")
 	if err := os.MkdirAll(filesDir, 0755); err != nil {
-		outputChan <- fmt.Sprintf("Error creating files directory: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error creating files directory: %v", err))
 		return err
 	}
 
@@ -153,15 +153,15 @@ func (e *BusinessAnalystExecutor) Execute(ctx context.Context, step model.Step,
 	filePath := filepath.Join(filesDir, fileName)
 
 	if err := os.WriteFile(filePath, []byte(result), 0644); err != nil {
-		outputChan <- fmt.Sprintf("Error writing analysis file: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error writing analysis file: %v", err))
 		return err
 	}
 
-	outputChan <- fmt.Sprintf("Document saved to: %s", filePath)
+	outputChan <- LogEvent(fmt.Sprintf("Document saved to: %s", filePath))
 
 	// Report usage
 	if usage.TotalTokens > 0 || usage.EstimatedCost > 0 {
-		outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+		outputChan <- UsageEvent(usage)
 	}
 
 	return nil
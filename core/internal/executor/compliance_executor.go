@@ -7,6 +7,7 @@ import (
 
 	"github.com/sjhoeksma/druppie/core/internal/llm"
 	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/policy"
 	"github.com/sjhoeksma/druppie/core/internal/registry"
 )
 
@@ -20,9 +21,20 @@ func (e *ComplianceExecutor) CanHandle(action string) bool {
 	return action == "compliance_check" || action == "validate_policy"
 }
 
-func (e *ComplianceExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *ComplianceExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	switch step.Action {
 	case "compliance_check":
+		// Rego policies are enforced first, the LLM analysis only adds context
+		decision, evaluated, err := e.evaluatePolicies(ctx, step)
+		if err != nil {
+			return err
+		}
+		if evaluated {
+			if done, err := e.reportDecision(step, decision, outputChan); done {
+				return err
+			}
+		}
+
 		// Use LLM for intelligent compliance checking
 		if e.LLM != nil {
 			region, _ := step.Params["region"].(string)
@@ -75,25 +87,25 @@ Additional Context: %v`, region, access, step.Params)
 
 			result, usage, err := e.LLM.Generate(ctx, userPrompt, systemPrompt)
 			if err != nil {
-				outputChan <- fmt.Sprintf("LLM compliance check failed: %v. Using rule-based fallback.", err)
-				return e.executeRuleBasedCheck(step, outputChan)
+				outputChan <- LogEvent(fmt.Sprintf("LLM compliance check failed: %v. Using rule-based fallback.", err))
+				return e.executeRuleBasedCheck(evaluated, outputChan)
 			}
 
-			outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=%s", strings.TrimSpace(result))
+			outputChan <- OutputEvent(OutputConsole, strings.TrimSpace(result))
 
 			// Report usage
 			if usage.TotalTokens > 0 || usage.EstimatedCost > 0 {
-				outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+				outputChan <- UsageEvent(usage)
 			}
 
 			return nil
 		}
 
-		return e.executeRuleBasedCheck(step, outputChan)
+		return e.executeRuleBasedCheck(evaluated, outputChan)
 
 	case "validate_policy":
 		policies, _ := step.Params["policy_frameworks"].([]interface{})
-		outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=Validating against: %v", policies)
+		outputChan <- OutputEvent(OutputConsole, fmt.Sprintf("Validating against: %v", policies))
 		return nil
 
 	case "audit_request":
@@ -101,24 +113,70 @@ Additional Context: %v`, region, access, step.Params)
 		if justification == "" {
 			justification, _ = step.Params["reason"].(string)
 		}
-		outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=[AUDIT] Approval Required for: %s", justification)
+		outputChan <- OutputEvent(OutputConsole, fmt.Sprintf("[AUDIT] Approval Required for: %s", justification))
 		return fmt.Errorf("Approval Required from Compliance Group. Please review plan and type '/approve' or '/reject'.")
 	}
 
 	return nil
 }
 
-func (e *ComplianceExecutor) executeRuleBasedCheck(step model.Step, outputChan chan<- string) error {
-	region, _ := step.Params["region"].(string)
-	if region == "" {
-		region, _ = step.Params["deployment_region"].(string)
+// evaluatePolicies runs the Rego policies of the registry's compliance rules against the step
+// and the plan it belongs to. evaluated is false when no rule carries a policy.
+func (e *ComplianceExecutor) evaluatePolicies(ctx context.Context, step model.Step) (policy.Decision, bool, error) {
+	if e.Registry == nil {
+		return policy.Decision{}, false, nil
+	}
+	engine := e.Registry.Policy()
+	if len(engine.Rules()) == 0 {
+		return policy.Decision{}, false, nil
+	}
+
+	var agent *model.AgentDefinition
+	if a, err := e.Registry.GetAgent(step.AgentID); err == nil {
+		agent = &a
+	}
+	decision, err := engine.Evaluate(ctx, policy.Input(PlanFromContext(ctx), step, agent))
+	if err != nil {
+		return decision, false, err
+	}
+	return decision, true, nil
+}
+
+// reportDecision emits the findings; done is true when the decision blocks the step or routes it to an approval group
+func (e *ComplianceExecutor) reportDecision(step model.Step, decision policy.Decision, outputChan chan<- Event) (bool, error) {
+	outputChan <- OutputEvent("policy_findings", decision.Findings)
+	for _, f := range decision.Findings {
+		if f.Effect == policy.EffectWarn {
+			outputChan <- OutputEvent(OutputConsole, fmt.Sprintf("[WARNING] %s", f))
+		} else {
+			outputChan <- OutputEvent(OutputConsole, fmt.Sprintf("[VIOLATION] %s", f))
+		}
 	}
-	access, _ := step.Params["access_level"].(string)
 
-	if region == "us-east-1" && strings.ToLower(access) == "public" {
-		outputChan <- "RESULT_CONSOLE_OUTPUT=[VIOLATION] Data Residency: US Region with Public Access detected."
+	if denied := decision.ByEffect(policy.EffectDeny); len(denied) > 0 {
+		msgs := make([]string, len(denied))
+		for i, f := range denied {
+			msgs[i] = f.String()
+		}
+		return true, fmt.Errorf("blocked by compliance policy: %s", strings.Join(msgs, "; "))
+	}
+	if groups := decision.ApprovalGroups(); len(groups) > 0 && step.ApprovedBy == "" {
+		var reasons []string
+		for _, f := range decision.ByEffect(policy.EffectApprove) {
+			reasons = append(reasons, f.String())
+		}
+		outputChan <- ApprovalEvent(groups[0], fmt.Sprintf("Approval required from %s: %s", strings.Join(groups, ", "), strings.Join(reasons, "; ")))
+		return true, nil
+	}
+	return false, nil
+}
+
+func (e *ComplianceExecutor) executeRuleBasedCheck(evaluated bool, outputChan chan<- Event) error {
+	// Violations were already reported by reportDecision
+	if evaluated {
+		outputChan <- OutputEvent(OutputConsole, "Compliance Check Passed (policies evaluated).")
 	} else {
-		outputChan <- "RESULT_CONSOLE_OUTPUT=Compliance Check Passed."
+		outputChan <- OutputEvent(OutputConsole, "Compliance Check Passed.")
 	}
 	return nil
 }
@@ -22,7 +22,7 @@ func (e *ContentMergerExecutor) CanHandle(action string) bool {
 	return a == "content_merge"
 }
 
-func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	planID, _ := step.Params["plan_id"].(string)
 	if planID == "" {
 		return fmt.Errorf("plan_id is required for content_merge")
@@ -30,11 +30,11 @@ func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, ou
 
 	ffmpegPath, err := exec.LookPath("ffmpeg")
 	if err != nil {
-		outputChan <- "⚠️ [Content Merge] FFmpeg not found in PATH. Falling back to simulation mode."
+		outputChan <- LogEvent("⚠️ [Content Merge] FFmpeg not found in PATH. Falling back to simulation mode.")
 		return e.simulate(ctx, step, outputChan)
 	}
 
-	outputChan <- fmt.Sprintf("🎬 [Content Merge] Initializing FFmpeg at %s...", ffmpegPath)
+	outputChan <- LogEvent(fmt.Sprintf("🎬 [Content Merge] Initializing FFmpeg at %s...", ffmpegPath))
 
 	// 1. Identify Scenes and Paths
 	basePath := fmt.Sprintf(".druppie/plans/%s/files", planID)
@@ -83,7 +83,7 @@ func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, ou
 		aIn := filepath.Join(basePath, audioFiles[i])
 		muxOut := filepath.Join(basePath, fmt.Sprintf("muxed_scene_%d.mp4", i+1))
 
-		outputChan <- fmt.Sprintf("⚙️ [Content Merge] Muxing Scene %d: [V] %s + [A] %s", i+1, sceneFiles[i], audioFiles[i])
+		outputChan <- LogEvent(fmt.Sprintf("⚙️ [Content Merge] Muxing Scene %d: [V] %s + [A] %s", i+1, sceneFiles[i], audioFiles[i]))
 
 		// ffmpeg -i video.mp4 -i audio.mp3 -c:v copy -c:a aac -map 0:v:0 -map 1:a:0 -shortest -y muxed.mp4
 		cmd := exec.CommandContext(ctx, ffmpegPath,
@@ -97,14 +97,14 @@ func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, ou
 			"-y", muxOut)
 
 		if out, err := cmd.CombinedOutput(); err != nil {
-			outputChan <- fmt.Sprintf("❌ [FFmpeg Error] Muxing failed for scene %d: %v\nOutput: %s", i+1, err, string(out))
+			outputChan <- LogEvent(fmt.Sprintf("❌ [FFmpeg Error] Muxing failed for scene %d: %v\nOutput: %s", i+1, err, string(out)))
 			return fmt.Errorf("FFmpeg muxing failed: %v", err)
 		}
 		muxedFiles = append(muxedFiles, fmt.Sprintf("muxed_scene_%d.mp4", i+1))
 	}
 
 	// 3. Concatenate
-	outputChan <- fmt.Sprintf("🎬 [Content Merge] Concatenating %d muxed segments...", len(muxedFiles))
+	outputChan <- LogEvent(fmt.Sprintf("🎬 [Content Merge] Concatenating %d muxed segments...", len(muxedFiles)))
 	concatFile := filepath.Join(basePath, "concat_list.txt")
 	var sb strings.Builder
 	for _, f := range muxedFiles {
@@ -125,7 +125,7 @@ func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, ou
 		"-y", finalPath)
 
 	if out, err := concatCmd.CombinedOutput(); err != nil {
-		outputChan <- fmt.Sprintf("❌ [FFmpeg Error] Final concatenation failed: %v\nOutput: %s", err, string(out))
+		outputChan <- LogEvent(fmt.Sprintf("❌ [FFmpeg Error] Final concatenation failed: %v\nOutput: %s", err, string(out)))
 		return fmt.Errorf("FFmpeg concatenation failed: %v", err)
 	}
 
@@ -135,17 +135,17 @@ func (e *ContentMergerExecutor) Execute(ctx context.Context, step model.Step, ou
 		_ = os.Remove(filepath.Join(basePath, f))
 	}
 
-	outputChan <- fmt.Sprintf("✅ [Content Merge] Final video rendered: %s", finalFile)
-	outputChan <- "RESULT_VIDEO_FILE=" + finalFile
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Content Merge] Final video rendered: %s", finalFile))
+	outputChan <- ArtifactEvent("video_file", finalFile)
 
 	// 4. Usage Cost
 	computeCost := 0.005 + (float64(totalDuration) * 0.0002)
-	outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=0,0,0,%f", computeCost)
+	outputChan <- UsageEvent(model.TokenUsage{EstimatedCost: computeCost})
 
 	return nil
 }
 
-func (e *ContentMergerExecutor) simulate(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *ContentMergerExecutor) simulate(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	totalDuration := 0
 	var sceneCount int
 	if scriptRaw, ok := step.Params["av_script"]; ok {
@@ -164,7 +164,7 @@ func (e *ContentMergerExecutor) simulate(ctx context.Context, step model.Step, o
 		}
 	}
 
-	outputChan <- fmt.Sprintf("⚙️ [Simulated Merge] Processing %d segments...", sceneCount)
+	outputChan <- LogEvent(fmt.Sprintf("⚙️ [Simulated Merge] Processing %d segments...", sceneCount))
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -172,10 +172,10 @@ func (e *ContentMergerExecutor) simulate(ctx context.Context, step model.Step, o
 	}
 
 	finalFile := "final_production.mp4"
-	outputChan <- fmt.Sprintf("✅ [Simulated Merge] Final video rendered: %s", finalFile)
-	outputChan <- "RESULT_VIDEO_FILE=" + finalFile
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Simulated Merge] Final video rendered: %s", finalFile))
+	outputChan <- ArtifactEvent("video_file", finalFile)
 
 	computeCost := 0.005 + (float64(totalDuration) * 0.0002)
-	outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=0,0,0,%f", computeCost)
+	outputChan <- UsageEvent(model.TokenUsage{EstimatedCost: computeCost})
 	return nil
 }
@@ -0,0 +1,20 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+type planKey struct{}
+
+// WithPlan returns a context carrying the plan the executed step belongs to
+func WithPlan(ctx context.Context, plan *model.ExecutionPlan) context.Context {
+	return context.WithValue(ctx, planKey{}, plan)
+}
+
+// PlanFromContext returns the plan attached with WithPlan, or nil
+func PlanFromContext(ctx context.Context) *model.ExecutionPlan {
+	plan, _ := ctx.Value(planKey{}).(*model.ExecutionPlan)
+	return plan
+}
@@ -33,9 +33,9 @@ func (e *DataScientistExecutor) CanHandle(action string) bool {
 		action == "ds_review"
 }
 
-func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	action := step.Action
-	outputChan <- fmt.Sprintf("DataScientistExecutor: Executing '%s'...", action)
+	outputChan <- LogEvent(fmt.Sprintf("DataScientistExecutor: Executing '%s'...", action))
 
 	// Extract planID
 	planID := ""
@@ -45,7 +45,7 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 		planID = p
 	} else {
 		planID = "unknown_plan"
-		outputChan <- "Warning: plan_id not found in params"
+		outputChan <- LogEvent("Warning: plan_id not found in params")
 	}
 
 	var result string
@@ -120,7 +120,7 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 			}
 			if count > 0 {
 				userPrompt += fileContext.String()
-				outputChan <- fmt.Sprintf("DataScientistExecutor: Loaded %d existing artifacts into context.", count)
+				outputChan <- LogEvent(fmt.Sprintf("DataScientistExecutor: Loaded %d existing artifacts into context.", count))
 			}
 		}
 
@@ -129,7 +129,7 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 		}
 
 		// Debug Logging
-		outputChan <- fmt.Sprintf("LLM Prompt Length: %d chars.", len(userPrompt))
+		outputChan <- LogEvent(fmt.Sprintf("LLM Prompt Length: %d chars.", len(userPrompt)))
 
 		var err error
 		if providerName != "" {
@@ -143,7 +143,7 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 		}
 
 		if err != nil {
-			outputChan <- fmt.Sprintf("LLM generation failed: %v. Using fallback.", err)
+			outputChan <- LogEvent(fmt.Sprintf("LLM generation failed: %v. Using fallback.", err))
 			result = e.getFallbackResult(action)
 		}
 	} else {
@@ -159,7 +159,7 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 
 	filesDir, _ := paths.ResolvePath(".druppie", "plans", planID, "files")
 	if err := os.MkdirAll(filesDir, 0755); err != nil {
-		outputChan <- fmt.Sprintf("Error creating files directory: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error creating files directory: %v", err))
 		return err
 	}
 
@@ -167,15 +167,15 @@ func (e *DataScientistExecutor) Execute(ctx context.Context, step model.Step, ou
 	filePath := filepath.Join(filesDir, fileName)
 
 	if err := os.WriteFile(filePath, []byte(result), 0644); err != nil {
-		outputChan <- fmt.Sprintf("Error writing analysis file: %v", err)
+		outputChan <- LogEvent(fmt.Sprintf("Error writing analysis file: %v", err))
 		return err
 	}
 
-	outputChan <- fmt.Sprintf("Document saved to: %s", filePath)
+	outputChan <- LogEvent(fmt.Sprintf("Document saved to: %s", filePath))
 
 	// Report usage
 	if usage.TotalTokens > 0 || usage.EstimatedCost > 0 {
-		outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+		outputChan <- UsageEvent(usage)
 	}
 
 	return nil
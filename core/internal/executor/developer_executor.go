@@ -17,7 +17,7 @@ func (e *DeveloperExecutor) CanHandle(action string) bool {
 	return action == "create_repo" || action == "modify_code"
 }
 
-func (e *DeveloperExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *DeveloperExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	planID := ""
 	if p, ok := step.Params["plan_id"].(string); ok {
 		planID = p
@@ -79,13 +79,13 @@ func (e *DeveloperExecutor) Execute(ctx context.Context, step model.Step, output
 		}
 		if hasGoFiles && !hasGoMod {
 			fileMap["go.mod"] = "module main\n\ngo 1.20"
-			outputChan <- "[developer] Auto-created 'go.mod' (Defensive Fix)"
+			outputChan <- LogEvent("[developer] Auto-created 'go.mod' (Defensive Fix)")
 		}
 
 		for path, content := range fileMap {
 			strContent, ok := content.(string)
 			if !ok {
-				outputChan <- fmt.Sprintf("[developer] skipping %s: content is not string", path)
+				outputChan <- LogEvent(fmt.Sprintf("[developer] skipping %s: content is not string", path))
 				continue
 			}
 
@@ -111,7 +111,7 @@ func (e *DeveloperExecutor) Execute(ctx context.Context, step model.Step, output
 				return fmt.Errorf("failed to write %s: %w", path, err)
 			}
 
-			outputChan <- fmt.Sprintf("[developer] created file: %s", path)
+			outputChan <- LogEvent(fmt.Sprintf("[developer] created file: %s", path))
 		}
 	}
 
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"errors"
 	"strings"
 
@@ -13,6 +14,7 @@ import (
 // Dispatcher selects the correct executor for a step
 type Dispatcher struct {
 	executors []Executor
+	registry  *registry.Registry
 }
 
 func NewDispatcher(buildEngine builder.BuildEngine, mcpManager *mcp.Manager, llmProvider llm.Provider, reg *registry.Registry) *Dispatcher {
@@ -22,11 +24,13 @@ func NewDispatcher(buildEngine builder.BuildEngine, mcpManager *mcp.Manager, llm
 	}
 
 	return &Dispatcher{
+		registry: reg,
 		executors: []Executor{
 			&MCPExecutor{Manager: mcpManager}, // Check MCP tools first? Or specific executors first?
 			// MCP tools are dynamic, so placing them high allows overriding.
 			// But specific actions "create_repo" etc should probably take precedence if they conflict.
 			// However, "create_repo" is unlikely to be an MCP tool name unless intentional override.
+			&AgentTaskExecutor{LLM: llmProvider, MCPManager: mcpManager, Registry: reg}, // Native tool calling
 
 			&AudioCreatorExecutor{LLM: llmProvider},
 			&VideoCreatorExecutor{LLM: llmProvider},
@@ -60,3 +64,16 @@ func (d *Dispatcher) GetExecutor(action string) (Executor, error) {
 	// Fallback? Or return error
 	return nil, errors.New("no executor found for action: " + action)
 }
+
+// AgentContext applies the LLM settings of the agent executing a step, such as its use of the
+// response cache
+func (d *Dispatcher) AgentContext(ctx context.Context, agentID string) context.Context {
+	if d.registry == nil || agentID == "" {
+		return ctx
+	}
+	agent, err := d.registry.GetAgent(strings.ReplaceAll(agentID, "-", "_"))
+	if err != nil {
+		return ctx
+	}
+	return llm.WithAgent(ctx, agent)
+}
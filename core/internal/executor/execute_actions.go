@@ -42,15 +42,15 @@ func (e *StandardExecutor) CanHandle(action string) bool {
 	return false
 }
 
-func (e *StandardExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *StandardExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	//outputChan <- fmt.Sprintf("StandardExecutor: Executing '%s'...", step.Action)
 	return ExecuteStandardAction(e, e.StdCtx, ctx, step, outputChan)
 }
 
 // ActionGeneric is a fallback (required by ExecuteStandardAction contract if used for fallback),
 // but since CanHandle is now strict, this might not be reached unless forced.
-func (e *StandardExecutor) ActionGeneric(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- fmt.Sprintf("StandardExecutor: Generic placeholder execution for '%s'", step.Action)
+func (e *StandardExecutor) ActionGeneric(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent(fmt.Sprintf("StandardExecutor: Generic placeholder execution for '%s'", step.Action))
 	return nil
 }
 
@@ -63,7 +63,7 @@ type StandardContext struct {
 
 // ExecuteStandardAction attempts to execute an action by looking for a specific method
 // on the executor struct, then falling back to StandardActions, then MCP tools.
-func ExecuteStandardAction(e interface{}, stdCtx *StandardContext, ctx context.Context, step model.Step, outputChan chan<- string) error {
+func ExecuteStandardAction(e interface{}, stdCtx *StandardContext, ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	methodName := "Action" + toCamelCase(step.Action)
 	val := reflect.ValueOf(e)
 
@@ -120,7 +120,7 @@ func ExecuteStandardAction(e interface{}, stdCtx *StandardContext, ctx context.C
 			}
 			for _, c := range res.Content {
 				if c.Type == "text" {
-					outputChan <- c.Text
+					outputChan <- LogEvent(c.Text)
 				}
 			}
 			return nil
@@ -175,7 +175,7 @@ func toCamelCase(s string) string {
 type StandardActions struct{}
 
 // ActionCreateProject initializes a project structure
-func (s *StandardActions) ActionCreateProject(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionCreateProject(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	planID := ""
 	if p, ok := step.Params["plan_id"].(string); ok {
 		planID = p
@@ -183,22 +183,22 @@ func (s *StandardActions) ActionCreateProject(ctx context.Context, step model.St
 		planID = p
 	}
 
-	outputChan <- "Initializing project structure (Generic)..."
+	outputChan <- LogEvent("Initializing project structure (Generic)...")
 	if planID != "" {
-		outputChan <- fmt.Sprintf("Target Plan: %s", planID)
+		outputChan <- LogEvent(fmt.Sprintf("Target Plan: %s", planID))
 	}
 
 	if name, ok := step.Params["name"].(string); ok {
-		outputChan <- fmt.Sprintf("Project Name: %s", name)
+		outputChan <- LogEvent(fmt.Sprintf("Project Name: %s", name))
 	}
 
-	outputChan <- "Project initialized successfully."
+	outputChan <- LogEvent("Project initialized successfully.")
 	return nil
 }
 
 // ActionCoding writes files to the project directory
-func (s *StandardActions) ActionCoding(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Writing Code / Manifests (Generic)..."
+func (s *StandardActions) ActionCoding(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Writing Code / Manifests (Generic)...")
 
 	planID := ""
 	if p, ok := step.Params["plan_id"].(string); ok {
@@ -234,7 +234,7 @@ func (s *StandardActions) ActionCoding(ctx context.Context, step model.Step, out
 	}
 
 	if len(fileMap) == 0 {
-		outputChan <- "Warning: No files provided in 'files' parameter."
+		outputChan <- LogEvent("Warning: No files provided in 'files' parameter.")
 	}
 
 	for path, content := range fileMap {
@@ -260,71 +260,71 @@ func (s *StandardActions) ActionCoding(ctx context.Context, step model.Step, out
 		if err := os.WriteFile(fullPath, []byte(strContent), 0644); err != nil {
 			return fmt.Errorf("failed to write %s: %w", path, err)
 		}
-		outputChan <- fmt.Sprintf("Created file: %s", path)
+		outputChan <- LogEvent(fmt.Sprintf("Created file: %s", path))
 	}
 
 	if desc, ok := step.Params["description"].(string); ok {
-		outputChan <- fmt.Sprintf("Goal: %s", desc)
+		outputChan <- LogEvent(fmt.Sprintf("Goal: %s", desc))
 	}
-	outputChan <- "Coding complete."
+	outputChan <- LogEvent("Coding complete.")
 	return nil
 }
 
-func (s *StandardActions) ActionValidation(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Validating configuration and manifests (Standard)..."
+func (s *StandardActions) ActionValidation(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Validating configuration and manifests (Standard)...")
 	// Return a special error indicating that input is required,
 	// which the engine can catch to update the step status to 'wait_for_input'.
 	return fmt.Errorf("wait_for_input: Please review and validate the configuration manually")
 }
 
-func (s *StandardActions) ActionValidate(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionValidate(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	return s.ActionValidation(ctx, step, outputChan)
 }
 
-func (s *StandardActions) ActionDeployment(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Deploying resources (Standard)..."
-	outputChan <- "Deployment successful."
+func (s *StandardActions) ActionDeployment(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Deploying resources (Standard)...")
+	outputChan <- LogEvent("Deployment successful.")
 	return nil
 }
 
-func (s *StandardActions) ActionDeploy(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionDeploy(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	return s.ActionDeployment(ctx, step, outputChan)
 }
 
-func (s *StandardActions) ActionVerification(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Verifying deployment health (Standard)..."
-	outputChan <- "Probes: OK"
-	outputChan <- "Verification complete."
+func (s *StandardActions) ActionVerification(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Verifying deployment health (Standard)...")
+	outputChan <- LogEvent("Probes: OK")
+	outputChan <- LogEvent("Verification complete.")
 	return nil
 }
 
-func (s *StandardActions) ActionVerify(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionVerify(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	return s.ActionVerification(ctx, step, outputChan)
 }
 
-func (s *StandardActions) ActionEnsureAvailability(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionEnsureAvailability(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	if target, ok := step.Params["target"].(string); ok {
-		outputChan <- fmt.Sprintf("Verifying availability of %s...", target)
+		outputChan <- LogEvent(fmt.Sprintf("Verifying availability of %s...", target))
 	}
-	outputChan <- "Infrastructure availability check passed (Standard)."
+	outputChan <- LogEvent("Infrastructure availability check passed (Standard).")
 	return nil
 }
 
-func (s *StandardActions) ActionCheckBlockStatus(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (s *StandardActions) ActionCheckBlockStatus(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	if block, ok := step.Params["block"].(string); ok {
-		outputChan <- fmt.Sprintf("Checking status of Building Block (Standard): %s", block)
-		outputChan <- fmt.Sprintf("Block %s is RUNNING", block)
+		outputChan <- LogEvent(fmt.Sprintf("Checking status of Building Block (Standard): %s", block))
+		outputChan <- LogEvent(fmt.Sprintf("Block %s is RUNNING", block))
 		return nil
 	}
 	return fmt.Errorf("check-block-status requires 'block' param")
 }
 
-func (s *StandardActions) ActionKubernetes(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Kubernetes placeholder execution"
+func (s *StandardActions) ActionKubernetes(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Kubernetes placeholder execution")
 	return nil
 }
 
-func (s *StandardActions) ActionTerraform(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "Terraform placeholder execution"
+func (s *StandardActions) ActionTerraform(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("Terraform placeholder execution")
 	return nil
 }
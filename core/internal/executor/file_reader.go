@@ -17,8 +17,8 @@ func (e *FileReaderExecutor) CanHandle(action string) bool {
 	return strings.EqualFold(action, "read_file") || strings.EqualFold(action, "analyze_file")
 }
 
-func (e *FileReaderExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- fmt.Sprintf("📂 [File Reader] Processing: %v", step.Params)
+func (e *FileReaderExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent(fmt.Sprintf("📂 [File Reader] Processing: %v", step.Params))
 
 	// Get filename from params
 	fileNameRaw, ok := step.Params["filename"]
@@ -119,8 +119,8 @@ func (e *FileReaderExecutor) Execute(ctx context.Context, step model.Step, outpu
 		content = content[:2000] + "\n... (truncated)"
 	}
 
-	outputChan <- fmt.Sprintf("✅ [File Reader] Read %d bytes.", len(data))
-	outputChan <- fmt.Sprintf("RESULT_FILE_CONTENT=%s", content)
+	outputChan <- LogEvent(fmt.Sprintf("✅ [File Reader] Read %d bytes.", len(data)))
+	outputChan <- OutputEvent("file_content", content)
 
 	return nil
 }
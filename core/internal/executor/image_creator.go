@@ -22,7 +22,7 @@ func (e *ImageCreatorExecutor) CanHandle(action string) bool {
 	return action == "image_creator" || action == "image_generation" || action == "generate_images"
 }
 
-func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	// Extract Scene ID
 	sceneID := fmt.Sprintf("%d", step.ID)
 	if sID, ok := step.Params["scene_id"]; ok {
@@ -33,7 +33,7 @@ func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		planID = p
 	}
 
-	outputChan <- fmt.Sprintf("🎨 [Image Creator] Processing Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🎨 [Image Creator] Processing Scene %s...", sceneID))
 
 	// Extract Prompt
 	prompt := ""
@@ -54,7 +54,7 @@ func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		prompt = "Abstract digital art"
 	}
 
-	outputChan <- fmt.Sprintf("   📝 Logic: Generating image for \"%s\"", prompt)
+	outputChan <- LogEvent(fmt.Sprintf("   📝 Logic: Generating image for \"%s\"", prompt))
 
 	// 1. Try LLM Provider "image_creator"
 	if e.LLM != nil {
@@ -66,14 +66,14 @@ func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 				filename := fmt.Sprintf("image_scene_%s.png", sceneID)
 				if planID != "" {
 					if err := SaveAsset(planID, filename, resp); err == nil {
-						outputChan <- fmt.Sprintf("✅ [Image Creator] Generated via Provider: %s", filename)
-						outputChan <- fmt.Sprintf("RESULT_IMAGE_FILE=%s", filename)
-						outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+						outputChan <- LogEvent(fmt.Sprintf("✅ [Image Creator] Generated via Provider: %s", filename))
+						outputChan <- ArtifactEvent("image_file", filename)
+						outputChan <- UsageEvent(usage)
 						return nil
 					}
-					outputChan <- fmt.Sprintf("⚠️ Failed to save image from provider: %v", err)
+					outputChan <- LogEvent(fmt.Sprintf("⚠️ Failed to save image from provider: %v", err))
 				} else {
-					outputChan <- "⚠️ Plan ID missing, cannot save file."
+					outputChan <- LogEvent("⚠️ Plan ID missing, cannot save file.")
 				}
 			} else {
 				// Log only if error is NOT "provider not found"?
@@ -112,9 +112,9 @@ func (e *ImageCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		}
 	}
 
-	outputChan <- fmt.Sprintf("✅ [Image Creator] Generated (Mock): %s", filename)
-	outputChan <- fmt.Sprintf("RESULT_IMAGE_FILE=%s", filename)
-	outputChan <- "RESULT_TOKEN_USAGE=0,0,0,0.00100"
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Image Creator] Generated (Mock): %s", filename))
+	outputChan <- ArtifactEvent("image_file", filename)
+	outputChan <- UsageEvent(model.TokenUsage{EstimatedCost: 0.001})
 
 	return nil
 }
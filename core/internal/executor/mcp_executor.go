@@ -30,7 +30,7 @@ func (e *MCPExecutor) CanHandle(action string) bool {
 }
 
 // Execute calls the tool via the MCP Manager
-func (e *MCPExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *MCPExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	// Handle generic "tool_usage" action
 	if step.Action == "tool_usage" {
 		toolName, ok := step.Params["tool"].(string)
@@ -43,7 +43,7 @@ func (e *MCPExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 
 		// Update step action to the actual tool
 		step.Action = toolName
-		outputChan <- fmt.Sprintf("[mcp] Unwrapped tool_usage -> %s", toolName)
+		outputChan <- LogEvent(fmt.Sprintf("[mcp] Unwrapped tool_usage -> %s", toolName))
 
 		// Rescope params: prefer "arguments" or "args", otherwise usage remaining params
 		if args, ok := step.Params["arguments"].(map[string]interface{}); ok {
@@ -60,7 +60,7 @@ func (e *MCPExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 	if serverName == "" {
 		serverName = "unknown"
 	}
-	outputChan <- fmt.Sprintf("[mcp] Executing tool '%s' on server '%s'", step.Action, serverName)
+	outputChan <- LogEvent(fmt.Sprintf("[mcp] Executing tool '%s' on server '%s'", step.Action, serverName))
 
 	// Normalize Parameters (AI alias handling)
 	// Flatten 'input' or 'args' if they wrap the actual parameters
@@ -144,23 +144,23 @@ func (e *MCPExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 		switch content.Type {
 		case "text":
 			texts = append(texts, content.Text)
-			outputChan <- content.Text
+			outputChan <- LogEvent(content.Text)
 		case "image":
-			outputChan <- "[Image Content Received]"
+			outputChan <- LogEvent("[Image Content Received]")
 		default:
-			outputChan <- fmt.Sprintf("[%s content]", content.Type)
+			outputChan <- LogEvent(fmt.Sprintf("[%s content]", content.Type))
 		}
 	}
 
 	if len(texts) == 0 {
 		texts = append(texts, "Tool executed successfully (No text content returned).")
-		outputChan <- "Tool executed successfully (No text content returned)."
-		outputChan <- "DEBUG: Ensure the MCP Plugin returns { content: [{ type: 'text', text: '...' }] }"
+		outputChan <- LogEvent("Tool executed successfully (No text content returned).")
+		outputChan <- LogEvent("DEBUG: Ensure the MCP Plugin returns { content: [{ type: 'text', text: '...' }] }")
 	}
 
 	// Capture result for Plan History
 	finalOutput := strings.Join(texts, "\n")
-	outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=%s", finalOutput)
+	outputChan <- OutputEvent(OutputConsole, finalOutput)
 
 	return nil
 }
@@ -25,7 +25,7 @@ func (e *PluginExecutor) CanHandle(action string) bool {
 	return action == "test_plugin" || action == "promote_plugin" || action == "execute_plugin"
 }
 
-func (e *PluginExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *PluginExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	planID := ""
 	if p, ok := step.Params["plan_id"].(string); ok {
 		planID = p
@@ -49,7 +49,7 @@ func (e *PluginExecutor) Execute(ctx context.Context, step model.Step, outputCha
 	}
 }
 
-func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- string) error {
+func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- Event) error {
 	inputFileRaw, _ := step.Params["input_file"].(string)
 	pluginPathRaw, _ := step.Params["plugin_path"].(string)
 	pluginNameRaw, _ := step.Params["plugin_name"].(string)
@@ -76,7 +76,7 @@ func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, pla
 
 		if toolName != "" {
 			// This looks like an MCP call disguised as execute_plugin
-			outputChan <- fmt.Sprintf("[plugin-executor] Redirecting '%s' to MCP Executor...", pluginNameRaw)
+			outputChan <- LogEvent(fmt.Sprintf("[plugin-executor] Redirecting '%s' to MCP Executor...", pluginNameRaw))
 
 			// Try execute
 			res, err := e.MCPManager.ExecuteTool(ctx, toolName, args)
@@ -85,14 +85,14 @@ func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, pla
 			}
 			for _, c := range res.Content {
 				if c.Type == "text" {
-					outputChan <- c.Text
+					outputChan <- LogEvent(c.Text)
 				}
 			}
 			return nil
 		}
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-executor] Analyzing execution request: plugin=%s input=%s", pluginPathRaw, inputFileRaw)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-executor] Analyzing execution request: plugin=%s input=%s", pluginPathRaw, inputFileRaw))
 
 	// Resolve src dir
 	srcDir, err := paths.ResolvePath(".druppie", "plans", planID, "src")
@@ -128,7 +128,7 @@ func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, pla
 	}
 	entryPoint := filepath.Join(srcDir, pluginPathClean)
 
-	outputChan <- fmt.Sprintf("[plugin-executor] Executing %s < %s", entryPoint, inputPath)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-executor] Executing %s < %s", entryPoint, inputPath))
 
 	// Check files
 	if _, err := os.Stat(entryPoint); os.IsNotExist(err) {
@@ -151,17 +151,17 @@ func (e *PluginExecutor) executePlugin(ctx context.Context, step model.Step, pla
 	// Capture output
 	// Since json-rpc output might optionally be used later, we log it
 	out, err := cmd.CombinedOutput()
-	outputChan <- string(out)
+	outputChan <- LogEvent(string(out))
 
 	if err != nil {
 		return fmt.Errorf("plugin execution returned error: %w", err)
 	}
 
-	outputChan <- "[plugin-executor] ✅ Execution successful"
+	outputChan <- LogEvent("[plugin-executor] ✅ Execution successful")
 	return nil
 }
 
-func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- string) error {
+func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- Event) error {
 	buildID, ok := step.Params["build_id"].(string)
 	if !ok || buildID == "" {
 		return fmt.Errorf("missing required parameter 'build_id'")
@@ -173,7 +173,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		return fmt.Errorf("build directory not found: %s", buildDir)
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Testing plugin from build: %s", buildID)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Testing plugin from build: %s", buildID))
 
 	// Check if package.json exists (Node.js plugin)
 	pkgPath := filepath.Join(buildDir, "package.json")
@@ -190,7 +190,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		}
 	}
 
-	outputChan <- "[plugin-converter] ✅ Plugin structure validated"
+	outputChan <- LogEvent("[plugin-converter] ✅ Plugin structure validated")
 
 	// Parse package.json to find entry point
 	pkgData, err := os.ReadFile(pkgPath)
@@ -221,28 +221,28 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		return fmt.Errorf("entry point not found: %s", entryPoint)
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Entry point: %s", entryPoint)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Entry point: %s", entryPoint))
 
 	// Install dependencies if node_modules doesn't exist
 	nodeModulesPath := filepath.Join(buildDir, "node_modules")
 	if _, err := os.Stat(nodeModulesPath); os.IsNotExist(err) {
-		outputChan <- "[plugin-converter] Installing dependencies..."
+		outputChan <- LogEvent("[plugin-converter] Installing dependencies...")
 		installCmd := exec.CommandContext(ctx, "npm", "install", "--production")
 		installCmd.Dir = buildDir
 		if output, err := installCmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("npm install failed: %w\nOutput: %s", err, string(output))
 		}
-		outputChan <- "[plugin-converter] ✅ Dependencies installed"
+		outputChan <- LogEvent("[plugin-converter] ✅ Dependencies installed")
 	}
 
 	// Get test cases
 	testCases, _ := step.Params["test_cases"].([]interface{})
 	if len(testCases) == 0 {
-		outputChan <- "[plugin-converter] ⚠️  No test cases provided - skipping functional tests"
+		outputChan <- LogEvent("[plugin-converter] ⚠️  No test cases provided - skipping functional tests")
 		return nil
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Running %d test case(s)...", len(testCases))
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Running %d test case(s)...", len(testCases)))
 
 	// Start MCP server
 	serverCmd := exec.CommandContext(ctx, "node", entryPointPath)
@@ -279,12 +279,12 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		for scanner.Scan() {
 			line := scanner.Text()
 			if line != "" {
-				outputChan <- fmt.Sprintf("[plugin-server] %s", line)
+				outputChan <- LogEvent(fmt.Sprintf("[plugin-server] %s", line))
 			}
 		}
 	}()
 
-	outputChan <- "[plugin-converter] ✅ MCP server started"
+	outputChan <- LogEvent("[plugin-converter] ✅ MCP server started")
 
 	// Initialize MCP connection
 	initRequest := map[string]interface{}{
@@ -312,7 +312,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		return fmt.Errorf("failed to read initialize response: %w", err)
 	}
 
-	outputChan <- "[plugin-converter] ✅ MCP connection initialized"
+	outputChan <- LogEvent("[plugin-converter] ✅ MCP connection initialized")
 
 	// Send initialized notification
 	initializedNotif := map[string]interface{}{
@@ -330,7 +330,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 	for i, tc := range testCases {
 		testCase, ok := tc.(map[string]interface{})
 		if !ok {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ Invalid test case format", i+1)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ Invalid test case format", i+1))
 			failedTests++
 			continue
 		}
@@ -340,14 +340,14 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		expected, _ := testCase["expected"]
 
 		if toolName == "" {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ Missing 'tool' in test case", i+1)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ Missing 'tool' in test case", i+1))
 			failedTests++
 			continue
 		}
 
-		outputChan <- fmt.Sprintf("[test-%d] Testing tool: %s", i+1, toolName)
+		outputChan <- LogEvent(fmt.Sprintf("[test-%d] Testing tool: %s", i+1, toolName))
 		inputJSON, _ := json.Marshal(input)
-		outputChan <- fmt.Sprintf("[test-%d] Input: %s", i+1, string(inputJSON))
+		outputChan <- LogEvent(fmt.Sprintf("[test-%d] Input: %s", i+1, string(inputJSON)))
 
 		// Call tool
 		callRequest := map[string]interface{}{
@@ -361,7 +361,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		}
 
 		if err := json.NewEncoder(stdin).Encode(callRequest); err != nil {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ Failed to send request: %v", i+1, err)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ Failed to send request: %v", i+1, err))
 			failedTests++
 			continue
 		}
@@ -369,14 +369,14 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		// Read response
 		var response map[string]interface{}
 		if err := decoder.Decode(&response); err != nil {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ Failed to read response: %v", i+1, err)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ Failed to read response: %v", i+1, err))
 			failedTests++
 			continue
 		}
 
 		// Check for errors
 		if errObj, ok := response["error"]; ok {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ Tool returned error: %v", i+1, errObj)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ Tool returned error: %v", i+1, errObj))
 			failedTests++
 			continue
 		}
@@ -384,7 +384,7 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 		// Validate response
 		result, ok := response["result"]
 		if !ok {
-			outputChan <- fmt.Sprintf("[test-%d] ❌ No result in response", i+1)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ No result in response", i+1))
 			failedTests++
 			continue
 		}
@@ -395,32 +395,32 @@ func (e *PluginExecutor) testPlugin(ctx context.Context, step model.Step, planID
 			expectedJSON, _ := json.Marshal(expected)
 
 			if string(resultJSON) == string(expectedJSON) {
-				outputChan <- fmt.Sprintf("[test-%d] ✅ PASS", i+1)
+				outputChan <- LogEvent(fmt.Sprintf("[test-%d] ✅ PASS", i+1))
 				passedTests++
 			} else {
-				outputChan <- fmt.Sprintf("[test-%d] ❌ FAIL - Expected: %s, Got: %s", i+1, string(expectedJSON), string(resultJSON))
+				outputChan <- LogEvent(fmt.Sprintf("[test-%d] ❌ FAIL - Expected: %s, Got: %s", i+1, string(expectedJSON), string(resultJSON)))
 				failedTests++
 			}
 		} else {
 			// No expected value, just check that we got a result
-			outputChan <- fmt.Sprintf("[test-%d] ✅ PASS (result received)", i+1)
+			outputChan <- LogEvent(fmt.Sprintf("[test-%d] ✅ PASS (result received)", i+1))
 			passedTests++
 		}
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Test Results: %d passed, %d failed", passedTests, failedTests)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Test Results: %d passed, %d failed", passedTests, failedTests))
 
 	if failedTests > 0 {
-		outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=Test Results: %d passed, %d failed", passedTests, failedTests)
+		outputChan <- OutputEvent(OutputConsole, fmt.Sprintf("Test Results: %d passed, %d failed", passedTests, failedTests))
 		return fmt.Errorf("plugin tests failed: %d/%d tests failed", failedTests, len(testCases))
 	}
 
-	outputChan <- "RESULT_CONSOLE_OUTPUT=✅ All tests passed!"
+	outputChan <- OutputEvent(OutputConsole, "✅ All tests passed!")
 
 	return nil
 }
 
-func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- string) error {
+func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, planID string, outputChan chan<- Event) error {
 	buildID, ok := step.Params["build_id"].(string)
 	if !ok || buildID == "" {
 		return fmt.Errorf("missing required parameter 'build_id'")
@@ -441,7 +441,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 	pluginName = strings.ReplaceAll(pluginName, " ", "-")
 	pluginName = strings.ReplaceAll(pluginName, "_", "-")
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Promoting plugin: %s", pluginName)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Promoting plugin: %s", pluginName))
 
 	// 1. Locate build directory
 	buildDir, _ := paths.ResolvePath(".druppie", "plans", planID, "builds", buildID)
@@ -455,14 +455,14 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 		return fmt.Errorf("failed to create plugin directory: %w", err)
 	}
 
-	outputChan <- fmt.Sprintf("[plugin-converter] Created plugin directory: %s", pluginDir)
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Created plugin directory: %s", pluginDir))
 
 	// 3. Copy build artifacts to plugin directory
 	if err := copyDir(buildDir, pluginDir); err != nil {
 		return fmt.Errorf("failed to copy plugin files: %w", err)
 	}
 
-	outputChan <- "[plugin-converter] Copied plugin files"
+	outputChan <- LogEvent("[plugin-converter] Copied plugin files")
 
 	// 4. Handle MCP server definition
 	authGroups, _ := step.Params["auth_groups"].([]interface{})
@@ -479,7 +479,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 
 	if _, err := os.Stat(buildMcpPath); err == nil {
 		// Use existing mcp.md from build
-		outputChan <- "[plugin-converter] Found mcp.md in build, using it"
+		outputChan <- LogEvent("[plugin-converter] Found mcp.md in build, using it")
 		mcpBytes, err := os.ReadFile(buildMcpPath)
 		if err != nil {
 			return fmt.Errorf("failed to read mcp.md from build: %w", err)
@@ -491,7 +491,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 		mcpContent = strings.ReplaceAll(mcpContent, fmt.Sprintf("/plans/%s/", planID), "/plugins/")
 	} else {
 		// Generate default MCP definition
-		outputChan <- "[plugin-converter] No mcp.md found, generating default"
+		outputChan <- LogEvent("[plugin-converter] No mcp.md found, generating default")
 		mcpContent = createMCPDefinition(pluginName, description, authGroupsStr)
 	}
 
@@ -500,7 +500,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 	if err := os.WriteFile(mcpPluginPath, []byte(mcpContent), 0644); err != nil {
 		return fmt.Errorf("failed to create MCP definition in plugin dir: %w", err)
 	}
-	outputChan <- "[plugin-converter] Copied MCP definition to plugin directory"
+	outputChan <- LogEvent("[plugin-converter] Copied MCP definition to plugin directory")
 
 	// 5. Create block.yaml for registry
 	block := model.BuildingBlock{
@@ -523,7 +523,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 
 	// 6. Register MCP server dynamically if Manager is available
 	if e.MCPManager != nil {
-		outputChan <- "[plugin-converter] Registering MCP server dynamically..."
+		outputChan <- LogEvent("[plugin-converter] Registering MCP server dynamically...")
 
 		// Parse frontmatter
 		parts := strings.Split(mcpContent, "---")
@@ -537,7 +537,7 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 			}
 
 			if err := yaml.Unmarshal([]byte(parts[1]), &config); err != nil {
-				outputChan <- fmt.Sprintf("[plugin-converter] ⚠️ Failed to parse MCP frontmatter for registration: %v", err)
+				outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] ⚠️ Failed to parse MCP frontmatter for registration: %v", err))
 			} else {
 				// Create ServerConfig
 				serverCfg := mcp.ServerConfig{
@@ -550,18 +550,18 @@ func (e *PluginExecutor) promotePlugin(ctx context.Context, step model.Step, pla
 
 				// Register with Manager
 				if err := e.MCPManager.AddServerConfig(ctx, serverCfg); err != nil {
-					outputChan <- fmt.Sprintf("[plugin-converter] ⚠️ Failed to register MCP server: %v", err)
+					outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] ⚠️ Failed to register MCP server: %v", err))
 				} else {
-					outputChan <- fmt.Sprintf("[plugin-converter] ✅ MCP Server '%s' registered and active", config.Name)
+					outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] ✅ MCP Server '%s' registered and active", config.Name))
 				}
 			}
 		} else {
-			outputChan <- "[plugin-converter] ⚠️ Could not parse MCP definition (missing frontmatter)"
+			outputChan <- LogEvent("[plugin-converter] ⚠️ Could not parse MCP definition (missing frontmatter)")
 		}
 	}
 
-	outputChan <- "[plugin-converter] ✅ Plugin promoted successfully!"
-	outputChan <- fmt.Sprintf("[plugin-converter] Plugin available as MCP server: %s", pluginName)
+	outputChan <- LogEvent("[plugin-converter] ✅ Plugin promoted successfully!")
+	outputChan <- LogEvent(fmt.Sprintf("[plugin-converter] Plugin available as MCP server: %s", pluginName))
 
 	return nil
 }
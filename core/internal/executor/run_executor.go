@@ -31,8 +31,8 @@ func (e *RunExecutor) CanHandle(action string) bool {
 	return action == "run_code"
 }
 
-func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
-	outputChan <- "RunExecutor: Initializing..."
+func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	outputChan <- LogEvent("RunExecutor: Initializing...")
 
 	// Extract params
 	buildID, _ := step.Params["build_id"].(string)
@@ -70,7 +70,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 		// Fallback: Find latest build
 		if !isValid {
 			if buildID != "" {
-				outputChan <- fmt.Sprintf("Warning: buildID '%s' not found or empty. Searching for latest build...", buildID)
+				outputChan <- LogEvent(fmt.Sprintf("Warning: buildID '%s' not found or empty. Searching for latest build...", buildID))
 			}
 			entries, err := os.ReadDir(buildsDir)
 			var latestDir string
@@ -88,7 +88,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 			}
 			if latestDir != "" {
 				artifactPath = filepath.Join(buildsDir, latestDir)
-				outputChan <- fmt.Sprintf("Auto-detected latest build: %s", latestDir)
+				outputChan <- LogEvent(fmt.Sprintf("Auto-detected latest build: %s", latestDir))
 			}
 		}
 
@@ -97,7 +97,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 			srcDir, _ := paths.ResolvePath(".druppie", "plans", planID, "src")
 			if entries, err := os.ReadDir(srcDir); err == nil && len(entries) > 0 {
 				artifactPath = srcDir
-				outputChan <- "No build artifacts found. Falling back to source directory for execution."
+				outputChan <- LogEvent("No build artifacts found. Falling back to source directory for execution.")
 			}
 		}
 	}
@@ -172,7 +172,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 
 	// Local Execution Branch
 	if e.Builder.IsLocal() {
-		outputChan <- fmt.Sprintf("Running command locally in %s: %v", artifactPath, cmd)
+		outputChan <- LogEvent(fmt.Sprintf("Running command locally in %s: %v", artifactPath, cmd))
 		lcmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
 		lcmd.Dir = artifactPath
 
@@ -209,7 +209,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 
 			for scanner.Scan() {
 				line := scanner.Text()
-				outputChan <- line
+				outputChan <- LogEvent(line)
 				if fileWriter != nil {
 					fileWriter.Write([]byte(line + "\n"))
 				}
@@ -227,7 +227,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 		wg.Wait()
 
 		if sb.Len() > 0 {
-			outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=%s", sb.String())
+			outputChan <- OutputEvent(OutputConsole, sb.String())
 		}
 		if err != nil {
 			return fmt.Errorf("local process exited with error: %w", err)
@@ -236,7 +236,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 	}
 
 	// Docker Execution Branch
-	outputChan <- fmt.Sprintf("Running container %s with command: %v", imageRef, cmd)
+	outputChan <- LogEvent(fmt.Sprintf("Running container %s with command: %v", imageRef, cmd))
 
 	// Pull Image
 	reader, err := cli.ImagePull(ctx, imageRef, image.PullOptions{})
@@ -244,7 +244,7 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 		io.Copy(io.Discard, reader)
 		reader.Close()
 	} else {
-		outputChan <- fmt.Sprintf("Warning: Failed to pull image %s (might exist locally): %v", imageRef, err)
+		outputChan <- LogEvent(fmt.Sprintf("Warning: Failed to pull image %s (might exist locally): %v", imageRef, err))
 	}
 
 	// Container Config
@@ -299,11 +299,11 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 			for scanner.Scan() {
 				line := scanner.Text()
 				// Send to UI
-				outputChan <- line
+				outputChan <- LogEvent(line)
 				sb.WriteString(line + "\n")
 			}
 			if sb.Len() > 0 {
-				outputChan <- fmt.Sprintf("RESULT_CONSOLE_OUTPUT=%s", sb.String())
+				outputChan <- OutputEvent(OutputConsole, sb.String())
 			}
 		}()
 	}
@@ -331,6 +331,6 @@ func (e *RunExecutor) Execute(ctx context.Context, step model.Step, outputChan c
 		return fmt.Errorf("container exited with non-zero status: %d", exitCode)
 	}
 
-	outputChan <- "Execution completed."
+	outputChan <- LogEvent("Execution completed.")
 	return nil
 }
@@ -29,9 +29,9 @@ func (e *SceneCreatorExecutor) CanHandle(action string) bool {
 		action == "scene_creator"
 }
 
-func (e *SceneCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *SceneCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 	if step.Action == "expand_loop" {
-		outputChan <- "Loop expansion handling (Metadata Step) - Completed."
+		outputChan <- LogEvent("Loop expansion handling (Metadata Step) - Completed.")
 		return nil
 	}
 
@@ -43,7 +43,7 @@ func (e *SceneCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 
 	// 1. Simulate Audio Generation (TTS)
 	// In a real implementation, this would call the 'ai-text-to-speech' block using the Registry
-	outputChan <- fmt.Sprintf("🗣️ [TTS] Generating Audio for Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🗣️ [TTS] Generating Audio for Scene %s...", sceneID))
 
 	// Simulate work
 	select {
@@ -51,25 +51,25 @@ func (e *SceneCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		return ctx.Err()
 	case <-time.After(1 * time.Second):
 	}
-	outputChan <- fmt.Sprintf("✅ [TTS] Audio generated: %s_audio.mp3", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("✅ [TTS] Audio generated: %s_audio.mp3", sceneID))
 
 	// 2. Simulate ComfyUI Video Generation
 	// In a real implementation, this would call 'ai-video-comfyui' block
-	outputChan <- fmt.Sprintf("🎥 [ComfyUI] Generating Video for Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🎥 [ComfyUI] Generating Video for Scene %s...", sceneID))
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-time.After(3 * time.Second):
 	}
-	outputChan <- fmt.Sprintf("✅ [ComfyUI] Video generated: %s_video.mp4", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("✅ [ComfyUI] Video generated: %s_video.mp4", sceneID))
 
 	// 3. Assemble
 	// In a real implementation, this would call an ffmpeg wrapper
-	outputChan <- fmt.Sprintf("🎬 [FFmpeg] Assembling Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🎬 [FFmpeg] Assembling Scene %s...", sceneID))
 	time.Sleep(500 * time.Millisecond)
-	outputChan <- fmt.Sprintf("✅ [Scene Creator] Scene %s Complete: %s_scene_final.mp4", sceneID, sceneID)
-	outputChan <- fmt.Sprintf("RESULT_VIDEO_FILE=%s_scene_final.mp4", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Scene Creator] Scene %s Complete: %s_scene_final.mp4", sceneID, sceneID))
+	outputChan <- ArtifactEvent("video_file", fmt.Sprintf("%s_scene_final.mp4", sceneID))
 
 	return nil
 }
@@ -2,12 +2,151 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/sjhoeksma/druppie/core/internal/model"
 )
 
-// Executor defines the interface for executing a single step
+// Executor defines the interface for executing a single step.
+// Progress and results are reported as structured events on outputChan.
 type Executor interface {
-	Execute(ctx context.Context, step model.Step, outputChan chan<- string) error
+	Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error
 	CanHandle(action string) bool
 }
+
+// EventType identifies the kind of event an executor emits
+type EventType string
+
+const (
+	EventLog          EventType = "log"           // Human readable progress line
+	EventOutput       EventType = "output"        // Typed key/value output, stored in Step.Outputs
+	EventArtifact     EventType = "artifact"      // File produced by the step, stored in Step.Outputs
+	EventUsage        EventType = "usage"         // Token usage and cost to attribute to the step
+	EventInputRequest EventType = "input_request" // Executor needs user input before the step can complete
+	EventApproval     EventType = "approval"      // Step must be approved by a group before it can complete
+)
+
+// Event is a structured message from an executor
+type Event struct {
+	Type    EventType         `json:"type"`
+	Message string            `json:"message,omitempty"` // Log line or input request prompt
+	Key     string            `json:"key,omitempty"`     // Output or artifact name (snake_case, e.g. "build_id")
+	Value   interface{}       `json:"value,omitempty"`   // Output value (any JSON value) or artifact path
+	Usage   *model.TokenUsage `json:"usage,omitempty"`
+}
+
+// Well known output keys
+const (
+	OutputConsole = "console_output"
+	OutputBuildID = "build_id"
+)
+
+func LogEvent(msg string) Event {
+	return Event{Type: EventLog, Message: msg}
+}
+
+func OutputEvent(key string, value interface{}) Event {
+	return Event{Type: EventOutput, Key: key, Value: value}
+}
+
+func ArtifactEvent(key, path string) Event {
+	return Event{Type: EventArtifact, Key: key, Value: path}
+}
+
+func UsageEvent(usage model.TokenUsage) Event {
+	return Event{Type: EventUsage, Usage: &usage}
+}
+
+func InputRequestEvent(prompt string) Event {
+	return Event{Type: EventInputRequest, Message: prompt}
+}
+
+func ApprovalEvent(group, reason string) Event {
+	return Event{Type: EventApproval, Message: reason, Value: group}
+}
+
+// StepResult accumulates the events of one execution into step outputs
+type StepResult struct {
+	Outputs       map[string]interface{}
+	Usage         *model.TokenUsage
+	Console       []string // console_output values, in order
+	InputRequest  string   // Last input request prompt, if any
+	ApprovalGroup string   // Group that must approve the step, if any
+}
+
+// Apply folds an event into the result; it returns false for log events
+func (r *StepResult) Apply(ev Event) bool {
+	switch ev.Type {
+	case EventOutput, EventArtifact:
+		if ev.Key == "" {
+			return true
+		}
+		if r.Outputs == nil {
+			r.Outputs = make(map[string]interface{})
+		}
+		if ev.Key == OutputConsole {
+			// Several console outputs are concatenated, like the previous result text
+			r.Console = append(r.Console, fmt.Sprint(ev.Value))
+			r.Outputs[OutputConsole] = strings.Join(r.Console, "\n")
+			return true
+		}
+		r.Outputs[ev.Key] = normalizeOutput(ev.Value)
+	case EventUsage:
+		if ev.Usage == nil {
+			return true
+		}
+		if r.Usage == nil {
+			r.Usage = &model.TokenUsage{}
+		}
+		r.Usage.Add(*ev.Usage)
+	case EventInputRequest:
+		r.InputRequest = ev.Message
+	case EventApproval:
+		r.InputRequest = ev.Message
+		r.ApprovalGroup = fmt.Sprint(ev.Value)
+	default:
+		return false
+	}
+	return true
+}
+
+// normalizeOutput converts Go values (structs, typed slices) to their JSON form,
+// so outputs look the same before and after the plan is persisted
+func normalizeOutput(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, float64:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return out
+}
+
+// Summary renders the outputs as the human readable step result
+// (console output first, then "KEY: value" lines for the other outputs).
+func (r *StepResult) Summary() string {
+	var sb strings.Builder
+	for _, line := range r.Console {
+		sb.WriteString(line + "\n")
+	}
+	keys := make([]string, 0, len(r.Outputs))
+	for k := range r.Outputs {
+		if k != OutputConsole {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", strings.ToUpper(k), model.FormatOutputValue(r.Outputs[k])))
+	}
+	return sb.String()
+}
@@ -23,7 +23,7 @@ func (e *VideoCreatorExecutor) CanHandle(action string) bool {
 	return action == "video_creator" || action == "video_generation"
 }
 
-func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- string) error {
+func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
 
 	// Extract Scene ID
 	sceneID := fmt.Sprintf("%d", step.ID)
@@ -37,7 +37,7 @@ func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		planID = p
 	}
 
-	outputChan <- fmt.Sprintf("🎥 [Video Creator] Processing Scene %s...", sceneID)
+	outputChan <- LogEvent(fmt.Sprintf("🎥 [Video Creator] Processing Scene %s...", sceneID))
 
 	// Extract params
 	visual := ""
@@ -66,16 +66,16 @@ func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		imageFile = fmt.Sprintf("%v", f)
 	}
 
-	outputChan <- fmt.Sprintf("   👀 Visual: \"%s\"", visual)
+	outputChan <- LogEvent(fmt.Sprintf("   👀 Visual: \"%s\"", visual))
 	if imageFile != "" {
-		outputChan <- fmt.Sprintf("   🖼️ Starting Image: %s", imageFile)
+		outputChan <- LogEvent(fmt.Sprintf("   🖼️ Starting Image: %s", imageFile))
 	}
-	outputChan <- fmt.Sprintf("   ⏱️ Duration: %s", duration)
+	outputChan <- LogEvent(fmt.Sprintf("   ⏱️ Duration: %s", duration))
 
 	if audioFile != "" {
-		outputChan <- fmt.Sprintf("   🎵 Synced to: %s", audioFile)
+		outputChan <- LogEvent(fmt.Sprintf("   🎵 Synced to: %s", audioFile))
 	} else {
-		outputChan <- "   ⚠️ No Audio ID provided, using default pacing."
+		outputChan <- LogEvent("   ⚠️ No Audio ID provided, using default pacing.")
 	}
 
 	// Try LLM Provider "video_creator"
@@ -87,22 +87,22 @@ func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 				filename := fmt.Sprintf("video_scene_%s.mp4", sceneID)
 				if planID != "" {
 					if err := SaveAsset(planID, filename, resp); err == nil {
-						outputChan <- fmt.Sprintf("✅ [Video Creator] Generated via Provider: %s", filename)
-						outputChan <- fmt.Sprintf("RESULT_VIDEO_FILE=%s", filename)
+						outputChan <- LogEvent(fmt.Sprintf("✅ [Video Creator] Generated via Provider: %s", filename))
+						outputChan <- ArtifactEvent("video_file", filename)
 						if usage.EstimatedCost > 0 || usage.TotalTokens > 0 {
-							outputChan <- fmt.Sprintf("RESULT_TOKEN_USAGE=%d,%d,%d,%.5f", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, usage.EstimatedCost)
+							outputChan <- UsageEvent(usage)
 						}
 						return nil
 					}
-					outputChan <- fmt.Sprintf("⚠️ Failed to save video from provider: %v", err)
+					outputChan <- LogEvent(fmt.Sprintf("⚠️ Failed to save video from provider: %v", err))
 				} else {
-					outputChan <- "⚠️ Plan ID missing, cannot save file."
+					outputChan <- LogEvent("⚠️ Plan ID missing, cannot save file.")
 				}
 			}
 		}
 	}
 
-	outputChan <- "   ⚙️ sending to ai-video-comfyui..."
+	outputChan <- LogEvent("   ⚙️ sending to ai-video-comfyui...")
 	// Simulate Latency (1-5s)
 	delay := time.Duration(1000+rand.Intn(4000)) * time.Millisecond
 	select {
@@ -141,9 +141,9 @@ func (e *VideoCreatorExecutor) Execute(ctx context.Context, step model.Step, out
 		}
 	}
 
-	outputChan <- fmt.Sprintf("✅ [Video Creator] Asset Generated (Mock): %s", filename)
-	outputChan <- fmt.Sprintf("RESULT_VIDEO_FILE=%s", filename)
-	outputChan <- "RESULT_TOKEN_USAGE=0,0,0,0.00100"
+	outputChan <- LogEvent(fmt.Sprintf("✅ [Video Creator] Asset Generated (Mock): %s", filename))
+	outputChan <- ArtifactEvent("video_file", filename)
+	outputChan <- UsageEvent(model.TokenUsage{EstimatedCost: 0.001})
 
 	return nil
 }
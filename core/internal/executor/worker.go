@@ -0,0 +1,287 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// Worker executes queued plan steps with a Dispatcher. Several workers may share a queue,
+// in one process or many; each job is held by one worker at a time.
+type Worker struct {
+	ID         string
+	Queue      store.JobQueue
+	Dispatcher *Dispatcher
+	Plans      store.Store   // Optional, provides the plan to executors (policy checks)
+	Lease      time.Duration // Lease per job, renewed every third of it (default 60s)
+	Poll       time.Duration // Wait between polls of an empty queue (default 1s)
+	Progress   time.Duration // Interval to publish the events of a running job (default 1s)
+
+	// Prepare runs before the first step of a plan is executed (e.g. starting its MCP server)
+	Prepare func(ctx context.Context, planID string) error
+	// Logf reports worker activity, fmt.Printf when nil
+	Logf func(format string, args ...interface{})
+}
+
+func (w *Worker) lease() time.Duration {
+	if w.Lease <= 0 {
+		return 60 * time.Second
+	}
+	return w.Lease
+}
+
+func (w *Worker) progress() time.Duration {
+	if w.Progress <= 0 {
+		return time.Second
+	}
+	return w.Progress
+}
+
+// jobEvents collects the events of a running job
+type jobEvents struct {
+	mu        sync.Mutex
+	events    []Event
+	published int // Events stored in the queue
+}
+
+func (e *jobEvents) add(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, ev)
+}
+
+// unpublished returns all events as JSON when some were not published yet
+func (e *jobEvents) unpublished() (json.RawMessage, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.events) == e.published {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(e.events)
+	if err != nil {
+		return nil, false, err
+	}
+	e.published = len(e.events)
+	return data, true, nil
+}
+
+func (w *Worker) logf(format string, args ...interface{}) {
+	if w.Logf != nil {
+		w.Logf(format, args...)
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
+
+// Run processes jobs until the context is cancelled
+func (w *Worker) Run(ctx context.Context) error {
+	poll := w.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	prepared := make(map[string]bool)
+	for {
+		ran, err := w.RunOnce(ctx, prepared)
+		if err != nil {
+			w.logf("[Worker %s] %v", w.ID, err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// RunOnce leases and executes a single job. It returns false when the queue was empty.
+// prepared remembers the plans Prepare ran for; it may be nil.
+func (w *Worker) RunOnce(ctx context.Context, prepared map[string]bool) (bool, error) {
+	job, err := w.Queue.LeaseJob(w.ID, w.lease())
+	if err != nil || job == nil {
+		return false, err
+	}
+	w.logf("[Worker %s] Executing %s step %d: %s (%s), attempt %d", w.ID, job.PlanID, job.Step.ID, job.Step.Action, job.Step.AgentID, job.Attempts)
+
+	jobCtx, cancel := context.WithCancel(audit.WithChain(ctx, job.PlanID))
+	defer cancel()
+	if w.Prepare != nil && !prepared[job.PlanID] {
+		if err := w.Prepare(jobCtx, job.PlanID); err != nil {
+			w.logf("[Worker %s] Warning: failed to prepare %s: %v", w.ID, job.PlanID, err)
+		} else if prepared != nil {
+			prepared[job.PlanID] = true
+		}
+	}
+
+	// Heartbeat: a lost lease means the job was cancelled or handed to another worker. The
+	// events so far are published in between, for the server to stream.
+	events := &jobEvents{}
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(w.lease() / 3)
+		defer ticker.Stop()
+		progress := time.NewTicker(w.progress())
+		defer progress.Stop()
+		for {
+			var err error
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				err = w.Queue.RenewLease(job.ID, w.ID, w.lease())
+			case <-progress.C:
+				var data json.RawMessage
+				var changed bool
+				if data, changed, err = events.unpublished(); changed && err == nil {
+					err = w.Queue.ReportJobEvents(job.ID, w.ID, data)
+				}
+			}
+			if err != nil {
+				w.logf("[Worker %s] Stopping job %s: %v", w.ID, job.ID, err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	execErr := w.execute(jobCtx, *job, events)
+	cancel()
+	<-heartbeatDone
+
+	events.mu.Lock()
+	data, err := json.Marshal(events.events)
+	events.mu.Unlock()
+	if err != nil {
+		return true, fmt.Errorf("failed to marshal events of job %s: %w", job.ID, err)
+	}
+	errMsg := ""
+	if execErr != nil {
+		errMsg = execErr.Error()
+	}
+	if err := w.Queue.FinishJob(job.ID, w.ID, data, errMsg); err != nil {
+		if errors.Is(err, store.ErrLeaseLost) {
+			// The result is discarded, the server or another worker owns the job now
+			return true, nil
+		}
+		return true, fmt.Errorf("failed to finish job %s: %w", job.ID, err)
+	}
+	return true, nil
+}
+
+// execute runs the step like the server would and collects the events of the executor
+func (w *Worker) execute(ctx context.Context, job model.StepJob, events *jobEvents) error {
+	exec, err := w.Dispatcher.GetExecutor(job.Step.AgentID)
+	if err != nil {
+		exec, err = w.Dispatcher.GetExecutor(job.Step.Action)
+	}
+	if err != nil {
+		return fmt.Errorf("no executor found for agent '%s' or action '%s'", job.Step.AgentID, job.Step.Action)
+	}
+	if w.Plans != nil {
+		if plan, err := w.Plans.GetPlan(job.PlanID); err == nil {
+			ctx = WithPlan(ctx, &plan)
+		}
+	}
+
+	outputChan := make(chan Event)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for ev := range outputChan {
+			events.add(ev)
+		}
+	}()
+	logCtx := llm.WithLogger(w.Dispatcher.AgentContext(ctx, job.Step.AgentID), func(msg string) {
+		select {
+		case outputChan <- LogEvent(msg):
+		case <-ctx.Done():
+		}
+	})
+	execErr := exec.Execute(logCtx, job.Step, outputChan)
+	close(outputChan)
+	<-collected
+	return execErr
+}
+
+// QueuedExecutor hands steps to workers: it queues the step, waits for the job to finish
+// and streams the events the worker publishes to the output channel. A step that still has
+// a job (left by a previous server process) picks it up instead of being queued again.
+// Cancelling the context removes the job, which stops the worker holding it at its next
+// heartbeat.
+type QueuedExecutor struct {
+	Queue  store.JobQueue
+	PlanID string
+	Poll   time.Duration // Interval to check the job (default 500ms)
+}
+
+func (q *QueuedExecutor) CanHandle(action string) bool {
+	return true
+}
+
+func (q *QueuedExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	poll := q.Poll
+	if poll <= 0 {
+		poll = 500 * time.Millisecond
+	}
+	existing, err := q.Queue.FindJob(q.PlanID, step.ID)
+	if err != nil {
+		return err
+	}
+	var id string
+	if existing != nil {
+		id = existing.ID
+		outputChan <- LogEvent(fmt.Sprintf("Step %d picks up job %s (%s)", step.ID, id, existing.Status))
+	} else {
+		if id, err = q.Queue.EnqueueJob(model.StepJob{PlanID: q.PlanID, Step: step}); err != nil {
+			return err
+		}
+		outputChan <- LogEvent(fmt.Sprintf("Step %d queued for a worker", step.ID))
+	}
+	defer q.Queue.RemoveJob(id)
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	attempt, sent := 0, 0 // Lease of the job and its events passed on
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		job, err := q.Queue.GetJob(id)
+		if err != nil {
+			return err
+		}
+		if len(job.Events) > 0 {
+			var events []Event
+			if err := json.Unmarshal(job.Events, &events); err != nil {
+				return fmt.Errorf("corrupt events of job %s: %w", id, err)
+			}
+			if job.Attempts != attempt {
+				attempt, sent = job.Attempts, 0 // Another worker took over and started again
+			}
+			for _, ev := range events[sent:] {
+				outputChan <- ev
+			}
+			sent = len(events)
+		}
+		if !job.Finished() {
+			continue
+		}
+		if job.Error != "" {
+			return errors.New(job.Error)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// countingExecutor counts the executions per step and emits one output
+type countingExecutor struct {
+	mu   sync.Mutex
+	runs map[int]int
+	hold chan struct{} // When set, executions wait for it to close
+}
+
+func (e *countingExecutor) CanHandle(action string) bool {
+	return action == "count"
+}
+
+func (e *countingExecutor) Execute(ctx context.Context, step model.Step, outputChan chan<- Event) error {
+	e.mu.Lock()
+	e.runs[step.ID]++
+	e.mu.Unlock()
+	outputChan <- OutputEvent("step", step.ID)
+	if e.hold != nil {
+		select {
+		case <-e.hold:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func newTestWorker(id string, q store.JobQueue, exec Executor) *Worker {
+	return &Worker{
+		ID:         id,
+		Queue:      q,
+		Dispatcher: &Dispatcher{executors: []Executor{exec}},
+		Lease:      300 * time.Millisecond,
+		Poll:       5 * time.Millisecond,
+		Progress:   5 * time.Millisecond,
+		Logf:       func(string, ...interface{}) {},
+	}
+}
+
+// waitForJobs polls until every job finished
+func waitForJobs(t *testing.T, q store.JobQueue, ids []string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range ids {
+		for {
+			job, err := q.GetJob(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Finished() {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s did not finish: %+v", id, job)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestWorkersExecuteEachJobOnce(t *testing.T) {
+	q := store.NewMemoryQueue()
+	exec := &countingExecutor{runs: make(map[int]int)}
+
+	const jobs = 40
+	var ids []string
+	for i := 1; i <= jobs; i++ {
+		id, err := q.EnqueueJob(model.StepJob{PlanID: "plan-1", Step: model.Step{ID: i, Action: "count"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, id := range []string{"w1", "w2", "w3", "w4"} {
+		go newTestWorker(id, q, exec).Run(ctx)
+	}
+	waitForJobs(t, q, ids)
+
+	for i := 1; i <= jobs; i++ {
+		if n := exec.runs[i]; n != 1 {
+			t.Errorf("step %d executed %d times", i, n)
+		}
+	}
+	for _, id := range ids {
+		job, _ := q.GetJob(id)
+		if job.Status != model.JobDone || job.Error != "" || len(job.Events) == 0 {
+			t.Errorf("job %s: status %s, error %q, %d bytes of events", id, job.Status, job.Error, len(job.Events))
+		}
+	}
+}
+
+func TestExpiredLeaseGoesToAnotherWorker(t *testing.T) {
+	q := store.NewMemoryQueue()
+	exec := &countingExecutor{runs: make(map[int]int)}
+	id, err := q.EnqueueJob(model.StepJob{PlanID: "plan-1", Step: model.Step{ID: 1, Action: "count"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker that leases the job and dies without renewing it
+	if job, err := q.LeaseJob("dead", 20*time.Millisecond); err != nil || job == nil {
+		t.Fatalf("lease: %v %v", job, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newTestWorker("alive", q, exec).Run(ctx)
+	waitForJobs(t, q, []string{id})
+
+	job, _ := q.GetJob(id)
+	if job.Status != model.JobDone || job.WorkerID != "alive" || job.Attempts != 2 {
+		t.Fatalf("expected the job done by the second worker on attempt 2, got %+v", job)
+	}
+	if exec.runs[1] != 1 {
+		t.Fatalf("step executed %d times", exec.runs[1])
+	}
+	if err := q.FinishJob(id, "dead", nil, ""); !errors.Is(err, store.ErrLeaseLost) {
+		t.Fatalf("expected the dead worker to have lost its lease, got %v", err)
+	}
+}
+
+func TestQueuedExecutorStreamsEventsOfRunningJob(t *testing.T) {
+	q := store.NewMemoryQueue()
+	exec := &countingExecutor{runs: make(map[int]int), hold: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newTestWorker("w1", q, exec).Run(ctx)
+
+	out := make(chan Event, 10)
+	done := make(chan error, 1)
+	queued := &QueuedExecutor{Queue: q, PlanID: "plan-1", Poll: 5 * time.Millisecond}
+	go func() {
+		done <- queued.Execute(ctx, model.Step{ID: 7, Action: "count"}, out)
+	}()
+
+	// The output arrives while the step still runs
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-out:
+			if ev.Type != EventOutput {
+				continue
+			}
+		case err := <-done:
+			t.Fatalf("step finished before its output was streamed: %v", err)
+		case <-timeout:
+			t.Fatal("no output streamed")
+		}
+		break
+	}
+	close(exec.hold)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for len(out) > 0 {
+		if ev := <-out; ev.Type == EventOutput {
+			t.Fatalf("output passed on twice: %+v", ev)
+		}
+	}
+}
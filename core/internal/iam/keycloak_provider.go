@@ -0,0 +1,842 @@
+package iam
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// --- Keycloak / OIDC Provider ---
+
+const (
+	jwksMinRefresh = 30 * time.Second // Unknown key IDs trigger a JWKS reload at most this often
+	tokenLeeway    = 30 * time.Second // Allowed clock skew for exp/nbf
+	loginStateTTL  = 10 * time.Minute // Lifetime of a pending authorization code login
+	sessionIdleTTL = 12 * time.Hour   // UI sessions unused this long are dropped
+)
+
+// oidcDiscovery is the subset of /.well-known/openid-configuration we use
+type oidcDiscovery struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	JWKSURI                     string `json:"jwks_uri"`
+	EndSessionEndpoint          string `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+// oidcClaims holds the token claims, including the Keycloak specific role claims
+type oidcClaims struct {
+	Type              string       `json:"typ"` // Keycloak: "Bearer" for access tokens, "ID" for ID tokens
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          oidcAudience `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	Expiry            int64        `json:"exp"`
+	NotBefore         int64        `json:"nbf"`
+	PreferredUsername string       `json:"preferred_username"`
+	Email             string       `json:"email"`
+	Groups            []string     `json:"groups"`
+	RealmAccess       struct {
+		Roles []string `json:"roles"`
+	} `json:"realm_access"`
+	ResourceAccess map[string]struct {
+		Roles []string `json:"roles"`
+	} `json:"resource_access"`
+}
+
+// oidcAudience accepts both the single string and the array form of "aud"
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type pendingLogin struct {
+	verifier string
+	redirect string
+	expires  time.Time
+}
+
+// oidcSession is a browser session created by the UI login, kept in memory.
+// The opaque session token stays valid while the refresh token does and it is in use.
+type oidcSession struct {
+	source   oauth2.TokenSource
+	user     *User
+	lastUsed time.Time
+}
+
+// KeycloakProvider authenticates users against Keycloak (or any OIDC issuer).
+// API clients send the access token as bearer, which is validated against the issuer JWKS.
+// The UI uses the authorization code flow and receives an opaque session token.
+type KeycloakProvider struct {
+	cfg    config.KeycloakConfig
+	issuer string
+	client *http.Client
+
+	mu        sync.RWMutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey // kid -> *rsa.PublicKey or *ecdsa.PublicKey
+	keysAt    time.Time
+	pending   map[string]*pendingLogin // state -> pending auth code login
+	sessions  map[string]*oidcSession  // session token -> session
+}
+
+func NewKeycloakProvider(cfg config.KeycloakConfig) (*KeycloakProvider, error) {
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	if issuer == "" {
+		if cfg.URL == "" || cfg.Realm == "" {
+			return nil, fmt.Errorf("keycloak requires either issuer or url and realm")
+		}
+		issuer = strings.TrimSuffix(cfg.URL, "/") + "/realms/" + cfg.Realm
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("keycloak requires a client_id")
+	}
+
+	// Discovery is lazy so the server still starts when the issuer is temporarily down
+	return &KeycloakProvider{
+		cfg:      cfg,
+		issuer:   issuer,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		keys:     make(map[string]crypto.PublicKey),
+		pending:  make(map[string]*pendingLogin),
+		sessions: make(map[string]*oidcSession),
+	}, nil
+}
+
+// Discover fetches (once) the OpenID configuration of the issuer
+func (p *KeycloakProvider) Discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.RLock()
+	d := p.discovery
+	p.mu.RUnlock()
+	if d != nil {
+		return d, nil
+	}
+
+	var doc oidcDiscovery
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover issuer %s: %w", p.issuer, err)
+	}
+	if doc.JWKSURI == "" || doc.TokenEndpoint == "" {
+		return nil, fmt.Errorf("issuer %s returned an incomplete discovery document", p.issuer)
+	}
+	if doc.Issuer == "" {
+		doc.Issuer = p.issuer
+	}
+
+	p.mu.Lock()
+	p.discovery = &doc
+	p.mu.Unlock()
+	fmt.Printf("[IAM] Discovered OIDC issuer %s\n", doc.Issuer)
+	return &doc, nil
+}
+
+func (p *KeycloakProvider) getJSON(ctx context.Context, u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// refreshKeys reloads the JWKS, unless that was done recently
+func (p *KeycloakProvider) refreshKeys(ctx context.Context) error {
+	p.mu.RLock()
+	recent := time.Since(p.keysAt) < jwksMinRefresh
+	p.mu.RUnlock()
+	if recent {
+		return nil
+	}
+
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *KeycloakProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.RLock()
+	k, ok := p.keys[kid]
+	p.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	// Unknown kid: the issuer may have rotated its keys
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	k, ok = p.keys[kid]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// VerifyToken validates a signed JWT issued by the configured issuer and maps it to a User
+func (p *KeycloakProvider) VerifyToken(ctx context.Context, raw string) (*User, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload")
+	}
+	var claims oidcClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := p.validateClaims(ctx, &claims); err != nil {
+		return nil, err
+	}
+	return p.userFromClaims(&claims), nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	var h hash.Hash
+	var ch crypto.Hash
+	switch alg[2:] {
+	case "256":
+		h, ch = sha256.New(), crypto.SHA256
+	case "384":
+		h, ch = sha512.New384(), crypto.SHA384
+	case "512":
+		h, ch = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(alg, "RS"):
+			if err := rsa.VerifyPKCS1v15(k, ch, digest, sig); err != nil {
+				return fmt.Errorf("invalid token signature")
+			}
+			return nil
+		case strings.HasPrefix(alg, "PS"):
+			if err := rsa.VerifyPSS(k, ch, digest, sig, nil); err != nil {
+				return fmt.Errorf("invalid token signature")
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if strings.HasPrefix(alg, "ES") && len(sig)%2 == 0 {
+			half := len(sig) / 2
+			r := new(big.Int).SetBytes(sig[:half])
+			s := new(big.Int).SetBytes(sig[half:])
+			if !ecdsa.Verify(k, digest, r, s) {
+				return fmt.Errorf("invalid token signature")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("token algorithm %q does not match signing key", alg)
+}
+
+func (p *KeycloakProvider) validateClaims(ctx context.Context, c *oidcClaims) error {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	if c.Issuer != d.Issuer {
+		return fmt.Errorf("token issuer %q does not match %q", c.Issuer, d.Issuer)
+	}
+	now := time.Now()
+	if c.Expiry == 0 || now.After(time.Unix(c.Expiry, 0).Add(tokenLeeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if c.NotBefore != 0 && now.Add(tokenLeeway).Before(time.Unix(c.NotBefore, 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+	// ID and refresh tokens are signed by the realm too, but do not grant access
+	if c.Type != "Bearer" {
+		return fmt.Errorf("token of type %q is not an access token", c.Type)
+	}
+
+	// Keycloak access tokens carry the client in azp, or in aud with an audience mapper
+	if c.AuthorizedParty == p.cfg.ClientID {
+		return nil
+	}
+	for _, aud := range c.Audience {
+		if aud == p.cfg.ClientID {
+			return nil
+		}
+	}
+	return fmt.Errorf("token was not issued for client %s", p.cfg.ClientID)
+}
+
+// userFromClaims maps realm roles, client roles and group membership to User.Groups
+func (p *KeycloakProvider) userFromClaims(c *oidcClaims) *User {
+	seen := make(map[string]bool)
+	var groups []string
+	add := func(g string) {
+		// Keycloak group paths are "/parent/child"; the leaf is the group name
+		g = strings.TrimSuffix(g, "/")
+		if idx := strings.LastIndex(g, "/"); idx != -1 {
+			g = g[idx+1:]
+		}
+		if g != "" && !seen[g] {
+			seen[g] = true
+			groups = append(groups, g)
+		}
+	}
+	for _, r := range c.RealmAccess.Roles {
+		add(r)
+	}
+	if client, ok := c.ResourceAccess[p.cfg.ClientID]; ok {
+		for _, r := range client.Roles {
+			add(r)
+		}
+	}
+	for _, g := range c.Groups {
+		add(g)
+	}
+
+	username := c.PreferredUsername
+	if username == "" {
+		username = c.Subject
+	}
+	return &User{
+		ID:       c.Subject,
+		Username: username,
+		Email:    c.Email,
+		Groups:   groups,
+	}
+}
+
+// oauthConfig builds the oauth2 client configuration, redirectURL may be empty for non browser flows
+func (p *KeycloakProvider) oauthConfig(ctx context.Context, redirectURL string) (*oauth2.Config, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:       d.AuthorizationEndpoint,
+			TokenURL:      d.TokenEndpoint,
+			DeviceAuthURL: d.DeviceAuthorizationEndpoint,
+		},
+	}, nil
+}
+
+func (p *KeycloakProvider) oauthContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.client)
+}
+
+// userFromToken validates the access token of an oauth2 token response
+func (p *KeycloakProvider) userFromToken(ctx context.Context, tok *oauth2.Token) (*User, error) {
+	if tok == nil || tok.AccessToken == "" {
+		return nil, fmt.Errorf("issuer returned no access token")
+	}
+	return p.VerifyToken(ctx, tok.AccessToken)
+}
+
+// PasswordToken performs the resource owner password grant ("Direct Access Grants" in Keycloak)
+func (p *KeycloakProvider) PasswordToken(ctx context.Context, username, password string) (*oauth2.Token, *User, error) {
+	oc, err := p.oauthConfig(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	tok, err := oc.PasswordCredentialsToken(p.oauthContext(ctx), username, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	u, err := p.userFromToken(ctx, tok)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tok, u, nil
+}
+
+// DeviceToken performs the device authorization grant. prompt is called with the URL and code the user must enter.
+func (p *KeycloakProvider) DeviceToken(ctx context.Context, prompt func(verificationURL, userCode string)) (*oauth2.Token, *User, error) {
+	oc, err := p.oauthConfig(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if oc.Endpoint.DeviceAuthURL == "" {
+		return nil, nil, fmt.Errorf("issuer does not support the device authorization grant")
+	}
+	octx := p.oauthContext(ctx)
+	da, err := oc.DeviceAuth(octx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start device login: %w", err)
+	}
+	verification := da.VerificationURIComplete
+	if verification == "" {
+		verification = da.VerificationURI
+	}
+	prompt(verification, da.UserCode)
+
+	tok, err := oc.DeviceAccessToken(octx, da)
+	if err != nil {
+		return nil, nil, fmt.Errorf("device login failed: %w", err)
+	}
+	u, err := p.userFromToken(ctx, tok)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tok, u, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token set
+func (p *KeycloakProvider) RefreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, *User, error) {
+	oc, err := p.oauthConfig(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	tok, err := oc.TokenSource(p.oauthContext(ctx), &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	u, err := p.userFromToken(ctx, tok)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tok, u, nil
+}
+
+// RevokeToken ends the issuer session belonging to the refresh token (best effort)
+func (p *KeycloakProvider) RevokeToken(ctx context.Context, refreshToken string) error {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	if d.EndSessionEndpoint == "" || refreshToken == "" {
+		return nil
+	}
+	form := url.Values{"client_id": {p.cfg.ClientID}, "refresh_token": {refreshToken}}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.EndSessionEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("logout at issuer failed: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Login performs the password grant and opens a server side session (used by the UI login form)
+func (p *KeycloakProvider) Login(username, password string) (string, *User, error) {
+	ctx := context.Background()
+	tok, u, err := p.PasswordToken(ctx, username, password)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.startSession(ctx, tok, u)
+}
+
+func (p *KeycloakProvider) startSession(ctx context.Context, tok *oauth2.Token, u *User) (string, *User, error) {
+	oc, err := p.oauthConfig(ctx, "")
+	if err != nil {
+		return "", nil, err
+	}
+	token := generateToken()
+	now := time.Now()
+	p.mu.Lock()
+	// Drop the sessions that were abandoned without logout
+	for t, s := range p.sessions {
+		if now.Sub(s.lastUsed) > sessionIdleTTL {
+			delete(p.sessions, t)
+		}
+	}
+	p.sessions[token] = &oidcSession{
+		// Background context: the source outlives the request that created it
+		source:   oc.TokenSource(p.oauthContext(context.Background()), tok),
+		user:     u,
+		lastUsed: now,
+	}
+	p.mu.Unlock()
+	return token, u, nil
+}
+
+// Logout removes a session token; a JWT bearer has no server side state
+func (p *KeycloakProvider) Logout(token string) error {
+	p.mu.Lock()
+	s, ok := p.sessions[token]
+	delete(p.sessions, token)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if tok, err := s.source.Token(); err == nil {
+		return p.RevokeToken(context.Background(), tok.RefreshToken)
+	}
+	return nil
+}
+
+// GetUserByToken resolves a session token or validates a JWT bearer
+func (p *KeycloakProvider) GetUserByToken(token string) (*User, bool) {
+	ctx := context.Background()
+
+	p.mu.Lock()
+	s, ok := p.sessions[token]
+	if ok && time.Since(s.lastUsed) > sessionIdleTTL {
+		delete(p.sessions, token)
+		p.mu.Unlock()
+		return nil, false
+	}
+	p.mu.Unlock()
+	if ok {
+		// The source refreshes the access token when it expired; roles may have changed
+		tok, err := s.source.Token()
+		if err != nil {
+			p.mu.Lock()
+			delete(p.sessions, token)
+			p.mu.Unlock()
+			return nil, false
+		}
+		u, err := p.userFromToken(ctx, tok)
+		if err != nil {
+			return nil, false
+		}
+		p.mu.Lock()
+		s.user = u
+		s.lastUsed = time.Now()
+		p.mu.Unlock()
+		return u, true
+	}
+
+	if strings.Count(token, ".") != 2 {
+		return nil, false
+	}
+	u, err := p.VerifyToken(ctx, token)
+	if err != nil {
+		fmt.Printf("[IAM] Rejected bearer token: %v\n", err)
+		return nil, false
+	}
+	return u, true
+}
+
+func (p *KeycloakProvider) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/iam/login") || strings.HasSuffix(r.URL.Path, "/iam/callback") || strings.HasSuffix(r.URL.Path, "/v1/health") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			auth := r.Header.Get("Authorization")
+			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+				http.Error(w, "Unauthorized: Login required", http.StatusUnauthorized)
+				return
+			}
+
+			if u, ok := p.GetUserByToken(strings.TrimPrefix(auth, "Bearer ")); ok {
+				ctx := context.WithValue(r.Context(), userContextKey, u)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+		})
+	}
+}
+
+func (p *KeycloakProvider) GetUser(r *http.Request) (*User, error) {
+	u, ok := r.Context().Value(userContextKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("unauthorized")
+	}
+	return u, nil
+}
+
+func (p *KeycloakProvider) RegisterRoutes(r chi.Router) {
+	r.Route("/iam", func(r chi.Router) {
+		r.Get("/login", p.handleAuthorize)
+		r.Post("/login", p.handleLogin)
+		r.Get("/callback", p.handleCallback)
+		r.Post("/logout", p.handleLogout)
+		r.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+			u, err := p.GetUser(r)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		})
+	})
+}
+
+// Handlers
+
+// handleAuthorize starts the authorization code flow (with PKCE) and redirects to the issuer
+func (p *KeycloakProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	oc, err := p.oauthConfig(r.Context(), p.redirectURL(r))
+	if err != nil {
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state := generateToken()
+	verifier := oauth2.GenerateVerifier()
+	now := time.Now()
+	p.mu.Lock()
+	for s, pl := range p.pending {
+		if now.After(pl.expires) {
+			delete(p.pending, s)
+		}
+	}
+	p.pending[state] = &pendingLogin{
+		verifier: verifier,
+		redirect: safeRedirect(r.URL.Query().Get("redirect")),
+		expires:  now.Add(loginStateTTL),
+	}
+	p.mu.Unlock()
+
+	http.Redirect(w, r, oc.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// handleCallback completes the authorization code flow and hands the session token to the UI
+func (p *KeycloakProvider) handleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "Login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	state := q.Get("state")
+	p.mu.Lock()
+	pl, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || time.Now().After(pl.expires) {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	oc, err := p.oauthConfig(ctx, p.redirectURL(r))
+	if err != nil {
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	tok, err := oc.Exchange(p.oauthContext(ctx), q.Get("code"), oauth2.VerifierOption(pl.verifier))
+	if err != nil {
+		fmt.Printf("[IAM] Code exchange failed: %v\n", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	u, err := p.userFromToken(ctx, tok)
+	if err != nil {
+		fmt.Printf("[IAM] Rejected token from code exchange: %v\n", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	token, u, err := p.startSession(ctx, tok, u)
+	if err != nil {
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+
+	userJSON, _ := json.Marshal(u)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	callbackPage.Execute(w, map[string]string{
+		"Token":    token,
+		"User":     string(userJSON),
+		"Redirect": pl.redirect,
+	})
+}
+
+// callbackPage stores the session like the UI login form does and returns to the UI
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html><head><title>Druppie Login</title></head><body>
+<script>
+localStorage.setItem('druppie_token', {{.Token}});
+localStorage.setItem('druppie_user', {{.User}});
+window.location.replace({{.Redirect}});
+</script>
+</body></html>`))
+
+func (p *KeycloakProvider) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	token, user, err := p.Login(req.Username, req.Password)
+	if err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	resp := LoginResponse{Token: token, User: *user}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (p *KeycloakProvider) handleLogout(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if auth != "" && strings.HasPrefix(auth, "Bearer ") {
+		_ = p.Logout(strings.TrimPrefix(auth, "Bearer "))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// redirectURL returns the configured callback or derives it from the login request
+func (p *KeycloakProvider) redirectURL(r *http.Request) string {
+	if p.cfg.RedirectURL != "" {
+		return p.cfg.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if fwd := r.Header.Get("X-Forwarded-Proto"); fwd != "" {
+		scheme = fwd
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/login")
+	path = strings.TrimSuffix(path, "/callback")
+	return scheme + "://" + r.Host + path + "/callback"
+}
+
+// safeRedirect only allows local paths to prevent open redirects
+func safeRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/ui/"
+	}
+	return target
+}
@@ -0,0 +1,314 @@
+package iam
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sjhoeksma/druppie/core/internal/config"
+)
+
+const testClientID = "druppie-ui"
+
+// fakeIssuer is an OIDC issuer with discovery, JWKS, token and logout endpoints. It knows
+// one user, "alice" with password "secret".
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	codes     map[string]string // Authorization code -> PKCE challenge
+	loggedOut []string          // Refresh tokens ended at the logout endpoint
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIssuer{key: key, codes: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/auth",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+			"end_session_endpoint":   f.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", f.handleToken)
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.loggedOut = append(f.loggedOut, r.FormValue("refresh_token"))
+		f.mu.Unlock()
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.FormValue("grant_type") {
+	case "password":
+		if r.FormValue("username") != "alice" || r.FormValue("password") != "secret" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+	case "authorization_code":
+		challenge, ok := f.codes[r.FormValue("code")]
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		delete(f.codes, r.FormValue("code"))
+	case "refresh_token":
+	default:
+		http.Error(w, `{"error": "unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  f.token(nil, f.claims(time.Hour)),
+		"refresh_token": "refresh-alice",
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	})
+}
+
+// claims of alice, valid for ttl
+func (f *fakeIssuer) claims(ttl time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"typ":                "Bearer",
+		"iss":                f.URL,
+		"sub":                "user-alice",
+		"azp":                testClientID,
+		"exp":                time.Now().Add(ttl).Unix(),
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"realm_access":       map[string]interface{}{"roles": []string{"developer"}},
+		"resource_access":    map[string]interface{}{testClientID: map[string]interface{}{"roles": []string{"approver"}}},
+		"groups":             []string{"/org/compliance", "/org/developer"},
+	}
+}
+
+// token signs claims with RS256, with the key of the issuer when key is nil
+func (f *fakeIssuer) token(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	if key == nil {
+		key = f.key
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key-1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestKeycloak(t *testing.T, issuer *fakeIssuer) *KeycloakProvider {
+	p, err := NewKeycloakProvider(config.KeycloakConfig{Issuer: issuer.URL, ClientID: testClientID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestKeycloakVerifyToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	p := newTestKeycloak(t, issuer)
+
+	u, ok := p.GetUserByToken(issuer.token(nil, issuer.claims(time.Hour)))
+	if !ok {
+		t.Fatal("valid token rejected")
+	}
+	if u.ID != "user-alice" || u.Username != "alice" || u.Email != "alice@example.com" {
+		t.Errorf("unexpected user %+v", u)
+	}
+	if got := strings.Join(u.Groups, ","); got != "developer,approver,compliance" {
+		t.Errorf("expected realm roles, client roles and group names, got %s", got)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := strings.Split(issuer.token(nil, issuer.claims(time.Hour)), ".")
+	unsigned[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"key-1"}`))
+	unsigned[2] = ""
+	idToken := with(with(issuer.claims(time.Hour), "typ", "ID"), "aud", testClientID)
+	rejected := map[string]string{
+		"ID":            issuer.token(nil, idToken),
+		"refresh":       issuer.token(nil, with(issuer.claims(time.Hour), "typ", "Refresh")),
+		"untyped":       issuer.token(nil, with(issuer.claims(time.Hour), "typ", nil)),
+		"expired":       issuer.token(nil, issuer.claims(-time.Hour)),
+		"forged":        issuer.token(other, issuer.claims(time.Hour)),
+		"other issuer":  issuer.token(nil, with(issuer.claims(time.Hour), "iss", "https://evil.example.com")),
+		"other client":  issuer.token(nil, with(issuer.claims(time.Hour), "azp", "someone-else")),
+		"not yet valid": issuer.token(nil, with(issuer.claims(time.Hour), "nbf", time.Now().Add(time.Hour).Unix())),
+		"not a JWT":     "abc.def",
+		"session token": generateToken(),
+		"unsigned":      strings.Join(unsigned, "."),
+	}
+	for name, token := range rejected {
+		if _, ok := p.GetUserByToken(token); ok {
+			t.Errorf("%s token accepted", name)
+		}
+	}
+
+	// An audience mapper puts the client in aud instead of azp
+	audience := with(issuer.claims(time.Hour), "azp", "")
+	audience["aud"] = []string{"account", testClientID}
+	if _, err := p.VerifyToken(t.Context(), issuer.token(nil, audience)); err != nil {
+		t.Errorf("access token with the client in aud rejected: %v", err)
+	}
+}
+
+func with(claims map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	claims[key] = value
+	return claims
+}
+
+// The UI login: authorization code flow with PKCE, the session token, logout
+func TestKeycloakLoginFlow(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	p := newTestKeycloak(t, issuer)
+	r := chi.NewRouter()
+	r.Use(p.Middleware())
+	p.RegisterRoutes(r)
+	api := httptest.NewServer(r)
+	defer api.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(api.URL + "/iam/login?redirect=/ui/plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	auth, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || !strings.HasPrefix(auth.String(), issuer.URL+"/auth") {
+		t.Fatalf("expected a redirect to the issuer, got %d %s", resp.StatusCode, auth)
+	}
+	q := auth.Query()
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != api.URL+"/iam/callback" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request %s", auth)
+	}
+
+	// The issuer authenticated the user and sends the browser back with a code
+	issuer.mu.Lock()
+	issuer.codes["code-1"] = q.Get("code_challenge")
+	issuer.mu.Unlock()
+	resp, err = client.Get(api.URL + "/iam/callback?code=code-1&state=" + url.QueryEscape(q.Get("state")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := new(strings.Builder)
+	_, _ = io.Copy(page, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(page.String(), "/ui/plans") {
+		t.Fatalf("callback failed: %d %s", resp.StatusCode, page)
+	}
+	var session string
+	for token := range p.sessions {
+		session = token
+	}
+	if session == "" || !strings.Contains(page.String(), session) {
+		t.Fatalf("expected the session token in the callback page:\n%s", page)
+	}
+
+	// A state is used once
+	resp, err = client.Get(api.URL + "/iam/callback?code=code-1&state=" + url.QueryEscape(q.Get("state")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("replayed callback: expected 400, got %d", resp.StatusCode)
+	}
+
+	me := func(token string) int {
+		req, _ := http.NewRequest(http.MethodGet, api.URL+"/iam/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := me(session); code != http.StatusOK {
+		t.Fatalf("session token: expected 200, got %d", code)
+	}
+	if code := me(issuer.token(nil, issuer.claims(time.Hour))); code != http.StatusOK {
+		t.Fatalf("bearer token: expected 200, got %d", code)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, api.URL+"/iam/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+session)
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if code := me(session); code != http.StatusUnauthorized {
+		t.Errorf("session after logout: expected 401, got %d", code)
+	}
+	if len(issuer.loggedOut) != 1 || issuer.loggedOut[0] != "refresh-alice" {
+		t.Errorf("expected the issuer session to be ended, got %v", issuer.loggedOut)
+	}
+}
+
+func TestKeycloakPasswordLoginAndIdleSessions(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	p := newTestKeycloak(t, issuer)
+
+	if _, _, err := p.Login("alice", "wrong"); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	token, u, err := p.Login("alice", "secret")
+	if err != nil || u.Username != "alice" {
+		t.Fatalf("login: %v %+v", err, u)
+	}
+	if _, ok := p.GetUserByToken(token); !ok {
+		t.Fatal("session token rejected")
+	}
+
+	// An abandoned session expires, and is dropped by the next login
+	p.sessions[token].lastUsed = time.Now().Add(-sessionIdleTTL - time.Minute)
+	other, _, err := p.Login("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.sessions[token]; ok || len(p.sessions) != 1 {
+		t.Errorf("expected only the new session, got %d", len(p.sessions))
+	}
+	p.sessions[other].lastUsed = time.Now().Add(-sessionIdleTTL - time.Minute)
+	if _, ok := p.GetUserByToken(other); ok {
+		t.Error("idle session accepted")
+	}
+}
@@ -60,12 +60,11 @@ type contextKey string
 
 const userContextKey contextKey = "iam_user"
 
-// NewProvider creates the IAM provider
 // NewProvider creates the IAM provider
 func NewProvider(cfg config.IAMConfig, baseDir string) (Provider, error) {
 	switch strings.ToLower(cfg.Provider) {
 	case "keycloak":
-		return NewKeycloakProvider(cfg.Keycloak), nil
+		return NewKeycloakProvider(cfg.Keycloak)
 	case "local":
 		return NewLocalProvider(baseDir)
 	case "demo":
@@ -592,36 +591,3 @@ func generateToken() string {
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
-
-// --- Keycloak Stub ---
-
-type KeycloakProvider struct {
-	cfg config.KeycloakConfig
-}
-
-func NewKeycloakProvider(cfg config.KeycloakConfig) *KeycloakProvider {
-	return &KeycloakProvider{cfg: cfg}
-}
-
-func (p *KeycloakProvider) Middleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Mock Keycloak User
-			ctx := context.WithValue(r.Context(), userContextKey, &User{
-				ID:       "keycloak-user",
-				Username: "kcuser",
-				Email:    "user@keycloak.local",
-				Groups:   []string{"user"},
-			})
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
-}
-
-func (p *KeycloakProvider) GetUser(r *http.Request) (*User, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-func (p *KeycloakProvider) RegisterRoutes(r chi.Router) {
-	// No local routes
-}
@@ -44,5 +44,27 @@ func ClearClientToken() error {
 	if err != nil {
 		return err
 	}
+	_ = os.Remove(path + "_refresh")
 	return os.Remove(path)
 }
+
+// SaveClientRefreshToken stores the OIDC refresh token next to the access token (~/.druppie/token_refresh)
+func SaveClientRefreshToken(token string) error {
+	path, err := getClientTokenPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+"_refresh", []byte(token), 0600)
+}
+
+func LoadClientRefreshToken() (string, error) {
+	path, err := getClientTokenPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path + "_refresh")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
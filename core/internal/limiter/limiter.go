@@ -0,0 +1,111 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter hands out execution slots by key (e.g. "steps", "plan:<id>", "executor:image_creator").
+// A caller asks for all the slots it needs at once and gets all of them or waits, so nobody
+// holds one slot while waiting for another. Waiters are served in arrival order, skipping
+// those whose slots are still taken.
+type Limiter struct {
+	mu      sync.Mutex
+	limit   func(key string) int // Slots of a key, 0 or less is unlimited
+	used    map[string]int
+	waiters []*waiter
+}
+
+type waiter struct {
+	keys  []string
+	ready chan struct{}
+}
+
+// New returns a limiter with the slot counts given by limit
+func New(limit func(key string) int) *Limiter {
+	return &Limiter{limit: limit, used: make(map[string]int)}
+}
+
+// fits reports whether all keys have a free slot; the caller holds the lock
+func (l *Limiter) fits(keys []string) bool {
+	for _, k := range keys {
+		if n := l.limit(k); n > 0 && l.used[k] >= n {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Limiter) take(keys []string) {
+	for _, k := range keys {
+		l.used[k]++
+	}
+}
+
+// TryAcquire takes the slots of all keys if they are free
+func (l *Limiter) TryAcquire(keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.fits(keys) {
+		return false
+	}
+	l.take(keys)
+	return true
+}
+
+// Acquire takes the slots of all keys, waiting until they are free or the context is done
+func (l *Limiter) Acquire(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	if l.fits(keys) {
+		l.take(keys)
+		l.mu.Unlock()
+		return nil
+	}
+	w := &waiter{keys: keys, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, other := range l.waiters {
+			if other == w {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// Granted while giving up: hand the slots back
+		l.release(keys)
+		return ctx.Err()
+	}
+}
+
+// Release returns the slots taken by Acquire or TryAcquire
+func (l *Limiter) Release(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(keys)
+}
+
+func (l *Limiter) release(keys []string) {
+	for _, k := range keys {
+		if l.used[k] <= 1 {
+			delete(l.used, k)
+		} else {
+			l.used[k]--
+		}
+	}
+	remaining := l.waiters[:0]
+	for _, w := range l.waiters {
+		if l.fits(w.keys) {
+			l.take(w.keys)
+			close(w.ready)
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	l.waiters = remaining
+}
@@ -0,0 +1,282 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// --- Anthropic Messages API Provider ---
+
+// AnthropicProvider talks to the Anthropic Messages API, or a compatible gateway
+type AnthropicProvider struct {
+	Model                   string
+	BaseURL                 string
+	APIKey                  string
+	Version                 string // anthropic-version header
+	MaxTokens               int    // Required by the API
+	Headers                 map[string]string
+	PricePerPromptToken     float64
+	PricePerCompletionToken float64
+}
+
+const (
+	DefaultAnthropicURL       = "https://api.anthropic.com/v1"
+	DefaultAnthropicVersion   = "2023-06-01"
+	DefaultAnthropicMaxTokens = 4096
+)
+
+type anthropicContent struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text,omitempty"`
+	ID        string                 `json:"id,omitempty"`          // tool_use
+	Name      string                 `json:"name,omitempty"`        // tool_use
+	Input     map[string]interface{} `json:"input,omitempty"`       // tool_use
+	ToolUseID string                 `json:"tool_use_id,omitempty"` // tool_result
+	Content   string                 `json:"content,omitempty"`     // tool_result
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) tokenUsage() model.TokenUsage {
+	return model.TokenUsage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// payload builds a Messages API request. The system prompt is a top level field and tool
+// results are user turns with tool_result blocks.
+func (p *AnthropicProvider) payload(messages []Message, tools []ToolSpec, stream bool) map[string]interface{} {
+	var system []string
+	var msgs []anthropicMessage
+	add := func(role string, block anthropicContent) {
+		// Consecutive blocks of the same role form one turn
+		if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+			msgs[n-1].Content = append(msgs[n-1].Content, block)
+			return
+		}
+		msgs = append(msgs, anthropicMessage{Role: role, Content: []anthropicContent{block}})
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			system = append(system, msg.Content)
+		case RoleAssistant:
+			if msg.Content != "" {
+				add("assistant", anthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := call.Arguments
+				if input == nil {
+					input = map[string]interface{}{}
+				}
+				add("assistant", anthropicContent{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+		case RoleTool:
+			add("user", anthropicContent{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			add("user", anthropicContent{Type: "text", Text: msg.Content})
+		}
+	}
+
+	maxTokens := p.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultAnthropicMaxTokens
+	}
+	payload := map[string]interface{}{
+		"model":      p.Model,
+		"max_tokens": maxTokens,
+		"messages":   msgs,
+	}
+	if len(system) > 0 {
+		payload["system"] = strings.Join(system, "\n\n")
+	}
+	if stream {
+		payload["stream"] = true
+	}
+	if len(tools) > 0 {
+		defs := make([]map[string]interface{}, 0, len(tools))
+		for _, t := range tools {
+			defs = append(defs, map[string]interface{}{
+				"name":         t.Name,
+				"description":  t.Description,
+				"input_schema": toolParameters(t.Parameters),
+			})
+		}
+		payload["tools"] = defs
+	}
+	return payload
+}
+
+// post sends a Messages API request and returns the response for a 200 status
+func (p *AnthropicProvider) post(ctx context.Context, payload map[string]interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(p.BaseURL, "/")+"/messages", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	version := p.Version
+	if version == "" {
+		version = DefaultAnthropicVersion
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", version)
+	if p.APIKey != "" {
+		req.Header.Set("x-api-key", p.APIKey)
+	}
+	for k, v := range expandHeaders(p.Headers) {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, statusError("anthropic", resp.StatusCode, bodyBytes)
+	}
+	return resp, nil
+}
+
+func (p *AnthropicProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	resp, err := p.post(ctx, p.payload(messages, tools, false))
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Content []anthropicContent `json:"content"`
+		Usage   anthropicUsage     `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("failed to decode anthropic response: %w", err)
+	}
+
+	reply := Message{Role: RoleAssistant}
+	var sb strings.Builder
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			sb.WriteString(block.Text)
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: args})
+		}
+	}
+	reply.Content = sb.String()
+
+	usage := result.Usage.tokenUsage()
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return reply, usage, nil
+}
+
+func (p *AnthropicProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	reply, usage, err := p.GenerateWithTools(ctx, promptMessages(prompt, systemPrompt), nil)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	if reply.Content == "" {
+		return "", model.TokenUsage{}, fmt.Errorf("no content from anthropic")
+	}
+	return cleanResponse(reply.Content), usage, nil
+}
+
+// GenerateStream reads the Messages API event stream (message_start, content_block_delta,
+// message_delta with the final usage, message_stop)
+func (p *AnthropicProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	resp, err := p.post(ctx, p.payload(promptMessages(prompt, systemPrompt), nil, true))
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	var usage anthropicUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return "", model.TokenUsage{}, fmt.Errorf("failed to decode anthropic stream: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			usage = ev.Message.Usage
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				sb.WriteString(ev.Delta.Text)
+				onChunk(ev.Delta.Text)
+			}
+		case "message_delta":
+			if ev.Usage != nil {
+				usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "error":
+			msg := "unknown error"
+			if ev.Error != nil {
+				msg = ev.Error.Message
+			}
+			return "", model.TokenUsage{}, fmt.Errorf("anthropic stream error: %s", msg)
+		}
+		if ev.Type == "message_stop" {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("anthropic stream interrupted: %w", err)
+	}
+	if sb.Len() == 0 {
+		return "", model.TokenUsage{}, fmt.Errorf("no content from anthropic")
+	}
+
+	tu := usage.tokenUsage()
+	tu.EstimatedCost = tokenCost(tu, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return cleanResponse(sb.String()), tu, nil
+}
+
+func (p *AnthropicProvider) Close() error {
+	return nil
+}
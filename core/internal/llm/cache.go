@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// DefaultCacheEntries is the number of responses a ResponseCache keeps in memory by default
+const DefaultCacheEntries = 1000
+
+// ResponseCache is a content-addressed cache of LLM responses, keyed by the model, the system
+// prompt and the prompt. An in-memory LRU sits in front of JSON files under dir, so responses
+// survive restarts and are shared by the processes using the same .druppie directory.
+type ResponseCache struct {
+	dir        string        // Empty keeps responses in memory only
+	maxEntries int           // In-memory entries
+	ttl        time.Duration // Maximum age of a response, 0 never expires
+
+	mu    sync.Mutex
+	lru   *list.List // Most recently used first
+	items map[string]*list.Element
+}
+
+// cacheEntry is a cached response with the usage of the call that produced it
+type cacheEntry struct {
+	Key      string           `json:"key"`
+	Model    string           `json:"model"`
+	Response string           `json:"response"`
+	Usage    model.TokenUsage `json:"usage"`
+	Created  time.Time        `json:"created"`
+}
+
+// NewResponseCache returns a cache persisting under dir, or in memory only when dir is empty
+func NewResponseCache(dir string, maxEntries int, ttl time.Duration) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheEntries
+	}
+	return &ResponseCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// cacheKey addresses the response of a model to a system prompt and prompt
+func cacheKey(modelID, systemPrompt, prompt string) string {
+	h := sha256.New()
+	for _, part := range []string{modelID, systemPrompt, prompt} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *ResponseCache) expired(e cacheEntry) bool {
+	return c.ttl > 0 && time.Since(e.Created) > c.ttl
+}
+
+// Get returns the cached response of the key
+func (c *ResponseCache) Get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(cacheEntry)
+		if !c.expired(e) {
+			c.lru.MoveToFront(el)
+			return e, true
+		}
+		c.lru.Remove(el)
+		delete(c.items, key)
+	}
+	if c.dir == "" {
+		return cacheEntry{}, false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return cacheEntry{}, false
+	}
+	if c.expired(e) {
+		_ = os.Remove(c.path(key))
+		return cacheEntry{}, false
+	}
+	c.add(e)
+	return e, true
+}
+
+// Put stores a response; failing to persist it only loses it on restart
+func (c *ResponseCache) Put(e cacheEntry) {
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	c.mu.Lock()
+	c.add(e)
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = writeFileAtomic(c.path(e.Key), data)
+}
+
+// writeFileAtomic writes the file through a temporary file and a rename, so other processes
+// never read a partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// add puts the entry in front of the LRU, evicting the least recently used; the caller holds the lock
+func (c *ResponseCache) add(e cacheEntry) {
+	if el, ok := c.items[e.Key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[e.Key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(cacheEntry).Key)
+	}
+}
+
+type cacheCtxKey struct{}
+
+// WithCache turns the response cache on or off for the calls made with the context, overriding
+// llm.cache.enabled. Use false to force a fresh answer.
+func WithCache(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, cacheCtxKey{}, enabled)
+}
+
+// WithAgent applies the LLM settings of the agent making the calls (its cache setting)
+func WithAgent(ctx context.Context, agent model.AgentDefinition) context.Context {
+	if agent.Cache != nil {
+		ctx = WithCache(ctx, *agent.Cache)
+	}
+	return ctx
+}
+
+// cacheEnabled reports whether calls with the context use the cache, def when not set
+func cacheEnabled(ctx context.Context, def bool) bool {
+	if enabled, ok := ctx.Value(cacheCtxKey{}).(bool); ok {
+		return enabled
+	}
+	return def
+}
@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+)
+
+// Capabilities are the limits of a model
+type Capabilities struct {
+	Model         string
+	ContextLength int // Tokens of prompt and answer together
+	MaxOutput     int // Tokens of the answer
+	Tokenizer     Tokenizer
+}
+
+// PromptBudget is the number of prompt tokens that leave room for the answer; at most a
+// quarter of the window is kept for it
+func (c Capabilities) PromptBudget() int {
+	reserve := c.MaxOutput
+	if reserve > c.ContextLength/4 {
+		reserve = c.ContextLength / 4
+	}
+	return c.ContextLength - reserve
+}
+
+// knownModel is an entry of the built-in capability table
+type knownModel struct {
+	prefix        string // Of the lower case model name, without provider prefix
+	contextLength int
+	maxOutput     int
+	family        string // Tokenizer family
+}
+
+// knownModels is the built-in capability table; the longest matching prefix wins
+var knownModels = []knownModel{
+	{"gpt-5", 400000, 128000, "o200k"},
+	{"gpt-4.1", 1047576, 32768, "o200k"},
+	{"gpt-4o", 128000, 16384, "o200k"},
+	{"gpt-4-turbo", 128000, 4096, "cl100k"},
+	{"gpt-4", 8192, 4096, "cl100k"},
+	{"gpt-3.5-turbo", 16385, 4096, "cl100k"},
+	{"o1", 200000, 100000, "o200k"},
+	{"o3", 200000, 100000, "o200k"},
+	{"o4-mini", 200000, 100000, "o200k"},
+	{"gemini-1.5-pro", 2097152, 8192, "gemini"},
+	{"gemini-1.5", 1048576, 8192, "gemini"},
+	{"gemini-2.0", 1048576, 8192, "gemini"},
+	{"gemini-2.5", 1048576, 65536, "gemini"},
+	{"claude-3", 200000, 4096, "claude"},
+	{"claude-3-5", 200000, 8192, "claude"},
+	{"claude-3-7", 200000, 64000, "claude"},
+	{"claude-sonnet-4", 200000, 64000, "claude"},
+	{"claude-opus-4", 200000, 32000, "claude"},
+	{"claude-haiku-4", 200000, 64000, "claude"},
+	{"llama2", 4096, 1024, "sentencepiece"},
+	{"llama3", 8192, 2048, "llama3"},
+	{"llama3.1", 131072, 4096, "llama3"},
+	{"llama3.2", 131072, 4096, "llama3"},
+	{"llama3.3", 131072, 4096, "llama3"},
+	{"mistral", 32768, 4096, "sentencepiece"},
+	{"mixtral", 32768, 4096, "sentencepiece"},
+	{"gemma2", 8192, 2048, "sentencepiece"},
+	{"gemma3", 131072, 8192, "sentencepiece"},
+	{"qwen2.5", 32768, 8192, "cl100k"},
+	{"qwen3", 40960, 8192, "cl100k"},
+	{"phi3", 4096, 1024, "sentencepiece"},
+	{"phi4", 16384, 4096, "cl100k"},
+	{"deepseek-r1", 131072, 8192, "cl100k"},
+	{"glm-4", 128000, 4096, "cl100k"},
+}
+
+// Limits of models that are not in the table: small, so prompts are trimmed rather than cut
+const (
+	DefaultContextLength = 8192
+	DefaultMaxOutput     = 2048
+)
+
+// LookupCapabilities returns the limits of a model from the built-in table; the model name may
+// carry a provider prefix ("google/gemini-2.0-flash") or a tag ("llama3.1:8b")
+func LookupCapabilities(modelName string) Capabilities {
+	name := strings.ToLower(modelName)
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		name = name[i+1:]
+	}
+	caps := Capabilities{Model: modelName, ContextLength: DefaultContextLength, MaxOutput: DefaultMaxOutput, Tokenizer: DefaultTokenizer}
+	best := ""
+	for _, m := range knownModels {
+		if strings.HasPrefix(name, m.prefix) && len(m.prefix) > len(best) {
+			best = m.prefix
+			caps.ContextLength, caps.MaxOutput, caps.Tokenizer = m.contextLength, m.maxOutput, familyTokenizer(m.family)
+		}
+	}
+	return caps
+}
+
+// modelCapabilities returns the limits of a configured model: the models entry of the provider
+// over the built-in table. max_tokens of the provider bounds the answer.
+func modelCapabilities(modelName string, pCfg config.ProviderConfig) Capabilities {
+	caps := LookupCapabilities(modelName)
+	mc := pCfg.Models[modelName]
+	if mc.ContextLength > 0 {
+		caps.ContextLength = mc.ContextLength
+	}
+	if mc.MaxOutput > 0 {
+		caps.MaxOutput = mc.MaxOutput
+	} else if pCfg.MaxTokens > 0 {
+		caps.MaxOutput = pCfg.MaxTokens
+	}
+	switch {
+	case mc.Tokenizer == "":
+	case tokenizerFamilies[mc.Tokenizer].Family != "":
+		caps.Tokenizer = familyTokenizer(mc.Tokenizer)
+	default:
+		t, err := LoadBPETokenizer(mc.Tokenizer)
+		if err != nil {
+			fmt.Printf("Warning: tokenizer of model '%s' not loaded: %v. Estimating tokens.\n", modelName, err)
+			break
+		}
+		caps.Tokenizer = t
+	}
+	return caps
+}
+
+// providerModel returns the model a provider calls
+func providerModel(p Provider) string {
+	switch p := p.(type) {
+	case *GeminiProvider:
+		return p.model
+	case *OllamaProvider:
+		return p.Model
+	case *LMStudioProvider:
+		return p.Model
+	case *OpenRouterProvider:
+		return p.Model
+	case *OpenAIProvider:
+		if p.Model == "" {
+			return p.Deployment
+		}
+		return p.Model
+	case *AnthropicProvider:
+		return p.Model
+	case *ZAIProvider:
+		return p.Model
+	}
+	return ""
+}
+
+// CapabilitiesOf returns the limits of the model behind a provider; the Manager reports the
+// smallest window of its default chain, so the prompt fits any fallback
+func CapabilitiesOf(p Provider) Capabilities {
+	if c, ok := p.(interface{ Capabilities() Capabilities }); ok {
+		return c.Capabilities()
+	}
+	return LookupCapabilities(providerModel(p))
+}
+
+// Capabilities returns the limits of the default chain: the smallest window of its providers,
+// with the tokenizer of the first
+func (m *Manager) Capabilities() Capabilities {
+	var caps Capabilities
+	for i, p := range m.chain {
+		c, _ := m.capabilities(p)
+		if i == 0 {
+			caps = c
+			continue
+		}
+		if c.ContextLength < caps.ContextLength {
+			caps.ContextLength, caps.MaxOutput = c.ContextLength, c.MaxOutput
+		}
+	}
+	if caps.Tokenizer == nil {
+		caps = LookupCapabilities("")
+	}
+	return caps
+}
+
+// capabilities returns the limits of a provider of the manager; false for providers without a
+// language model (text to speech, images)
+func (m *Manager) capabilities(p Provider) (Capabilities, bool) {
+	if c, ok := m.caps[m.providerName(p)]; ok {
+		return c, true
+	}
+	modelName := providerModel(p)
+	return LookupCapabilities(modelName), modelName != ""
+}
+
+// ContextLengthError is returned when a prompt doesn't fit the context window of any provider
+type ContextLengthError struct {
+	Provider string
+	Tokens   int // Of the prompt
+	Limit    int // Context length of the model
+}
+
+func (e *ContextLengthError) Error() string {
+	return fmt.Sprintf("prompt of %d tokens exceeds the context window of %d tokens of %s", e.Tokens, e.Limit, e.Provider)
+}
+
+// fitChain returns the providers of the chain whose context window holds the prompt. A prompt
+// that fits none returns a *ContextLengthError for the first, instead of a silently truncated
+// prompt.
+func (m *Manager) fitChain(ctx context.Context, chain []Provider, prompt, systemPrompt string) ([]Provider, error) {
+	var fits []Provider
+	var tooLong *ContextLengthError
+	for _, p := range chain {
+		caps, ok := m.capabilities(p)
+		if !ok {
+			fits = append(fits, p)
+			continue
+		}
+		tokens := caps.Tokenizer.CountTokens(systemPrompt) + caps.Tokenizer.CountTokens(prompt)
+		if tokens <= caps.ContextLength {
+			fits = append(fits, p)
+			continue
+		}
+		name := m.providerName(p)
+		msg := fmt.Sprintf("[LLM] Prompt of %d tokens exceeds the context window of %s (%d tokens), skipping it", tokens, name, caps.ContextLength)
+		fmt.Println(msg)
+		Log(ctx, msg)
+		if tooLong == nil {
+			tooLong = &ContextLengthError{Provider: name, Tokens: tokens, Limit: caps.ContextLength}
+		}
+	}
+	if len(fits) == 0 && tooLong != nil {
+		return nil, tooLong
+	}
+	return fits, nil
+}
@@ -0,0 +1,374 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// Embedder turns texts into vectors, one per text in the same order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error)
+}
+
+// EmbeddingProvider is implemented by providers that can embed text. CanEmbed reports
+// whether an embedding model is configured for the provider.
+type EmbeddingProvider interface {
+	Provider
+	Embedder
+	CanEmbed() bool
+}
+
+// LocalEmbeddingProvider is the name selecting the local HashEmbedder as embedding provider
+const LocalEmbeddingProvider = "local"
+
+// Default embedding models, used when embedding_model is "default"
+const (
+	DefaultOllamaEmbeddingModel = "nomic-embed-text"
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	DefaultGeminiEmbeddingModel = "text-embedding-004"
+	geminiEmbedBatchSize        = 100 // BatchEmbedContents limit
+)
+
+// Embed embeds texts with the embedding provider of the configuration: the configured
+// embedding_provider, else the default provider when it has an embedding model, else the
+// first provider with an embedding model. Without any, the local HashEmbedder is used.
+func (m *Manager) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	if len(texts) == 0 {
+		return nil, model.TokenUsage{}, nil
+	}
+	e := m.embedder()
+	attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	vectors, usage, err := e.Embed(attemptCtx, texts)
+	if err != nil {
+		return nil, usage, fmt.Errorf("embedding failed: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, usage, fmt.Errorf("embedding failed: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, usage, nil
+}
+
+// EmbedWithProvider embeds texts with a specific provider (as defined in config)
+func (m *Manager) EmbedWithProvider(ctx context.Context, providerName string, texts []string) ([][]float32, model.TokenUsage, error) {
+	if providerName == LocalEmbeddingProvider {
+		return HashEmbedder{}.Embed(ctx, texts)
+	}
+	p, err := m.GetProvider(providerName)
+	if err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+	ep, ok := p.(EmbeddingProvider)
+	if !ok || !ep.CanEmbed() {
+		return nil, model.TokenUsage{}, fmt.Errorf("provider '%s' has no embedding model configured", providerName)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	return ep.Embed(attemptCtx, texts)
+}
+
+// EmbeddingProviderName returns the name of the provider used by Embed
+func (m *Manager) EmbeddingProviderName() string {
+	e := m.embedder()
+	if p, ok := e.(Provider); ok {
+		return m.providerName(p)
+	}
+	return LocalEmbeddingProvider
+}
+
+func (m *Manager) embedder() Embedder {
+	if m.embeddingProvider != "" {
+		if p, ok := m.providers[m.embeddingProvider].(EmbeddingProvider); ok && p.CanEmbed() {
+			return p
+		}
+		return HashEmbedder{}
+	}
+	if p, ok := m.defaultProvider.(EmbeddingProvider); ok && p.CanEmbed() {
+		return p
+	}
+	names := make([]string, 0, len(m.providers))
+	for name := range m.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p, ok := m.providers[name].(EmbeddingProvider); ok && p.CanEmbed() {
+			return p
+		}
+	}
+	return HashEmbedder{}
+}
+
+// embeddingModel resolves the configured embedding model ("default" selects the provider default)
+func embeddingModel(configured, fallback string) string {
+	if strings.EqualFold(configured, "default") {
+		return fallback
+	}
+	return configured
+}
+
+// embeddingUsage returns the usage of an embedding request, costed per 1M tokens
+func embeddingUsage(tokens int, pricePerToken float64) model.TokenUsage {
+	return model.TokenUsage{
+		PromptTokens:  tokens,
+		TotalTokens:   tokens,
+		EstimatedCost: (float64(tokens) / 1000000.0) * pricePerToken,
+	}
+}
+
+// estimateEmbeddingTokens approximates the token count of texts when the API reports none
+func estimateEmbeddingTokens(texts []string) int {
+	total := 0
+	for _, t := range texts {
+		total += DefaultTokenizer.CountTokens(t)
+	}
+	return total
+}
+
+// postEmbeddings posts a JSON embedding request and decodes the JSON response into out
+func postEmbeddings(ctx context.Context, name, url string, headers map[string]string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s embedding request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s embedding error %d: %s", name, resp.StatusCode, string(bodyBytes))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s embedding response: %w", name, err)
+	}
+	return nil
+}
+
+// openAIEmbeddings calls an OpenAI compatible /embeddings endpoint
+func openAIEmbeddings(ctx context.Context, name, url string, headers map[string]string, modelName string, texts []string, pricePerToken float64) ([][]float32, model.TokenUsage, error) {
+	payload := map[string]interface{}{
+		"input": texts,
+	}
+	if modelName != "" {
+		payload["model"] = modelName
+	}
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := postEmbeddings(ctx, name, url, headers, payload, &result); err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, d := range result.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(vectors) {
+			idx = i
+		}
+		vectors[idx] = d.Embedding
+	}
+	tokens := result.Usage.PromptTokens
+	if tokens == 0 {
+		tokens = result.Usage.TotalTokens
+	}
+	return vectors, embeddingUsage(tokens, pricePerToken), nil
+}
+
+// --- Ollama ---
+
+func (p *OllamaProvider) CanEmbed() bool {
+	return p.EmbeddingModel != ""
+}
+
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	payload := map[string]interface{}{
+		"model": embeddingModel(p.EmbeddingModel, DefaultOllamaEmbeddingModel),
+		"input": texts,
+	}
+	var result struct {
+		Embeddings      [][]float32 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	if err := postEmbeddings(ctx, "ollama", fmt.Sprintf("%s/api/embed", p.BaseURL), nil, payload, &result); err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+	return result.Embeddings, embeddingUsage(result.PromptEvalCount, p.PricePerEmbeddingToken), nil
+}
+
+// --- OpenAI compatible (OpenAI, Azure OpenAI, LM Studio, Z.AI) ---
+
+func (p *OpenAIProvider) CanEmbed() bool {
+	return p.EmbeddingModel != ""
+}
+
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	modelName := embeddingModel(p.EmbeddingModel, DefaultOpenAIEmbeddingModel)
+	base := strings.TrimSuffix(p.BaseURL, "/")
+	headers := expandHeaders(p.Headers)
+
+	if p.Deployment == "" {
+		if p.APIKey != "" {
+			headers["Authorization"] = "Bearer " + p.APIKey
+		}
+		return openAIEmbeddings(ctx, "openai", base+"/embeddings", headers, modelName, texts, p.PricePerEmbeddingToken)
+	}
+
+	// Azure OpenAI: the embedding model is the name of the embedding deployment
+	version := p.APIVersion
+	if version == "" {
+		version = DefaultAzureAPIVersion
+	}
+	if !strings.HasSuffix(base, "/openai") {
+		base += "/openai"
+	}
+	if p.APIKey != "" {
+		headers["api-key"] = p.APIKey
+	}
+	endpoint := fmt.Sprintf("%s/deployments/%s/embeddings?api-version=%s", base, url.PathEscape(modelName), url.QueryEscape(version))
+	return openAIEmbeddings(ctx, "openai", endpoint, headers, "", texts, p.PricePerEmbeddingToken)
+}
+
+func (p *LMStudioProvider) CanEmbed() bool {
+	return p.EmbeddingModel != ""
+}
+
+func (p *LMStudioProvider) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	return openAIEmbeddings(ctx, "lmstudio", fmt.Sprintf("%s/embeddings", p.BaseURL), nil, p.EmbeddingModel, texts, p.PricePerEmbeddingToken)
+}
+
+func (p *ZAIProvider) CanEmbed() bool {
+	return p.EmbeddingModel != ""
+}
+
+func (p *ZAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	var headers map[string]string
+	if p.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + p.APIKey}
+	}
+	modelName := embeddingModel(p.EmbeddingModel, "embedding-3")
+	return openAIEmbeddings(ctx, "z.ai", fmt.Sprintf("%s/embeddings", strings.TrimSuffix(p.BaseURL, "/")), headers, modelName, texts, p.PricePerEmbeddingToken)
+}
+
+// --- Gemini ---
+
+// CanEmbed is only true with an API key; the Cloud Code (OAuth) API has no embeddings
+func (p *GeminiProvider) CanEmbed() bool {
+	return p.EmbeddingModel != "" && p.genaiClient != nil
+}
+
+func (p *GeminiProvider) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	if p.genaiClient == nil {
+		return nil, model.TokenUsage{}, fmt.Errorf("gemini embeddings require an api_key")
+	}
+	em := p.genaiClient.EmbeddingModel(embeddingModel(p.EmbeddingModel, DefaultGeminiEmbeddingModel))
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += geminiEmbedBatchSize {
+		end := start + geminiEmbedBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch := em.NewBatch()
+		for _, text := range texts[start:end] {
+			batch.AddContent(genai.Text(text))
+		}
+		resp, err := em.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			return nil, model.TokenUsage{}, fmt.Errorf("gemini embedding failed: %w", err)
+		}
+		for _, e := range resp.Embeddings {
+			vectors = append(vectors, e.Values)
+		}
+	}
+	// The embedding API reports no token counts
+	return vectors, embeddingUsage(estimateEmbeddingTokens(texts), p.PricePerEmbeddingToken), nil
+}
+
+// --- Local fallback ---
+
+// HashEmbedder is a deterministic local embedder (feature hashing of words and word pairs).
+// It needs no model and matches on shared vocabulary rather than meaning, which makes it
+// the offline fallback and a stable embedder for tests.
+type HashEmbedder struct {
+	Dims int
+}
+
+func (e HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error) {
+	dims := e.Dims
+	if dims <= 0 {
+		dims = 512
+	}
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, dims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for j, w := range words {
+			addFeature(vec, w, 1)
+			if j > 0 {
+				addFeature(vec, words[j-1]+" "+w, 0.5)
+			}
+		}
+		normalize(vec)
+		out[i] = vec
+	}
+	return out, model.TokenUsage{}, nil
+}
+
+func addFeature(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	idx := int(sum % uint64(len(vec)))
+	if sum&(1<<63) != 0 {
+		weight = -weight // Signed hashing keeps collisions unbiased
+	}
+	vec[idx] += weight
+}
+
+func normalize(vec []float32) {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] = float32(float64(vec[i]) / norm)
+	}
+}
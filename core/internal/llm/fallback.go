@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"google.golang.org/api/googleapi"
+)
+
+// MaxRetryDelay caps the exponential backoff between attempts on one provider
+const MaxRetryDelay = 30 * time.Second
+
+// StatusError is an HTTP error response of a provider
+type StatusError struct {
+	Provider string
+	Code     int
+	Body     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.Provider, e.Code, e.Body)
+}
+
+func statusError(provider string, code int, body []byte) error {
+	return &StatusError{Provider: provider, Code: code, Body: string(body)}
+}
+
+// ErrCircuitOpen is returned when every provider of a chain is skipped by its circuit breaker
+var ErrCircuitOpen = errors.New("circuit open")
+
+// statusCode returns the HTTP status of a provider error, 0 when it has none
+func statusCode(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code
+	}
+	// Google API errors (Gemini SDK)
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	var coded interface{ HTTPCode() int }
+	if errors.As(err, &coded) {
+		return coded.HTTPCode()
+	}
+	return 0
+}
+
+// Retryable reports whether trying the call again can succeed: rate limits, server errors,
+// timeouts and network failures are retryable; cancellation, authentication and other 4xx
+// errors are fatal.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch code := statusCode(err); {
+	case code == 0:
+		return true // Network errors, timeouts, broken streams
+	case code == http.StatusRequestTimeout, code == http.StatusTooEarly, code == http.StatusTooManyRequests:
+		return true
+	default:
+		return code >= 500
+	}
+}
+
+// authFailure reports errors that mean the provider is unusable as configured
+func authFailure(err error) bool {
+	code := statusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusPaymentRequired
+}
+
+// backoff returns the wait before the given retry (1-based): exponential from RetryDelay,
+// capped at MaxRetryDelay, with jitter so parallel callers don't retry in lockstep
+func backoff(retry int) time.Duration {
+	d := RetryDelay << (retry - 1)
+	if d > MaxRetryDelay || d <= 0 {
+		d = MaxRetryDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// breaker is the circuit breaker of one provider. It opens after a number of consecutive
+// failures; after the cooldown a single probe call is let through (half-open), which closes
+// the circuit on success or opens it again on failure.
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+
+	threshold int
+	cooldown  time.Duration
+}
+
+func newBreaker(cfg config.CircuitBreakerConfig) *breaker {
+	b := &breaker{threshold: cfg.Failures, cooldown: time.Duration(cfg.CooldownSeconds) * time.Second}
+	if b.threshold <= 0 {
+		b.threshold = 3
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = 60 * time.Second
+	}
+	return b
+}
+
+// allow reports whether the provider may be called
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.open, b.probing = 0, false, false
+}
+
+// abort ends a probe call that was cancelled without a verdict
+func (b *breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// failure records a failed call; it returns true when the circuit (re)opens
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		wasOpen := b.open && !b.probing
+		b.open, b.probing, b.openedAt = true, false, time.Now()
+		return !wasOpen
+	}
+	return false
+}
+
+// breakerFor returns the breaker of the named provider
+func (m *Manager) breakerFor(name string) *breaker {
+	m.breakersMu.Lock()
+	defer m.breakersMu.Unlock()
+	b, ok := m.breakers[name]
+	if !ok {
+		b = newBreaker(m.breakerCfg)
+		m.breakers[name] = b
+	}
+	return b
+}
+
+// callChain runs call on the providers of the chain in order until one succeeds. Each provider
+// is retried with backoff on retryable errors; fatal errors and exhausted retries move on to
+// the next provider. Providers with an open circuit are skipped. It returns the provider that
+// answered, or the last one tried with its error.
+func (m *Manager) callChain(ctx context.Context, chain []Provider, call func(ctx context.Context, p Provider) error) (Provider, error) {
+	maxRetries := m.retries
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
+
+	var last Provider
+	var lastErr error
+	var skipped []string
+	for _, p := range chain {
+		name := m.providerName(p)
+		b := m.breakerFor(name)
+		if !b.allow() {
+			skipped = append(skipped, name)
+			continue
+		}
+		if last != nil {
+			msg := fmt.Sprintf("[LLM] Provider %s failed, falling back to %s", m.providerName(last), name)
+			fmt.Println(msg)
+			Log(ctx, msg)
+		}
+		last = p
+
+		for attempt := 1; attempt <= maxRetries; attempt++ {
+			if attempt > 1 {
+				wait := backoff(attempt - 1)
+				fmt.Printf("[LLM] %s attempt %d failed: %v. Retrying in %v...\n", name, attempt-1, lastErr, wait.Round(time.Millisecond))
+				select {
+				case <-ctx.Done():
+					b.abort()
+					return p, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+			lastErr = call(ctx, p)
+			if lastErr == nil {
+				b.success()
+				return p, nil
+			}
+			// Stopped by the caller
+			if ctx.Err() != nil {
+				b.abort()
+				return p, ctx.Err()
+			}
+			if !Retryable(lastErr) {
+				break
+			}
+		}
+
+		if Retryable(lastErr) || authFailure(lastErr) {
+			if b.failure() {
+				fmt.Printf("[LLM] Circuit of provider %s opened for %v\n", name, b.cooldown)
+			}
+		} else {
+			// The provider answered; request errors (4xx) say nothing about its health
+			b.success()
+		}
+	}
+
+	if last == nil {
+		return nil, fmt.Errorf("no provider available (%s): %w", strings.Join(skipped, ", "), ErrCircuitOpen)
+	}
+	if Retryable(lastErr) {
+		lastErr = fmt.Errorf("llm generate failed after %d attempts: %w", maxRetries, lastErr)
+	}
+	return last, lastErr
+}
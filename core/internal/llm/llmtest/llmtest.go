@@ -0,0 +1,71 @@
+// Package llmtest runs tests against recorded LLM calls (see llm.ReplayProvider)
+package llmtest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+var record = flag.Bool("record", false, "record the LLM fixtures of the tests from their scripts")
+
+// Script answers the calls whose prompts contain a key with its value, like a model would.
+// It stands in for a live model when the fixtures are recorded.
+type Script map[string]string
+
+func (s Script) answer(text string) (string, error) {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // The same answer on every run
+	for _, k := range keys {
+		if strings.Contains(text, k) {
+			return s[k], nil
+		}
+	}
+	start := text
+	if len(start) > 200 {
+		start = start[:200] + "..."
+	}
+	return "", fmt.Errorf("script has no answer for %q", start)
+}
+
+func (s Script) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	resp, err := s.answer(systemPrompt + "\n" + prompt)
+	return resp, usage(resp), err
+}
+
+func (s Script) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.ToolSpec) (llm.Message, model.TokenUsage, error) {
+	var sb strings.Builder
+	for _, m := range messages {
+		sb.WriteString(m.Content + "\n")
+	}
+	resp, err := s.answer(sb.String())
+	return llm.Message{Role: llm.RoleAssistant, Content: resp}, usage(resp), err
+}
+
+func (s Script) Close() error {
+	return nil
+}
+
+func usage(resp string) model.TokenUsage {
+	n := len(strings.Fields(resp))
+	return model.TokenUsage{CompletionTokens: n, TotalTokens: n}
+}
+
+// Replay returns a provider serving the fixtures of dir. Run the tests with -record to record
+// them again from the script, after a prompt changed.
+func Replay(t testing.TB, dir string, script Script) *llm.ReplayProvider {
+	t.Helper()
+	if *record {
+		return llm.NewReplayProvider(dir, llm.ReplayModeRecord, script)
+	}
+	return llm.NewReplayProvider(dir, llm.ReplayModeReplay, nil)
+}
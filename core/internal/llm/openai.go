@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// --- Generic OpenAI Compatible Provider (OpenAI, Azure OpenAI, vLLM, LocalAI, ...) ---
+
+// OpenAIProvider talks to any endpoint implementing the OpenAI chat completions API.
+// With a Deployment it uses the Azure OpenAI URL layout and api-key authentication.
+type OpenAIProvider struct {
+	Model                   string
+	BaseURL                 string
+	APIKey                  string
+	Deployment              string // Azure: deployment name, replaces the model in the URL
+	APIVersion              string // Azure: api-version query parameter
+	Headers                 map[string]string
+	PricePerPromptToken     float64
+	PricePerCompletionToken float64
+	EmbeddingModel          string // Azure: name of the embedding deployment
+	PricePerEmbeddingToken  float64
+}
+
+const (
+	DefaultOpenAIURL       = "https://api.openai.com/v1"
+	DefaultAzureAPIVersion = "2024-10-21"
+)
+
+// endpoint returns the chat completions URL and the request headers
+func (p *OpenAIProvider) endpoint() (string, map[string]string) {
+	base := strings.TrimSuffix(p.BaseURL, "/")
+	headers := expandHeaders(p.Headers)
+
+	if p.Deployment == "" {
+		if p.APIKey != "" {
+			headers["Authorization"] = "Bearer " + p.APIKey
+		}
+		return base + "/chat/completions", headers
+	}
+
+	// Azure OpenAI: https://<resource>.openai.azure.com/openai/deployments/<deployment>/chat/completions?api-version=...
+	version := p.APIVersion
+	if version == "" {
+		version = DefaultAzureAPIVersion
+	}
+	if !strings.HasSuffix(base, "/openai") {
+		base += "/openai"
+	}
+	if p.APIKey != "" {
+		headers["api-key"] = p.APIKey
+	}
+	return fmt.Sprintf("%s/deployments/%s/chat/completions?api-version=%s", base, url.PathEscape(p.Deployment), url.QueryEscape(version)), headers
+}
+
+func (p *OpenAIProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	endpoint, headers := p.endpoint()
+	reply, usage, err := chatWithTools(ctx, "openai", endpoint, headers, p.Model, promptMessages(prompt, systemPrompt), nil)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return cleanResponse(reply.Content), usage, nil
+}
+
+func (p *OpenAIProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	endpoint, headers := p.endpoint()
+	payload := chatPayload(p.Model, prompt, systemPrompt, true)
+	if schema := SchemaFromContext(ctx); schema != nil {
+		payload["response_format"] = responseFormat(schema)
+	}
+	resp, usage, err := streamChatCompletion(ctx, "openai", endpoint, headers, payload, onChunk)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return resp, usage, err
+}
+
+func (p *OpenAIProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	endpoint, headers := p.endpoint()
+	reply, usage, err := chatWithTools(ctx, "openai", endpoint, headers, p.Model, messages, tools)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return reply, usage, err
+}
+
+func (p *OpenAIProvider) Close() error {
+	return nil
+}
+
+// promptMessages converts a prompt and optional system prompt to chat messages
+func promptMessages(prompt, systemPrompt string) []Message {
+	var messages []Message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: systemPrompt})
+	}
+	return append(messages, Message{Role: RoleUser, Content: prompt})
+}
+
+// expandHeaders copies the configured headers, expanding ${ENV} references so secrets
+// can stay out of the configuration
+func expandHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		out[k] = os.ExpandEnv(v)
+	}
+	return out
+}
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/limiter"
 	"github.com/sjhoeksma/druppie/core/internal/model"
 	"google.golang.org/api/option"
 )
@@ -24,10 +28,26 @@ type Provider interface {
 
 // Manager holds multiple providers and routes requests
 type Manager struct {
-	defaultProvider Provider
+	defaultProvider Provider   // First provider of the chain
+	chain           []Provider // Default provider followed by its fallbacks
 	providers       map[string]Provider
 	timeout         time.Duration
 	retries         int
+
+	embeddingProvider string // Configured embedding provider, empty selects one (see Embed)
+
+	slots         *limiter.Limiter // Calls in flight per provider
+	maxConcurrent map[string]int   // Provider name -> max_concurrent
+
+	breakerCfg config.CircuitBreakerConfig
+	breakersMu sync.Mutex
+	breakers   map[string]*breaker // Provider name -> circuit breaker
+
+	cache        *ResponseCache    // Optional, see UseCache
+	cacheDefault bool              // Cache calls without a WithCache setting
+	models       map[string]string // Provider name -> type/model, the model of cache keys
+
+	caps map[string]Capabilities // Provider name -> limits of its model
 }
 
 // NewManager initializes the LLM manager with the given configuration
@@ -42,10 +62,17 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 	}
 
 	mgr := &Manager{
-		providers: make(map[string]Provider),
-		timeout:   timeout,
-		retries:   retries,
+		providers:         make(map[string]Provider),
+		timeout:           timeout,
+		retries:           retries,
+		embeddingProvider: cfg.EmbeddingProvider,
+		maxConcurrent:     make(map[string]int),
+		breakerCfg:        cfg.CircuitBreaker,
+		breakers:          make(map[string]*breaker),
+		models:            make(map[string]string),
+		caps:              make(map[string]Capabilities),
 	}
+	mgr.slots = limiter.New(func(name string) int { return mgr.maxConcurrent[name] })
 
 	// Helper to create a provider based on type and details
 	// Helper to create a provider based on type and details
@@ -68,6 +95,8 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 					model:                   model,
 					PricePerPromptToken:     pCfg.PricePerPromptToken,
 					PricePerCompletionToken: pCfg.PricePerCompletionToken,
+					EmbeddingModel:          pCfg.EmbeddingModel,
+					PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
 				}, nil
 			} else {
 				if pCfg.ProjectID == "" && pCfg.ClientID == "" {
@@ -85,6 +114,8 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 					model:                   model,
 					PricePerPromptToken:     pCfg.PricePerPromptToken,
 					PricePerCompletionToken: pCfg.PricePerCompletionToken,
+					EmbeddingModel:          pCfg.EmbeddingModel,
+					PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
 				}, nil
 			}
 		case "ollama":
@@ -101,6 +132,8 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 				BaseURL:                 baseURL,
 				PricePerPromptToken:     pCfg.PricePerPromptToken,
 				PricePerCompletionToken: pCfg.PricePerCompletionToken,
+				EmbeddingModel:          pCfg.EmbeddingModel,
+				PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
 			}, nil
 		case "lmstudio":
 			baseURL := "http://localhost:1234/v1"
@@ -112,6 +145,8 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 				BaseURL:                 baseURL,
 				PricePerPromptToken:     pCfg.PricePerPromptToken,
 				PricePerCompletionToken: pCfg.PricePerCompletionToken,
+				EmbeddingModel:          pCfg.EmbeddingModel,
+				PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
 			}, nil
 		case "openrouter":
 			model := pCfg.Model
@@ -122,6 +157,45 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 				Model:  model,
 				APIKey: pCfg.APIKey,
 			}, nil
+		case "openai", "azure-openai", "azure":
+			baseURL := DefaultOpenAIURL
+			if pCfg.URL != "" {
+				baseURL = pCfg.URL
+			}
+			if pCfg.Deployment == "" && pCfg.Model == "" {
+				return nil, fmt.Errorf("openai config incomplete (missing model or deployment)")
+			}
+			return &OpenAIProvider{
+				Model:                   pCfg.Model,
+				BaseURL:                 baseURL,
+				APIKey:                  pCfg.APIKey,
+				Deployment:              pCfg.Deployment,
+				APIVersion:              pCfg.APIVersion,
+				Headers:                 pCfg.Headers,
+				PricePerPromptToken:     pCfg.PricePerPromptToken,
+				PricePerCompletionToken: pCfg.PricePerCompletionToken,
+				EmbeddingModel:          pCfg.EmbeddingModel,
+				PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
+			}, nil
+		case "anthropic":
+			model := pCfg.Model
+			if model == "" {
+				model = "claude-sonnet-4-5"
+			}
+			baseURL := DefaultAnthropicURL
+			if pCfg.URL != "" {
+				baseURL = pCfg.URL
+			}
+			return &AnthropicProvider{
+				Model:                   model,
+				BaseURL:                 baseURL,
+				APIKey:                  pCfg.APIKey,
+				Version:                 pCfg.APIVersion,
+				MaxTokens:               pCfg.MaxTokens,
+				Headers:                 pCfg.Headers,
+				PricePerPromptToken:     pCfg.PricePerPromptToken,
+				PricePerCompletionToken: pCfg.PricePerCompletionToken,
+			}, nil
 		case "sherpa-onnx":
 			// Language from configuration or default to EN
 			lang := "en"
@@ -144,6 +218,27 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 				APIKey:                  pCfg.APIKey,
 				PricePerPromptToken:     pCfg.PricePerPromptToken,
 				PricePerCompletionToken: pCfg.PricePerCompletionToken,
+				EmbeddingModel:          pCfg.EmbeddingModel,
+				PricePerEmbeddingToken:  pCfg.PricePerEmbeddingToken,
+			}, nil
+		case "replay":
+			dir := pCfg.Fixtures
+			if dir == "" {
+				dir = filepath.Join("testdata", "llm")
+			}
+			switch pCfg.Mode {
+			case "", ReplayModeReplay, ReplayModeRecord, ReplayModeAuto:
+			default:
+				return nil, fmt.Errorf("unknown replay mode: %s", pCfg.Mode)
+			}
+			if pCfg.Mode != "" && pCfg.Mode != ReplayModeReplay && pCfg.Upstream == "" {
+				return nil, fmt.Errorf("replay config incomplete (mode %s needs an upstream provider)", pCfg.Mode)
+			}
+			return &ReplayProvider{
+				Dir:           dir,
+				Mode:          pCfg.Mode,
+				UpstreamName:  pCfg.Upstream,
+				MinSimilarity: pCfg.MinSimilarity,
 			}, nil
 		case "stable-diffusion":
 			// Use BaseURL field from config which maps to APIURL usually?
@@ -164,6 +259,29 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 			continue
 		}
 		mgr.providers[name] = p
+		mgr.models[name] = strings.ToLower(pCfg.Type) + "/" + pCfg.Model
+		if modelName := providerModel(p); modelName != "" {
+			mgr.caps[name] = modelCapabilities(modelName, pCfg)
+			if op, ok := p.(*OllamaProvider); ok {
+				op.Window = mgr.caps[name]
+			}
+		}
+		if pCfg.MaxConcurrent > 0 {
+			mgr.maxConcurrent[name] = pCfg.MaxConcurrent
+		}
+	}
+
+	// Replay providers record the calls of another provider
+	for name, p := range mgr.providers {
+		rp, ok := p.(*ReplayProvider)
+		if !ok || rp.UpstreamName == "" {
+			continue
+		}
+		if upstream, ok := mgr.providers[rp.UpstreamName]; ok && upstream != p {
+			rp.Upstream = upstream
+		} else {
+			fmt.Printf("Warning: upstream provider '%s' of replay provider '%s' is not available.\n", rp.UpstreamName, name)
+		}
 	}
 
 	/*
@@ -180,12 +298,17 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 		}
 	*/
 
-	// 3. Set Default Provider
-	if cfg.DefaultProvider != "" {
-		if p, ok := mgr.providers[cfg.DefaultProvider]; ok {
-			mgr.defaultProvider = p
+	// 3. Set Default Provider and its fallbacks
+	for _, name := range cfg.DefaultProvider {
+		if p, ok := mgr.providers[name]; ok {
+			mgr.chain = append(mgr.chain, p)
+		} else {
+			fmt.Printf("Warning: provider '%s' of the default chain is not available. Skipping.\n", name)
 		}
 	}
+	if len(mgr.chain) > 0 {
+		mgr.defaultProvider = mgr.chain[0]
+	}
 
 	// If still no default, and we have providers, pick one?
 	if mgr.defaultProvider == nil && len(mgr.providers) > 0 {
@@ -198,6 +321,15 @@ func NewManager(ctx context.Context, cfg config.LLMConfig) (*Manager, error) {
 	if mgr.defaultProvider == nil {
 		return nil, fmt.Errorf("no usable default provider configured")
 	}
+	if len(mgr.chain) == 0 {
+		mgr.chain = []Provider{mgr.defaultProvider}
+	}
+
+	if name := cfg.EmbeddingProvider; name != "" && name != LocalEmbeddingProvider {
+		if p, ok := mgr.providers[name].(EmbeddingProvider); !ok || !p.CanEmbed() {
+			fmt.Printf("Warning: embedding provider '%s' is missing or has no embedding_model. Using local embeddings.\n", name)
+		}
+	}
 
 	return mgr, nil
 }
@@ -208,12 +340,12 @@ const (
 	GlobalTimeout = 120 * time.Second // 2 minutes as upper bound
 )
 
-// Generate uses the default provider with retry and timeout logic
+// Generate uses the default provider chain with retry, fallback and timeout logic
 func (m *Manager) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
 	if m.defaultProvider == nil {
 		return "", model.TokenUsage{}, fmt.Errorf("no default provider configured")
 	}
-	return m.generateWithRetry(ctx, m.defaultProvider, prompt, systemPrompt)
+	return m.generate(ctx, m.chain, prompt, systemPrompt)
 }
 
 // GenerateWithProvider uses a specific provider with retry and timeout logic
@@ -222,48 +354,118 @@ func (m *Manager) GenerateWithProvider(ctx context.Context, providerName string,
 	if err != nil {
 		return "", model.TokenUsage{}, err
 	}
-	return m.generateWithRetry(ctx, p, prompt, systemPrompt)
+	return m.generate(ctx, []Provider{p}, prompt, systemPrompt)
 }
 
-func (m *Manager) generateWithRetry(ctx context.Context, p Provider, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
-	// Use configured retries
-	maxRetries := m.retries
-	if maxRetries <= 0 {
-		maxRetries = 1
-	}
+// UseCache puts the response cache in front of Generate and GenerateWithProvider. enabled is
+// the default for calls without a WithCache setting.
+func (m *Manager) UseCache(cache *ResponseCache, enabled bool) {
+	m.cache = cache
+	m.cacheDefault = enabled
+}
 
-	var lastErr error
-	for i := 0; i < maxRetries; i++ {
-		// Create a context with timeout for this specific attempt
-		attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+// cacheKey returns the key of a call to the provider; the schema of structured output is part
+// of the system prompt
+func (m *Manager) cacheKey(ctx context.Context, p Provider, prompt, systemPrompt string) string {
+	name := m.providerName(p)
+	modelID, ok := m.models[name]
+	if !ok {
+		modelID = name
+	}
+	if schema := SchemaFromContext(ctx); schema != nil {
+		data, _ := json.Marshal(schema)
+		systemPrompt += "\x00" + string(data)
+	}
+	return cacheKey(modelID, systemPrompt, prompt)
+}
 
-		if i > 0 {
-			fmt.Printf("[LLM] Retry attempt %d/%d...\n", i+1, maxRetries)
+// generate runs the prompt on the first provider of the chain that answers (see callChain).
+// The usage names the provider that answered. A cached response of a provider of the chain is
+// returned without calling it, as usage without cost marked cached.
+func (m *Manager) generate(ctx context.Context, chain []Provider, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	useCache := m.cache != nil && cacheEnabled(ctx, m.cacheDefault)
+	if useCache {
+		for _, p := range chain {
+			if e, ok := m.cache.Get(m.cacheKey(ctx, p, prompt, systemPrompt)); ok {
+				usage := model.TokenUsage{Provider: m.providerName(p), Cached: true}
+				Log(ctx, fmt.Sprintf("LLM response of %s served from cache", usage.Provider))
+				m.audit(ctx, p, prompt, systemPrompt, e.Response, usage, nil)
+				return e.Response, usage, nil
+			}
 		}
+	}
 
-		resp, usage, err := p.Generate(attemptCtx, prompt, systemPrompt)
-		cancel() // Ensure we release the timeout resources immediately
+	chain, err := m.fitChain(ctx, chain, prompt, systemPrompt)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
 
-		if err == nil {
-			return resp, usage, nil
+	var resp string
+	var usage model.TokenUsage
+	p, err := m.callChain(ctx, chain, func(ctx context.Context, p Provider) error {
+		var err error
+		// Each attempt gets its own timeout (an inactivity timeout when streaming)
+		resp, usage, err = m.generateOnce(ctx, p, prompt, systemPrompt)
+		return err
+	})
+	if err != nil {
+		if p != nil && ctx.Err() == nil {
+			m.audit(ctx, p, prompt, systemPrompt, "", model.TokenUsage{}, err)
 		}
+		return "", model.TokenUsage{}, err
+	}
+	usage.Provider = m.providerName(p)
+	m.audit(ctx, p, prompt, systemPrompt, resp, usage, nil)
+	if useCache {
+		key := m.cacheKey(ctx, p, prompt, systemPrompt)
+		m.cache.Put(cacheEntry{Key: key, Model: m.models[usage.Provider], Response: resp, Usage: usage})
+	}
+	return resp, usage, nil
+}
 
-		// Check if parent context was canceled (User stopped)
-		if ctx.Err() != nil {
-			return "", model.TokenUsage{}, ctx.Err()
+// acquire waits for a free call slot of the provider (max_concurrent) and returns its release
+func (m *Manager) acquire(ctx context.Context, p Provider) (func(), error) {
+	name := m.providerName(p)
+	if m.maxConcurrent[name] == 0 {
+		return func() {}, nil
+	}
+	if !m.slots.TryAcquire(name) {
+		Log(ctx, fmt.Sprintf("Waiting for a free slot of LLM provider %s...", name))
+		if err := m.slots.Acquire(ctx, name); err != nil {
+			return nil, err
 		}
+	}
+	return func() { m.slots.Release(name) }, nil
+}
 
-		lastErr = err
-		fmt.Printf("[LLM] Attempt %d failed: %v. Retrying in %v...\n", i+1, err, RetryDelay)
+// audit records the prompt and the hash of the response on the audit chain of the context
+func (m *Manager) audit(ctx context.Context, p Provider, prompt, systemPrompt, resp string, usage model.TokenUsage, err error) {
+	data := map[string]interface{}{
+		"provider":          m.providerName(p),
+		"prompt":            prompt,
+		"system_prompt":     systemPrompt,
+		"response_hash":     audit.HashContent(resp),
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"cost":              usage.EstimatedCost,
+	}
+	if usage.Cached {
+		data["cached"] = true
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	audit.Log(audit.ChainFromContext(ctx), audit.TypeLLMCall, audit.ActorFromContext(ctx), 0, "LLM generate", data)
+}
 
-		// Wait before retry, listening for parent context cancellation
-		select {
-		case <-ctx.Done():
-			return "", model.TokenUsage{}, ctx.Err()
-		case <-time.After(RetryDelay):
+// providerName returns the configured name of a provider
+func (m *Manager) providerName(p Provider) string {
+	for name, candidate := range m.providers {
+		if candidate == p {
+			return name
 		}
 	}
-	return "", model.TokenUsage{}, fmt.Errorf("llm generate failed after %d attempts: %w", maxRetries, lastErr)
+	return "default"
 }
 
 // Close closes all providers
@@ -291,6 +493,8 @@ type GeminiProvider struct {
 	model                   string
 	PricePerPromptToken     float64
 	PricePerCompletionToken float64
+	EmbeddingModel          string
+	PricePerEmbeddingToken  float64
 }
 
 func (p *GeminiProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
@@ -300,6 +504,7 @@ func (p *GeminiProvider) Generate(ctx context.Context, prompt string, systemProm
 		if systemPrompt != "" {
 			modelVal.SystemInstruction = genai.NewUserContent(genai.Text(systemPrompt))
 		}
+		applyResponseSchema(ctx, modelVal)
 		resp, err := modelVal.GenerateContent(ctx, genai.Text(prompt))
 		if err != nil {
 			return "", model.TokenUsage{}, fmt.Errorf("gemini generation failed: %w", err)
@@ -454,6 +659,9 @@ func (p *GeminiProvider) Generate(ctx context.Context, prompt string, systemProm
 				"candidateCount": 1,
 			},
 		}
+		if SchemaFromContext(ctx) != nil {
+			reqPayload["generationConfig"].(map[string]interface{})["responseMimeType"] = "application/json"
+		}
 		// Removed systemInstruction field to avoid 404/Unknown Field errors on internal API
 
 		// Wrap in outer envelope as expected by Cloud Code API
@@ -489,8 +697,8 @@ func (p *GeminiProvider) Generate(ctx context.Context, prompt string, systemProm
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
 			// Log full details for debugging 500s
-			return "", model.TokenUsage{}, fmt.Errorf("llm generation failed: %s\nURL: %s\nBody Sent: %s\nResponse: %s",
-				resp.Status, url, string(jsonBody), string(body))
+			return "", model.TokenUsage{}, &StatusError{Provider: "gemini", Code: resp.StatusCode,
+				Body: fmt.Sprintf("%s\nURL: %s\nBody Sent: %s\nResponse: %s", resp.Status, url, string(jsonBody), string(body))}
 		}
 
 		// Parse Response
@@ -530,8 +738,11 @@ func (p *GeminiProvider) Close() error {
 type OllamaProvider struct {
 	Model                   string
 	BaseURL                 string
+	Window                  Capabilities // Sizes num_ctx; unset leaves the Ollama default
 	PricePerPromptToken     float64
 	PricePerCompletionToken float64
+	EmbeddingModel          string
+	PricePerEmbeddingToken  float64
 }
 
 func (p *OllamaProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
@@ -543,7 +754,10 @@ func (p *OllamaProvider) Generate(ctx context.Context, prompt string, systemProm
 		"prompt": prompt,
 		"system": systemPrompt,
 		"stream": false,
-		"format": "json", // Force JSON since we usually want structure
+		"format": ollamaFormat(ctx), // Force JSON since we usually want structure
+	}
+	if numCtx := p.numCtx(prompt, systemPrompt); numCtx > 0 {
+		payload["options"] = map[string]interface{}{"num_ctx": numCtx}
 	}
 
 	body, err := json.Marshal(payload)
@@ -565,7 +779,7 @@ func (p *OllamaProvider) Generate(ctx context.Context, prompt string, systemProm
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", model.TokenUsage{}, fmt.Errorf("ollama error %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", model.TokenUsage{}, statusError("ollama", resp.StatusCode, bodyBytes)
 	}
 
 	var result struct {
@@ -582,6 +796,30 @@ func (p *OllamaProvider) Close() error {
 	return nil
 }
 
+// minOllamaContext is the smallest num_ctx requested from Ollama
+const minOllamaContext = 4096
+
+// numCtx returns the num_ctx of a call, 0 when the window of the model is unknown. The default
+// window of Ollama is small and silently truncates longer prompts, so it is raised in powers of
+// two (to limit model reloads) to what the call needs, up to the context length of the model.
+func (p *OllamaProvider) numCtx(texts ...string) int {
+	if p.Window.ContextLength == 0 || p.Window.Tokenizer == nil {
+		return 0
+	}
+	need := p.Window.ContextLength - p.Window.PromptBudget() // Room for the answer
+	for _, text := range texts {
+		need += p.Window.Tokenizer.CountTokens(text)
+	}
+	numCtx := minOllamaContext
+	for numCtx < need {
+		numCtx *= 2
+	}
+	if numCtx > p.Window.ContextLength {
+		numCtx = p.Window.ContextLength
+	}
+	return numCtx
+}
+
 // --- LM Studio Provider (OpenAI Compatible) ---
 
 type LMStudioProvider struct {
@@ -589,6 +827,8 @@ type LMStudioProvider struct {
 	BaseURL                 string
 	PricePerPromptToken     float64
 	PricePerCompletionToken float64
+	EmbeddingModel          string
+	PricePerEmbeddingToken  float64
 }
 
 func (p *LMStudioProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
@@ -628,7 +868,7 @@ func (p *LMStudioProvider) Generate(ctx context.Context, prompt string, systemPr
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", model.TokenUsage{}, fmt.Errorf("lmstudio error %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", model.TokenUsage{}, statusError("lmstudio", resp.StatusCode, bodyBytes)
 	}
 
 	// OpenAI format response
@@ -712,7 +952,7 @@ func (p *OpenRouterProvider) Generate(ctx context.Context, prompt string, system
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", model.TokenUsage{}, fmt.Errorf("openrouter error %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", model.TokenUsage{}, statusError("openrouter", resp.StatusCode, bodyBytes)
 	}
 
 	// OpenAI format response
@@ -781,6 +1021,8 @@ type ZAIProvider struct {
 	APIKey                  string
 	PricePerPromptToken     float64
 	PricePerCompletionToken float64
+	EmbeddingModel          string
+	PricePerEmbeddingToken  float64
 }
 
 func (p *ZAIProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
@@ -820,7 +1062,7 @@ func (p *ZAIProvider) Generate(ctx context.Context, prompt string, systemPrompt
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", model.TokenUsage{}, fmt.Errorf("z.ai error %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", model.TokenUsage{}, statusError("z.ai", resp.StatusCode, bodyBytes)
 	}
 
 	// OpenAI format response
@@ -0,0 +1,403 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// Modes of the replay provider
+const (
+	ReplayModeReplay = "replay" // Serve recorded calls only, fail on a miss
+	ReplayModeRecord = "record" // Call the upstream provider and record every call
+	ReplayModeAuto   = "auto"   // Serve recorded calls, record the misses
+)
+
+// DefaultMinSimilarity is the similarity a fuzzy match needs by default
+const DefaultMinSimilarity = 0.9
+
+// Fixture is a recorded LLM call, stored as <key>.json in the fixtures directory
+type Fixture struct {
+	Key          string                 `json:"key"`
+	Prompt       string                 `json:"prompt"`
+	SystemPrompt string                 `json:"system_prompt"`
+	Schema       map[string]interface{} `json:"schema,omitempty"` // Structured output schema of the call
+	Response     string                 `json:"response"`
+	Reply        *Message               `json:"reply,omitempty"` // Assistant message of a tool turn
+	Usage        model.TokenUsage       `json:"usage"`
+	Recorded     time.Time              `json:"recorded"`
+
+	normalized string              // Prompts with volatile values masked (see normalizePrompt)
+	shingles   map[string]struct{} // Word pairs of normalized, for fuzzy matching
+}
+
+// ReplayMissError is returned in replay mode for a call that was not recorded
+type ReplayMissError struct {
+	Key        string
+	Dir        string
+	Prompt     string  // Start of the prompt
+	Closest    string  // Key of the most similar fixture, empty without fixtures
+	Similarity float64 // Of the closest fixture
+}
+
+func (e *ReplayMissError) Error() string {
+	msg := fmt.Sprintf("no recorded response for call %s (prompt %q) in %s", shortKey(e.Key), e.Prompt, e.Dir)
+	if e.Closest != "" {
+		msg += fmt.Sprintf("; closest fixture %s is %.0f%% similar", shortKey(e.Closest), e.Similarity*100)
+	}
+	return msg + `; record it with mode "record" or "auto"`
+}
+
+// HTTPCode makes a miss a fatal error: retrying can't help, a fallback provider can
+func (e *ReplayMissError) HTTPCode() int {
+	return http.StatusNotFound
+}
+
+// ReplayProvider records LLM calls to fixture files and serves them back, for deterministic
+// runs without a live model. Calls are matched by the hash of the system prompt, the prompt and
+// the structured output schema; then with volatile values (ids, numbers, timestamps) masked;
+// then, in replay mode, by the most similar recorded prompt.
+type ReplayProvider struct {
+	Dir           string   // Fixture files
+	Mode          string   // ReplayModeReplay (default), ReplayModeRecord or ReplayModeAuto
+	Upstream      Provider // Answers the recorded calls
+	UpstreamName  string   // Configured name of Upstream, resolved by the Manager
+	MinSimilarity float64  // Fuzzy match threshold (0-1), 0 uses DefaultMinSimilarity
+
+	mu         sync.Mutex
+	loaded     bool
+	fixtures   map[string]*Fixture // Key -> fixture
+	normalized map[string]*Fixture // Normalized key -> fixture
+}
+
+// NewReplayProvider returns a provider serving the fixtures of dir; upstream answers the calls
+// to record (nil in replay mode)
+func NewReplayProvider(dir, mode string, upstream Provider) *ReplayProvider {
+	return &ReplayProvider{Dir: dir, Mode: mode, Upstream: upstream}
+}
+
+func (p *ReplayProvider) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	f, err := p.serve(ctx, prompt, systemPrompt, SchemaFromContext(ctx), func() (*Fixture, error) {
+		resp, usage, err := p.Upstream.Generate(ctx, prompt, systemPrompt)
+		if err != nil {
+			return nil, err
+		}
+		return &Fixture{Response: resp, Usage: usage}, nil
+	})
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	return f.Response, f.Usage, nil
+}
+
+// GenerateWithTools records and replays tool turns like Generate. A turn is matched by its
+// transcript (see transcript) with the offered tools as schema.
+func (p *ReplayProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	prompt, systemPrompt := transcript(messages)
+	f, err := p.serve(ctx, prompt, systemPrompt, toolsSchema(tools), func() (*Fixture, error) {
+		tp, ok := p.Upstream.(ToolProvider)
+		if !ok {
+			return nil, fmt.Errorf("upstream provider %q of the replay provider does not support tool calling", p.UpstreamName)
+		}
+		reply, usage, err := tp.GenerateWithTools(ctx, messages, tools)
+		if err != nil {
+			return nil, err
+		}
+		return &Fixture{Response: reply.Content, Reply: &reply, Usage: usage}, nil
+	})
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	if f.Reply != nil {
+		return *f.Reply, f.Usage, nil
+	}
+	// A hand-written fixture with only a response answers without tool calls
+	return Message{Role: RoleAssistant, Content: f.Response}, f.Usage, nil
+}
+
+// serve answers a call from the fixtures or, depending on the mode, records the answer of
+// upstream, which fills in the response of the fixture
+func (p *ReplayProvider) serve(ctx context.Context, prompt, systemPrompt string, schema map[string]interface{}, upstream func() (*Fixture, error)) (*Fixture, error) {
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	key := fixtureKey(prompt, systemPrompt, schema)
+
+	mode := p.Mode
+	if mode == "" {
+		mode = ReplayModeReplay
+	}
+	if mode != ReplayModeRecord {
+		if f, how := p.match(key, prompt, systemPrompt, schema, mode == ReplayModeReplay); f != nil {
+			if how != "" {
+				Log(ctx, fmt.Sprintf("Replaying fixture %s (%s)", shortKey(f.Key), how))
+			}
+			return f, nil
+		}
+		if mode == ReplayModeReplay {
+			return nil, p.miss(key, prompt, systemPrompt)
+		}
+	}
+
+	if p.Upstream == nil {
+		return nil, fmt.Errorf("replay provider records calls but has no upstream provider (%q)", p.UpstreamName)
+	}
+	f, err := upstream()
+	if err != nil {
+		return nil, err
+	}
+	f.Key, f.Prompt, f.SystemPrompt, f.Schema, f.Recorded = key, prompt, systemPrompt, schema, time.Now()
+	if err := p.record(f); err != nil {
+		return f, fmt.Errorf("failed to record fixture: %w", err)
+	}
+	return f, nil
+}
+
+func (p *ReplayProvider) Close() error {
+	return nil
+}
+
+// shortKey abbreviates a fixture key for messages; hand-written fixtures may have short keys
+func shortKey(key string) string {
+	if len(key) > 12 {
+		return key[:12]
+	}
+	return key
+}
+
+// fixtureKey addresses a call: the schema of structured output is part of the system prompt,
+// like in the response cache
+func fixtureKey(prompt, systemPrompt string, schema map[string]interface{}) string {
+	if schema != nil {
+		data, _ := json.Marshal(schema)
+		systemPrompt += "\x00" + string(data)
+	}
+	return cacheKey("", systemPrompt, prompt)
+}
+
+// transcript renders a tool conversation as the prompts of a fixture: the system messages
+// are the system prompt, the others one line each. Call ids are left out, they differ per run.
+func transcript(messages []Message) (prompt, systemPrompt string) {
+	var sys, sb strings.Builder
+	for _, m := range messages {
+		if m.Role == RoleSystem {
+			sys.WriteString(m.Content + "\n")
+			continue
+		}
+		sb.WriteString(m.Role + ": " + m.Content + "\n")
+		for _, call := range m.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			sb.WriteString(fmt.Sprintf("%s: call %s %s\n", m.Role, call.Name, args))
+		}
+	}
+	return sb.String(), sys.String()
+}
+
+// toolsSchema describes the offered tools like a structured output schema, so a tool turn
+// never matches a plain call with the same prompts
+func toolsSchema(tools []ToolSpec) map[string]interface{} {
+	var list []interface{}
+	data, _ := json.Marshal(tools)
+	_ = json.Unmarshal(data, &list) // The form a fixture file reads back
+	if list == nil {
+		list = []interface{}{}
+	}
+	return map[string]interface{}{"tools": list}
+}
+
+// load reads the fixtures of the directory, once
+func (p *ReplayProvider) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded {
+		return nil
+	}
+	p.fixtures = make(map[string]*Fixture)
+	p.normalized = make(map[string]*Fixture)
+
+	paths, err := filepath.Glob(filepath.Join(p.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths) // Same winner of normalized duplicates on every run
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+		p.add(&f)
+	}
+	p.loaded = true
+	return nil
+}
+
+// add indexes a fixture; the caller holds the lock
+func (p *ReplayProvider) add(f *Fixture) {
+	if f.Key == "" {
+		f.Key = fixtureKey(f.Prompt, f.SystemPrompt, f.Schema)
+	}
+	f.normalized = normalizePrompt(f.SystemPrompt + "\n" + f.Prompt)
+	f.shingles = shingles(f.normalized)
+	p.fixtures[f.Key] = f
+	normKey := fixtureKey(f.normalized, "", f.Schema)
+	if _, ok := p.normalized[normKey]; !ok {
+		p.normalized[normKey] = f
+	}
+}
+
+// match finds the fixture of a call: by key, by normalized prompt and, when fuzzy, by the most
+// similar prompt. how describes an inexact match.
+func (p *ReplayProvider) match(key, prompt, systemPrompt string, schema map[string]interface{}, fuzzy bool) (*Fixture, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f, ok := p.fixtures[key]; ok {
+		return f, ""
+	}
+	normalized := normalizePrompt(systemPrompt + "\n" + prompt)
+	if f, ok := p.normalized[fixtureKey(normalized, "", schema)]; ok {
+		return f, "volatile values differ"
+	}
+	if !fuzzy {
+		return nil, ""
+	}
+	f, similarity := p.closest(normalized, schema)
+	if f == nil || similarity < p.minSimilarity() {
+		return nil, ""
+	}
+	return f, fmt.Sprintf("%.0f%% similar", similarity*100)
+}
+
+// closest returns the fixture with the same schema whose prompt is most similar; the caller
+// holds the lock
+func (p *ReplayProvider) closest(normalized string, schema map[string]interface{}) (*Fixture, float64) {
+	want := shingles(normalized)
+	schemaJSON, _ := json.Marshal(schema)
+	var best *Fixture
+	bestSimilarity := -1.0
+	for _, f := range p.sortedFixtures() {
+		if data, _ := json.Marshal(f.Schema); string(data) != string(schemaJSON) {
+			continue
+		}
+		if s := similarity(want, f.shingles); s > bestSimilarity {
+			best, bestSimilarity = f, s
+		}
+	}
+	return best, bestSimilarity
+}
+
+// sortedFixtures returns the fixtures by key, so ties resolve the same way on every run; the
+// caller holds the lock
+func (p *ReplayProvider) sortedFixtures() []*Fixture {
+	list := make([]*Fixture, 0, len(p.fixtures))
+	for _, f := range p.fixtures {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+func (p *ReplayProvider) minSimilarity() float64 {
+	if p.MinSimilarity > 0 {
+		return p.MinSimilarity
+	}
+	return DefaultMinSimilarity
+}
+
+// miss describes a call without fixture, with the closest one to help update the fixtures
+func (p *ReplayProvider) miss(key, prompt, systemPrompt string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	start := prompt
+	if len(start) > 80 {
+		start = start[:80] + "..."
+	}
+	err := &ReplayMissError{Key: key, Dir: p.Dir, Prompt: start}
+	want := shingles(normalizePrompt(systemPrompt + "\n" + prompt))
+	err.Similarity = -1
+	for _, f := range p.sortedFixtures() {
+		if s := similarity(want, f.shingles); s > err.Similarity {
+			err.Closest, err.Similarity = f.Key, s
+		}
+	}
+	return err
+}
+
+// record stores a fixture; a call recorded again replaces the previous recording
+func (p *ReplayProvider) record(f *Fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(p.Dir, f.Key+".json"), data); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(f)
+	return nil
+}
+
+// Volatile values masked before matching: ids, timestamps and other numbers change per run
+var (
+	volatileUUID   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	volatileHex    = regexp.MustCompile(`(?i)\b[0-9a-f]{8,}\b`)
+	volatileNumber = regexp.MustCompile(`\d+`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// normalizePrompt masks the volatile values of a prompt and collapses whitespace
+func normalizePrompt(text string) string {
+	text = volatileUUID.ReplaceAllString(text, "<id>")
+	text = volatileHex.ReplaceAllStringFunc(text, func(s string) string {
+		if !strings.ContainsAny(s, "0123456789") {
+			return s // A word
+		}
+		return "<id>"
+	})
+	text = volatileNumber.ReplaceAllString(text, "<n>")
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// shingles returns the pairs of consecutive words of a text
+func shingles(text string) map[string]struct{} {
+	words := strings.Fields(text)
+	set := make(map[string]struct{}, len(words))
+	if len(words) == 1 {
+		set[words[0]] = struct{}{}
+	}
+	for i := 0; i+1 < len(words); i++ {
+		set[words[i]+" "+words[i+1]] = struct{}{}
+	}
+	return set
+}
+
+// similarity is the Dice coefficient of two shingle sets, 1 for the same text
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for s := range a {
+		if _, ok := b[s]; ok {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
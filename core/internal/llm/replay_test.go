@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// Hand-written fixtures may use any key, also one shorter than the abbreviation in messages
+func TestReplayShortFixtureKeys(t *testing.T) {
+	dir := t.TempDir()
+	fixture := `{"key": "abc", "prompt": "hello there", "system_prompt": "", "response": "hi"}`
+	if err := os.WriteFile(filepath.Join(dir, "abc.json"), []byte(fixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewReplayProvider(dir, ReplayModeReplay, nil)
+
+	// Matched by the normalized prompt, which logs the key
+	resp, _, err := p.Generate(context.Background(), "hello   there", "")
+	if err != nil || resp != "hi" {
+		t.Fatalf("expected the fixture, got %q, %v", resp, err)
+	}
+
+	_, _, err = p.Generate(context.Background(), "something else entirely", "")
+	var miss *ReplayMissError
+	if !errors.As(err, &miss) {
+		t.Fatalf("expected a miss, got %v", err)
+	}
+	if !strings.Contains(miss.Error(), "closest fixture abc") {
+		t.Fatalf("expected the closest fixture in %q", miss.Error())
+	}
+	if msg := (&ReplayMissError{Key: "k"}).Error(); !strings.Contains(msg, "call k") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+// scriptedTools answers tool turns: first a call, then an answer with the tool result
+type scriptedTools struct {
+	calls int
+}
+
+func (s *scriptedTools) Generate(ctx context.Context, prompt, systemPrompt string) (string, model.TokenUsage, error) {
+	return "", model.TokenUsage{}, errors.New("unexpected plain call")
+}
+
+func (s *scriptedTools) Close() error {
+	return nil
+}
+
+func (s *scriptedTools) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	s.calls++
+	last := messages[len(messages)-1]
+	if last.Role == RoleTool {
+		return Message{Role: RoleAssistant, Content: "the time is " + last.Content}, model.TokenUsage{TotalTokens: 2, Provider: "ollama", Cached: true}, nil
+	}
+	// The id differs per run, like the ids of a live model
+	call := ToolCall{ID: fmt.Sprintf("call-%d", time.Now().UnixNano()), Name: "clock", Arguments: map[string]interface{}{"zone": "UTC"}}
+	return Message{Role: RoleAssistant, ToolCalls: []ToolCall{call}}, model.TokenUsage{TotalTokens: 1, Provider: "gemini"}, nil
+}
+
+func TestReplayToolTurns(t *testing.T) {
+	dir := t.TempDir()
+	tools := []ToolSpec{{Name: "clock", Description: "Current time", Parameters: map[string]interface{}{"type": "object"}}}
+	messages := []Message{{Role: RoleSystem, Content: "You tell the time"}, {Role: RoleUser, Content: "What time is it?"}}
+	clock := func(ctx context.Context, call ToolCall) (string, error) {
+		return "12:00 " + call.Arguments["zone"].(string), nil
+	}
+
+	upstream := &scriptedTools{}
+	answer, _, usage, err := RunTools(context.Background(), NewReplayProvider(dir, ReplayModeRecord, upstream), messages, tools, clock, 5)
+	if err != nil || answer != "the time is 12:00 UTC" || upstream.calls != 2 {
+		t.Fatalf("record: %q, %v after %d calls", answer, err, upstream.calls)
+	}
+	// The usage of the turns names every provider that answered
+	if usage.Provider != "gemini, ollama" || !usage.Cached {
+		t.Fatalf("expected the usage of both turns, got %+v", usage)
+	}
+
+	// Replayed without upstream, with the recorded tool call
+	replay := NewReplayProvider(dir, ReplayModeReplay, nil)
+	answer, conversation, usage, err := RunTools(context.Background(), replay, messages, tools, clock, 5)
+	if err != nil || answer != "the time is 12:00 UTC" {
+		t.Fatalf("replay: %q, %v", answer, err)
+	}
+	if len(conversation) != 5 || usage.TotalTokens != 3 {
+		t.Fatalf("expected the recorded turns, got %d messages and %d tokens", len(conversation), usage.TotalTokens)
+	}
+
+	// A tool turn is not a plain call with the same prompts
+	prompt, system := transcript(messages)
+	if _, _, err := replay.Generate(context.Background(), prompt, system); err == nil {
+		t.Fatal("expected a miss for a plain call")
+	}
+}
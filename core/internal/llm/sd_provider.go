@@ -134,7 +134,7 @@ func (p *StableDiffusionProvider) Generate(ctx context.Context, prompt string, s
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", totalUsage, fmt.Errorf("SD API Error %d: %s", resp.StatusCode, string(body))
+		return "", totalUsage, statusError("stable-diffusion", resp.StatusCode, body)
 	}
 
 	var parsed sdText2ImgResponse
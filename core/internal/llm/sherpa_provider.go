@@ -79,7 +79,7 @@ func (p *SherpaTTSProvider) Generate(ctx context.Context, prompt string, systemP
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", model.TokenUsage{}, fmt.Errorf("sherpa service error (status %d): %s", resp.StatusCode, string(body))
+		return "", model.TokenUsage{}, statusError("sherpa", resp.StatusCode, body)
 	}
 
 	var genResp GenerateResponse
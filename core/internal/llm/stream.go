@@ -0,0 +1,393 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"google.golang.org/api/iterator"
+)
+
+// StreamHandler receives the text of a generation chunk by chunk, as it arrives
+type StreamHandler func(chunk string)
+
+// StreamProvider is implemented by providers that can stream their output.
+// GenerateStream calls onChunk for every token chunk and returns the full (cleaned) response
+// and the final usage, like Generate.
+type StreamProvider interface {
+	Provider
+	GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error)
+}
+
+type streamKey struct{}
+
+// WithStream returns a context whose generations stream their partial output to handler.
+// Providers without streaming support deliver the full response as a single chunk.
+func WithStream(ctx context.Context, handler StreamHandler) context.Context {
+	return context.WithValue(ctx, streamKey{}, handler)
+}
+
+// StreamFromContext returns the stream handler attached to the context, if any
+func StreamFromContext(ctx context.Context) StreamHandler {
+	if h, ok := ctx.Value(streamKey{}).(StreamHandler); ok {
+		return h
+	}
+	return nil
+}
+
+// GenerateStream uses the default provider and streams the output to onChunk
+func (m *Manager) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	return m.Generate(WithStream(ctx, onChunk), prompt, systemPrompt)
+}
+
+// generateOnce runs a single attempt. With a stream handler on the context the provider streams
+// and the timeout is applied to inactivity instead of the whole generation, so long outputs
+// are not cut off as long as tokens keep arriving.
+func (m *Manager) generateOnce(ctx context.Context, p Provider, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	release, err := m.acquire(ctx, p)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	defer release()
+
+	onChunk := StreamFromContext(ctx)
+	if onChunk == nil {
+		attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+		return p.Generate(attemptCtx, prompt, systemPrompt)
+	}
+
+	sp, ok := p.(StreamProvider)
+	if !ok {
+		attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+		resp, usage, err := p.Generate(attemptCtx, prompt, systemPrompt)
+		if err == nil && resp != "" {
+			onChunk(resp)
+		}
+		return resp, usage, err
+	}
+
+	attemptCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idle := time.AfterFunc(m.timeout, func() {
+		cancel(fmt.Errorf("no output received for %v", m.timeout))
+	})
+	defer idle.Stop()
+
+	resp, usage, err := sp.GenerateStream(attemptCtx, prompt, systemPrompt, func(chunk string) {
+		idle.Reset(m.timeout)
+		onChunk(chunk)
+	})
+	if err != nil && ctx.Err() == nil {
+		if cause := context.Cause(attemptCtx); cause != nil && !errors.Is(cause, context.Canceled) && !strings.Contains(err.Error(), cause.Error()) {
+			err = fmt.Errorf("%w: %v", err, cause)
+		}
+	}
+	return resp, usage, err
+}
+
+// chatPayload builds the request body of an OpenAI compatible chat completion
+func chatPayload(modelName, prompt, systemPrompt string, stream bool) map[string]interface{} {
+	payload := map[string]interface{}{
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": prompt},
+		},
+		"model":       modelName,
+		"temperature": 0.7,
+		"stream":      stream,
+	}
+	if stream {
+		payload["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	return payload
+}
+
+// streamChatCompletion posts an OpenAI compatible streaming request and reads the
+// Server-Sent Events ("data: {...}" lines, terminated by "data: [DONE]")
+func streamChatCompletion(ctx context.Context, name, url string, headers map[string]string, payload map[string]interface{}, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", model.TokenUsage{}, statusError(name, resp.StatusCode, bodyBytes)
+	}
+
+	var sb strings.Builder
+	var usage model.TokenUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // Comments (": keep-alive"), event names and blank separators
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+				TotalTokens      int `json:"total_tokens"`
+			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", model.TokenUsage{}, fmt.Errorf("failed to decode %s stream: %w", name, err)
+		}
+		if chunk.Error != nil {
+			return "", model.TokenUsage{}, fmt.Errorf("%s stream error: %s", name, chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				sb.WriteString(choice.Delta.Content)
+				onChunk(choice.Delta.Content)
+			}
+		}
+		if chunk.Usage != nil {
+			usage.PromptTokens = chunk.Usage.PromptTokens
+			usage.CompletionTokens = chunk.Usage.CompletionTokens
+			usage.TotalTokens = chunk.Usage.TotalTokens
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("%s stream interrupted: %w", name, err)
+	}
+	if sb.Len() == 0 {
+		return "", model.TokenUsage{}, fmt.Errorf("no content from %s", name)
+	}
+	return cleanResponse(sb.String()), usage, nil
+}
+
+func tokenCost(usage model.TokenUsage, pricePerPromptToken, pricePerCompletionToken float64) float64 {
+	return (float64(usage.PromptTokens)/1000000.0)*pricePerPromptToken +
+		(float64(usage.CompletionTokens)/1000000.0)*pricePerCompletionToken
+}
+
+func (p *LMStudioProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	modelName := p.Model
+	if modelName == "" {
+		modelName = "local-model"
+	}
+	payload := chatPayload(modelName, prompt, systemPrompt, true)
+	payload["max_tokens"] = -1
+
+	resp, usage, err := streamChatCompletion(ctx, "lmstudio", fmt.Sprintf("%s/chat/completions", p.BaseURL), nil, payload, onChunk)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return resp, usage, err
+}
+
+func (p *OpenRouterProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	headers := map[string]string{
+		"HTTP-Referer": "druppie",
+		"X-Title":      "Druppie Core",
+	}
+	if p.APIKey != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", p.APIKey)
+	}
+	return streamChatCompletion(ctx, "openrouter", "https://openrouter.ai/api/v1/chat/completions", headers,
+		chatPayload(p.Model, prompt, systemPrompt, true), onChunk)
+}
+
+func (p *ZAIProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	headers := map[string]string{}
+	if p.APIKey != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", p.APIKey)
+	}
+	url := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(p.BaseURL, "/"))
+	resp, usage, err := streamChatCompletion(ctx, "z.ai", url, headers, chatPayload(p.Model, prompt, systemPrompt, true), onChunk)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return resp, usage, err
+}
+
+// GenerateStream reads the newline delimited JSON objects of /api/generate with stream enabled
+func (p *OllamaProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	payload := map[string]interface{}{
+		"model":  p.Model,
+		"prompt": prompt,
+		"system": systemPrompt,
+		"stream": true,
+		"format": ollamaFormat(ctx), // Same output contract as Generate
+	}
+	if numCtx := p.numCtx(prompt, systemPrompt); numCtx > 0 {
+		payload["options"] = map[string]interface{}{"num_ctx": numCtx}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/generate", p.BaseURL), bytes.NewBuffer(body))
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", model.TokenUsage{}, statusError("ollama", resp.StatusCode, bodyBytes)
+	}
+
+	var sb strings.Builder
+	var usage model.TokenUsage
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			Response        string `json:"response"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", model.TokenUsage{}, fmt.Errorf("failed to decode ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", model.TokenUsage{}, fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if chunk.Response != "" {
+			sb.WriteString(chunk.Response)
+			onChunk(chunk.Response)
+		}
+		if chunk.Done {
+			usage.PromptTokens = chunk.PromptEvalCount
+			usage.CompletionTokens = chunk.EvalCount
+			usage.TotalTokens = chunk.PromptEvalCount + chunk.EvalCount
+			break
+		}
+	}
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return cleanResponse(sb.String()), usage, nil
+}
+
+// GenerateStream streams with the API key client. The OAuth (Cloud Code) client has no
+// streaming endpoint we rely on; it returns the full response as a single chunk.
+func (p *GeminiProvider) GenerateStream(ctx context.Context, prompt string, systemPrompt string, onChunk StreamHandler) (string, model.TokenUsage, error) {
+	if p.genaiClient == nil {
+		resp, usage, err := p.Generate(ctx, prompt, systemPrompt)
+		if err == nil && resp != "" {
+			onChunk(resp)
+		}
+		return resp, usage, err
+	}
+
+	modelVal := p.genaiClient.GenerativeModel(p.model)
+	if systemPrompt != "" {
+		modelVal.SystemInstruction = genai.NewUserContent(genai.Text(systemPrompt))
+	}
+	applyResponseSchema(ctx, modelVal)
+
+	var sb strings.Builder
+	var usage model.TokenUsage
+	iter := modelVal.GenerateContentStream(ctx, genai.Text(prompt))
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return "", model.TokenUsage{}, fmt.Errorf("gemini generation failed: %w", err)
+		}
+		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if txt, ok := part.(genai.Text); ok && txt != "" {
+					sb.WriteString(string(txt))
+					onChunk(string(txt))
+				}
+			}
+		}
+		if resp.UsageMetadata != nil {
+			// Each chunk carries the running totals, the last one is final
+			usage.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+			usage.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+			usage.TotalTokens = int(resp.UsageMetadata.TotalTokenCount)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", model.TokenUsage{}, fmt.Errorf("no response candidates")
+	}
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return cleanResponse(sb.String()), usage, nil
+}
+
+// LineWriter collects streamed chunks and hands out complete, non-blank lines, for logs that
+// are line oriented. Flush emits the remaining partial line.
+type LineWriter struct {
+	mu     sync.Mutex
+	buf    strings.Builder
+	OnLine func(line string)
+}
+
+func (w *LineWriter) Write(chunk string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.WriteString(chunk)
+	text := w.buf.String()
+	idx := strings.LastIndexByte(text, '\n')
+	if idx < 0 {
+		return
+	}
+	w.buf.Reset()
+	w.buf.WriteString(text[idx+1:])
+	for _, line := range strings.Split(text[:idx], "\n") {
+		if strings.TrimSpace(line) != "" {
+			w.OnLine(line)
+		}
+	}
+}
+
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if strings.TrimSpace(w.buf.String()) != "" {
+		w.OnLine(w.buf.String())
+	}
+	w.buf.Reset()
+}
@@ -0,0 +1,398 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// MaxRepairRounds bounds the calls GenerateStructured makes to repair an invalid answer
+const MaxRepairRounds = 2
+
+// StructuredError is returned by GenerateStructured when the answer still doesn't match the
+// schema after the repair rounds
+type StructuredError struct {
+	Response string   // Last answer of the model
+	Problems []string // Why it is invalid
+}
+
+func (e *StructuredError) Error() string {
+	return fmt.Sprintf("invalid structured output: %s", strings.Join(e.Problems, "; "))
+}
+
+// GenerateFunc adapts a generate function to a Provider
+type GenerateFunc func(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error)
+
+func (f GenerateFunc) Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+	return f(ctx, prompt, systemPrompt)
+}
+
+func (f GenerateFunc) Close() error {
+	return nil
+}
+
+type schemaKey struct{}
+
+// WithSchema asks the providers that support structured output (Ollama format, OpenAI
+// response_format, Gemini responseSchema) to answer with JSON matching the JSON Schema
+func WithSchema(ctx context.Context, schema map[string]interface{}) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+// SchemaFromContext returns the schema attached with WithSchema, or nil
+func SchemaFromContext(ctx context.Context) map[string]interface{} {
+	schema, _ := ctx.Value(schemaKey{}).(map[string]interface{})
+	return schema
+}
+
+// MustParseSchema parses a JSON Schema literal; it panics on invalid JSON, like regexp.MustCompile
+func MustParseSchema(s string) map[string]interface{} {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &schema); err != nil {
+		panic(fmt.Sprintf("llm: invalid schema: %v", err))
+	}
+	return schema
+}
+
+// GenerateStructured asks the provider for JSON matching the schema and decodes it into out.
+// The schema is passed to providers that support it and described in the prompt for all. An
+// answer that isn't JSON or doesn't match the schema is sent back with the problems found, at
+// most MaxRepairRounds times; then a *StructuredError is returned. It returns the last answer
+// and the usage of all rounds.
+func GenerateStructured(ctx context.Context, p Provider, prompt string, systemPrompt string, schema map[string]interface{}, out interface{}) (string, model.TokenUsage, error) {
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("invalid schema: %w", err)
+	}
+	ctx = WithSchema(ctx, schema)
+	request := fmt.Sprintf("%s\n\nRespond with a single JSON value matching this JSON Schema, without explanation or markdown:\n%s", prompt, schemaJSON)
+
+	var total model.TokenUsage
+	current := request
+	for round := 0; ; round++ {
+		resp, usage, err := p.Generate(ctx, current, systemPrompt)
+		total.Add(usage)
+		if err != nil {
+			return resp, total, err
+		}
+		problems := decodeStructured(resp, schema, out)
+		if len(problems) == 0 {
+			return resp, total, nil
+		}
+		if round >= MaxRepairRounds {
+			return resp, total, &StructuredError{Response: resp, Problems: problems}
+		}
+
+		Log(ctx, fmt.Sprintf("Invalid structured output (%s), repairing (%d/%d)...", strings.Join(problems, "; "), round+1, MaxRepairRounds))
+		current = fmt.Sprintf("%s\n\nYour previous answer was:\n%s\n\nIt is invalid:\n- %s\n\nReturn the corrected JSON only.",
+			request, resp, strings.Join(problems, "\n- "))
+	}
+}
+
+// decodeStructured validates the answer against the schema and decodes it into out. It
+// returns the problems found, none when out was filled.
+func decodeStructured(resp string, schema map[string]interface{}, out interface{}) []string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(ExtractJSON(resp)), &value); err != nil {
+		return []string{fmt.Sprintf("not valid JSON: %v", err)}
+	}
+	value = wrapArray(schema, value)
+	if problems := ValidateSchema(schema, value); len(problems) > 0 {
+		return problems
+	}
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, out)
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// wrapArray puts a bare array in the only array property of an object schema: models often
+// answer {"steps": [...]} with just the list, or with just the one item of it
+func wrapArray(schema map[string]interface{}, value interface{}) interface{} {
+	if !schemaAllows(schema, "object") {
+		return value
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+	target := ""
+	var items map[string]interface{}
+	for name, prop := range props {
+		if ps, ok := prop.(map[string]interface{}); ok && schemaAllows(ps, "array") {
+			if target != "" {
+				return value
+			}
+			target = name
+			items, _ = ps["items"].(map[string]interface{})
+		}
+	}
+	if target == "" {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		return map[string]interface{}{target: v}
+	case map[string]interface{}:
+		if loneItem(props, items, v) {
+			return map[string]interface{}{target: []interface{}{v}}
+		}
+	}
+	return value
+}
+
+// loneItem reports whether an object is an item of the array rather than the object around
+// it: it has none of the properties of the object, but one required of an item
+func loneItem(props, items map[string]interface{}, obj map[string]interface{}) bool {
+	if items == nil || !schemaAllows(items, "object") {
+		return false
+	}
+	for name := range props {
+		if _, ok := obj[name]; ok {
+			return false
+		}
+	}
+	required, _ := items["required"].([]interface{})
+	for _, name := range required {
+		if n, ok := name.(string); ok {
+			if _, ok := obj[n]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExtractJSON returns the JSON in a model answer, without markdown fences or chatty text
+// around the outermost object or array
+func ExtractJSON(resp string) string {
+	clean := strings.TrimSpace(resp)
+
+	// Extract from markdown code blocks if present
+	if start := strings.Index(clean, "```"); start != -1 {
+		if newline := strings.Index(clean[start:], "\n"); newline != -1 {
+			start += newline + 1
+		} else {
+			start += 3
+		}
+		end := strings.LastIndex(clean, "```")
+		if end > start {
+			clean = clean[start:end]
+		}
+	}
+
+	// Scan for the outermost brackets, array or object, whichever comes first
+	startArr := strings.Index(clean, "[")
+	startObj := strings.Index(clean, "{")
+	var start, end int
+	switch {
+	case startArr != -1 && (startObj == -1 || startArr < startObj):
+		start, end = startArr, strings.LastIndex(clean, "]")
+	case startObj != -1:
+		start, end = startObj, strings.LastIndex(clean, "}")
+	default:
+		return clean
+	}
+	if end > start {
+		clean = clean[start : end+1]
+	}
+	return clean
+}
+
+// ValidateSchema checks a decoded JSON value against the subset of JSON Schema used for
+// structured output: type, enum, properties, required, additionalProperties, items, minItems
+// and maxItems. It returns the problems found with the path of the value.
+func ValidateSchema(schema map[string]interface{}, value interface{}) []string {
+	var problems []string
+	validateValue(schema, value, "$", &problems)
+	return problems
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	if len(schema) == 0 {
+		return
+	}
+	if t := jsonType(value); !schemaAllows(schema, t) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(schemaTypes(schema), " or "), t))
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := v[name]; !ok {
+					*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+				}
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := props[name].(map[string]interface{}); ok {
+				validateValue(prop, v[name], path+"."+name, problems)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*problems = append(*problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+				}
+			case map[string]interface{}:
+				validateValue(extra, v[name], path+"."+name, problems)
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			*problems = append(*problems, fmt.Sprintf("%s: expected at least %v items, got %d", path, min, len(v)))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			*problems = append(*problems, fmt.Sprintf("%s: expected at most %v items, got %d", path, max, len(v)))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// jsonType returns the JSON Schema type of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaTypes returns the types a schema allows, empty for any
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// schemaAllows reports whether a value of the JSON type t matches the type of the schema
+func schemaAllows(schema map[string]interface{}, t string) bool {
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return true
+	}
+	for _, allowed := range types {
+		if allowed == t || (allowed == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseSchema returns the schema for a Gemini responseSchema, or nil when it can't be
+// expressed: Gemini requires the properties of every object
+func responseSchema(schema map[string]interface{}) *genai.Schema {
+	if !closedSchema(schema) {
+		return nil
+	}
+	return toGenaiSchema(schema)
+}
+
+func closedSchema(schema map[string]interface{}) bool {
+	props, hasProps := schema["properties"].(map[string]interface{})
+	if schemaTypes(schema) == nil && !hasProps {
+		return false // Any value
+	}
+	if schemaAllows(schema, "object") && len(schemaTypes(schema)) > 0 && len(props) == 0 {
+		return false
+	}
+	for _, prop := range props {
+		if ps, ok := prop.(map[string]interface{}); !ok || !closedSchema(ps) {
+			return false
+		}
+	}
+	items, hasItems := schema["items"].(map[string]interface{})
+	if hasItems && !closedSchema(items) {
+		return false
+	}
+	if schemaAllows(schema, "array") && len(schemaTypes(schema)) > 0 && !hasItems {
+		return false
+	}
+	return true
+}
+
+// responseFormat is the OpenAI response_format of a schema
+func responseFormat(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "response",
+			"schema": schema,
+			"strict": false,
+		},
+	}
+}
+
+// ollamaFormat is the format of an Ollama request: the schema of the context, or plain JSON
+func ollamaFormat(ctx context.Context) interface{} {
+	if schema := SchemaFromContext(ctx); schema != nil {
+		return schema
+	}
+	return "json"
+}
+
+// applyResponseSchema asks Gemini for JSON, constrained to the schema of the context when it
+// can be expressed
+func applyResponseSchema(ctx context.Context, m *genai.GenerativeModel) {
+	schema := SchemaFromContext(ctx)
+	if schema == nil {
+		return
+	}
+	m.ResponseMIMEType = "application/json"
+	m.ResponseSchema = responseSchema(schema)
+}
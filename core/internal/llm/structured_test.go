@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+var stepsSchema = MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "steps": {"type": "array", "items": {
+      "type": "object",
+      "properties": {"agent_id": {"type": "string"}, "action": {"type": "string"}},
+      "required": ["agent_id", "action"]
+    }},
+    "error": {"type": "string"}
+  }
+}`)
+
+// fixedProvider answers every call with the same response and counts the calls
+type fixedProvider struct {
+	resp  string
+	calls int
+}
+
+func (p *fixedProvider) Generate(ctx context.Context, prompt, systemPrompt string) (string, model.TokenUsage, error) {
+	p.calls++
+	return p.resp, model.TokenUsage{}, nil
+}
+
+func (p *fixedProvider) Close() error {
+	return nil
+}
+
+func TestGenerateStructuredWrapsSteps(t *testing.T) {
+	answers := map[string]int{
+		`{"steps": [{"agent_id": "writer", "action": "draft"}]}`:          1,
+		`[{"agent_id": "writer", "action": "draft"}]`:                     1, // Bare list
+		"```json\n{\"agent_id\": \"writer\", \"action\": \"draft\"}\n```": 1, // Lone step
+		`{"error": "no steps"}`:                                           0,
+	}
+	for resp, want := range answers {
+		p := &fixedProvider{resp: resp}
+		var out struct {
+			Steps []struct {
+				AgentID string `json:"agent_id"`
+				Action  string `json:"action"`
+			} `json:"steps"`
+		}
+		if _, _, err := GenerateStructured(context.Background(), p, "plan", "", stepsSchema, &out); err != nil {
+			t.Errorf("%s: %v", resp, err)
+			continue
+		}
+		if len(out.Steps) != want || p.calls != 1 {
+			t.Errorf("%s: expected %d steps in one call, got %+v in %d calls", resp, want, out.Steps, p.calls)
+		}
+		if want == 1 && out.Steps[0].Action != "draft" {
+			t.Errorf("%s: unexpected step %+v", resp, out.Steps[0])
+		}
+	}
+
+	// An object that is not a step is not wrapped
+	p := &fixedProvider{resp: `{"plan": "write a draft"}`}
+	var out map[string]interface{}
+	if _, _, err := GenerateStructured(context.Background(), p, "plan", "", stepsSchema, &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, wrapped := out["steps"]; wrapped || p.calls != 1 {
+		t.Errorf("expected the object unchanged, got %v after %d calls", out, p.calls)
+	}
+}
@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model sees in a text
+type Tokenizer interface {
+	Name() string
+	CountTokens(text string) int
+}
+
+// pretokenize splits text the way BPE tokenizers of the GPT-4 and Llama 3 families do before
+// merging: words with their leading space, numbers of up to 3 digits, punctuation runs and
+// whitespace. (RE2 has no lookahead, so trailing whitespace splits slightly differently.)
+var pretokenize = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// maxPieceBytes bounds the pieces merged at once; longer pieces (base64, minified data) are
+// counted in chunks, merging is quadratic in the piece length
+const maxPieceBytes = 512
+
+// HeuristicTokenizer estimates tokens from the pieces of the text, without a vocabulary
+type HeuristicTokenizer struct {
+	Family    string
+	WordChars float64 // Average letters per token of a word
+}
+
+func (t HeuristicTokenizer) Name() string {
+	return "heuristic/" + t.Family
+}
+
+func (t HeuristicTokenizer) CountTokens(text string) int {
+	wordChars := t.WordChars
+	if wordChars <= 0 {
+		wordChars = 4
+	}
+	n := 0
+	for _, piece := range pretokenize.FindAllString(text, -1) {
+		letters, digits, symbols, wide := 0, 0, 0, 0
+		for _, r := range piece {
+			switch {
+			case r >= 0x2E80: // CJK and other scripts with large per-character vocabularies
+				wide++
+			case unicode.IsLetter(r):
+				letters++
+			case unicode.IsDigit(r):
+				digits++
+			case !unicode.IsSpace(r):
+				symbols++
+			}
+		}
+		tokens := wide + int(math.Ceil(float64(letters)/wordChars)) + int(math.Ceil(float64(digits)/3)) + (symbols+1)/2
+		if tokens == 0 {
+			tokens = 1 // Whitespace and newlines
+		}
+		n += tokens
+	}
+	return n
+}
+
+// BPETokenizer counts tokens with the byte pair ranks of a tiktoken vocabulary file (one
+// "<base64 token> <rank>" per line), the format of cl100k_base.tiktoken, o200k_base.tiktoken
+// and the tokenizer.model of Llama 3
+type BPETokenizer struct {
+	name  string
+	ranks map[string]int
+}
+
+var (
+	bpeMu    sync.Mutex
+	bpeCache = make(map[string]*BPETokenizer) // Path -> loaded vocabulary
+)
+
+// LoadBPETokenizer reads a tiktoken vocabulary file; files already loaded are shared
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	bpeMu.Lock()
+	defer bpeMu.Unlock()
+	if t, ok := bpeCache[path]; ok {
+		return t, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<token> <rank>\"", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".tiktoken")
+	t := &BPETokenizer{name: "bpe/" + name, ranks: ranks}
+	bpeCache[path] = t
+	return t, nil
+}
+
+func (t *BPETokenizer) Name() string {
+	return t.name
+}
+
+func (t *BPETokenizer) CountTokens(text string) int {
+	n := 0
+	for _, piece := range pretokenize.FindAllString(text, -1) {
+		for len(piece) > 0 {
+			chunk := piece
+			if len(chunk) > maxPieceBytes {
+				end := maxPieceBytes
+				for end > 0 && !utf8.RuneStart(chunk[end]) {
+					end--
+				}
+				chunk = chunk[:end]
+			}
+			piece = piece[len(chunk):]
+			if _, ok := t.ranks[chunk]; ok {
+				n++
+				continue
+			}
+			n += t.merge(chunk)
+		}
+	}
+	return n
+}
+
+// merge applies the byte pair merges to a piece, lowest rank first, and returns the number
+// of tokens left
+func (t *BPETokenizer) merge(piece string) int {
+	// bounds[i] is the start of part i; the last bound is the end of the piece
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best == -1 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// tokenizerFamilies are the heuristics of the model families, by the letters per token of
+// their vocabularies
+var tokenizerFamilies = map[string]HeuristicTokenizer{
+	"o200k":         {Family: "o200k", WordChars: 6.5},
+	"cl100k":        {Family: "cl100k", WordChars: 6},
+	"gemini":        {Family: "gemini", WordChars: 6},
+	"claude":        {Family: "claude", WordChars: 5},
+	"llama3":        {Family: "llama3", WordChars: 6}, // tiktoken based, 128k vocabulary
+	"sentencepiece": {Family: "sentencepiece", WordChars: 4.5},
+}
+
+// DefaultTokenizer is used for models of unknown families
+var DefaultTokenizer Tokenizer = HeuristicTokenizer{Family: "default", WordChars: 5}
+
+// familyTokenizer returns the heuristic of a family, DefaultTokenizer when unknown
+func familyTokenizer(family string) Tokenizer {
+	if t, ok := tokenizerFamilies[family]; ok {
+		return t
+	}
+	return DefaultTokenizer
+}
@@ -0,0 +1,653 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// ToolSpec describes a function the model may call. Parameters is a JSON Schema object.
+type ToolSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Message roles of a tool conversation
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one turn of a tool conversation
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Assistant: calls to execute
+	ToolCallID string     `json:"tool_call_id,omitempty"` // Tool: the call this result answers
+	Name       string     `json:"name,omitempty"`         // Tool: the function name
+}
+
+// ToolProvider is implemented by providers with native function calling. GenerateWithTools
+// runs a single model turn and returns the assistant message, which holds either the answer
+// or the tool calls to execute.
+type ToolProvider interface {
+	GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error)
+}
+
+// ToolExecutor executes a tool call and returns the result text for the model
+type ToolExecutor func(ctx context.Context, call ToolCall) (string, error)
+
+// MaxToolResultChars caps a tool result fed back to the model
+const MaxToolResultChars = 20000
+
+// abortError ends RunTools instead of being reported to the model
+type abortError struct{ error }
+
+func (e abortError) Unwrap() error { return e.error }
+
+// AbortTools wraps an error of a ToolExecutor so that RunTools returns it, instead of
+// reporting it to the model
+func AbortTools(err error) error {
+	return abortError{err}
+}
+
+// RunTools lets the model call tools until it answers without tool calls. Failed tool calls
+// are reported to the model, so it can correct its arguments, unless the executor aborts
+// with AbortTools. It returns the final answer, the conversation and the usage of all rounds.
+func RunTools(ctx context.Context, p ToolProvider, messages []Message, tools []ToolSpec, exec ToolExecutor, maxRounds int) (string, []Message, model.TokenUsage, error) {
+	var total model.TokenUsage
+	if maxRounds <= 0 {
+		maxRounds = 10
+	}
+	for round := 0; round < maxRounds; round++ {
+		// Last round: no tools, the model has to answer with what it has
+		offered := tools
+		if round == maxRounds-1 {
+			offered = nil
+		}
+		reply, usage, err := p.GenerateWithTools(ctx, messages, offered)
+		total.Add(usage)
+		if err != nil {
+			return "", messages, total, err
+		}
+		messages = append(messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			return cleanResponse(reply.Content), messages, total, nil
+		}
+
+		for i, call := range reply.ToolCalls {
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call_%d_%d", round+1, i+1)
+				reply.ToolCalls[i].ID = call.ID
+			}
+			result, err := exec(ctx, call)
+			var abort abortError
+			if errors.As(err, &abort) {
+				return "", messages, total, abort.error
+			}
+			if err != nil {
+				result = fmt.Sprintf("Error: %v", err)
+			}
+			if len(result) > MaxToolResultChars {
+				result = result[:MaxToolResultChars] + "\n... (truncated)"
+			}
+			messages = append(messages, Message{Role: RoleTool, ToolCallID: call.ID, Name: call.Name, Content: result})
+		}
+	}
+	return "", messages, total, fmt.Errorf("model did not finish within %d tool rounds", maxRounds)
+}
+
+// GenerateWithTools runs a tool turn on the default provider chain, with retries and fallback
+func (m *Manager) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	chain := m.toolChain()
+	if len(chain) == 0 {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("no default provider with tool calling configured")
+	}
+	return m.generateTools(ctx, chain, messages, tools)
+}
+
+// toolChain returns the providers of the default chain that support tool calling
+func (m *Manager) toolChain() []Provider {
+	var chain []Provider
+	for _, p := range m.chain {
+		if _, ok := p.(ToolProvider); ok {
+			chain = append(chain, p)
+		}
+	}
+	return chain
+}
+
+// ToolProviderFor returns the named provider (the default chain when name is empty) as
+// ToolProvider, with the retries, fallback and auditing of the manager
+func (m *Manager) ToolProviderFor(name string) (ToolProvider, error) {
+	if name == "" {
+		chain := m.toolChain()
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("provider '%s' does not support tool calling", m.providerName(m.defaultProvider))
+		}
+		return &managedToolProvider{m: m, chain: chain}, nil
+	}
+	p, err := m.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.(ToolProvider); !ok {
+		return nil, fmt.Errorf("provider '%s' does not support tool calling", name)
+	}
+	return &managedToolProvider{m: m, chain: []Provider{p}}, nil
+}
+
+type managedToolProvider struct {
+	m     *Manager
+	chain []Provider
+}
+
+func (t *managedToolProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	return t.m.generateTools(ctx, t.chain, messages, tools)
+}
+
+// generateTools runs a tool turn on the first provider of the chain that answers (see callChain)
+func (m *Manager) generateTools(ctx context.Context, chain []Provider, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	var reply Message
+	var usage model.TokenUsage
+	p, err := m.callChain(ctx, chain, func(ctx context.Context, p Provider) error {
+		release, err := m.acquire(ctx, p)
+		if err != nil {
+			return err
+		}
+		defer release()
+		attemptCtx, cancel := context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+		reply, usage, err = p.(ToolProvider).GenerateWithTools(attemptCtx, messages, tools)
+		return err
+	})
+	if err != nil {
+		if p != nil && ctx.Err() == nil {
+			m.auditTools(ctx, p, messages, Message{}, model.TokenUsage{}, err)
+		}
+		return Message{}, model.TokenUsage{}, err
+	}
+	usage.Provider = m.providerName(p)
+	m.auditTools(ctx, p, messages, reply, usage, nil)
+	return reply, usage, nil
+}
+
+// auditTools records a tool turn: the last message sent and the reply (answer and calls)
+func (m *Manager) auditTools(ctx context.Context, p Provider, messages []Message, reply Message, usage model.TokenUsage, err error) {
+	var system, prompt string
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			system = msg.Content
+		}
+	}
+	if len(messages) > 0 {
+		last, _ := json.Marshal(messages[len(messages)-1])
+		prompt = string(last)
+	}
+	resp, _ := json.Marshal(reply)
+	m.audit(ctx, p, prompt, system, string(resp), usage, err)
+}
+
+// --- OpenAI compatible function calling (LM Studio, OpenRouter, Z.AI) ---
+
+type openAIToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+}
+
+func toOpenAIMessages(messages []Message) []openAIMessage {
+	out := make([]openAIMessage, 0, len(messages))
+	for _, msg := range messages {
+		content := msg.Content
+		om := openAIMessage{Role: msg.Role, Content: &content, ToolCallID: msg.ToolCallID}
+		if msg.Role == RoleTool {
+			om.Name = msg.Name
+		}
+		if len(msg.ToolCalls) > 0 {
+			if content == "" {
+				om.Content = nil
+			}
+			for _, call := range msg.ToolCalls {
+				tc := openAIToolCall{ID: call.ID, Type: "function"}
+				tc.Function.Name = call.Name
+				args, _ := json.Marshal(call.Arguments)
+				tc.Function.Arguments = string(args)
+				om.ToolCalls = append(om.ToolCalls, tc)
+			}
+		}
+		out = append(out, om)
+	}
+	return out
+}
+
+func toOpenAITools(tools []ToolSpec) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  toolParameters(t.Parameters),
+			},
+		})
+	}
+	return out
+}
+
+// toolParameters defaults an empty schema to an object without properties
+func toolParameters(schema map[string]interface{}) map[string]interface{} {
+	if len(schema) == 0 {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return schema
+}
+
+// parseToolArguments decodes the arguments of a call; models sometimes send them double encoded
+func parseToolArguments(raw string) (map[string]interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return map[string]interface{}{}, nil
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &args); err == nil {
+		return args, nil
+	}
+	var inner string
+	if err := json.Unmarshal([]byte(raw), &inner); err == nil {
+		if err := json.Unmarshal([]byte(inner), &args); err == nil {
+			return args, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid tool arguments: %s", raw)
+}
+
+// chatWithTools posts an OpenAI compatible chat completion with tools
+func chatWithTools(ctx context.Context, name, url string, headers map[string]string, modelName string, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	payload := map[string]interface{}{
+		"model":       modelName,
+		"messages":    toOpenAIMessages(messages),
+		"temperature": 0.2,
+		"stream":      false,
+	}
+	if len(tools) > 0 {
+		payload["tools"] = toOpenAITools(tools)
+		payload["tool_choice"] = "auto"
+	} else if schema := SchemaFromContext(ctx); schema != nil {
+		payload["response_format"] = responseFormat(schema)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return Message{}, model.TokenUsage{}, statusError(name, resp.StatusCode, bodyBytes)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content   string           `json:"content"`
+				ToolCalls []openAIToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("failed to decode %s response: %w", name, err)
+	}
+	if len(result.Choices) == 0 {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("no content from %s", name)
+	}
+
+	choice := result.Choices[0].Message
+	reply := Message{Role: RoleAssistant, Content: choice.Content}
+	for _, tc := range choice.ToolCalls {
+		args, err := parseToolArguments(tc.Function.Arguments)
+		if err != nil {
+			// Let the model see its mistake instead of failing the turn
+			args = map[string]interface{}{"_invalid_arguments": tc.Function.Arguments}
+		}
+		reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args})
+	}
+	usage := model.TokenUsage{
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		TotalTokens:      result.Usage.TotalTokens,
+	}
+	return reply, usage, nil
+}
+
+func (p *LMStudioProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	modelName := p.Model
+	if modelName == "" {
+		modelName = "local-model"
+	}
+	reply, usage, err := chatWithTools(ctx, "lmstudio", fmt.Sprintf("%s/chat/completions", p.BaseURL), nil, modelName, messages, tools)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return reply, usage, err
+}
+
+func (p *OpenRouterProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	headers := map[string]string{
+		"HTTP-Referer": "druppie",
+		"X-Title":      "Druppie Core",
+	}
+	if p.APIKey != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", p.APIKey)
+	}
+	return chatWithTools(ctx, "openrouter", "https://openrouter.ai/api/v1/chat/completions", headers, p.Model, messages, tools)
+}
+
+func (p *ZAIProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	headers := map[string]string{}
+	if p.APIKey != "" {
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", p.APIKey)
+	}
+	url := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(p.BaseURL, "/"))
+	reply, usage, err := chatWithTools(ctx, "z.ai", url, headers, p.Model, messages, tools)
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return reply, usage, err
+}
+
+// --- Ollama function calling (/api/chat) ---
+
+func (p *OllamaProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	type ollamaCall struct {
+		Function struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		} `json:"function"`
+	}
+	type ollamaMessage struct {
+		Role      string       `json:"role"`
+		Content   string       `json:"content"`
+		ToolCalls []ollamaCall `json:"tool_calls,omitempty"`
+		ToolName  string       `json:"tool_name,omitempty"`
+	}
+
+	msgs := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		om := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		if msg.Role == RoleTool {
+			om.ToolName = msg.Name
+		}
+		for _, call := range msg.ToolCalls {
+			var oc ollamaCall
+			oc.Function.Name = call.Name
+			oc.Function.Arguments = call.Arguments
+			om.ToolCalls = append(om.ToolCalls, oc)
+		}
+		msgs = append(msgs, om)
+	}
+
+	payload := map[string]interface{}{
+		"model":    p.Model,
+		"messages": msgs,
+		"stream":   false,
+	}
+	if len(tools) > 0 {
+		payload["tools"] = toOpenAITools(tools) // Same tool format as OpenAI
+	}
+	texts := make([]string, 0, len(msgs)+1)
+	for _, msg := range msgs {
+		texts = append(texts, msg.Content)
+	}
+	if len(tools) > 0 {
+		data, _ := json.Marshal(payload["tools"])
+		texts = append(texts, string(data))
+	}
+	if numCtx := p.numCtx(texts...); numCtx > 0 {
+		payload["options"] = map[string]interface{}{"num_ctx": numCtx}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/chat", p.BaseURL), bytes.NewBuffer(body))
+	if err != nil {
+		return Message{}, model.TokenUsage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return Message{}, model.TokenUsage{}, statusError("ollama", resp.StatusCode, bodyBytes)
+	}
+
+	var result struct {
+		Message         ollamaMessage `json:"message"`
+		PromptEvalCount int           `json:"prompt_eval_count"`
+		EvalCount       int           `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+
+	reply := Message{Role: RoleAssistant, Content: result.Message.Content}
+	for _, call := range result.Message.ToolCalls {
+		args := call.Function.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		reply.ToolCalls = append(reply.ToolCalls, ToolCall{Name: call.Function.Name, Arguments: args})
+	}
+	usage := model.TokenUsage{
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+		TotalTokens:      result.PromptEvalCount + result.EvalCount,
+	}
+	usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	return reply, usage, nil
+}
+
+// --- Gemini function calling ---
+
+// GenerateWithTools uses the API key client; the OAuth (Cloud Code) client has no function calling
+func (p *GeminiProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (Message, model.TokenUsage, error) {
+	if p.genaiClient == nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("gemini tool calling requires an API key")
+	}
+
+	modelVal := p.genaiClient.GenerativeModel(p.model)
+	if len(tools) > 0 {
+		decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+		for _, t := range tools {
+			decls = append(decls, &genai.FunctionDeclaration{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  toGenaiSchema(toolParameters(t.Parameters)),
+			})
+		}
+		modelVal.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	}
+
+	var history []*genai.Content
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			modelVal.SystemInstruction = genai.NewUserContent(genai.Text(msg.Content))
+		case RoleAssistant:
+			content := &genai.Content{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, genai.Text(msg.Content))
+			}
+			for _, call := range msg.ToolCalls {
+				content.Parts = append(content.Parts, genai.FunctionCall{Name: call.Name, Args: call.Arguments})
+			}
+			history = append(history, content)
+		case RoleTool:
+			part := genai.FunctionResponse{Name: msg.Name, Response: map[string]any{"result": msg.Content}}
+			// Results of one round are sent together as one turn
+			if n := len(history); n > 0 && history[n-1].Role == "user" && isFunctionResponse(history[n-1]) {
+				history[n-1].Parts = append(history[n-1].Parts, part)
+			} else {
+				history = append(history, genai.NewUserContent(part))
+			}
+		default:
+			history = append(history, genai.NewUserContent(genai.Text(msg.Content)))
+		}
+	}
+	if len(history) == 0 {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("no messages to send")
+	}
+
+	chat := modelVal.StartChat()
+	chat.History = history[:len(history)-1]
+	resp, err := chat.SendMessage(ctx, history[len(history)-1].Parts...)
+	if err != nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("gemini generation failed: %w", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return Message{}, model.TokenUsage{}, fmt.Errorf("no response candidates")
+	}
+
+	reply := Message{Role: RoleAssistant}
+	var sb strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		switch v := part.(type) {
+		case genai.Text:
+			sb.WriteString(string(v))
+		case genai.FunctionCall:
+			reply.ToolCalls = append(reply.ToolCalls, ToolCall{Name: v.Name, Arguments: v.Args})
+		}
+	}
+	reply.Content = sb.String()
+
+	usage := model.TokenUsage{}
+	if resp.UsageMetadata != nil {
+		usage.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		usage.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+		usage.TotalTokens = int(resp.UsageMetadata.TotalTokenCount)
+		usage.EstimatedCost = tokenCost(usage, p.PricePerPromptToken, p.PricePerCompletionToken)
+	}
+	return reply, usage, nil
+}
+
+func isFunctionResponse(c *genai.Content) bool {
+	for _, part := range c.Parts {
+		if _, ok := part.(genai.FunctionResponse); !ok {
+			return false
+		}
+	}
+	return len(c.Parts) > 0
+}
+
+// toGenaiSchema converts a JSON Schema document to the (OpenAPI subset) schema of Gemini
+func toGenaiSchema(schema map[string]interface{}) *genai.Schema {
+	s := &genai.Schema{}
+	typ, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok {
+		// ["string", "null"]
+		for _, t := range types {
+			if ts, _ := t.(string); ts == "null" {
+				s.Nullable = true
+			} else if typ == "" {
+				typ = ts
+			}
+		}
+	}
+	switch typ {
+	case "string":
+		s.Type = genai.TypeString
+	case "number":
+		s.Type = genai.TypeNumber
+	case "integer":
+		s.Type = genai.TypeInteger
+	case "boolean":
+		s.Type = genai.TypeBoolean
+	case "array":
+		s.Type = genai.TypeArray
+	default:
+		if _, hasProps := schema["properties"]; hasProps || typ == "object" {
+			s.Type = genai.TypeObject
+		} else {
+			s.Type = genai.TypeString // Untyped values are passed as text
+		}
+	}
+	s.Description, _ = schema["description"].(string)
+	if f, ok := schema["format"].(string); ok && (f == "enum" || s.Type == genai.TypeNumber || s.Type == genai.TypeInteger) {
+		s.Format = f
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && s.Type == genai.TypeString {
+		for _, e := range enum {
+			s.Enum = append(s.Enum, fmt.Sprint(e))
+		}
+		s.Format = "enum"
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		s.Items = toGenaiSchema(items)
+	} else if s.Type == genai.TypeArray {
+		s.Items = &genai.Schema{Type: genai.TypeString}
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		s.Properties = make(map[string]*genai.Schema, len(props))
+		for name, prop := range props {
+			if ps, ok := prop.(map[string]interface{}); ok {
+				s.Properties[name] = toGenaiSchema(ps)
+			}
+		}
+	}
+	if req, ok := schema["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PromptSection is a part of a prompt that can be shortened to fit the context window
+type PromptSection struct {
+	Name    string
+	Text    string
+	Shorten func(maxTokens int) string // Returns the text in at most maxTokens; nil keeps the start (KeepStart)
+}
+
+// FitPrompt shortens the sections, in order, until they fit in budget tokens together with the
+// fixed text of the prompt. A section is only shortened when the ones before it were not
+// enough. It returns the names of the shortened sections.
+func FitPrompt(tok Tokenizer, budget int, fixed string, sections []*PromptSection) []string {
+	total := tok.CountTokens(fixed)
+	sizes := make([]int, len(sections))
+	for i, s := range sections {
+		sizes[i] = tok.CountTokens(s.Text)
+		total += sizes[i]
+	}
+
+	var shortened []string
+	for i, s := range sections {
+		if total <= budget {
+			break
+		}
+		maxTokens := sizes[i] - (total - budget)
+		if maxTokens < 0 {
+			maxTokens = 0
+		}
+		if s.Shorten != nil {
+			s.Text = s.Shorten(maxTokens)
+		} else {
+			s.Text = KeepStart(tok, s.Text, maxTokens)
+		}
+		size := tok.CountTokens(s.Text)
+		total += size - sizes[i]
+		sizes[i] = size
+		shortened = append(shortened, s.Name)
+	}
+	return shortened
+}
+
+// Markers of text left out by KeepStart and KeepEnd
+const (
+	truncatedEnd   = "... (truncated)"
+	truncatedStart = "(earlier part omitted) ..."
+)
+
+// KeepStart returns the start of the text in at most maxTokens, cut at a line when possible
+func KeepStart(tok Tokenizer, text string, maxTokens int) string {
+	if tok.CountTokens(text) <= maxTokens {
+		return text
+	}
+	maxTokens -= tok.CountTokens("\n" + truncatedEnd)
+	if maxTokens <= 0 {
+		return ""
+	}
+	fits := func(n int) bool { return tok.CountTokens(text[:n]) <= maxTokens }
+	cut := longestFit(lineEnds(text), fits)
+	if cut == 0 {
+		cut = longestFit(runeStarts(text), fits)
+	}
+	return strings.TrimRight(text[:cut], "\n") + "\n" + truncatedEnd
+}
+
+// KeepEnd returns the end of the text in at most maxTokens, cut at a line when possible; for
+// history, where the latest turns matter most
+func KeepEnd(tok Tokenizer, text string, maxTokens int) string {
+	if tok.CountTokens(text) <= maxTokens {
+		return text
+	}
+	maxTokens -= tok.CountTokens(truncatedStart + "\n")
+	if maxTokens <= 0 {
+		return ""
+	}
+	// Offsets counted from the end, so longer suffixes come later
+	fits := func(n int) bool { return tok.CountTokens(text[len(text)-n:]) <= maxTokens }
+	var suffixes []int
+	for _, start := range lineEnds(text) {
+		suffixes = append(suffixes, len(text)-start)
+	}
+	sort.Ints(suffixes)
+	keep := longestFit(suffixes, fits)
+	if keep == 0 {
+		var runes []int
+		for _, start := range runeStarts(text) {
+			runes = append(runes, len(text)-start)
+		}
+		sort.Ints(runes)
+		keep = longestFit(runes, fits)
+	}
+	return truncatedStart + "\n" + strings.TrimLeft(text[len(text)-keep:], "\n")
+}
+
+// longestFit returns the largest of the ascending lengths that fits, 0 when none does
+func longestFit(lengths []int, fits func(n int) bool) int {
+	i := sort.Search(len(lengths), func(i int) bool { return !fits(lengths[i]) })
+	if i == 0 {
+		return 0
+	}
+	return lengths[i-1]
+}
+
+// lineEnds returns the offsets just after each newline of the text, ascending
+func lineEnds(text string) []int {
+	var ends []int
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			ends = append(ends, i+1)
+		}
+	}
+	return ends
+}
+
+// runeStarts returns the offsets of the runes of the text after the first, ascending
+func runeStarts(text string) []int {
+	starts := make([]int, 0, len(text))
+	for i := 1; i < len(text); i++ {
+		if utf8.RuneStart(text[i]) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
@@ -12,11 +12,37 @@ import (
 )
 
 var (
-	logMu sync.Mutex
+	logMu   sync.Mutex
+	backend Backend
 )
 
+// Backend receives plan logs instead of the execution.log files.
+// It is set when plans are persisted somewhere other than the file system (e.g. SQL store).
+type Backend interface {
+	LogInteraction(planID string, tag string, input string, output string) error
+	AppendRawLog(planID string, message string) error
+	GetLogs(planID string) (string, error)
+}
+
+// SetBackend routes all plan logging to the given backend. Passing nil restores file logging.
+func SetBackend(b Backend) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	backend = b
+}
+
+func currentBackend() Backend {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return backend
+}
+
 // LogInteraction appends a formatted interaction log to the plan's execution.log
 func LogInteraction(planID string, tag string, input string, output string) error {
+	if b := currentBackend(); b != nil {
+		return b.LogInteraction(planID, tag, input, output)
+	}
+
 	logMu.Lock()
 	defer logMu.Unlock()
 
@@ -45,6 +71,10 @@ func LogInteraction(planID string, tag string, input string, output string) erro
 
 // AppendRawLog appends a raw line to the plan's execution.log
 func AppendRawLog(planID string, message string) error {
+	if b := currentBackend(); b != nil {
+		return b.AppendRawLog(planID, message)
+	}
+
 	logMu.Lock()
 	defer logMu.Unlock()
 
@@ -75,6 +105,10 @@ func GetLogPath(planID string) (string, error) {
 
 // GetLogs reads the execution.log for a given plan
 func GetLogs(planID string) (string, error) {
+	if b := currentBackend(); b != nil {
+		return b.GetLogs(planID)
+	}
+
 	logMu.Lock()
 	defer logMu.Unlock()
 
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/paths"
 	"github.com/sjhoeksma/druppie/core/internal/registry"
 	"github.com/sjhoeksma/druppie/core/internal/store"
@@ -270,6 +271,7 @@ func (m *Manager) refreshToolsLocked(ctx context.Context) error {
 			// Register namespaced name (server__tool) for uniqueness
 			namespaced := fmt.Sprintf("%s__%s", name, tool.Name)
 			m.tools[namespaced] = name
+			tool.Server = name
 			m.cachedTools = append(m.cachedTools, tool)
 		}
 	}
@@ -310,7 +312,28 @@ func (m *Manager) ExecuteTool(ctx context.Context, toolName string, args map[str
 		realToolName = strings.TrimPrefix(toolName, serverName+"__")
 	}
 
-	return client.CallTool(ctx, realToolName, args)
+	result, err := client.CallTool(ctx, realToolName, args)
+	auditToolCall(ctx, serverName, toolName, args, result, err)
+	return result, err
+}
+
+// auditToolCall records the invocation on the audit chain of the context
+func auditToolCall(ctx context.Context, serverName, toolName string, args map[string]interface{}, result *CallToolResult, err error) {
+	argsJSON, _ := json.Marshal(args)
+	data := map[string]interface{}{
+		"server":    serverName,
+		"tool":      toolName,
+		"arguments": string(argsJSON),
+	}
+	if result != nil {
+		resultJSON, _ := json.Marshal(result)
+		data["result_hash"] = audit.HashContent(string(resultJSON))
+		data["is_error"] = result.IsError
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	audit.Log(audit.ChainFromContext(ctx), audit.TypeToolCall, audit.ActorFromContext(ctx), 0, "Tool "+toolName, data)
 }
 
 // GetServers returns list of configs
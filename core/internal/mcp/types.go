@@ -23,6 +23,7 @@ type Tool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema interface{} `json:"inputSchema"`
+	Server      string      `json:"server,omitempty"` // Set by the manager: server hosting the tool
 }
 
 // ListToolsResult matches the result of tools/list
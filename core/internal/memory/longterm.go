@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+)
+
+// Long-term memory defaults
+const (
+	DefaultRecallTopK   = 5
+	DefaultMinRelevance = 0.2
+	chunkSize           = 1200
+	chunkOverlap        = 150
+	maxRememberChars    = 200000 // Larger documents are only remembered in part
+	maxFactChars        = 600    // Length of a fact in the context
+)
+
+// Remember chunks and embeds text into the long-term memory of a plan. kind is one of the
+// Kind constants, source identifies where the text came from (step, file, approval);
+// remembering the same source again replaces its chunks.
+func (m *Manager) Remember(ctx context.Context, planID, kind, source, text string) error {
+	if m.Vectors == nil || planID == "" {
+		return nil
+	}
+	if len(text) > maxRememberChars {
+		text = text[:maxRememberChars]
+	}
+	chunks := Chunk(text, chunkSize, chunkOverlap)
+	if len(chunks) == 0 {
+		return m.Vectors.DeleteSource(ctx, planID, kind, source)
+	}
+
+	vectors, usage, err := m.embedder().Embed(ctx, chunks)
+	m.addUsage(planID, usage)
+	if err != nil {
+		return fmt.Errorf("failed to embed %s: %w", source, err)
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("failed to embed %s: got %d vectors for %d chunks", source, len(vectors), len(chunks))
+	}
+
+	now := time.Now()
+	records := make([]VectorRecord, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = VectorRecord{
+			ID:        fmt.Sprintf("%s/%s/%s#%d", planID, kind, source, i),
+			PlanID:    planID,
+			Kind:      kind,
+			Source:    source,
+			Text:      chunk,
+			Vector:    vectors[i],
+			CreatedAt: now,
+		}
+	}
+	// The new text may have fewer chunks than the old one
+	if err := m.Vectors.DeleteSource(ctx, planID, kind, source); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", source, err)
+	}
+	return m.Vectors.Upsert(ctx, records)
+}
+
+// RememberAsync remembers in the background, so plan execution does not wait for embeddings
+func (m *Manager) RememberAsync(planID, kind, source, text string) {
+	if m == nil || m.Vectors == nil || strings.TrimSpace(text) == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(audit.WithChain(context.Background(), planID), 2*time.Minute)
+		defer cancel()
+		if err := m.Remember(ctx, planID, kind, source, text); err != nil {
+			fmt.Printf("[Memory] Failed to remember %s of plan %s: %v\n", source, planID, err)
+		}
+	}()
+}
+
+// Recall returns the remembered chunks most relevant to the query within the given plans
+// (all plans when planIDs is nil)
+func (m *Manager) Recall(ctx context.Context, query string, planIDs []string, topK int) ([]VectorMatch, error) {
+	if m.Vectors == nil || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if topK <= 0 {
+		topK = m.RecallTopK
+	}
+	if topK <= 0 {
+		topK = DefaultRecallTopK
+	}
+	vectors, _, err := m.embedder().Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	matches, err := m.Vectors.Search(ctx, vectors[0], planIDs, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	minScore := m.MinRelevance
+	if minScore == 0 {
+		minScore = DefaultMinRelevance
+	}
+	relevant := matches[:0]
+	for _, match := range matches {
+		if match.Score >= minScore {
+			relevant = append(relevant, match)
+		}
+	}
+	return relevant, nil
+}
+
+// ForgetPlan removes the long-term memory of a deleted plan
+func (m *Manager) ForgetPlan(ctx context.Context, planID string) error {
+	if m == nil || m.Vectors == nil {
+		return nil
+	}
+	return m.Vectors.DeletePlan(ctx, planID)
+}
+
+func (m *Manager) embedder() Embedder {
+	if m.Embedder != nil {
+		return m.Embedder
+	}
+	return llm.HashEmbedder{}
+}
+
+// Scope returns the plans whose memory the user of the context may read: plans without
+// a creator, the user's own plans and plans shared with one of the user's groups.
+// Without a user (a resumed or recovered task) the plan's creator and allowed groups stand in
+// for the user. Only for the demo user, or without user and creator (CLI), every plan is in
+// scope (nil).
+func (m *Manager) Scope(ctx context.Context, planID string) []string {
+	if m.store == nil {
+		return nil
+	}
+	var userID, username string
+	var groups []string
+	if user, ok := iam.GetUserFromContext(ctx); ok {
+		if user.ID == "demo-user" {
+			return nil
+		}
+		userID, username, groups = user.ID, user.Username, user.Groups
+	} else {
+		plan, err := m.store.GetPlan(planID)
+		if err != nil {
+			return []string{planID}
+		}
+		if plan.CreatorID == "" {
+			return nil
+		}
+		userID, username, groups = plan.CreatorID, plan.CreatorID, plan.AllowedGroups
+	}
+
+	scope := []string{planID}
+	plans, err := m.store.ListPlans()
+	if err != nil {
+		return scope
+	}
+	for _, p := range plans {
+		if p.ID != planID && p.VisibleTo(userID, username, groups) {
+			scope = append(scope, p.ID)
+		}
+	}
+	return scope
+}
+
+// GetContext returns the recent turns of the plan and the long-term facts relevant to the prompt,
+// retrieved from the plans in scope (see Scope)
+func (m *Manager) GetContext(ctx context.Context, planID, prompt string, scope []string) MemoryContext {
+	mc := MemoryContext{RecentTurns: m.history(planID)}
+
+	query := prompt
+	if query == "" {
+		// Last user turn stands for the current question
+		for i := len(mc.RecentTurns) - 1; i >= 0; i-- {
+			if mc.RecentTurns[i].Role == "user" {
+				query = mc.RecentTurns[i].Content
+				break
+			}
+		}
+	}
+	matches, err := m.Recall(ctx, query, scope, 0)
+	if err != nil {
+		fmt.Printf("[Memory] Recall for plan %s failed: %v\n", planID, err)
+	}
+	for _, match := range matches {
+		text := match.Text
+		if len(text) > maxFactChars {
+			text = text[:maxFactChars] + "..."
+		}
+		origin := match.Source
+		if match.PlanID != planID {
+			origin = match.PlanID + " " + origin
+		}
+		mc.RelevantFacts = append(mc.RelevantFacts, fmt.Sprintf("[%s: %s] %s", match.Kind, origin, text))
+	}
+	return mc
+}
+
+// String renders the context for a prompt: summary, recent turns and relevant facts
+func (c MemoryContext) String() string {
+	var sb strings.Builder
+	for _, entry := range c.RecentTurns {
+		if entry.Role == RoleSummary {
+			// Summary block of the older turns comes first
+			sb.WriteString(fmt.Sprintf("SUMMARY OF EARLIER CONVERSATION:\n%s\n\n", entry.Summary))
+			continue
+		}
+		// Simple format: Role: Content
+		sb.WriteString(fmt.Sprintf("%s: %s\n", roleLabel(entry.Role), entry.Content))
+	}
+	if len(c.RelevantFacts) > 0 {
+		sb.WriteString("\nRELEVANT FACTS FROM MEMORY:\n")
+		for _, fact := range c.RelevantFacts {
+			sb.WriteString("- " + fact + "\n")
+		}
+	}
+	return sb.String()
+}
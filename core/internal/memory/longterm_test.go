@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/iam"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// newTestManager returns a manager with the file vector store and the default local
+// HashEmbedder, and the plans of alice (shared with the designers) and bob
+func newTestManager(t *testing.T) *Manager {
+	dir := t.TempDir()
+	s, err := store.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []model.ExecutionPlan{
+		{ID: "plan-alice", CreatorID: "alice", AllowedGroups: []string{"designers"}},
+		{ID: "plan-bob", CreatorID: "bob"},
+	} {
+		if err := s.SavePlan(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewManager(0, s)
+	m.Vectors = NewFileVectorStore(dir)
+	return m
+}
+
+func TestRememberAndRecall(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+	facts := map[string]string{
+		"step 1": "The database of the shop runs on PostgreSQL 16 in the eu-west region.",
+		"step 2": "The logo of the shop is a green bicycle on a white background.",
+		"step 3": "Invoices are sent every first Monday of the month.",
+	}
+	for source, text := range facts {
+		if err := m.Remember(ctx, "plan-alice", KindStepResult, source, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := m.Recall(ctx, "Which database does the shop use?", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 || matches[0].Source != "step 1" {
+		t.Fatalf("expected the database fact first, got %+v", matches)
+	}
+	if matches, _ := m.Recall(ctx, "quantum chromodynamics", nil, 0); len(matches) != 0 {
+		t.Errorf("expected no relevant facts for an unrelated query, got %+v", matches)
+	}
+
+	// Remembering a source again replaces it
+	if err := m.Remember(ctx, "plan-alice", KindStepResult, "step 1", "The database of the shop moved to MySQL."); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ = m.Recall(ctx, "database of the shop", nil, 10)
+	var texts []string
+	for _, match := range matches {
+		if match.Source == "step 1" {
+			texts = append(texts, match.Text)
+		}
+	}
+	if len(texts) != 1 || !strings.Contains(texts[0], "MySQL") {
+		t.Errorf("expected only the new text of step 1, got %q", texts)
+	}
+
+	if err := m.ForgetPlan(ctx, "plan-alice"); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := m.Recall(ctx, "database of the shop", nil, 0); len(matches) != 0 {
+		t.Errorf("expected nothing after ForgetPlan, got %+v", matches)
+	}
+}
+
+func TestRememberShorterDocumentDropsOldChunks(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+	long := strings.Repeat("The shop stores its orders in a PostgreSQL database with daily backups. ", 100)
+	if err := m.Remember(ctx, "plan-alice", KindDocument, "doc.md", long); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remember(ctx, "plan-alice", KindDocument, "doc.md", "The shop stores its orders in SQLite."); err != nil {
+		t.Fatal(err)
+	}
+
+	// Also after loading the index from disk
+	stores := map[string]VectorStore{
+		"cached":   m.Vectors,
+		"reloaded": NewFileVectorStore(m.Vectors.(*FileVectorStore).baseDir),
+	}
+	for name, vectors := range stores {
+		records, err := vectors.Search(ctx, nil, []string{"plan-alice"}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || !strings.Contains(records[0].Text, "SQLite") {
+			t.Errorf("%s: expected only the new chunk of doc.md, got %d chunks", name, len(records))
+		}
+	}
+}
+
+func TestContextOnlyRecallsPlansInScope(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+	if err := m.Remember(ctx, "plan-alice", KindDecision, "approval step 2", "The budget for the shop redesign is 5000 euro."); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remember(ctx, "plan-bob", KindDecision, "approval step 4", "The budget for the bob salary raise is secret."); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scopes of bob's plan; without a user its creator stands in
+	scopes := map[string]struct {
+		ctx  context.Context
+		want string
+	}{
+		"owner":        {iam.ContextWithUser(ctx, &iam.User{ID: "bob", Username: "bob"}), "plan-bob"},
+		"group member": {iam.ContextWithUser(ctx, &iam.User{ID: "carol", Groups: []string{"designers"}}), "plan-bob,plan-alice"},
+		"outsider":     {iam.ContextWithUser(ctx, &iam.User{ID: "mallory"}), "plan-bob"},
+		"no user":      {ctx, "plan-bob"},
+		"demo user":    {iam.ContextWithUser(ctx, &iam.User{ID: "demo-user"}), ""},
+	}
+	for name, tc := range scopes {
+		if got := strings.Join(m.Scope(tc.ctx, "plan-bob"), ","); got != tc.want {
+			t.Errorf("%s: expected scope %q, got %q", name, tc.want, got)
+		}
+	}
+
+	// Carol works on bob's plan and may see the decisions of alice's plan, mallory may not
+	carol := iam.ContextWithUser(ctx, &iam.User{ID: "carol", Groups: []string{"designers"}})
+	facts := m.GetContext(carol, "plan-bob", "What is the budget?", m.Scope(carol, "plan-bob")).String()
+	if !strings.Contains(facts, "plan-alice approval step 2") || !strings.Contains(facts, "5000 euro") {
+		t.Errorf("expected the fact of the shared plan with its origin:\n%s", facts)
+	}
+	mallory := iam.ContextWithUser(ctx, &iam.User{ID: "mallory"})
+	facts = m.GetContext(mallory, "plan-bob", "What is the budget?", m.Scope(mallory, "plan-bob")).String()
+	if strings.Contains(facts, "5000 euro") || !strings.Contains(facts, "salary raise") {
+		t.Errorf("expected only the facts of plan-bob:\n%s", facts)
+	}
+}
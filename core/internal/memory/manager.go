@@ -1,12 +1,16 @@
 package memory
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/sjhoeksma/druppie/core/internal/audit"
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+	"github.com/sjhoeksma/druppie/core/internal/model"
 	"github.com/sjhoeksma/druppie/core/internal/store"
 )
 
@@ -18,12 +22,15 @@ const (
 	MemoryLongTerm  MemoryType = "long_term"  // Vector/semantic search
 )
 
+// RoleSummary marks the entry holding the condensed summary of older turns
+const RoleSummary = "summary"
+
 // HistoryEntry represents a single turn in the conversation
 type HistoryEntry struct {
 	Timestamp time.Time `json:"timestamp"`
-	Role      string    `json:"role"`    // user, ai, system
-	Content   string    `json:"content"` // Full text
-	Summary   string    `json:"summary,omitempty"`
+	Role      string    `json:"role"`              // user, ai, system, summary
+	Content   string    `json:"content"`           // Full text
+	Summary   string    `json:"summary,omitempty"` // Condensed earlier turns (RoleSummary entries)
 	Tokens    int       `json:"tokens"`
 	PlanID    string    `json:"plan_id"`
 }
@@ -34,15 +41,30 @@ type MemoryContext struct {
 	RelevantFacts []string       `json:"relevant_facts"`
 }
 
+// Summarizer condenses text with an LLM; llm.Provider satisfies it
+type Summarizer interface {
+	Generate(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error)
+}
+
 // Manager handles storage and retrieval of conversation history
 type Manager struct {
-	mu        sync.RWMutex
-	shortTerm map[string][]HistoryEntry // planID -> history
-	store     store.Store
+	mu          sync.RWMutex
+	shortTerm   map[string][]HistoryEntry // planID -> history
+	store       store.Store
+	usage       map[string]model.TokenUsage // planID -> summarization usage not yet charged
+	summarizing map[string]bool
 
 	// Configuration
 	MaxWindowTokens int
-	SummarizeAfter  int
+	SummarizeAfter  int           // Turns kept verbatim before older turns are summarized
+	Summarizer      Summarizer    // Without a summarizer the oldest turns are dropped
+	Tokenizer       llm.Tokenizer // Counts the tokens of the turns, defaults to llm.DefaultTokenizer
+
+	// Long-term memory: plan results, documents and decisions, searched by similarity
+	Vectors      VectorStore // Nil disables long-term memory
+	Embedder     Embedder    // Defaults to the local llm.HashEmbedder
+	RecallTopK   int
+	MinRelevance float64
 }
 
 // NewManager creates a new Memory Manager
@@ -53,15 +75,23 @@ func NewManager(maxTokens int, s store.Store) *Manager {
 	return &Manager{
 		shortTerm:       make(map[string][]HistoryEntry),
 		store:           s,
+		usage:           make(map[string]model.TokenUsage),
+		summarizing:     make(map[string]bool),
 		MaxWindowTokens: maxTokens,
 		SummarizeAfter:  10, // Default
-		// Future: VectorStore: enabled by default per plan (.druppie/plans/<id>/vector_store)
-
 	}
 }
 
-// AddEntry adds a new message to the plan's history
+// AddEntry adds a new message to the plan's history. When the history grows beyond
+// SummarizeAfter turns or MaxWindowTokens, the older turns are summarized first.
 func (m *Manager) AddEntry(planID, role, content string) {
+	m.addEntry(planID, role, content)
+	if m.needsSummary(planID) {
+		m.summarize(audit.WithChain(context.Background(), planID), planID)
+	}
+}
+
+func (m *Manager) addEntry(planID, role, content string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -82,16 +112,22 @@ func (m *Manager) AddEntry(planID, role, content string) {
 		Role:      role,
 		Content:   content,
 		PlanID:    planID,
-		Tokens:    estimateTokens(content),
+		Tokens:    m.countTokens(content),
 	}
 
 	m.shortTerm[planID] = append(m.shortTerm[planID], entry)
 
-	// Prune history to stay within token limits
+	// Without a summarizer prune history to stay within token limits
 	// We keep system prompt (usually handled by caller) separate, so this is just chat history
-	m.pruneHistory(planID)
+	if m.Summarizer == nil {
+		m.pruneHistory(planID)
+	}
 
 	// Persist immediately
+	m.persistLocked(planID)
+}
+
+func (m *Manager) persistLocked(planID string) {
 	if m.store != nil {
 		if data, err := json.Marshal(m.shortTerm[planID]); err == nil {
 			_ = m.store.SaveMemory(planID, data)
@@ -99,6 +135,150 @@ func (m *Manager) AddEntry(planID, role, content string) {
 	}
 }
 
+// needsSummary reports whether the plan has more turns than SummarizeAfter or exceeds the token budget
+func (m *Manager) needsSummary(planID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.Summarizer == nil || m.summarizing[planID] {
+		return false
+	}
+	turns, tokens := 0, 0
+	for _, e := range m.shortTerm[planID] {
+		tokens += e.Tokens
+		if e.Role != RoleSummary {
+			turns++
+		}
+	}
+	return (m.SummarizeAfter > 0 && turns > m.SummarizeAfter) || tokens > m.MaxWindowTokens
+}
+
+// keepRecent is the number of turns kept verbatim after summarizing
+func (m *Manager) keepRecent() int {
+	keep := m.SummarizeAfter / 2
+	if keep < 2 {
+		keep = 2
+	}
+	return keep
+}
+
+const summarySystemPrompt = "You maintain the memory of a conversation between a user and an AI platform. " +
+	"Condense the conversation into a summary that keeps every requirement, decision, constraint, name, number and open question. " +
+	"Merge the previous summary, if any, with the new turns. Answer with the summary only, in the language of the conversation."
+
+// maxSummaryEntryChars caps a single turn in the summarization prompt
+const maxSummaryEntryChars = 8000
+
+// summarize condenses all but the most recent turns (and the previous summary) into a new summary
+// entry. The LLM is called without holding the lock; turns added meanwhile are kept. On failure
+// the history falls back to dropping the oldest turns.
+func (m *Manager) summarize(ctx context.Context, planID string) {
+	m.mu.Lock()
+	history := m.shortTerm[planID]
+	cut := len(history) - m.keepRecent()
+	if cut <= 0 || (cut == 1 && history[0].Role == RoleSummary) {
+		m.pruneHistory(planID)
+		m.persistLocked(planID)
+		m.mu.Unlock()
+		return
+	}
+	older := append([]HistoryEntry(nil), history[:cut]...)
+	m.summarizing[planID] = true
+	m.mu.Unlock()
+
+	var sb strings.Builder
+	for _, e := range older {
+		if e.Role == RoleSummary {
+			sb.WriteString("PREVIOUS SUMMARY:\n" + e.Summary + "\n\n")
+			continue
+		}
+		content := e.Content
+		if len(content) > maxSummaryEntryChars {
+			content = content[:maxSummaryEntryChars] + " ..."
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", roleLabel(e.Role), content))
+	}
+
+	summary, usage, err := m.Summarizer.Generate(ctx, "Summarize this conversation:\n\n"+sb.String(), summarySystemPrompt)
+
+	m.addUsage(planID, usage)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.summarizing, planID)
+
+	summary = strings.TrimSpace(summary)
+	if err != nil || summary == "" {
+		fmt.Printf("[Memory] Summarization of plan %s failed, dropping oldest turns: %v\n", planID, err)
+		m.pruneHistory(planID)
+		m.persistLocked(planID)
+		return
+	}
+
+	// Only appends happen meanwhile, so the summarized turns are still the head of the history
+	current := m.shortTerm[planID]
+	if len(current) < cut {
+		return // History was replaced (Load), the summary no longer applies
+	}
+	entry := HistoryEntry{
+		Timestamp: time.Now(),
+		Role:      RoleSummary,
+		Summary:   summary,
+		PlanID:    planID,
+		Tokens:    m.countTokens(summary),
+	}
+	m.shortTerm[planID] = append([]HistoryEntry{entry}, current[cut:]...)
+	m.pruneHistory(planID) // Still over budget: drop the oldest remaining turns
+	m.persistLocked(planID)
+}
+
+// addUsage records summarization and embedding usage to charge to the plan
+func (m *Manager) addUsage(planID string, usage model.TokenUsage) {
+	if usage.TotalTokens == 0 && usage.EstimatedCost == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := m.usage[planID]
+	total.Add(usage)
+	m.usage[planID] = total
+}
+
+// TakeUsage returns the summarization and embedding usage of a plan that was not charged yet and resets it
+func (m *Manager) TakeUsage(planID string) model.TokenUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := m.usage[planID]
+	delete(m.usage, planID)
+	return usage
+}
+
+// ChargeUsage adds the pending summarization and embedding usage to the plan's memory usage
+func (m *Manager) ChargeUsage(plan *model.ExecutionPlan) {
+	if m == nil || plan == nil {
+		return
+	}
+	usage := m.TakeUsage(plan.ID)
+	if usage.TotalTokens == 0 && usage.EstimatedCost == 0 {
+		return
+	}
+	plan.ChargeMemory(usage)
+}
+
+// ReturnUsage puts usage taken with TakeUsage back when it could not be charged to the plan
+func (m *Manager) ReturnUsage(planID string, usage model.TokenUsage) {
+	if m == nil {
+		return
+	}
+	m.addUsage(planID, usage)
+}
+
+func roleLabel(role string) string {
+	label := strings.ToUpper(role)
+	if label == "AI" {
+		label = "ASSISTANT"
+	}
+	return label
+}
+
 // LoadHistory ensures the memory for a plan is loaded from store
 func (m *Manager) LoadHistory(planID string) error {
 	m.mu.Lock()
@@ -121,10 +301,10 @@ func (m *Manager) LoadHistory(planID string) error {
 	return nil
 }
 
-// GetContext retrieves the constructed context for the next LLM call
-func (m *Manager) GetContext(planID string) string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// history returns a copy of the short-term history of a plan, loading it from the store
+func (m *Manager) history(planID string) []HistoryEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	history, ok := m.shortTerm[planID]
 	if !ok {
@@ -139,31 +319,25 @@ func (m *Manager) GetContext(planID string) string {
 			}
 		}
 	}
-	if len(history) == 0 {
-		return ""
-	}
-
-	var sb strings.Builder
-	for _, entry := range history {
-		// Simple format: Role: Content
-		role := strings.ToUpper(entry.Role)
-		if role == "AI" {
-			role = "ASSISTANT"
-		}
-		sb.WriteString(fmt.Sprintf("%s: %s\n", role, entry.Content))
-	}
-	return sb.String()
+	return append([]HistoryEntry(nil), history...)
 }
 
 // pruneHistory removes oldest messages if token count exceeds limit
-// Always preserves the most recent messages
+// Always preserves the most recent messages and the summary block
 func (m *Manager) pruneHistory(planID string) {
 	history := m.shortTerm[planID]
+	var head []HistoryEntry
+	if len(history) > 0 && history[0].Role == RoleSummary {
+		head, history = history[:1], history[1:]
+	}
 	if len(history) == 0 {
 		return
 	}
 
 	totalTokens := 0
+	for _, e := range head {
+		totalTokens += e.Tokens
+	}
 	for _, e := range history {
 		totalTokens += e.Tokens
 	}
@@ -172,8 +346,8 @@ func (m *Manager) pruneHistory(planID string) {
 		return
 	}
 
-	// Simple FIFO pruning from the start
-	// In a real system, we might want to summarize instead of delete
+	// Simple FIFO pruning from the start, used without a summarizer or when
+	// the summary and the recent turns still exceed the budget
 	kbCutoff := 0
 	for i, e := range history {
 		totalTokens -= e.Tokens
@@ -185,13 +359,16 @@ func (m *Manager) pruneHistory(planID string) {
 
 	if kbCutoff > 0 && kbCutoff < len(history) {
 		// Keep the new slice
-		m.shortTerm[planID] = history[kbCutoff:]
+		m.shortTerm[planID] = append(head, history[kbCutoff:]...)
 	}
 }
 
-// Simple approximation: 1 token ~= 4 chars (english)
-func estimateTokens(text string) int {
-	return len(text) / 4
+// countTokens counts the tokens of a turn with the tokenizer of the model
+func (m *Manager) countTokens(text string) int {
+	if m.Tokenizer == nil {
+		return llm.DefaultTokenizer.CountTokens(text)
+	}
+	return m.Tokenizer.CountTokens(text)
 }
 
 // Store/Load persistence methods could be added here
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// VectorRecord is an embedded chunk of long-term memory
+type VectorRecord struct {
+	ID        string    `json:"id"`      // Stable per source chunk, re-remembering replaces it
+	PlanID    string    `json:"plan_id"` // Plan the fact belongs to
+	Kind      string    `json:"kind"`    // step_result, document, decision
+	Source    string    `json:"source"`  // Step, file path or approval the chunk came from
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VectorMatch is a search result with its cosine similarity
+type VectorMatch struct {
+	VectorRecord
+	Score float64 `json:"score"`
+}
+
+// VectorStore persists embedded chunks and searches them by similarity
+type VectorStore interface {
+	Upsert(ctx context.Context, records []VectorRecord) error
+	// Search returns the topK most similar records of the given plans (all plans when planIDs is nil)
+	Search(ctx context.Context, vector []float32, planIDs []string, topK int) ([]VectorMatch, error)
+	// DeleteSource removes the records of one source, before it is remembered again
+	DeleteSource(ctx context.Context, planID, kind, source string) error
+	DeletePlan(ctx context.Context, planID string) error
+}
+
+// Embedder turns texts into vectors; llm.Manager satisfies it
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, model.TokenUsage, error)
+}
+
+// Long-term memory kinds
+const (
+	KindStepResult = "step_result"
+	KindDocument   = "document"
+	KindDecision   = "decision"
+)
+
+// cosine returns the cosine similarity of two vectors (0 for different dimensions)
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Chunk splits text into pieces of about size characters on paragraph, line or sentence
+// boundaries, with overlap characters repeated between consecutive chunks
+func Chunk(text string, size, overlap int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if size <= 0 {
+		size = 1200
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	for len(text) > size {
+		cut := size
+		for _, sep := range []string{"\n\n", "\n", ". ", " "} {
+			if i := strings.LastIndex(text[:size], sep); i > size/2 {
+				cut = i + len(sep)
+				break
+			}
+		}
+		for cut > 1 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		next := cut - overlap
+		if next <= 0 {
+			next = cut
+		}
+		for next < cut && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		// Continue at a word boundary inside the overlap
+		if i := strings.IndexAny(text[next:cut], " \n"); overlap > 0 && i >= 0 {
+			next += i + 1
+		}
+		text = strings.TrimSpace(text[next:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
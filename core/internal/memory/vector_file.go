@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileVectorStore is the embedded on-disk index: one JSONL file per plan in
+// <baseDir>/<plan-id>/vector_store/index.jsonl, loaded into memory on first use.
+// Later lines replace earlier lines with the same ID; the file is compacted when loaded.
+type FileVectorStore struct {
+	mu      sync.Mutex
+	baseDir string                             // e.g. .druppie/plans
+	plans   map[string]map[string]VectorRecord // planID -> ID -> record
+}
+
+func NewFileVectorStore(baseDir string) *FileVectorStore {
+	return &FileVectorStore{baseDir: baseDir, plans: make(map[string]map[string]VectorRecord)}
+}
+
+func (s *FileVectorStore) indexFile(planID string) string {
+	return filepath.Join(s.baseDir, planID, "vector_store", "index.jsonl")
+}
+
+// loadLocked reads the index of a plan (caller holds the lock)
+func (s *FileVectorStore) loadLocked(planID string) (map[string]VectorRecord, error) {
+	if records, ok := s.plans[planID]; ok {
+		return records, nil
+	}
+	records := make(map[string]VectorRecord)
+	f, err := os.Open(s.indexFile(planID))
+	if os.IsNotExist(err) {
+		s.plans[planID] = records
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec VectorRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue // Torn write at the end of the file
+		}
+		records[rec.ID] = rec
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vector index of %s: %w", planID, err)
+	}
+	s.plans[planID] = records
+	if lines > len(records) {
+		_ = s.rewriteLocked(planID, records)
+	}
+	return records, nil
+}
+
+// rewriteLocked replaces the index file with the current records
+func (s *FileVectorStore) rewriteLocked(planID string, records map[string]VectorRecord) error {
+	path := s.indexFile(planID)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byPlan := make(map[string][]VectorRecord)
+	for _, rec := range records {
+		byPlan[rec.PlanID] = append(byPlan[rec.PlanID], rec)
+	}
+	for planID, recs := range byPlan {
+		index, err := s.loadLocked(planID)
+		if err != nil {
+			return err
+		}
+		path := s.indexFile(planID)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open vector index: %w", err)
+		}
+		enc := json.NewEncoder(f)
+		for _, rec := range recs {
+			if err := enc.Encode(rec); err != nil {
+				f.Close()
+				return err
+			}
+			index[rec.ID] = rec
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileVectorStore) Search(ctx context.Context, vector []float32, planIDs []string, topK int) ([]VectorMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if planIDs == nil {
+		entries, err := os.ReadDir(s.baseDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				planIDs = append(planIDs, e.Name())
+			}
+		}
+	}
+
+	var matches []VectorMatch
+	for _, planID := range planIDs {
+		index, err := s.loadLocked(planID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range index {
+			matches = append(matches, VectorMatch{VectorRecord: rec, Score: cosine(vector, rec.Vector)})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches, nil
+}
+
+func (s *FileVectorStore) DeleteSource(ctx context.Context, planID, kind, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.loadLocked(planID)
+	if err != nil {
+		return err
+	}
+	removed := false
+	for id, rec := range index {
+		if rec.Kind == kind && rec.Source == source {
+			delete(index, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return s.rewriteLocked(planID, index)
+}
+
+func (s *FileVectorStore) DeletePlan(ctx context.Context, planID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.plans, planID)
+	if err := os.RemoveAll(filepath.Dir(s.indexFile(planID))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
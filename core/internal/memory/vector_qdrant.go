@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QdrantVectorStore keeps long-term memory in a Qdrant collection (REST API).
+// The collection is created on first write with the dimension of the vectors.
+type QdrantVectorStore struct {
+	URL        string // e.g. http://qdrant.data-system:6333
+	Collection string
+	APIKey     string
+
+	mu    sync.Mutex
+	ready bool
+}
+
+const DefaultQdrantCollection = "druppie_memory"
+
+func NewQdrantVectorStore(url, collection, apiKey string) *QdrantVectorStore {
+	if collection == "" {
+		collection = DefaultQdrantCollection
+	}
+	return &QdrantVectorStore{URL: strings.TrimSuffix(url, "/"), Collection: collection, APIKey: apiKey}
+}
+
+func (q *QdrantVectorStore) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, q.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.APIKey != "" {
+		req.Header.Set("api-key", q.APIKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("qdrant error %d: %s", resp.StatusCode, string(data))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// ensureCollection creates the collection when it does not exist yet
+func (q *QdrantVectorStore) ensureCollection(ctx context.Context, dims int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ready {
+		return nil
+	}
+	if err := q.do(ctx, "GET", "/collections/"+q.Collection, nil, nil); err != nil {
+		create := map[string]interface{}{
+			"vectors": map[string]interface{}{"size": dims, "distance": "Cosine"},
+		}
+		if err := q.do(ctx, "PUT", "/collections/"+q.Collection, create, nil); err != nil {
+			return fmt.Errorf("failed to create qdrant collection %s: %w", q.Collection, err)
+		}
+		// Searches filter on the plan
+		index := map[string]interface{}{"field_name": "plan_id", "field_schema": "keyword"}
+		_ = q.do(ctx, "PUT", "/collections/"+q.Collection+"/index", index, nil)
+	}
+	q.ready = true
+	return nil
+}
+
+// pointID maps a record ID to the UUID form Qdrant requires
+func pointID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (q *QdrantVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := q.ensureCollection(ctx, len(records[0].Vector)); err != nil {
+		return err
+	}
+	points := make([]map[string]interface{}, 0, len(records))
+	for _, rec := range records {
+		points = append(points, map[string]interface{}{
+			"id":     pointID(rec.ID),
+			"vector": rec.Vector,
+			"payload": map[string]interface{}{
+				"record_id":  rec.ID,
+				"plan_id":    rec.PlanID,
+				"kind":       rec.Kind,
+				"source":     rec.Source,
+				"text":       rec.Text,
+				"created_at": rec.CreatedAt.Format(time.RFC3339),
+			},
+		})
+	}
+	return q.do(ctx, "PUT", "/collections/"+q.Collection+"/points?wait=true", map[string]interface{}{"points": points}, nil)
+}
+
+func (q *QdrantVectorStore) Search(ctx context.Context, vector []float32, planIDs []string, topK int) ([]VectorMatch, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	query := map[string]interface{}{
+		"vector":       vector,
+		"limit":        topK,
+		"with_payload": true,
+	}
+	if planIDs != nil {
+		if len(planIDs) == 0 {
+			return nil, nil
+		}
+		query["filter"] = planFilter(planIDs)
+	}
+
+	var result struct {
+		Result []struct {
+			Score   float64 `json:"score"`
+			Payload struct {
+				RecordID  string `json:"record_id"`
+				PlanID    string `json:"plan_id"`
+				Kind      string `json:"kind"`
+				Source    string `json:"source"`
+				Text      string `json:"text"`
+				CreatedAt string `json:"created_at"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	if err := q.do(ctx, "POST", "/collections/"+q.Collection+"/points/search", query, &result); err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return nil, nil // Nothing remembered yet
+		}
+		return nil, err
+	}
+
+	matches := make([]VectorMatch, 0, len(result.Result))
+	for _, r := range result.Result {
+		created, _ := time.Parse(time.RFC3339, r.Payload.CreatedAt)
+		matches = append(matches, VectorMatch{
+			VectorRecord: VectorRecord{
+				ID:        r.Payload.RecordID,
+				PlanID:    r.Payload.PlanID,
+				Kind:      r.Payload.Kind,
+				Source:    r.Payload.Source,
+				Text:      r.Payload.Text,
+				CreatedAt: created,
+			},
+			Score: r.Score,
+		})
+	}
+	return matches, nil
+}
+
+func (q *QdrantVectorStore) DeletePlan(ctx context.Context, planID string) error {
+	err := q.do(ctx, "POST", "/collections/"+q.Collection+"/points/delete?wait=true",
+		map[string]interface{}{"filter": planFilter([]string{planID})}, nil)
+	if err != nil && strings.Contains(err.Error(), "404") {
+		return nil
+	}
+	return err
+}
+
+func (q *QdrantVectorStore) DeleteSource(ctx context.Context, planID, kind, source string) error {
+	filter := planFilter([]string{planID})
+	filter["must"] = append(filter["must"].([]interface{}),
+		map[string]interface{}{"key": "kind", "match": map[string]interface{}{"value": kind}},
+		map[string]interface{}{"key": "source", "match": map[string]interface{}{"value": source}},
+	)
+	err := q.do(ctx, "POST", "/collections/"+q.Collection+"/points/delete?wait=true",
+		map[string]interface{}{"filter": filter}, nil)
+	if err != nil && strings.Contains(err.Error(), "404") {
+		return nil // Nothing remembered yet
+	}
+	return err
+}
+
+func planFilter(planIDs []string) map[string]interface{} {
+	return map[string]interface{}{
+		"must": []interface{}{
+			map[string]interface{}{"key": "plan_id", "match": map[string]interface{}{"any": planIDs}},
+		},
+	}
+}
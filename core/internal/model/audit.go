@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// AuditRecord is one entry of the append-only, hash-chained audit trail.
+// Every plan has its own chain (Chain = plan ID); events outside a plan go to the "system" chain.
+type AuditRecord struct {
+	Chain    string                 `json:"chain"`
+	Seq      int64                  `json:"seq"` // Position in the chain, starting at 1
+	Time     time.Time              `json:"time"`
+	Type     string                 `json:"type"`              // llm_call, tool_call, step, approval, config_change, file_edit, interaction
+	Actor    string                 `json:"actor,omitempty"`   // User or component that caused the event
+	StepID   int                    `json:"step_id,omitempty"` // Plan step, if any
+	Summary  string                 `json:"summary,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	PrevHash string                 `json:"prev_hash"` // Hash of the previous record ("" for the first)
+	Hash     string                 `json:"hash"`      // Hash over all fields above
+}
@@ -1,12 +1,21 @@
 package model
 
-// CalculateCost aggregates the total cost from steps and planning usage
+// ChargeMemory adds summarization and embedding usage to the memory and total usage of the plan
+func (p *ExecutionPlan) ChargeMemory(usage TokenUsage) {
+	p.MemoryUsage.Add(usage)
+	p.TotalUsage.Add(usage)
+}
+
+// CalculateCost aggregates the total cost from steps, planning and memory usage
 func (p *ExecutionPlan) CalculateCost() {
 	var total float64
 
 	// Add Planning Logic Usage Cost
 	total += p.PlanningUsage.EstimatedCost
 
+	// Add Memory Summarization Cost
+	total += p.MemoryUsage.EstimatedCost
+
 	// Add Step Usage Costs
 	for _, s := range p.Steps {
 		if s.Usage != nil {
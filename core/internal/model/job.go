@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses
+const (
+	JobQueued = "queued" // Waiting for a worker, also after its lease expired
+	JobLeased = "leased" // Held by a worker until LeaseUntil
+	JobDone   = "done"   // Executed; Events holds the output, Error the failure if any
+	JobFailed = "failed" // Given up, e.g. its workers kept dying
+)
+
+// StepJob is a plan step queued for execution by a worker
+type StepJob struct {
+	ID         string          `json:"id"`
+	PlanID     string          `json:"plan_id"`
+	Step       Step            `json:"step"` // Step with its parameters resolved by the server
+	Status     string          `json:"status"`
+	Attempts   int             `json:"attempts"` // Number of leases handed out
+	WorkerID   string          `json:"worker_id,omitempty"`
+	LeaseUntil time.Time       `json:"lease_until,omitempty"`
+	Created    time.Time       `json:"created"`
+	Updated    time.Time       `json:"updated"`
+	Events     json.RawMessage `json:"events,omitempty"` // Executor events, replayed by the server
+	Error      string          `json:"error,omitempty"`
+}
+
+// Finished reports whether the job needs no worker anymore
+func (j StepJob) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
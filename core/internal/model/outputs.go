@@ -0,0 +1,137 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stepRefPattern matches ${step.<id>.outputs.<key>[.<nested>...]}
+var stepRefPattern = regexp.MustCompile(`\$\{step\.(\d+)\.outputs\.([A-Za-z0-9_\-]+(?:\.[A-Za-z0-9_\-]+)*)\}`)
+
+// ResolveStepReferences replaces ${step.N.outputs.key} references in value (strings, maps and slices, recursively)
+// with the outputs of the given steps. A string that is exactly one reference receives the typed value,
+// references embedded in text are formatted (JSON for objects and arrays).
+func ResolveStepReferences(value interface{}, steps []Step) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveString(v, steps)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved, err := ResolveStepReferences(item, steps)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := ResolveStepReferences(item, steps)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func resolveString(s string, steps []Step) (interface{}, error) {
+	if !strings.Contains(s, "${step.") {
+		return s, nil
+	}
+
+	// Whole value is a single reference: keep the type (number, object, list)
+	if m := stepRefPattern.FindStringSubmatch(s); m != nil && m[0] == s {
+		return lookupStepOutput(m[1], m[2], steps)
+	}
+
+	var firstErr error
+	result := stepRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := stepRefPattern.FindStringSubmatch(ref)
+		val, err := lookupStepOutput(m[1], m[2], steps)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ref
+		}
+		return FormatOutputValue(val)
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func lookupStepOutput(idStr, path string, steps []Step) (interface{}, error) {
+	id, _ := strconv.Atoi(idStr)
+	var step *Step
+	for i := range steps {
+		if steps[i].ID == id {
+			step = &steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return nil, fmt.Errorf("reference ${step.%s.outputs.%s}: step %d not found", idStr, path, id)
+	}
+	if step.Status != "completed" {
+		return nil, fmt.Errorf("reference ${step.%s.outputs.%s}: step %d is %s", idStr, path, id, step.Status)
+	}
+
+	var current interface{} = step.Outputs
+	walked := ""
+	for _, part := range strings.Split(path, ".") {
+		// Outputs holding a JSON document can be navigated like structured values
+		if str, ok := current.(string); ok {
+			var decoded interface{}
+			if json.Unmarshal([]byte(str), &decoded) == nil {
+				current = decoded
+			}
+		}
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next, ok := c[part]
+			if !ok {
+				return nil, fmt.Errorf("reference ${step.%s.outputs.%s}: no output %q", idStr, path, strings.TrimPrefix(walked+"."+part, "."))
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil, fmt.Errorf("reference ${step.%s.outputs.%s}: invalid index %q", idStr, path, part)
+			}
+			current = c[idx]
+		default:
+			return nil, fmt.Errorf("reference ${step.%s.outputs.%s}: %q is not an object or list", idStr, path, strings.TrimPrefix(walked, "."))
+		}
+		walked += "." + part
+	}
+	return current, nil
+}
+
+// FormatOutputValue renders an output value for use inside text
+func FormatOutputValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
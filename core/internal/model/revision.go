@@ -0,0 +1,106 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// PlanRevision is a saved version of a plan. Revisions are numbered from 1 per plan.
+type PlanRevision struct {
+	PlanID string         `json:"plan_id"`
+	Number int            `json:"revision"`
+	Time   time.Time      `json:"time"`
+	Status string         `json:"status"` // Plan status of the revision
+	Steps  int            `json:"steps"`  // Number of steps
+	Hash   string         `json:"hash"`   // SHA-256 of the plan JSON
+	Plan   *ExecutionPlan `json:"plan,omitempty"`
+}
+
+// StepChange describes a step present in both revisions whose fields differ
+type StepChange struct {
+	StepID int                      `json:"step_id"`
+	Fields map[string][]interface{} `json:"fields"` // Field -> [old, new]
+}
+
+// PlanDiff is the structural difference between two versions of a plan
+type PlanDiff struct {
+	PlanID       string       `json:"plan_id"`
+	From         int          `json:"from"`
+	To           int          `json:"to"`
+	StatusBefore string       `json:"status_before,omitempty"`
+	StatusAfter  string       `json:"status_after,omitempty"`
+	CostBefore   float64      `json:"cost_before"`
+	CostAfter    float64      `json:"cost_after"`
+	Added        []Step       `json:"added,omitempty"`
+	Removed      []Step       `json:"removed,omitempty"`
+	Changed      []StepChange `json:"changed,omitempty"`
+}
+
+// Empty reports whether the steps and the status are the same in both versions
+func (d PlanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && d.StatusBefore == d.StatusAfter
+}
+
+// DiffPlans compares two versions of a plan, matching steps by ID
+func DiffPlans(before, after ExecutionPlan) PlanDiff {
+	diff := PlanDiff{
+		PlanID:       after.ID,
+		StatusBefore: before.Status,
+		StatusAfter:  after.Status,
+		CostBefore:   before.TotalCost,
+		CostAfter:    after.TotalCost,
+	}
+
+	old := make(map[int]Step, len(before.Steps))
+	for _, s := range before.Steps {
+		old[s.ID] = s
+	}
+	seen := make(map[int]bool, len(after.Steps))
+	for _, s := range after.Steps {
+		seen[s.ID] = true
+		prev, ok := old[s.ID]
+		if !ok {
+			diff.Added = append(diff.Added, s)
+			continue
+		}
+		if fields := stepFieldChanges(prev, s); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, StepChange{StepID: s.ID, Fields: fields})
+		}
+	}
+	for _, s := range before.Steps {
+		if !seen[s.ID] {
+			diff.Removed = append(diff.Removed, s)
+		}
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].StepID < diff.Changed[j].StepID })
+	return diff
+}
+
+// stepFieldChanges compares two steps field by field on their JSON form
+func stepFieldChanges(before, after Step) map[string][]interface{} {
+	a, b := stepFields(before), stepFields(after)
+	changes := make(map[string][]interface{})
+	for k, v := range b {
+		if !reflect.DeepEqual(a[k], v) {
+			changes[k] = []interface{}{a[k], v}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = []interface{}{v, nil}
+		}
+	}
+	return changes
+}
+
+func stepFields(s Step) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
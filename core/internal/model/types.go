@@ -83,22 +83,55 @@ type TokenUsage struct {
 	CompletionTokens int     `json:"completion_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	EstimatedCost    float64 `json:"estimated_cost,omitempty"` // Cost in EUR (or base currency)
+	Provider         string  `json:"provider,omitempty"`       // LLM provider(s) that answered, comma separated
+	Cached           bool    `json:"cached,omitempty"`         // Answered (partly) from the response cache, at no cost
+}
+
+// Add accumulates the usage of another call
+func (u *TokenUsage) Add(o TokenUsage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	u.EstimatedCost += o.EstimatedCost
+	u.Cached = u.Cached || o.Cached
+	for _, name := range strings.Split(o.Provider, ", ") {
+		u.AddProvider(name)
+	}
+}
+
+// AddProvider records a provider that answered, once
+func (u *TokenUsage) AddProvider(name string) {
+	if name == "" {
+		return
+	}
+	for _, p := range strings.Split(u.Provider, ", ") {
+		if p == name {
+			return
+		}
+	}
+	if u.Provider != "" {
+		u.Provider += ", "
+	}
+	u.Provider += name
 }
 
 // Step represents a single unit of work in a plan
 type Step struct {
-	ID            int                    `json:"step_id"`
-	AgentID       string                 `json:"agent_id"`
-	Action        string                 `json:"action"`
-	Params        map[string]interface{} `json:"params"`
-	Result        string                 `json:"result,omitempty"`         // User feedback/answer for this step
-	Error         string                 `json:"error,omitempty"`          // captured error message
-	Status        string                 `json:"status"`                   // pending, running, completed, requires_approval
-	DependsOn     []int                  `json:"depends_on,omitempty"`     // List of step IDs that must complete before this step starts
-	DependsOnRaw  interface{}            `json:"-"`                        // Raw value for Mixed Dependency resolution
-	AssignedGroup string                 `json:"assigned_group,omitempty"` // The group (e.g. "compliance") that must approve this step
-	ApprovedBy    string                 `json:"approved_by,omitempty"`    // The user/agent that approved this step
-	Usage         *TokenUsage            `json:"usage,omitempty"`          // Token usage for this step
+	ID             int                    `json:"step_id"`
+	AgentID        string                 `json:"agent_id"`
+	Action         string                 `json:"action"`
+	Params         map[string]interface{} `json:"params"`
+	Result         string                 `json:"result,omitempty"`          // User feedback/answer for this step
+	Error          string                 `json:"error,omitempty"`           // captured error message
+	Status         string                 `json:"status"`                    // pending, queued, running, completed, requires_approval
+	DependsOn      []int                  `json:"depends_on,omitempty"`      // List of step IDs that must complete before this step starts
+	DependsOnRaw   interface{}            `json:"-"`                         // Raw value for Mixed Dependency resolution
+	AssignedGroup  string                 `json:"assigned_group,omitempty"`  // The group (e.g. "compliance") that must approve this step
+	ApprovedBy     string                 `json:"approved_by,omitempty"`     // The user/agent that approved this step
+	ApprovalGroups []string               `json:"approval_groups,omitempty"` // All groups that must approve this step, AssignedGroup is the one asked now
+	Approvals      map[string]string      `json:"approvals,omitempty"`       // Group -> user that approved the step for it
+	Usage          *TokenUsage            `json:"usage,omitempty"`           // Token usage for this step
+	Outputs        map[string]interface{} `json:"outputs,omitempty"`         // Typed outputs, referenced as ${step.<id>.outputs.<key>}
 }
 
 func (s *Step) UnmarshalJSON(data []byte) error {
@@ -164,8 +197,26 @@ type ExecutionPlan struct {
 	AllowedGroups            []string   `json:"allowed_groups,omitempty"`
 	TotalUsage               TokenUsage `json:"total_usage,omitempty"`
 	PlanningUsage            TokenUsage `json:"planning_usage,omitempty"`              // LLM usage from plan generation and UpdatePlan calls
+	MemoryUsage              TokenUsage `json:"memory_usage,omitempty"`                // LLM usage from summarizing the conversation memory
 	TotalCost                float64    `json:"total_cost,omitempty"`                  // Total cost in euros
 	LastInteractionTotalCost float64    `json:"last_interaction_total_cost,omitempty"` // Cost snapshot at last user interaction
+	Version                  int64      `json:"version,omitempty"`                     // Incremented by every save; see store.CompareAndSwapPlan
+}
+
+// VisibleTo reports whether a user may see the plan: plans without a creator, the user's
+// own plans and plans shared with one of the user's groups
+func (p ExecutionPlan) VisibleTo(userID, username string, groups []string) bool {
+	if p.CreatorID == "" || (userID != "" && p.CreatorID == userID) || (username != "" && p.CreatorID == username) {
+		return true
+	}
+	for _, allowed := range p.AllowedGroups {
+		for _, g := range groups {
+			if allowed == g {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // MCPServer represents an external tool server
@@ -215,6 +266,7 @@ type AgentDefinition struct {
 	Priority     float64           `json:"priority" yaml:"priority"`
 	FinalActions []string          `json:"final_actions" yaml:"final_actions"` // Actions that trigger an immediate stop of the plan
 	AuthGroups   []string          `json:"auth_groups,omitempty" yaml:"auth_groups,omitempty"`
+	Cache        *bool             `json:"cache,omitempty" yaml:"cache,omitempty"` // Use the LLM response cache, llm.cache.enabled when unset
 }
 
 func (a *AgentDefinition) UnmarshalJSON(data []byte) error {
@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm"
+)
+
+// planContext is the part of a planner prompt that grows with the registry and the conversation
+type planContext struct {
+	Tools   []string // Building blocks and MCP tools: "name (description) Args: schema"
+	Agents  []string // Agent definitions
+	History string   // Conversation history (UpdatePlan)
+}
+
+// fitContext shortens the context to the prompt budget of the model; fixed is the rest of the
+// prompt. The tools go first (arguments, then descriptions, then the last tools), then the
+// oldest history, then the agent directives. It returns the %tools% and %agents% lists and the
+// history.
+func (p *Planner) fitContext(planID, fixed string, schema map[string]interface{}, pc planContext) (string, string, string) {
+	caps := llm.CapabilitiesOf(p.llm)
+	tok := caps.Tokenizer
+	if schemaJSON, err := json.Marshal(schema); err == nil {
+		fixed += string(schemaJSON) // Added to the prompt by GenerateStructured
+	}
+
+	sections := []*llm.PromptSection{
+		{
+			Name: "tools",
+			Text: fmt.Sprint(pc.Tools),
+			Shorten: func(maxTokens int) string {
+				return shortenTools(tok, pc.Tools, maxTokens)
+			},
+		},
+		{
+			Name: "history",
+			Text: pc.History,
+			Shorten: func(maxTokens int) string {
+				return llm.KeepEnd(tok, pc.History, maxTokens)
+			},
+		},
+		{
+			Name: "agents",
+			Text: fmt.Sprint(pc.Agents),
+			Shorten: func(maxTokens int) string {
+				agents := make([]string, len(pc.Agents))
+				for i, a := range pc.Agents {
+					agents[i] = withoutDirectives(a)
+				}
+				return llm.KeepStart(tok, fmt.Sprint(agents), maxTokens)
+			},
+		},
+	}
+	shortened := llm.FitPrompt(tok, caps.PromptBudget(), fixed, sections)
+	if len(shortened) > 0 {
+		msg := fmt.Sprintf("Shortened %s to fit the context window of %s (%d tokens, %s)",
+			strings.Join(shortened, ", "), caps.Model, caps.ContextLength, tok.Name())
+		fmt.Printf("[Planner] %s\n", msg)
+		if p.Store != nil {
+			_ = p.Store.LogInteraction(planID, "Planner", "Context Window", msg)
+		}
+	}
+	return sections[0].Text, sections[2].Text, sections[1].Text
+}
+
+// shortenTools fits the tools list in maxTokens: without arguments, then without
+// descriptions, then without the last tools
+func shortenTools(tok llm.Tokenizer, tools []string, maxTokens int) string {
+	reductions := []func(string) string{
+		func(t string) string { return strings.SplitN(t, " Args: ", 2)[0] },
+		func(t string) string { return strings.SplitN(t, " (", 2)[0] },
+	}
+	items := tools
+	for _, reduce := range reductions {
+		reduced := make([]string, len(items))
+		for i, t := range items {
+			reduced[i] = reduce(t)
+		}
+		items = reduced
+		if text := fmt.Sprint(items); tok.CountTokens(text) <= maxTokens {
+			return text
+		}
+	}
+	for n := len(items) - 1; n >= 0; n-- {
+		kept := append(append([]string(nil), items[:n]...), fmt.Sprintf("... %d more", len(items)-n))
+		if text := fmt.Sprint(kept); tok.CountTokens(text) <= maxTokens || n == 0 {
+			return text
+		}
+	}
+	return fmt.Sprint(items)
+}
+
+// withoutDirectives leaves the directives out of an agent definition of the prompt
+func withoutDirectives(agent string) string {
+	if i := strings.Index(agent, "  Directives:\n"); i != -1 {
+		return agent[:i]
+	}
+	return agent
+}
@@ -164,8 +164,10 @@ func (p *Planner) expandLoop(triggerStep model.Step, history []model.Step, curre
 				// Match audio creator and same scene_id
 				if h.Status == "completed" && (h.AgentID == "audio_creator" || h.Action == "text_to_speech") {
 					if fmt.Sprintf("%v", h.Params["scene_id"]) == fmt.Sprintf("%v", sid) {
-						// Extract from result string (e.g. "AUDIO_FILE: audio_scene_1.wav")
-						if strings.Contains(h.Result, "AUDIO_FILE:") {
+						// Prefer the typed output, older plans only have it in the result string (e.g. "AUDIO_FILE: audio_scene_1.wav")
+						if f, ok := h.Outputs["audio_file"].(string); ok && f != "" {
+							audioFile = f
+						} else if strings.Contains(h.Result, "AUDIO_FILE:") {
 							lines := strings.Split(h.Result, "\n")
 							for _, line := range lines {
 								if strings.HasPrefix(line, "AUDIO_FILE:") {
@@ -187,7 +189,9 @@ func (p *Planner) expandLoop(triggerStep model.Step, history []model.Step, curre
 			for _, h := range history {
 				if h.Status == "completed" && (h.AgentID == "image_creator" || h.Action == "image_generation") {
 					if fmt.Sprintf("%v", h.Params["scene_id"]) == fmt.Sprintf("%v", sid) {
-						if strings.Contains(h.Result, "IMAGE_FILE:") {
+						if f, ok := h.Outputs["image_file"].(string); ok && f != "" {
+							imageFile = f
+						} else if strings.Contains(h.Result, "IMAGE_FILE:") {
 							lines := strings.Split(h.Result, "\n")
 							for _, line := range lines {
 								if strings.HasPrefix(line, "IMAGE_FILE:") {
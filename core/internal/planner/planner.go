@@ -3,6 +3,7 @@ package planner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -11,6 +12,7 @@ import (
 
 	"reflect"
 
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/iam"
 	"github.com/sjhoeksma/druppie/core/internal/llm"
 	"github.com/sjhoeksma/druppie/core/internal/mcp"
@@ -52,50 +54,6 @@ func NewPlanner(llm llm.Provider, reg *registry.Registry, store store.Store, mcp
 	}
 }
 
-func (p *Planner) cleanJSONResponse(resp string) string {
-	clean := strings.TrimSpace(resp)
-
-	// 1. Extract from Markdown Code Blocks if present
-	if start := strings.Index(clean, "```"); start != -1 {
-		if newline := strings.Index(clean[start:], "\n"); newline != -1 {
-			start += newline + 1
-		} else {
-			start += 3
-		}
-		end := strings.LastIndex(clean, "```")
-		if end > start {
-			clean = clean[start:end]
-		}
-	}
-
-	// 2. Scan for Outermost Brackets (Array or Object) to ignore chatty prefixes/suffixes
-	startArr := strings.Index(clean, "[")
-	startObj := strings.Index(clean, "{")
-
-	var start, end int
-	// Determine if we are looking for Array or Object start
-	if startArr != -1 && (startObj == -1 || startArr < startObj) {
-		start = startArr
-		end = strings.LastIndex(clean, "]")
-	} else if startObj != -1 {
-		start = startObj
-		end = strings.LastIndex(clean, "}")
-	} else {
-		// No brackets found, return original trimmed (likely will Assert Error later)
-		return clean
-	}
-
-	if end > start {
-		clean = clean[start : end+1]
-	}
-
-	// 3. REMOVED Destructive Newline Replacement
-	// We trust the LLM/Template to produce valid JSON-escaped strings for code content.
-	// Replacing \n with space corrupts 'create_repo' file contents.
-
-	return clean
-}
-
 func (p *Planner) selectRelevantAgents(ctx context.Context, intent model.Intent, agents []model.AgentDefinition, planID string) ([]string, model.TokenUsage) {
 	var detailedList []string
 	for _, a := range agents {
@@ -124,28 +82,20 @@ Rules:
 
 Example: ["business_analyst"]`, intent.Prompt, detailedList)
 
-	resp, usage, err := p.llm.Generate(ctx, "Select Agents", prompt)
-	if err != nil {
-		fmt.Printf("[Planner] Agent selection failed: %v\n", err)
-		return nil, model.TokenUsage{}
+	var selection struct {
+		SelectedAgents []string `json:"selected_agents"`
 	}
-
-	clean := p.cleanJSONResponse(resp)
-	var selected []string
-	if err := json.Unmarshal([]byte(clean), &selected); err != nil {
-		// Try parsing as a wrapped object
-		var wrapped struct {
-			SelectedAgents []string `json:"selected_agents"`
-		}
-		if err2 := json.Unmarshal([]byte(clean), &wrapped); err2 == nil && len(wrapped.SelectedAgents) > 0 {
-			selected = wrapped.SelectedAgents
-		} else {
-			if p.Debug {
-				fmt.Printf("[Planner] Failed to parse agent selection: %v. Raw: %s\n", err, resp)
-			}
-			return nil, usage
+	resp, usage, err := llm.GenerateStructured(ctx, p.llm, "Select Agents", prompt, agentSelectionSchema, &selection)
+	if err != nil {
+		var invalid *llm.StructuredError
+		if !errors.As(err, &invalid) {
+			fmt.Printf("[Planner] Agent selection failed: %v\n", err)
+		} else if p.Debug {
+			fmt.Printf("[Planner] Failed to parse agent selection: %v. Raw: %s\n", err, resp)
 		}
+		return nil, usage
 	}
+	selected := selection.SelectedAgents
 
 	// Limit selection based on config
 	if len(selected) > p.MaxAgentSelection {
@@ -163,6 +113,8 @@ Example: ["business_analyst"]`, intent.Prompt, detailedList)
 }
 
 func (p *Planner) CreatePlan(ctx context.Context, intent model.Intent, planID string) (model.ExecutionPlan, error) {
+	ctx = audit.WithChain(ctx, planID)
+
 	// Extract user groups for filtering
 	userGroups := []string{}
 	if user, ok := iam.GetUserFromContext(ctx); ok && user != nil {
@@ -208,8 +160,11 @@ func (p *Planner) CreatePlan(ctx context.Context, intent model.Intent, planID st
 
 		mcpTools := p.MCPManager.ListAllTools()
 		for _, t := range mcpTools {
-			// Find server for tool to create Namespaced Name
-			srv, _ := p.MCPManager.GetToolServer(t.Name)
+			// Server of the tool creates the Namespaced Name
+			srv := t.Server
+			if srv == "" {
+				srv, _ = p.MCPManager.GetToolServer(t.Name)
+			}
 
 			// Check Access
 			if allRegistryMap[srv] {
@@ -374,16 +329,21 @@ func (p *Planner) CreatePlan(ctx context.Context, intent model.Intent, planID st
 	sysTemplate := ""
 	if plannerAgent, err := p.Registry.GetAgent("planner"); err == nil && plannerAgent.Instructions != "" {
 		sysTemplate = plannerAgent.Instructions
+		ctx = llm.WithAgent(ctx, plannerAgent)
 	} else {
 		return model.ExecutionPlan{}, fmt.Errorf("Planner agent not found or no instructions in registry. Ensure agents/planner.md exists")
 	}
 
+	// Fit the tools and agents in the context window of the model
+	fixed := strings.NewReplacer("%goal%", intent.Prompt, "%action%", intent.Action, "%language%", intent.Language, "%tools%", "", "%agents%", "").Replace(sysTemplate)
+	toolsText, agentsText, _ := p.fitContext(planID, fixed+"Generate plan data", planSchema, planContext{Tools: blockNames, Agents: agentList})
+
 	replacer := strings.NewReplacer(
 		"%goal%", intent.Prompt,
 		"%action%", intent.Action,
 		"%language%", intent.Language,
-		"%tools%", fmt.Sprintf("--- AVAILABLE TOOLS & BLOCKS ---\n%v\n--- END TOOLS ---", blockNames),
-		"%agents%", fmt.Sprintf("--- AVAILABLE AGENT DEFINITIONS ---\n%v\n--- END AGENTS ---", agentList),
+		"%tools%", fmt.Sprintf("--- AVAILABLE TOOLS & BLOCKS ---\n%s\n--- END TOOLS ---", toolsText),
+		"%agents%", fmt.Sprintf("--- AVAILABLE AGENT DEFINITIONS ---\n%s\n--- END AGENTS ---", agentsText),
 	)
 
 	if p.Store != nil {
@@ -408,41 +368,27 @@ func (p *Planner) CreatePlan(ctx context.Context, intent model.Intent, planID st
 			fmt.Printf("[Planner] Retrying Plan Generation (Attempt %d). Error: %v\n", attempt+1, validationErr)
 		}
 
+		// 3. Generate and parse the steps, repairing JSON that doesn't match the schema
 		var err error
 		var usage model.TokenUsage
-		resp, usage, err = p.llm.Generate(ctx, "Generate plan data", sysPrompt)
-		if err != nil {
-			return model.ExecutionPlan{}, err
+		var parsed struct {
+			Steps []model.Step `json:"steps"`
 		}
+		resp, usage, err = llm.GenerateStructured(ctx, p.llm, "Generate plan data", sysPrompt, planSchema, &parsed)
 
 		// Accumulate usage
-		totalUsage.PromptTokens += usage.PromptTokens
-		totalUsage.CompletionTokens += usage.CompletionTokens
-		totalUsage.TotalTokens += usage.TotalTokens
-
-		// 3. Parse Response
-		cleanResp := p.cleanJSONResponse(resp)
-
-		// Attempt 1: Direct Array
-		if err := json.Unmarshal([]byte(cleanResp), &steps); err != nil {
-			// Attempt 2: Wrapped Object {"steps": [...]}
-			var wrapped struct {
-				Steps []model.Step `json:"steps"`
-			}
-			if err2 := json.Unmarshal([]byte(cleanResp), &wrapped); err2 == nil && len(wrapped.Steps) > 0 {
-				steps = wrapped.Steps
-			} else {
-				// Attempt 3: Single Object
-				var singleStep model.Step
-				if err3 := json.Unmarshal([]byte(cleanResp), &singleStep); err3 == nil && singleStep.Action != "" {
-					steps = []model.Step{singleStep}
-				} else {
-					// JSON Parse Error - Retry
-					validationErr = fmt.Errorf("invalid json format: %v", err)
-					continue
-				}
+		totalUsage.Add(usage)
+
+		if err != nil {
+			var invalid *llm.StructuredError
+			if !errors.As(err, &invalid) {
+				return model.ExecutionPlan{}, err
 			}
+			// JSON Parse Error - Retry
+			validationErr = fmt.Errorf("invalid json format: %v", err)
+			continue
 		}
+		steps = parsed.Steps
 
 		// Ensure all steps have a status and normalized params
 		validationErr = nil // Reset
@@ -617,6 +563,8 @@ func (p *Planner) CreatePlan(ctx context.Context, intent model.Intent, planID st
 
 } // UpdatePlan updates an existing plan based on user feedback or answers.
 func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, feedback string) (*model.ExecutionPlan, error) {
+	ctx = audit.WithChain(ctx, plan.ID)
+
 	// 0. Handle Feedback
 	// Find the first non-completed step that matches the feedback category and mark it as completed
 	for i := range plan.Steps {
@@ -805,6 +753,7 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 	sysTemplate := ""
 	if plannerAgent, err := p.Registry.GetAgent("planner"); err == nil && plannerAgent.Instructions != "" {
 		sysTemplate = plannerAgent.Instructions
+		ctx = llm.WithAgent(ctx, plannerAgent)
 	} else {
 		fmt.Println("[Planner] Planner agent not found or no instructions")
 		os.Exit(1)
@@ -833,7 +782,10 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 
 		mcpTools := p.MCPManager.ListAllTools()
 		for _, t := range mcpTools {
-			srv, _ := p.MCPManager.GetToolServer(t.Name)
+			srv := t.Server
+			if srv == "" {
+				srv, _ = p.MCPManager.GetToolServer(t.Name)
+			}
 
 			// Check Access
 			if allRegistryMap[srv] {
@@ -854,15 +806,6 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 		}
 	}
 
-	replacer := strings.NewReplacer(
-		"%goal%", plan.Intent.Prompt,
-		"%action%", plan.Intent.Action,
-		"%language%", plan.Intent.Language,
-		"%tools%", fmt.Sprintf("--- AVAILABLE TOOLS & BLOCKS ---\n%v\n--- END TOOLS ---", blockNames),
-		"%agents%", fmt.Sprintf("--- AVAILABLE AGENT DEFINITIONS ---\n%v\n--- END AGENTS ---", updatedAgentList),
-	)
-	baseSystemPrompt := replacer.Replace(sysTemplate)
-
 	startID := 0
 	if len(plan.Steps) > 0 {
 		startID = plan.Steps[len(plan.Steps)-1].ID
@@ -903,32 +846,48 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 
 	chatHistory := ""
 	if p.Memory != nil {
-		chatHistory = p.Memory.GetContext(plan.ID)
+		chatHistory = p.Memory.GetContext(ctx, plan.ID, feedback, p.Memory.Scope(ctx, plan.ID)).String()
 	} else {
 		chatHistory = "User Feedback: " + feedback
 	}
 
-	taskPrompt := fmt.Sprintf(
-		"--- HISTORY & PROGRESS ---\n"+
-			"Current Steps (with results): %s\n"+
-			"Uploaded Files: %v\n"+
-			"Conversation History:\n%s\n\n"+
-			"--- TASK ---\n"+
-			"1. REPLAN: Review 'Objective' and 'Current Steps'. If a step has a 'result', that info is now known.\n"+
-			"2. STATUS CHECK: Review 'Current Steps'. If 'content-review' is pending, WAITING for user feedback. If 'scene-creator' is pending, WAITING for execution.\n"+
-			"3. GENERATE: Provide NEXT steps (starting from id %d). Follow the Strategies defined above.\n"+
-			"4. AVOID LOOPS: If the last completed step was an interactive agent (e.g. business-analyst) and the result was a confirmation/answer, DO NOT immediately schedule the same agent for the same task. Proceed to execution or the next phase.\n"+
-			"5. COMPLETION CHECK: If the 'current steps' have successfully achieved the 'Goal', you MUST return an empty JSON array `[]`. This will stop the plan.\n"+
-			"6. LANGUAGE: Ensure all generated content/parameters use the user's language: %s. NO ENGLISH when not requested.\n"+
-			"7. OUTPUT: Return a JSON array of Step objects.",
-		string(stepsJSON),
-		plan.Files,
-		chatHistory,
-		startID+1,            // Start ID for new steps
-		plan.Intent.Language, // Inject Language
+	taskPrompt := func(chatHistory string) string {
+		return fmt.Sprintf(
+			"--- HISTORY & PROGRESS ---\n"+
+				"Current Steps (with results): %s\n"+
+				"Uploaded Files: %v\n"+
+				"Conversation History:\n%s\n\n"+
+				"--- TASK ---\n"+
+				"1. REPLAN: Review 'Objective' and 'Current Steps'. If a step has a 'result', that info is now known.\n"+
+				"2. STATUS CHECK: Review 'Current Steps'. If 'content-review' is pending, WAITING for user feedback. If 'scene-creator' is pending, WAITING for execution.\n"+
+				"3. GENERATE: Provide NEXT steps (starting from id %d). Follow the Strategies defined above.\n"+
+				"4. AVOID LOOPS: If the last completed step was an interactive agent (e.g. business-analyst) and the result was a confirmation/answer, DO NOT immediately schedule the same agent for the same task. Proceed to execution or the next phase.\n"+
+				"5. COMPLETION CHECK: If the 'current steps' have successfully achieved the 'Goal', you MUST return an empty JSON array `[]`. This will stop the plan.\n"+
+				"6. LANGUAGE: Ensure all generated content/parameters use the user's language: %s. NO ENGLISH when not requested.\n"+
+				"7. OUTPUT: Return a JSON array of Step objects.",
+			string(stepsJSON),
+			plan.Files,
+			chatHistory,
+			startID+1,            // Start ID for new steps
+			plan.Intent.Language, // Inject Language
+		)
+	}
+
+	// Fit the tools, history and agents in the context window of the model
+	fixed := strings.NewReplacer("%goal%", plan.Intent.Prompt, "%action%", plan.Intent.Action, "%language%", plan.Intent.Language, "%tools%", "", "%agents%", "").Replace(sysTemplate)
+	toolsText, agentsText, chatHistory := p.fitContext(plan.ID, fixed+"\n\n"+taskPrompt("")+"Refine Plan", planUpdateSchema,
+		planContext{Tools: blockNames, Agents: updatedAgentList, History: chatHistory})
+
+	replacer := strings.NewReplacer(
+		"%goal%", plan.Intent.Prompt,
+		"%action%", plan.Intent.Action,
+		"%language%", plan.Intent.Language,
+		"%tools%", fmt.Sprintf("--- AVAILABLE TOOLS & BLOCKS ---\n%s\n--- END TOOLS ---", toolsText),
+		"%agents%", fmt.Sprintf("--- AVAILABLE AGENT DEFINITIONS ---\n%s\n--- END AGENTS ---", agentsText),
 	)
+	baseSystemPrompt := replacer.Replace(sysTemplate)
 
-	fullPrompt := baseSystemPrompt + "\n\n" + taskPrompt
+	fullPrompt := baseSystemPrompt + "\n\n" + taskPrompt(chatHistory)
 
 	// Add a temporary replanning step to show in Kanban
 	replanID := 1
@@ -946,7 +905,28 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 	// Persist so UI sees "Running"
 	_ = p.Store.SavePlan(*plan)
 
-	resp, usage, err := p.llm.Generate(ctx, "Refine Plan", fullPrompt)
+	// Temporary struct to handle string dependencies from LLM
+	// Explicit struct to avoid embedding issues and handle flexible dependencies
+	type ParsingStep struct {
+		StepID       int                    `json:"step_id"`
+		AgentID      string                 `json:"agent_id"`
+		Action       string                 `json:"action"`
+		Params       map[string]interface{} `json:"params"`
+		DependsOnRaw interface{}            `json:"depends_on"`
+	}
+	var parsed struct {
+		Steps []ParsingStep `json:"steps"`
+		Error string        `json:"error"`
+	}
+	resp, usage, err := llm.GenerateStructured(ctx, p.llm, "Refine Plan", fullPrompt, planUpdateSchema, &parsed)
+	var invalid *llm.StructuredError
+	if errors.As(err, &invalid) {
+		// No usable steps, like an empty answer
+		if p.Debug {
+			fmt.Printf("[Planner] Failed to parse plan update: %v. Raw: %s\n", err, resp)
+		}
+		err = nil
+	}
 	if err != nil {
 		// Mark replan step as failed
 		for i := len(plan.Steps) - 1; i >= 0; i-- {
@@ -961,15 +941,8 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 	}
 
 	// Accumulate Usage in both TotalUsage and PlanningUsage
-	plan.TotalUsage.PromptTokens += usage.PromptTokens
-	plan.TotalUsage.CompletionTokens += usage.CompletionTokens
-	plan.TotalUsage.TotalTokens += usage.TotalTokens
-	plan.TotalUsage.EstimatedCost += usage.EstimatedCost
-
-	plan.PlanningUsage.PromptTokens += usage.PromptTokens
-	plan.PlanningUsage.CompletionTokens += usage.CompletionTokens
-	plan.PlanningUsage.TotalTokens += usage.TotalTokens
-	plan.PlanningUsage.EstimatedCost += usage.EstimatedCost
+	plan.TotalUsage.Add(usage)
+	plan.PlanningUsage.Add(usage)
 
 	// Attribute usage to the replanning step
 	for i := range plan.Steps {
@@ -980,47 +953,11 @@ func (p *Planner) UpdatePlan(ctx context.Context, plan *model.ExecutionPlan, fee
 		}
 	}
 
-	// 3. Parse and Append
-	cleanResp := p.cleanJSONResponse(resp)
-
-	// Temporary struct to handle string dependencies from LLM
-	// Explicit struct to avoid embedding issues and handle flexible dependencies
-	type ParsingStep struct {
-		StepID       int                    `json:"step_id"`
-		AgentID      string                 `json:"agent_id"`
-		Action       string                 `json:"action"`
-		Params       map[string]interface{} `json:"params"`
-		DependsOnRaw interface{}            `json:"depends_on"`
-	}
-	var parsingSteps []ParsingStep
-
-	if err := json.Unmarshal([]byte(cleanResp), &parsingSteps); err != nil {
-		// Try wrapped
-		var wrapped struct {
-			Steps []ParsingStep `json:"steps"`
-		}
-		if err2 := json.Unmarshal([]byte(cleanResp), &wrapped); err2 == nil && len(wrapped.Steps) > 0 {
-			parsingSteps = wrapped.Steps
-		} else {
-			// Try single object
-			var single ParsingStep
-			if err3 := json.Unmarshal([]byte(cleanResp), &single); err3 == nil {
-				if single.Action != "" {
-					parsingSteps = []ParsingStep{single}
-				}
-			} else {
-				// Attempt 4: Error Object
-				var errorResp struct {
-					Error string `json:"error"`
-				}
-				if err4 := json.Unmarshal([]byte(cleanResp), &errorResp); err4 == nil && errorResp.Error != "" {
-					if p.Debug {
-						fmt.Printf("[Planner] LLM returned error: %s\n", errorResp.Error)
-					}
-				}
-			}
-		}
+	// 3. Append the parsed steps
+	if parsed.Error != "" && p.Debug {
+		fmt.Printf("[Planner] LLM returned error: %s\n", parsed.Error)
 	}
+	parsingSteps := parsed.Steps
 
 	var newSteps []model.Step
 	for _, ps := range parsingSteps {
@@ -1275,6 +1212,9 @@ func (p *Planner) updatePlanCost(plan *model.ExecutionPlan) {
 		return
 	}
 
+	// Charge the memory summarization done for this plan since the last save
+	p.Memory.ChargeUsage(plan)
+
 	// CalculateCost now aggregates individual step costs
 	plan.CalculateCost()
 }
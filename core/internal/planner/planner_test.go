@@ -0,0 +1,125 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm/llmtest"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/registry"
+	"github.com/sjhoeksma/druppie/core/internal/store"
+)
+
+// script holds the answers the fixtures of testdata/llm were recorded with
+var script = llmtest.Script{
+	"Select the most relevant agents": `["writer"]`,
+	"Generate plan data": `[
+  {"step_id": 1, "agent_id": "writer", "action": "agent_task", "params": {"task": "Write a haiku about bicycles"}},
+  {"step_id": 2, "agent_id": "writer", "action": "agent_task", "params": {"task": "Translate the haiku to Dutch"}, "depends_on": [1]}
+]`,
+	"REPLAN": `{"steps": [{"step_id": 3, "agent_id": "planner", "action": "complete_plan", "params": {}}]}`,
+}
+
+func testRegistry() *registry.Registry {
+	reg := registry.NewRegistry()
+	reg.Agents["planner"] = model.AgentDefinition{
+		ID:           "planner",
+		Type:         "system_agent",
+		Instructions: "You plan the steps for the goal: %goal% (%action%, language %language%).\n%agents%\n%tools%",
+		FinalActions: []string{"complete_plan"},
+	}
+	reg.Agents["writer"] = model.AgentDefinition{
+		ID:          "writer",
+		Name:        "Writer",
+		Type:        "execution_agent",
+		Description: "Writes short texts",
+		Skills:      []string{"agent_task"},
+		Priority:    1,
+	}
+	return reg
+}
+
+// quietStore keeps the plan logs out of the .druppie directory of the project
+type quietStore struct {
+	*store.FileStore
+}
+
+func (quietStore) LogInteraction(planID, tag, input, output string) error {
+	return nil
+}
+
+func (quietStore) AppendRawLog(planID, message string) error {
+	return nil
+}
+
+func newTestPlanner(t *testing.T) *Planner {
+	s, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPlanner(llmtest.Replay(t, "testdata/llm", script), testRegistry(), quietStore{s}, nil, nil, 3, false)
+}
+
+var intent = model.Intent{Prompt: "Write a haiku about bicycles and translate it", Action: "create_project", Language: "en"}
+
+func TestCreatePlan(t *testing.T) {
+	p := newTestPlanner(t)
+	plan, err := p.CreatePlan(context.Background(), intent, "plan-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.SelectedAgents) != 1 || plan.SelectedAgents[0] != "writer" {
+		t.Errorf("expected the writer to be selected, got %v", plan.SelectedAgents)
+	}
+	if len(plan.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %+v", plan.Steps)
+	}
+	second := plan.Steps[1]
+	if second.ID != 2 || second.Status != "pending" || len(second.DependsOn) == 0 || second.DependsOn[0] != 1 {
+		t.Errorf("unexpected second step %+v", second)
+	}
+	if second.Params["language"] != "en" {
+		t.Errorf("expected the language of the intent in the params, got %v", second.Params)
+	}
+	if plan.TotalUsage.TotalTokens == 0 {
+		t.Error("expected the usage of the recorded calls")
+	}
+}
+
+func TestUpdatePlan(t *testing.T) {
+	p := newTestPlanner(t)
+	plan, err := p.CreatePlan(context.Background(), intent, "plan-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range plan.Steps {
+		plan.Steps[i].Status = "completed"
+		plan.Steps[i].Result = "Done"
+	}
+
+	updated, err := p.UpdatePlan(context.Background(), &plan, "Autoconfirmed: Parallel batch completed.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := updated.Steps[len(updated.Steps)-1]
+	if last.Action != "complete_plan" || last.ID != 3 || last.Status != "pending" {
+		t.Fatalf("expected a pending complete_plan step 3, got %+v", last)
+	}
+	replan := updated.Steps[len(updated.Steps)-2]
+	if replan.Action != "replanning" || replan.Status != "completed" {
+		t.Errorf("expected a completed replanning step, got %+v", replan)
+	}
+
+	// A final action stops the plan without asking the model
+	for i := range updated.Steps {
+		updated.Steps[i].Status = "completed"
+	}
+	n := len(updated.Steps)
+	final, err := p.UpdatePlan(context.Background(), updated, "Plan Completed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(final.Steps) != n {
+		t.Errorf("expected no new steps after complete_plan, got %+v", final.Steps[n:])
+	}
+}
@@ -0,0 +1,45 @@
+package planner
+
+import "github.com/sjhoeksma/druppie/core/internal/llm"
+
+// Structured output of the planner prompts (see llm.GenerateStructured)
+
+// agentSelectionSchema is the answer of selectRelevantAgents
+var agentSelectionSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "selected_agents": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["selected_agents"]
+}`)
+
+// planStepSchema is a step proposed by the planner
+const planStepSchema = `{
+  "type": "object",
+  "properties": {
+    "step_id": {"type": "integer"},
+    "agent_id": {"type": "string"},
+    "action": {"type": "string"},
+    "params": {"type": ["object", "null"]},
+    "depends_on": {"description": "IDs or actions of the steps this step waits for"}
+  },
+  "required": ["agent_id", "action"]
+}`
+
+// planSchema is the answer of CreatePlan
+var planSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "steps": {"type": "array", "items": ` + planStepSchema + `}
+  },
+  "required": ["steps"]
+}`)
+
+// planUpdateSchema is the answer of UpdatePlan: the next steps, none when the plan is done
+var planUpdateSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "steps": {"type": "array", "items": ` + planStepSchema + `},
+    "error": {"type": "string"}
+  }
+}`)
@@ -0,0 +1,282 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// Effect is what a finding does to the evaluated step
+type Effect string
+
+const (
+	EffectDeny    Effect = "deny"     // Step is blocked
+	EffectApprove Effect = "approval" // Step must be approved by a group
+	EffectWarn    Effect = "warn"     // Reported only
+)
+
+// DefaultApprovalGroup is used when an approval finding does not name a group
+const DefaultApprovalGroup = "compliance"
+
+// Finding is a single policy violation reported by a rule
+type Finding struct {
+	RuleID   string `json:"rule_id"`
+	RuleName string `json:"rule_name,omitempty"`
+	Effect   Effect `json:"effect"`
+	Message  string `json:"message"`
+	Group    string `json:"group,omitempty"`    // Approval group for EffectApprove
+	Severity string `json:"severity,omitempty"` // Defaults to the rule sensitivity
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.RuleName, f.Message)
+}
+
+// Decision is the combined result of all rules for one input
+type Decision struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Blocked reports whether any rule denies the step
+func (d Decision) Blocked() bool {
+	return len(d.ByEffect(EffectDeny)) > 0
+}
+
+// ApprovalGroups returns the distinct groups that must approve the step
+func (d Decision) ApprovalGroups() []string {
+	var groups []string
+	seen := make(map[string]bool)
+	for _, f := range d.ByEffect(EffectApprove) {
+		if !seen[f.Group] {
+			seen[f.Group] = true
+			groups = append(groups, f.Group)
+		}
+	}
+	return groups
+}
+
+// ByEffect filters the findings on effect
+func (d Decision) ByEffect(effect Effect) []Finding {
+	var out []Finding
+	for _, f := range d.Findings {
+		if f.Effect == effect {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+type compiledRule struct {
+	rule  model.ComplianceRule
+	query rego.PreparedEvalQuery
+}
+
+// Engine evaluates the Rego policies of compliance rules in-process.
+//
+// A policy document exposes up to three sets in its package:
+//
+//	deny contains msg if { ... }                                   # block the step
+//	approval contains {"msg": msg, "group": "security"} if { ... } # route to an approval group
+//	warn contains msg if { ... }                                   # report only
+//
+// Entries are either a message string or an object with msg (or message), group and severity.
+type Engine struct {
+	rules []compiledRule
+}
+
+// NewEngine compiles the policies of the given rules. Rules without a policy are ignored.
+// Rules that fail to compile are skipped and reported in the returned error; the engine is
+// usable for the remaining rules.
+func NewEngine(ctx context.Context, rules map[string]model.ComplianceRule) (*Engine, error) {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	e := &Engine{}
+	var errs []error
+	for _, id := range ids {
+		rule := rules[id]
+		if strings.TrimSpace(rule.RegoPolicy) == "" {
+			continue
+		}
+		query, err := prepare(ctx, rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compliance rule %s: %w", id, err))
+			continue
+		}
+		e.rules = append(e.rules, compiledRule{rule: rule, query: query})
+	}
+	return e, errors.Join(errs...)
+}
+
+func prepare(ctx context.Context, rule model.ComplianceRule) (rego.PreparedEvalQuery, error) {
+	filename := rule.ID + ".rego"
+	module, err := ast.ParseModule(filename, rule.RegoPolicy)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	query, err := rego.New(
+		rego.Query(module.Package.Path.String()),
+		rego.Module(filename, rule.RegoPolicy),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("failed to compile policy: %w", err)
+	}
+	return query, nil
+}
+
+// Rules returns the IDs of the rules with a compiled policy
+func (e *Engine) Rules() []string {
+	if e == nil {
+		return nil
+	}
+	ids := make([]string, len(e.rules))
+	for i, r := range e.rules {
+		ids[i] = r.rule.ID
+	}
+	return ids
+}
+
+// Evaluate runs every policy against the input and collects the findings
+func (e *Engine) Evaluate(ctx context.Context, input map[string]interface{}) (Decision, error) {
+	var decision Decision
+	if e == nil {
+		return decision, nil
+	}
+	for _, r := range e.rules {
+		rs, err := r.query.Eval(ctx, rego.EvalInput(input))
+		if err != nil {
+			return decision, fmt.Errorf("failed to evaluate compliance rule %s: %w", r.rule.ID, err)
+		}
+		for _, result := range rs {
+			for _, expr := range result.Expressions {
+				doc, ok := expr.Value.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				decision.Findings = append(decision.Findings, collect(r.rule, EffectDeny, doc["deny"])...)
+				decision.Findings = append(decision.Findings, collect(r.rule, EffectApprove, doc["approval"])...)
+				decision.Findings = append(decision.Findings, collect(r.rule, EffectWarn, doc["warn"])...)
+			}
+		}
+	}
+	return decision, nil
+}
+
+// collect converts the entries of a deny/approval/warn set into findings
+func collect(rule model.ComplianceRule, effect Effect, value interface{}) []Finding {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	name := rule.Name
+	if name == "" {
+		name = rule.ID
+	}
+
+	var findings []Finding
+	for _, entry := range entries {
+		f := Finding{RuleID: rule.ID, RuleName: name, Effect: effect, Severity: rule.Sensitivity}
+		switch v := entry.(type) {
+		case string:
+			f.Message = v
+		case map[string]interface{}:
+			f.Message = firstString(v, "msg", "message")
+			f.Group = firstString(v, "group")
+			if s := firstString(v, "severity"); s != "" {
+				f.Severity = s
+			}
+		default:
+			data, _ := json.Marshal(v)
+			f.Message = string(data)
+		}
+		if effect == EffectApprove && f.Group == "" {
+			f.Group = DefaultApprovalGroup
+		}
+		findings = append(findings, f)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Message < findings[j].Message })
+	return findings
+}
+
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Input builds the policy input document for a step. plan may be nil when only the step is known.
+//
+//	input.step  - the step (id, agent_id, action, params, ...)
+//	input.plan  - plan metadata (plan_id, creator_id, status, intent, selected_agents, allowed_groups)
+//	input.agent - the agent definition of the step, if known
+func Input(plan *model.ExecutionPlan, step model.Step, agent *model.AgentDefinition) map[string]interface{} {
+	input := map[string]interface{}{
+		"step": toDoc(step),
+	}
+	if plan != nil {
+		input["plan"] = map[string]interface{}{
+			"plan_id":         plan.ID,
+			"creator_id":      plan.CreatorID,
+			"status":          plan.Status,
+			"intent":          toDoc(plan.Intent),
+			"selected_agents": plan.SelectedAgents,
+			"allowed_groups":  plan.AllowedGroups,
+			"total_cost":      plan.TotalCost,
+		}
+	}
+	if agent != nil {
+		input["agent"] = toDoc(agent)
+	}
+	return input
+}
+
+// toDoc converts a value to its JSON document form, as seen by Rego
+func toDoc(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+var regoBlockPattern = regexp.MustCompile("(?s)```rego[ \t]*\r?\n(.*?)```")
+
+// ExtractRego returns the ```rego code blocks of a markdown document, concatenated.
+// The first block declares the package; package clauses of later blocks are dropped.
+func ExtractRego(markdown string) string {
+	var blocks []string
+	for _, m := range regoBlockPattern.FindAllStringSubmatch(markdown, -1) {
+		if block := strings.TrimSpace(m[1]); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+	// Only the first block keeps its package clause
+	out := blocks[0]
+	for _, b := range blocks[1:] {
+		out += "\n\n" + packageClause.ReplaceAllString(b, "")
+	}
+	return out
+}
+
+var packageClause = regexp.MustCompile(`(?m)^package\s+\S+\s*$`)
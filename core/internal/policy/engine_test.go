@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// newComplianceEngine compiles the policies of the compliance documents of the repository
+func newComplianceEngine(t *testing.T) *Engine {
+	rules := make(map[string]model.ComplianceRule)
+	for _, id := range []string{"bio_nis2", "ai_act"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "..", "compliance", id+".md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules[id] = model.ComplianceRule{ID: id, Name: id, RegoPolicy: ExtractRego(string(data))}
+	}
+	e, err := NewEngine(context.Background(), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.Rules(), ","); got != "ai_act,bio_nis2" {
+		t.Fatalf("expected both policies compiled, got %s", got)
+	}
+	return e
+}
+
+func TestCompliancePolicies(t *testing.T) {
+	e := newComplianceEngine(t)
+	tests := map[string]struct {
+		params map[string]interface{}
+		want   string // Sorted effects, with the group of approvals
+	}{
+		"no params":               {nil, ""},
+		"EU":                      {map[string]interface{}{"region": "westeurope", "data_classification": "BBN2"}, ""},
+		"EU public":               {map[string]interface{}{"region": "eu-west-1", "access_level": "Public", "data_classification": "BBN1"}, ""},
+		"EU without class":        {map[string]interface{}{"region": "europe-west4"}, "warn"},
+		"non-EU":                  {map[string]interface{}{"region": "us-east-1", "data_classification": "BBN2"}, "approval:security"},
+		"non-EU deployment":       {map[string]interface{}{"deployment_region": "US-East-1", "data_classification": "BBN2"}, "approval:security"},
+		"non-EU public":           {map[string]interface{}{"region": "us-east-1", "access_level": "public", "data_classification": "BBN2"}, "deny"},
+		"BBN3 in NL":              {map[string]interface{}{"region": "netherlands-north", "data_classification": "bbn3"}, ""},
+		"BBN3 outside NL":         {map[string]interface{}{"region": "francecentral", "data_classification": "BBN3"}, "approval:security"},
+		"null region":             {map[string]interface{}{"region": nil, "deployment_region": "us-east-1", "data_classification": "BBN2"}, "approval:security"},
+		"prohibited AI":           {map[string]interface{}{"ai_risk_level": "Unacceptable"}, "deny"},
+		"high risk AI":            {map[string]interface{}{"ai_risk_level": "hoog"}, "approval:compliance"},
+		"limited risk AI":         {map[string]interface{}{"ai_risk_level": "limited"}, "warn"},
+		"minimal risk AI":         {map[string]interface{}{"ai_risk_level": "minimal"}, ""},
+		"region list":             {map[string]interface{}{"region": []interface{}{"us-east-1"}, "access_level": "public", "data_classification": "BBN2"}, "deny"},
+		"deployment region value": {map[string]interface{}{"deployment_region": map[string]interface{}{"name": "us-east-1"}, "data_classification": "BBN2"}, "deny"},
+		"access level object":     {map[string]interface{}{"region": "us-east-1", "access_level": map[string]interface{}{"value": "public"}, "data_classification": "BBN2"}, "approval:security,deny"},
+		"classification number":   {map[string]interface{}{"region": "francecentral", "data_classification": 3}, "deny"},
+		"AI risk list":            {map[string]interface{}{"ai_risk_level": []interface{}{"high"}}, "deny"},
+	}
+	for name, tc := range tests {
+		decision, err := e.Evaluate(context.Background(), Input(nil, model.Step{ID: 1, Action: "create_repo", Params: tc.params}, nil))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var effects []string
+		for _, f := range decision.Findings {
+			effect := string(f.Effect)
+			if f.Effect == EffectApprove {
+				effect += ":" + f.Group
+			}
+			effects = append(effects, effect)
+		}
+		sort.Strings(effects)
+		if got := strings.Join(effects, ","); got != tc.want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, tc.want, got, decision.Findings)
+		}
+	}
+}
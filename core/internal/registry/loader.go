@@ -2,6 +2,7 @@ package registry
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/policy"
 	"gopkg.in/yaml.v3"
 )
 
@@ -134,8 +136,9 @@ func (r *Registry) Load(rootDir string) error {
 			rule.ID = strings.ReplaceAll(rule.ID, "-", "_")
 		}
 
+		// The markdown documents the rule, its ```rego blocks enforce it
 		if len(body) > 0 && rule.RegoPolicy == "" {
-			rule.RegoPolicy = string(body)
+			rule.RegoPolicy = policy.ExtractRego(string(body))
 		}
 		comp[rule.ID] = rule
 		return nil
@@ -173,6 +176,12 @@ func (r *Registry) Load(rootDir string) error {
 		return fmt.Errorf("error loading plugins: %w", err)
 	}
 
+	// Compile compliance policies; a broken policy is reported but does not stop the registry
+	engine, err := policy.NewEngine(context.Background(), comp)
+	if err != nil {
+		fmt.Printf("[Registry] Warning: %v\n", err)
+	}
+
 	// Atomic Update
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -181,6 +190,7 @@ func (r *Registry) Load(rootDir string) error {
 	r.Agents = agents
 	r.MCPServers = mcps
 	r.Compliance = comp
+	r.policy = engine
 
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/policy"
 )
 
 // RegistryInterface defines the interface for accessing registry items
@@ -21,6 +22,7 @@ type Registry struct {
 	MCPServers     map[string]model.MCPServer
 	Agents         map[string]model.AgentDefinition
 	Compliance     map[string]model.ComplianceRule
+	policy         *policy.Engine
 }
 
 // NewRegistry creates an empty registry
@@ -34,6 +36,13 @@ func NewRegistry() *Registry {
 	}
 }
 
+// Policy returns the engine with the compiled compliance policies (nil before Load)
+func (r *Registry) Policy() *policy.Engine {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy
+}
+
 // GetBuildingBlock retrieves a building block by ID
 func (r *Registry) GetBuildingBlock(id string) (model.BuildingBlock, error) {
 	r.mu.RLock()
@@ -2,9 +2,10 @@ package router
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/sjhoeksma/druppie/core/internal/audit"
 	"github.com/sjhoeksma/druppie/core/internal/llm"
 	"github.com/sjhoeksma/druppie/core/internal/model"
 	"github.com/sjhoeksma/druppie/core/internal/registry"
@@ -23,6 +24,21 @@ func NewRouter(llm llm.Provider, store store.Store, reg *registry.Registry, debu
 	return &Router{llm: llm, store: store, registry: reg, Debug: debug}
 }
 
+// intentSchema is the structured output of Analyze
+var intentSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "initial_prompt": {"type": "string"},
+    "prompt": {"type": "string"},
+    "action": {"type": "string", "enum": ["create_project", "update_project", "query_registry", "orchestrate_complex", "general_chat"]},
+    "category": {"type": "string"},
+    "content_type": {"type": "string"},
+    "language": {"type": "string"},
+    "answer": {"type": ["string", "null"]}
+  },
+  "required": ["prompt", "action", "language"]
+}`)
+
 const defaultSystemPrompt = `You are the Router Agent of the Druppie Platform.
 Your job is to analyze the User's input and determine their Intent.
 You must output a JSON object adhering to this schema:
@@ -44,28 +60,17 @@ func (r *Router) Analyze(ctx context.Context, planID string, input string) (mode
 	// Try to load prompt from Registry
 	sysPrompt := defaultSystemPrompt
 	if r.registry != nil {
-		if agent, err := r.registry.GetAgent("router"); err == nil && agent.Instructions != "" {
-			sysPrompt = agent.Instructions
+		if agent, err := r.registry.GetAgent("router"); err == nil {
+			ctx = llm.WithAgent(ctx, agent)
+			if agent.Instructions != "" {
+				sysPrompt = agent.Instructions
+			}
 		}
 	}
 
-	resp, usage, err := r.llm.Generate(ctx, input, sysPrompt)
-	if err != nil {
-		return model.Intent{}, "", model.TokenUsage{}, fmt.Errorf("llm generation failed: %w", err)
+	if planID != "" {
+		ctx = audit.WithChain(ctx, planID)
 	}
-
-	// Persistent Logging "Like Planner"
-	if r.store != nil && planID != "" {
-		_ = r.store.LogInteraction(planID, "Router Analyze",
-			fmt.Sprintf("--- PROMPT ---\n%s\n--- END PROMPT ---\n--- INPUT ---\n%s\n--- END INPUT ---", sysPrompt, input),
-			fmt.Sprintf("--- RESPONSE ---\n%s\n--- END RESPONSE ---", resp))
-	}
-
-	// Simple cleanup if LLM adds markdown blocks
-	// resp = strings.TrimPrefix(resp, "```json")
-	// resp = strings.TrimSuffix(resp, "```")
-	// For now assume mock returns clean JSON
-
 	var raw struct {
 		Summary       string `json:"summary"`
 		InitialPrompt string `json:"initial_prompt"`
@@ -76,8 +81,20 @@ func (r *Router) Analyze(ctx context.Context, planID string, input string) (mode
 		Language      string `json:"language"`
 		Answer        string `json:"answer"`
 	}
+	resp, usage, err := llm.GenerateStructured(ctx, r.llm, input, sysPrompt, intentSchema, &raw)
+	var invalid *llm.StructuredError
+	if err != nil && !errors.As(err, &invalid) {
+		return model.Intent{}, "", usage, fmt.Errorf("llm generation failed: %w", err)
+	}
+
+	// Persistent Logging "Like Planner"
+	if r.store != nil && planID != "" {
+		_ = r.store.LogInteraction(planID, "Router Analyze",
+			fmt.Sprintf("--- PROMPT ---\n%s\n--- END PROMPT ---\n--- INPUT ---\n%s\n--- END INPUT ---", sysPrompt, input),
+			fmt.Sprintf("--- RESPONSE ---\n%s\n--- END RESPONSE ---", resp))
+	}
 
-	if err := json.Unmarshal([]byte(resp), &raw); err != nil {
+	if invalid != nil {
 		return model.Intent{}, resp, usage, fmt.Errorf("failed to parse router response: %w. Raw: %s", err, resp)
 	}
 
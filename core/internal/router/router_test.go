@@ -0,0 +1,38 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sjhoeksma/druppie/core/internal/llm/llmtest"
+)
+
+// script holds the answers the fixtures of testdata/llm were recorded with
+var script = llmtest.Script{
+	"Maak een blog over elektrische fietsen": `{"initial_prompt": "Maak een blog over elektrische fietsen", "prompt": "Een blog schrijven over elektrische fietsen", "action": "create_project", "category": "create content", "content_type": "blog", "language": "nl", "answer": null}`,
+	"What is the capital of France?":         `{"prompt": "What is the capital of France?", "action": "general_chat", "category": "unknown", "language": "en", "answer": "Paris is the capital of France."}`,
+}
+
+func TestAnalyze(t *testing.T) {
+	r := NewRouter(llmtest.Replay(t, "testdata/llm", script), nil, nil, false)
+
+	intent, _, usage, err := r.Analyze(context.Background(), "", "Maak een blog over elektrische fietsen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent.Action != "create_project" || intent.Language != "nl" || intent.ContentType != "blog" {
+		t.Errorf("unexpected intent %+v", intent)
+	}
+	if intent.Prompt != "Een blog schrijven over elektrische fietsen" || usage.TotalTokens == 0 {
+		t.Errorf("expected the recorded prompt and usage, got %q and %+v", intent.Prompt, usage)
+	}
+
+	// The fallbacks fill in what the model left out
+	intent, _, _, err = r.Analyze(context.Background(), "", "What is the capital of France?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent.Action != "general_chat" || intent.Answer != "Paris is the capital of France." || intent.InitialPrompt != "What is the capital of France?" {
+		t.Errorf("unexpected intent %+v", intent)
+	}
+}
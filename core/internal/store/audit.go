@@ -0,0 +1,237 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// ErrAuditConflict is returned by AppendAudit when the record does not follow the last record
+// of its chain: another writer extended the chain since the head was read
+var ErrAuditConflict = errors.New("audit chain was extended concurrently")
+
+// AuditStore persists the append-only audit trail. Records are never updated or deleted,
+// also not when the plan they belong to is removed.
+type AuditStore interface {
+	// AppendAudit stores the record when it follows the last record of its chain (Seq and
+	// PrevHash), and returns ErrAuditConflict otherwise
+	AppendAudit(rec model.AuditRecord) error
+	ListAudit(chain string) ([]model.AuditRecord, error)
+	ListAuditChains() ([]string, error)
+}
+
+func (s *FileStore) auditPath(chain string) string {
+	return filepath.Join(s.baseDir, "audit", chain+".jsonl")
+}
+
+// AppendAudit appends the record as one JSON line to .druppie/audit/<chain>.jsonl
+func (s *FileStore) AppendAudit(rec model.AuditRecord) error {
+	if rec.Chain == "" || strings.ContainsAny(rec.Chain, `/\`) {
+		return fmt.Errorf("invalid audit chain: %q", rec.Chain)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.auditPath(rec.Chain)
+	head, err := lastAuditRecord(path)
+	if err != nil {
+		return err
+	}
+	if rec.Seq != head.Seq+1 || rec.PrevHash != head.Hash {
+		return ErrAuditConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return f.Sync()
+}
+
+// lastAuditRecord reads the last line of a chain file, the zero record for an empty chain
+func lastAuditRecord(path string) (model.AuditRecord, error) {
+	var head model.AuditRecord
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return head, nil
+		}
+		return head, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return head, fmt.Errorf("failed to stat audit log: %w", err)
+	}
+
+	// Read back from the end until the start of the last line: records may hold large prompts
+	const chunkSize = 64 * 1024
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		n := int64(chunkSize)
+		if n > end {
+			n = end
+		}
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, end-n); err != nil {
+			return head, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		tail = append(chunk, tail...)
+		end -= n
+		if i := bytes.LastIndexByte(bytes.TrimRight(tail, "\r\n\t "), '\n'); i != -1 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+	line := bytes.TrimSpace(tail)
+	if len(line) == 0 {
+		return head, nil
+	}
+	if err := json.Unmarshal(line, &head); err != nil {
+		return head, fmt.Errorf("corrupt audit record at the end of %s: %w", filepath.Base(path), err)
+	}
+	return head, nil
+}
+
+func (s *FileStore) ListAudit(chain string) ([]model.AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, err := os.Open(s.auditPath(chain))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var records []model.AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // Records may hold large prompts
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec model.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return records, fmt.Errorf("corrupt audit record at line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+func (s *FileStore) ListAuditChains() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.baseDir, "audit"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var chains []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jsonl") {
+			chains = append(chains, strings.TrimSuffix(e.Name(), ".jsonl"))
+		}
+	}
+	sort.Strings(chains)
+	return chains, nil
+}
+
+// AppendAudit inserts the record; the (chain, seq) primary key rejects forks of a chain, which
+// are reported as ErrAuditConflict
+func (s *SQLStore) AppendAudit(rec model.AuditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	if rec.Seq > 1 {
+		var prevHash string
+		err := s.db.QueryRow(s.rebind(`SELECT hash FROM audit_log WHERE chain = ? AND seq = ?`), rec.Chain, rec.Seq-1).Scan(&prevHash)
+		if err == sql.ErrNoRows || (err == nil && prevHash != rec.PrevHash) {
+			return ErrAuditConflict
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit chain head: %w", err)
+		}
+	}
+	_, err = s.exec(`INSERT INTO audit_log (chain, seq, type, actor, created_at, hash, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rec.Chain, rec.Seq, rec.Type, rec.Actor, rec.Time.UnixNano(), rec.Hash, string(data))
+	if err != nil {
+		// Primary key violations differ per driver: a record with this seq means another
+		// writer got there first
+		var n int
+		if s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM audit_log WHERE chain = ? AND seq = ?`), rec.Chain, rec.Seq).Scan(&n) == nil && n > 0 {
+			return ErrAuditConflict
+		}
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListAudit(chain string) ([]model.AuditRecord, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT data FROM audit_log WHERE chain = ? ORDER BY seq`), chain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var records []model.AuditRecord
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan audit record: %w", err)
+		}
+		var rec model.AuditRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return records, fmt.Errorf("corrupt audit record: %w", err)
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLStore) ListAuditChains() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT chain FROM audit_log ORDER BY chain`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit chains: %w", err)
+	}
+	defer rows.Close()
+
+	var chains []string
+	for rows.Next() {
+		var chain string
+		if err := rows.Scan(&chain); err != nil {
+			return nil, err
+		}
+		chains = append(chains, chain)
+	}
+	return chains, rows.Err()
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// ErrConflict is returned by CompareAndSwapPlan when the plan was saved by someone else
+// since it was read
+var ErrConflict = errors.New("plan was modified concurrently")
+
+// maxUpdateAttempts bounds the retries of UpdatePlan
+const maxUpdateAttempts = 8
+
+// UpdatePlan reads the plan, applies mutate and saves it with CompareAndSwapPlan. On a
+// conflict the plan is read again and mutate runs on the fresh copy, so mutate must only
+// depend on the plan it is given. An error from mutate aborts the update and is returned.
+// After maxUpdateAttempts conflicts the error wraps ErrConflict.
+func UpdatePlan(s Store, id string, mutate func(*model.ExecutionPlan) error) (model.ExecutionPlan, error) {
+	for attempt := 1; ; attempt++ {
+		plan, err := s.GetPlan(id)
+		if err != nil {
+			return model.ExecutionPlan{}, err
+		}
+		if err := mutate(&plan); err != nil {
+			return plan, err
+		}
+		err = s.CompareAndSwapPlan(&plan)
+		if err == nil {
+			return plan, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return plan, err
+		}
+		if attempt == maxUpdateAttempts {
+			return plan, fmt.Errorf("plan %s: %w (gave up after %d attempts)", id, err, attempt)
+		}
+		// Spread the writers out before trying again
+		time.Sleep(time.Duration(attempt)*5*time.Millisecond + time.Duration(rand.Intn(5))*time.Millisecond)
+	}
+}
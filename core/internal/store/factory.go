@@ -0,0 +1,30 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options selects and configures the Store implementation.
+// It mirrors config.StoreConfig; config imports this package so it cannot be used directly.
+type Options struct {
+	Type   string // "file" (default) or "sql"
+	Driver string // database/sql driver for type "sql" (default "sqlite")
+	DSN    string // data source name, for sqlite defaults to <baseDir>/druppie.db
+}
+
+// New creates the Store selected by opts rooted at baseDir (e.g. .druppie)
+func New(baseDir string, opts Options) (Store, error) {
+	switch strings.ToLower(opts.Type) {
+	case "", "file":
+		return NewFileStore(baseDir)
+	case "sql", "sqlite", "postgres":
+		driver := opts.Driver
+		if driver == "" && strings.ToLower(opts.Type) == "postgres" {
+			driver = "postgres"
+		}
+		return NewSQLStore(baseDir, driver, opts.DSN)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", opts.Type)
+	}
+}
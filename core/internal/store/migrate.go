@@ -0,0 +1,100 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrateResult summarizes an import from a FileStore
+type MigrateResult struct {
+	Plans   int
+	Skipped int
+	Failed  int
+}
+
+// ImportFileStore copies plans (plan.json, revisions.jsonl, memory.json, logs/execution.log), config and MCP servers
+// from a file based store into dst. Plans that already exist in dst are skipped unless overwrite is set.
+func ImportFileStore(src *FileStore, dst Store, overwrite bool, progress func(string)) (MigrateResult, error) {
+	var res MigrateResult
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	plans, err := src.ListPlans()
+	if err != nil {
+		return res, fmt.Errorf("failed to list source plans: %w", err)
+	}
+
+	for _, plan := range plans {
+		if _, err := dst.GetPlan(plan.ID); err == nil && !overwrite {
+			progress(fmt.Sprintf("Skipping %s (already exists)", plan.ID))
+			res.Skipped++
+			continue
+		}
+		if overwrite {
+			// Replace logs instead of appending a second copy
+			if sqlDst, ok := dst.(*SQLStore); ok {
+				_ = sqlDst.deletePlanRows(plan.ID)
+			}
+		}
+
+		// Earlier revisions are saved first, so the destination keeps the plan history
+		if revisions, err := src.readRevisions(plan.ID); err == nil {
+			for _, rev := range revisions {
+				if rev.Plan != nil {
+					_ = dst.SavePlan(*rev.Plan)
+				}
+			}
+		}
+		if err := dst.SavePlan(plan); err != nil {
+			progress(fmt.Sprintf("Failed to import %s: %v", plan.ID, err))
+			res.Failed++
+			continue
+		}
+		if data, err := src.LoadMemory(plan.ID); err == nil {
+			if err := dst.SaveMemory(plan.ID, data); err != nil {
+				progress(fmt.Sprintf("Warning: memory of %s not imported: %v", plan.ID, err))
+			}
+		}
+		if logs, err := src.readLogFile(plan.ID); err == nil && logs != "" {
+			// Keep the log as one raw block so GetLogs renders it unchanged
+			if err := dst.AppendRawLog(plan.ID, strings.TrimSuffix(logs, "\n")); err != nil {
+				progress(fmt.Sprintf("Warning: logs of %s not imported: %v", plan.ID, err))
+			}
+		}
+		progress(fmt.Sprintf("Imported %s (%d steps)", plan.ID, len(plan.Steps)))
+		res.Plans++
+	}
+
+	if data, err := src.LoadConfig(); err == nil {
+		if _, err := dst.LoadConfig(); err != nil || overwrite {
+			if err := dst.SaveConfig(data); err != nil {
+				return res, fmt.Errorf("failed to import config: %w", err)
+			}
+			progress("Imported config.yaml")
+		}
+	}
+	if data, err := src.LoadMCPServers(); err == nil {
+		if _, err := dst.LoadMCPServers(); err != nil || overwrite {
+			if err := dst.SaveMCPServers(data); err != nil {
+				return res, fmt.Errorf("failed to import mcp servers: %w", err)
+			}
+			progress("Imported mcp_servers.json")
+		}
+	}
+	return res, nil
+}
+
+// readLogFile reads the plan's execution.log directly, bypassing any logging backend
+func (s *FileStore) readLogFile(planID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(s.baseDir, "plans", planID, "logs", "execution.log"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// JobQueue is a queue of plan steps executed by workers. A worker leases a job and renews
+// the lease while it runs; a job whose lease expires goes back on the queue, until it was
+// leased MaxJobAttempts times.
+type JobQueue interface {
+	// EnqueueJob queues the job and returns its ID
+	EnqueueJob(job model.StepJob) (string, error)
+	// LeaseJob hands the oldest available job to the worker, nil when the queue is empty
+	LeaseJob(workerID string, ttl time.Duration) (*model.StepJob, error)
+	// RenewLease extends the lease; ErrLeaseLost when the worker no longer holds the job
+	RenewLease(jobID, workerID string, ttl time.Duration) error
+	// ReportJobEvents stores the events of a running job so far, so the server can stream
+	// them; ErrLeaseLost when the worker no longer holds the job
+	ReportJobEvents(jobID, workerID string, events json.RawMessage) error
+	// FinishJob stores the result; ErrLeaseLost when the worker no longer holds the job
+	FinishJob(jobID, workerID string, events json.RawMessage, errMsg string) error
+	GetJob(jobID string) (model.StepJob, error)
+	// FindJob returns the latest job of a plan step, nil when there is none
+	FindJob(planID string, stepID int) (*model.StepJob, error)
+	// RemoveJob drops the job, also while a worker holds it (cancelling it)
+	RemoveJob(jobID string) error
+}
+
+// ErrLeaseLost is returned to a worker whose job expired, was removed or finished elsewhere
+var ErrLeaseLost = errors.New("job lease lost")
+
+// MaxJobAttempts is the number of leases after which a job that keeps expiring fails
+const MaxJobAttempts = 3
+
+func newJobID(job model.StepJob) string {
+	return fmt.Sprintf("%s-%d-%d", job.PlanID, job.Step.ID, time.Now().UnixNano())
+}
+
+func expiredJobError(attempts int) string {
+	return fmt.Sprintf("worker lease expired %d times", attempts)
+}
+
+// --- MemoryQueue: embedded queue for in-process workers ---
+
+// MemoryQueue is a JobQueue in memory. It is not durable: use it for workers in the same
+// process as the server (or a test), and the SQL store for separate worker processes.
+type MemoryQueue struct {
+	mu   sync.Mutex
+	jobs map[string]*model.StepJob
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{jobs: make(map[string]*model.StepJob)}
+}
+
+func (q *MemoryQueue) EnqueueJob(job model.StepJob) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.ID == "" {
+		job.ID = newJobID(job)
+	}
+	now := time.Now().UTC()
+	job.Status, job.Attempts, job.WorkerID = model.JobQueued, 0, ""
+	job.Created, job.Updated = now, now
+	q.jobs[job.ID] = &job
+	return job.ID, nil
+}
+
+func (q *MemoryQueue) LeaseJob(workerID string, ttl time.Duration) (*model.StepJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now().UTC()
+
+	var available []*model.StepJob
+	for _, j := range q.jobs {
+		if j.Status == model.JobLeased && j.LeaseUntil.Before(now) {
+			if j.Attempts >= MaxJobAttempts {
+				j.Status, j.Error, j.Updated = model.JobFailed, expiredJobError(j.Attempts), now
+				continue
+			}
+			j.Status = model.JobQueued
+		}
+		if j.Status == model.JobQueued {
+			available = append(available, j)
+		}
+	}
+	if len(available) == 0 {
+		return nil, nil
+	}
+	sort.Slice(available, func(a, b int) bool { return available[a].Created.Before(available[b].Created) })
+
+	j := available[0]
+	j.Status, j.WorkerID, j.LeaseUntil, j.Updated = model.JobLeased, workerID, now.Add(ttl), now
+	j.Events = nil // Published again by the new worker
+	j.Attempts++
+	leased := *j
+	return &leased, nil
+}
+
+// held returns the job while the worker holds its lease; the caller holds the lock
+func (q *MemoryQueue) held(jobID, workerID string) (*model.StepJob, error) {
+	j, ok := q.jobs[jobID]
+	if !ok || j.Status != model.JobLeased || j.WorkerID != workerID || j.LeaseUntil.Before(time.Now()) {
+		return nil, ErrLeaseLost
+	}
+	return j, nil
+}
+
+func (q *MemoryQueue) RenewLease(jobID, workerID string, ttl time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.held(jobID, workerID)
+	if err != nil {
+		return err
+	}
+	j.Updated = time.Now().UTC()
+	j.LeaseUntil = j.Updated.Add(ttl)
+	return nil
+}
+
+func (q *MemoryQueue) ReportJobEvents(jobID, workerID string, events json.RawMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.held(jobID, workerID)
+	if err != nil {
+		return err
+	}
+	j.Events, j.Updated = events, time.Now().UTC()
+	return nil
+}
+
+func (q *MemoryQueue) FinishJob(jobID, workerID string, events json.RawMessage, errMsg string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.held(jobID, workerID)
+	if err != nil {
+		return err
+	}
+	j.Status, j.Events, j.Error, j.Updated = model.JobDone, events, errMsg, time.Now().UTC()
+	return nil
+}
+
+func (q *MemoryQueue) GetJob(jobID string) (model.StepJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[jobID]
+	if !ok {
+		return model.StepJob{}, fmt.Errorf("job %s not found", jobID)
+	}
+	return *j, nil
+}
+
+func (q *MemoryQueue) FindJob(planID string, stepID int) (*model.StepJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var latest *model.StepJob
+	for _, j := range q.jobs {
+		if j.PlanID == planID && j.Step.ID == stepID && (latest == nil || j.Created.After(latest.Created)) {
+			latest = j
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	found := *latest
+	return &found, nil
+}
+
+func (q *MemoryQueue) RemoveJob(jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.jobs, jobID)
+	return nil
+}
+
+// --- SQLStore: step_jobs table, shared by the server and druppie worker processes ---
+
+// jobColumns are read by scanJob, in this order
+const jobColumns = `id, plan_id, status, attempts, worker_id, lease_until, created_at, updated_at, step, events, error`
+
+func (s *SQLStore) EnqueueJob(job model.StepJob) (string, error) {
+	if job.ID == "" {
+		job.ID = newJobID(job)
+	}
+	step, err := json.Marshal(job.Step)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job step: %w", err)
+	}
+	now := time.Now().UnixNano()
+	_, err = s.exec(`INSERT INTO step_jobs (id, plan_id, step_id, status, attempts, worker_id, lease_until, created_at, updated_at, step, events, error) VALUES (?, ?, ?, ?, 0, '', 0, ?, ?, ?, '', '')`,
+		job.ID, job.PlanID, job.Step.ID, model.JobQueued, now, now, string(step))
+	if err != nil {
+		return "", fmt.Errorf("failed to queue job: %w", err)
+	}
+	return job.ID, nil
+}
+
+// LeaseJob picks the oldest available job and claims it with a conditional update, so two
+// workers racing for the same job cannot both get it
+func (s *SQLStore) LeaseJob(workerID string, ttl time.Duration) (*model.StepJob, error) {
+	now := time.Now().UnixNano()
+	// Jobs whose workers kept dying are given up
+	if _, err := s.exec(`UPDATE step_jobs SET status = ?, error = ?, updated_at = ? WHERE status = ? AND lease_until < ? AND attempts >= ?`,
+		model.JobFailed, expiredJobError(MaxJobAttempts), now, model.JobLeased, now, MaxJobAttempts); err != nil {
+		return nil, fmt.Errorf("failed to expire jobs: %w", err)
+	}
+
+	const available = `(status = ? OR (status = ? AND lease_until < ?))`
+	for attempt := 0; attempt < 5; attempt++ {
+		var id string
+		err := s.db.QueryRow(s.rebind(`SELECT id FROM step_jobs WHERE `+available+` ORDER BY created_at LIMIT 1`),
+			model.JobQueued, model.JobLeased, now).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queue: %w", err)
+		}
+		res, err := s.exec(`UPDATE step_jobs SET status = ?, worker_id = ?, lease_until = ?, attempts = attempts + 1, updated_at = ?, events = '' WHERE id = ? AND `+available,
+			model.JobLeased, workerID, now+int64(ttl), now, id, model.JobQueued, model.JobLeased, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lease job: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			job, err := s.GetJob(id)
+			if err != nil {
+				return nil, err
+			}
+			return &job, nil
+		}
+		// Another worker was faster, take the next one
+	}
+	return nil, nil
+}
+
+// updateHeld runs an update on a job the worker holds, ErrLeaseLost when no row matched
+func (s *SQLStore) updateHeld(set string, jobID, workerID string, args ...interface{}) error {
+	args = append(args, jobID, workerID, model.JobLeased, time.Now().UnixNano())
+	res, err := s.exec(`UPDATE step_jobs SET `+set+` WHERE id = ? AND worker_id = ? AND status = ? AND lease_until >= ?`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *SQLStore) RenewLease(jobID, workerID string, ttl time.Duration) error {
+	now := time.Now().UnixNano()
+	return s.updateHeld(`lease_until = ?, updated_at = ?`, jobID, workerID, now+int64(ttl), now)
+}
+
+func (s *SQLStore) ReportJobEvents(jobID, workerID string, events json.RawMessage) error {
+	return s.updateHeld(`events = ?, updated_at = ?`, jobID, workerID, string(events), time.Now().UnixNano())
+}
+
+func (s *SQLStore) FinishJob(jobID, workerID string, events json.RawMessage, errMsg string) error {
+	return s.updateHeld(`status = ?, events = ?, error = ?, updated_at = ?`, jobID, workerID,
+		model.JobDone, string(events), errMsg, time.Now().UnixNano())
+}
+
+func (s *SQLStore) GetJob(jobID string) (model.StepJob, error) {
+	job, err := scanJob(s.db.QueryRow(s.rebind(`SELECT `+jobColumns+` FROM step_jobs WHERE id = ?`), jobID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.StepJob{}, fmt.Errorf("job %s not found", jobID)
+	}
+	return job, err
+}
+
+func (s *SQLStore) FindJob(planID string, stepID int) (*model.StepJob, error) {
+	job, err := scanJob(s.db.QueryRow(s.rebind(`SELECT `+jobColumns+` FROM step_jobs WHERE plan_id = ? AND step_id = ? ORDER BY created_at DESC LIMIT 1`), planID, stepID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *SQLStore) RemoveJob(jobID string) error {
+	if _, err := s.exec(`DELETE FROM step_jobs WHERE id = ?`, jobID); err != nil {
+		return fmt.Errorf("failed to remove job: %w", err)
+	}
+	return nil
+}
+
+func scanJob(row *sql.Row) (model.StepJob, error) {
+	var job model.StepJob
+	var lease, created, updated int64
+	var step, events string
+	err := row.Scan(&job.ID, &job.PlanID, &job.Status, &job.Attempts, &job.WorkerID, &lease, &created, &updated, &step, &events, &job.Error)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, err
+		}
+		return job, fmt.Errorf("failed to read job: %w", err)
+	}
+	if err := json.Unmarshal([]byte(step), &job.Step); err != nil {
+		return job, fmt.Errorf("corrupt job step: %w", err)
+	}
+	if events != "" {
+		job.Events = json.RawMessage(events)
+	}
+	if lease > 0 {
+		job.LeaseUntil = time.Unix(0, lease).UTC()
+	}
+	job.Created = time.Unix(0, created).UTC()
+	job.Updated = time.Unix(0, updated).UTC()
+	return job, nil
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+)
+
+// RevisionStore keeps every saved version of a plan. SavePlan records a revision whenever
+// the plan differs from the previous one; revisions go away with the plan.
+type RevisionStore interface {
+	// ListRevisions returns the revisions of a plan, oldest first, without the plan itself
+	ListRevisions(planID string) ([]model.PlanRevision, error)
+	GetRevision(planID string, number int) (model.PlanRevision, error)
+}
+
+// planHash returns the SHA-256 of the compact JSON of the plan, leaving out the save counter
+func planHash(plan model.ExecutionPlan) (string, error) {
+	plan.Version = 0
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func newRevision(plan model.ExecutionPlan, number int, hash string) model.PlanRevision {
+	return model.PlanRevision{
+		PlanID: plan.ID,
+		Number: number,
+		Time:   time.Now().UTC(),
+		Status: plan.Status,
+		Steps:  len(plan.Steps),
+		Hash:   hash,
+		Plan:   &plan,
+	}
+}
+
+// --- FileStore: .druppie/plans/<id>/revisions.jsonl ---
+
+func (s *FileStore) revisionsPath(planID string) string {
+	return filepath.Join(s.baseDir, "plans", planID, "revisions.jsonl")
+}
+
+// readRevisions reads all revisions of a plan; the caller holds the lock
+func (s *FileStore) readRevisions(planID string) ([]model.PlanRevision, error) {
+	f, err := os.Open(s.revisionsPath(planID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open revisions: %w", err)
+	}
+	defer f.Close()
+
+	var revisions []model.PlanRevision
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rev model.PlanRevision
+		if err := json.Unmarshal(scanner.Bytes(), &rev); err != nil {
+			return nil, fmt.Errorf("failed to read revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, scanner.Err()
+}
+
+// revisionHead is the number and hash of the newest revision of a plan
+type revisionHead struct {
+	number int
+	hash   string
+}
+
+// lastRevision returns the newest revision, cached after the first read; the caller holds the lock
+func (s *FileStore) lastRevision(planID string) (revisionHead, error) {
+	if head, ok := s.revisionHeads[planID]; ok {
+		return head, nil
+	}
+	revisions, err := s.readRevisions(planID)
+	if err != nil || len(revisions) == 0 {
+		return revisionHead{}, err
+	}
+	last := revisions[len(revisions)-1]
+	return revisionHead{number: last.Number, hash: last.Hash}, nil
+}
+
+// appendRevision records the plan as a new revision unless it equals the newest one;
+// the caller holds the lock
+func (s *FileStore) appendRevision(plan model.ExecutionPlan) error {
+	hash, err := planHash(plan)
+	if err != nil {
+		return err
+	}
+	head, err := s.lastRevision(plan.ID)
+	if err != nil {
+		return err
+	}
+	if hash == head.hash {
+		return nil
+	}
+	rev := newRevision(plan, head.number+1, hash)
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+	f, err := os.OpenFile(s.revisionsPath(plan.ID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open revisions: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+	if s.revisionHeads == nil {
+		s.revisionHeads = make(map[string]revisionHead)
+	}
+	s.revisionHeads[plan.ID] = revisionHead{number: rev.Number, hash: hash}
+	return nil
+}
+
+func (s *FileStore) ListRevisions(planID string) ([]model.PlanRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions, err := s.readRevisions(planID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		revisions[i].Plan = nil
+	}
+	return revisions, nil
+}
+
+func (s *FileStore) GetRevision(planID string, number int) (model.PlanRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions, err := s.readRevisions(planID)
+	if err != nil {
+		return model.PlanRevision{}, err
+	}
+	for _, rev := range revisions {
+		if rev.Number == number {
+			return rev, nil
+		}
+	}
+	return model.PlanRevision{}, fmt.Errorf("revision %d of plan %s not found", number, planID)
+}
+
+// --- SQLStore: plan_revisions table ---
+
+// appendRevision records the plan as a new revision within the save transaction
+func (s *SQLStore) appendRevision(tx *sql.Tx, plan model.ExecutionPlan) error {
+	hash, err := planHash(plan)
+	if err != nil {
+		return err
+	}
+	var number int
+	var lastHash string
+	err = tx.QueryRow(s.rebind(`SELECT number, hash FROM plan_revisions WHERE plan_id = ? ORDER BY number DESC LIMIT 1`), plan.ID).Scan(&number, &lastHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read revisions: %w", err)
+	}
+	if hash == lastHash {
+		return nil
+	}
+
+	rev := newRevision(plan, number+1, hash)
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+	_, err = tx.Exec(s.rebind(`INSERT INTO plan_revisions (plan_id, number, status, steps, hash, created_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		plan.ID, rev.Number, rev.Status, rev.Steps, rev.Hash, rev.Time.UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListRevisions(planID string) ([]model.PlanRevision, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT number, status, steps, hash, created_at FROM plan_revisions WHERE plan_id = ? ORDER BY number`), planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []model.PlanRevision
+	for rows.Next() {
+		rev := model.PlanRevision{PlanID: planID}
+		var created int64
+		if err := rows.Scan(&rev.Number, &rev.Status, &rev.Steps, &rev.Hash, &created); err != nil {
+			return nil, fmt.Errorf("failed to read revision: %w", err)
+		}
+		rev.Time = time.Unix(0, created).UTC()
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *SQLStore) GetRevision(planID string, number int) (model.PlanRevision, error) {
+	rev := model.PlanRevision{PlanID: planID, Number: number}
+	var created int64
+	var data string
+	err := s.db.QueryRow(s.rebind(`SELECT status, steps, hash, created_at, data FROM plan_revisions WHERE plan_id = ? AND number = ?`), planID, number).
+		Scan(&rev.Status, &rev.Steps, &rev.Hash, &created, &data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.PlanRevision{}, fmt.Errorf("revision %d of plan %s not found", number, planID)
+		}
+		return model.PlanRevision{}, fmt.Errorf("failed to read revision: %w", err)
+	}
+	var plan model.ExecutionPlan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return model.PlanRevision{}, fmt.Errorf("failed to unmarshal revision: %w", err)
+	}
+	rev.Time = time.Unix(0, created).UTC()
+	rev.Plan = &plan
+	return rev, nil
+}
@@ -0,0 +1,580 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	_ "modernc.org/sqlite" // Pure Go SQLite driver (registered as "sqlite")
+)
+
+// SQLStore implements Store on top of a SQL database.
+// SQLite is embedded; the schema only uses portable types so the same tables work on Postgres
+// when a Postgres driver is registered under the configured driver name.
+type SQLStore struct {
+	db      *sql.DB
+	driver  string
+	baseDir string // Root persistent dir, still used for plan workspaces (src, files, builds)
+}
+
+// NewSQLStore opens (and migrates) the database.
+// driver is the database/sql driver name ("sqlite", "postgres", "pgx"), dsn the data source.
+// For sqlite an empty dsn defaults to <baseDir>/druppie.db.
+func NewSQLStore(baseDir, driver, dsn string) (*SQLStore, error) {
+	if driver == "" {
+		driver = "sqlite"
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "plans"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	if driver == "sqlite" {
+		if dsn == "" {
+			dsn = filepath.Join(baseDir, "druppie.db")
+		}
+		if !strings.Contains(dsn, "_pragma") {
+			sep := "?"
+			if strings.Contains(dsn, "?") {
+				sep = "&"
+			}
+			dsn += sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+		}
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", driver, err)
+	}
+	if driver == "sqlite" {
+		// SQLite allows a single writer; serialize access instead of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s database: %w", driver, err)
+	}
+
+	s := &SQLStore{db: db, driver: driver, baseDir: baseDir}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the database handle
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) isPostgres() bool {
+	return s.driver == "postgres" || s.driver == "pgx"
+}
+
+// rebind converts '?' placeholders to the '$n' form required by Postgres drivers
+func (s *SQLStore) rebind(query string) string {
+	if !s.isPostgres() {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (s *SQLStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.rebind(query), args...)
+}
+
+func (s *SQLStore) migrate() error {
+	// Auto-increment syntax is the only dialect specific part of the schema
+	serial := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if s.isPostgres() {
+		serial = "BIGSERIAL PRIMARY KEY"
+	}
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS plans (
+			id          VARCHAR(128) PRIMARY KEY,
+			creator_id  VARCHAR(255),
+			status      VARCHAR(64),
+			prompt      TEXT,
+			total_cost  DOUBLE PRECISION,
+			created_at  BIGINT NOT NULL,
+			updated_at  BIGINT NOT NULL,
+			data        TEXT NOT NULL,
+			version     BIGINT NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_plans_status ON plans(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_plans_updated ON plans(updated_at)`,
+		`CREATE TABLE IF NOT EXISTS plan_steps (
+			plan_id        VARCHAR(128) NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+			position       INTEGER NOT NULL,
+			step_id        INTEGER NOT NULL,
+			agent_id       VARCHAR(255),
+			action         VARCHAR(255),
+			status         VARCHAR(64),
+			assigned_group VARCHAR(255),
+			data           TEXT NOT NULL,
+			PRIMARY KEY (plan_id, position)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_plan_steps_status ON plan_steps(status)`,
+		`CREATE TABLE IF NOT EXISTS plan_logs (
+			id         ` + serial + `,
+			plan_id    VARCHAR(128) NOT NULL,
+			kind       VARCHAR(16) NOT NULL,
+			tag        VARCHAR(255),
+			input      TEXT,
+			output     TEXT,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_plan_logs_plan ON plan_logs(plan_id, id)`,
+		`CREATE TABLE IF NOT EXISTS plan_memory (
+			plan_id    VARCHAR(128) PRIMARY KEY,
+			data       TEXT NOT NULL,
+			updated_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS settings (
+			name       VARCHAR(64) PRIMARY KEY,
+			data       TEXT NOT NULL,
+			updated_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS plan_revisions (
+			plan_id    VARCHAR(128) NOT NULL,
+			number     INTEGER NOT NULL,
+			status     VARCHAR(64),
+			steps      INTEGER NOT NULL,
+			hash       VARCHAR(64) NOT NULL,
+			created_at BIGINT NOT NULL,
+			data       TEXT NOT NULL,
+			PRIMARY KEY (plan_id, number)
+		)`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			chain      VARCHAR(128) NOT NULL,
+			seq        BIGINT NOT NULL,
+			type       VARCHAR(64) NOT NULL,
+			actor      VARCHAR(255),
+			created_at BIGINT NOT NULL,
+			hash       VARCHAR(128) NOT NULL,
+			data       TEXT NOT NULL,
+			PRIMARY KEY (chain, seq)
+		)`,
+		`CREATE TABLE IF NOT EXISTS step_jobs (
+			id          VARCHAR(255) PRIMARY KEY,
+			plan_id     VARCHAR(128) NOT NULL,
+			step_id     INTEGER NOT NULL,
+			status      VARCHAR(16) NOT NULL,
+			attempts    INTEGER NOT NULL,
+			worker_id   VARCHAR(255) NOT NULL,
+			lease_until BIGINT NOT NULL,
+			created_at  BIGINT NOT NULL,
+			updated_at  BIGINT NOT NULL,
+			step        TEXT NOT NULL,
+			events      TEXT NOT NULL,
+			error       TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_step_jobs_status ON step_jobs(status, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_step_jobs_step ON step_jobs(plan_id, step_id)`,
+	}
+	for _, stmt := range schema {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+	}
+	// Databases created before optimistic concurrency lack the version column
+	if rows, err := s.db.Query(`SELECT version FROM plans WHERE 1 = 0`); err == nil {
+		rows.Close()
+	} else if _, err := s.db.Exec(`ALTER TABLE plans ADD COLUMN version BIGINT NOT NULL DEFAULT 0`); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) SavePlan(plan model.ExecutionPlan) error {
+	_, err := s.savePlan(plan, -1)
+	return err
+}
+
+func (s *SQLStore) CompareAndSwapPlan(plan *model.ExecutionPlan) error {
+	version, err := s.savePlan(*plan, plan.Version)
+	if err != nil {
+		return err
+	}
+	plan.Version = version
+	return nil
+}
+
+// savePlan writes the plan with its steps and revision in one transaction and returns the
+// new version. With expected >= 0 the plan is only written when its stored version equals it.
+func (s *SQLStore) savePlan(plan model.ExecutionPlan, expected int64) (int64, error) {
+	full := plan
+	steps := plan.Steps
+	plan.Steps = nil
+	plan.Version = 0 // The version column is authoritative
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	switch {
+	case expected < 0:
+		_, err = tx.Exec(s.rebind(`INSERT INTO plans (id, creator_id, status, prompt, total_cost, created_at, updated_at, data, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT (id) DO UPDATE SET creator_id = excluded.creator_id, status = excluded.status, prompt = excluded.prompt,
+				total_cost = excluded.total_cost, updated_at = excluded.updated_at, data = excluded.data, version = plans.version + 1`),
+			plan.ID, plan.CreatorID, plan.Status, plan.Intent.Prompt, plan.TotalCost, now, now, string(data))
+	case expected == 0:
+		var res sql.Result
+		res, err = tx.Exec(s.rebind(`INSERT INTO plans (id, creator_id, status, prompt, total_cost, created_at, updated_at, data, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1) ON CONFLICT (id) DO NOTHING`),
+			plan.ID, plan.CreatorID, plan.Status, plan.Intent.Prompt, plan.TotalCost, now, now, string(data))
+		if err == nil {
+			err = conflictUnlessChanged(res)
+		}
+	default:
+		var res sql.Result
+		res, err = tx.Exec(s.rebind(`UPDATE plans SET creator_id = ?, status = ?, prompt = ?, total_cost = ?, updated_at = ?, data = ?, version = version + 1
+			WHERE id = ? AND version = ?`),
+			plan.CreatorID, plan.Status, plan.Intent.Prompt, plan.TotalCost, now, string(data), plan.ID, expected)
+		if err == nil {
+			err = conflictUnlessChanged(res)
+		}
+	}
+	if errors.Is(err, ErrConflict) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write plan: %w", err)
+	}
+	var version int64
+	if err := tx.QueryRow(s.rebind(`SELECT version FROM plans WHERE id = ?`), plan.ID).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read plan version: %w", err)
+	}
+
+	if _, err := tx.Exec(s.rebind(`DELETE FROM plan_steps WHERE plan_id = ?`), plan.ID); err != nil {
+		return 0, fmt.Errorf("failed to clear plan steps: %w", err)
+	}
+	for i, step := range steps {
+		stepData, err := json.Marshal(step)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal step %d: %w", step.ID, err)
+		}
+		_, err = tx.Exec(s.rebind(`INSERT INTO plan_steps (plan_id, position, step_id, agent_id, action, status, assigned_group, data)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			plan.ID, i, step.ID, step.AgentID, step.Action, step.Status, step.AssignedGroup, string(stepData))
+		if err != nil {
+			return 0, fmt.Errorf("failed to write step %d: %w", step.ID, err)
+		}
+	}
+	full.Version = version
+	if err := s.appendRevision(tx, full); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit plan: %w", err)
+	}
+	return version, nil
+}
+
+// conflictUnlessChanged turns a conditional write that matched no row into ErrConflict
+func conflictUnlessChanged(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *SQLStore) GetPlan(id string) (model.ExecutionPlan, error) {
+	var data string
+	var version int64
+	err := s.db.QueryRow(s.rebind(`SELECT data, version FROM plans WHERE id = ?`), id).Scan(&data, &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ExecutionPlan{}, fmt.Errorf("plan not found: %s", id)
+		}
+		return model.ExecutionPlan{}, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan model.ExecutionPlan
+	if err := json.Unmarshal([]byte(data), &plan); err != nil {
+		return model.ExecutionPlan{}, fmt.Errorf("failed to unmarshal plan: %w", err)
+	}
+	plan.Version = version
+
+	steps, err := s.loadSteps(`WHERE plan_id = ?`, id)
+	if err != nil {
+		return model.ExecutionPlan{}, err
+	}
+	plan.Steps = steps[id]
+	if plan.Steps == nil {
+		plan.Steps = []model.Step{}
+	}
+	return plan, nil
+}
+
+// loadSteps reads steps (optionally filtered) grouped by plan ID in their stored order
+func (s *SQLStore) loadSteps(where string, args ...interface{}) (map[string][]model.Step, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT plan_id, data FROM plan_steps `+where+` ORDER BY plan_id, position`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan steps: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]model.Step)
+	for rows.Next() {
+		var planID, data string
+		if err := rows.Scan(&planID, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan plan step: %w", err)
+		}
+		var step model.Step
+		if err := json.Unmarshal([]byte(data), &step); err != nil {
+			fmt.Printf("[Store] Error unmarshaling step of plan %s: %v\n", planID, err)
+			continue
+		}
+		result[planID] = append(result[planID], step)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLStore) ListPlans() ([]model.ExecutionPlan, error) {
+	rows, err := s.db.Query(`SELECT id, data, version FROM plans ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []model.ExecutionPlan
+	for rows.Next() {
+		var id, data string
+		var version int64
+		if err := rows.Scan(&id, &data, &version); err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		var p model.ExecutionPlan
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			fmt.Printf("[Store] Error unmarshaling plan %s: %v\n", id, err)
+			continue
+		}
+		p.Version = version
+		plans = append(plans, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	rows.Close()
+
+	steps, err := s.loadSteps("")
+	if err != nil {
+		return nil, err
+	}
+	for i := range plans {
+		plans[i].Steps = steps[plans[i].ID]
+		if plans[i].Steps == nil {
+			plans[i].Steps = []model.Step{}
+		}
+	}
+	return plans, nil
+}
+
+func (s *SQLStore) DeletePlan(id string) error {
+	if err := s.deletePlanRows(id); err != nil {
+		return err
+	}
+
+	// Remove the workspace (src, files, builds) that lives next to the database
+	planDir := filepath.Join(s.baseDir, "plans", id)
+	if err := os.RemoveAll(planDir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete plan directory: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) deletePlanRows(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"plan_steps", "plan_logs", "plan_memory", "plan_revisions", "step_jobs"} {
+		if _, err := tx.Exec(s.rebind(`DELETE FROM `+table+` WHERE plan_id = ?`), id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(s.rebind(`DELETE FROM plans WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete plan: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) CleanupOldPlans(days int) (int, error) {
+	cutoff := time.Now().AddDate(0, 0, -days)
+	rows, err := s.db.Query(s.rebind(`SELECT id, updated_at FROM plans WHERE updated_at < ?`), cutoff.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query old plans: %w", err)
+	}
+	type oldPlan struct {
+		id      string
+		updated int64
+	}
+	var old []oldPlan
+	for rows.Next() {
+		var p oldPlan
+		if err := rows.Scan(&p.id, &p.updated); err == nil {
+			old = append(old, p)
+		}
+	}
+	rows.Close()
+
+	count := 0
+	for _, p := range old {
+		if err := s.DeletePlan(p.id); err != nil {
+			fmt.Printf("[Store] Failed to delete plan %s: %v\n", p.id, err)
+			continue
+		}
+		count++
+		fmt.Printf("[Store] Deleted old plan: %s (Age: %s)\n", p.id, time.Since(time.Unix(0, p.updated)))
+	}
+	return count, nil
+}
+
+func (s *SQLStore) LogInteraction(planID string, tag string, input string, output string) error {
+	if planID == "" {
+		return nil
+	}
+	_, err := s.exec(`INSERT INTO plan_logs (plan_id, kind, tag, input, output, created_at) VALUES (?, 'interaction', ?, ?, ?, ?)`,
+		planID, tag, input, output, time.Now().UnixNano())
+	return err
+}
+
+func (s *SQLStore) AppendRawLog(planID string, message string) error {
+	if planID == "" {
+		return fmt.Errorf("planID is empty")
+	}
+	_, err := s.exec(`INSERT INTO plan_logs (plan_id, kind, output, created_at) VALUES (?, 'raw', ?, ?)`,
+		planID, message, time.Now().UnixNano())
+	return err
+}
+
+// GetLogs renders the log rows in the same text layout as the execution.log file
+func (s *SQLStore) GetLogs(id string) (string, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT kind, tag, input, output, created_at FROM plan_logs WHERE plan_id = ? ORDER BY id`), id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs: %w", err)
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	found := false
+	for rows.Next() {
+		var kind string
+		var tag, input, output sql.NullString
+		var created int64
+		if err := rows.Scan(&kind, &tag, &input, &output, &created); err != nil {
+			return "", fmt.Errorf("failed to scan log: %w", err)
+		}
+		found = true
+		if kind == "interaction" {
+			timestamp := time.Unix(0, created).Format(time.RFC3339)
+			sb.WriteString(fmt.Sprintf("--- [%s] %s ---\nINPUT:\n%s\nOUTPUT:\n%s\n\n", tag.String, timestamp, input.String, output.String))
+		} else {
+			sb.WriteString(output.String + "\n")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("logs not found: %s", id)
+	}
+	return sb.String(), nil
+}
+
+func (s *SQLStore) saveSetting(name string, data []byte) error {
+	_, err := s.exec(`INSERT INTO settings (name, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		name, string(data), time.Now().UnixNano())
+	return err
+}
+
+func (s *SQLStore) loadSetting(name string) ([]byte, error) {
+	var data string
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM settings WHERE name = ?`), name).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, os.ErrNotExist // Same semantics as the file store: caller handles not found
+		}
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+func (s *SQLStore) SaveConfig(data []byte) error {
+	if err := s.saveSetting("config", data); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LoadConfig() ([]byte, error) {
+	return s.loadSetting("config")
+}
+
+func (s *SQLStore) SaveMCPServers(data []byte) error {
+	if err := s.saveSetting("mcp_servers", data); err != nil {
+		return fmt.Errorf("failed to write mcp servers: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LoadMCPServers() ([]byte, error) {
+	return s.loadSetting("mcp_servers")
+}
+
+func (s *SQLStore) SaveMemory(planID string, data []byte) error {
+	_, err := s.exec(`INSERT INTO plan_memory (plan_id, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (plan_id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		planID, string(data), time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LoadMemory(planID string) ([]byte, error) {
+	var data string
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM plan_memory WHERE plan_id = ?`), planID).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return []byte(data), nil
+}
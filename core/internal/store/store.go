@@ -16,6 +16,9 @@ import (
 type Store interface {
 	// Plans
 	SavePlan(plan model.ExecutionPlan) error
+	// CompareAndSwapPlan saves the plan only when the stored version still equals plan.Version
+	// (0 for a new plan) and then increments plan.Version. It returns ErrConflict otherwise.
+	CompareAndSwapPlan(plan *model.ExecutionPlan) error
 	GetPlan(id string) (model.ExecutionPlan, error)
 	ListPlans() ([]model.ExecutionPlan, error)
 	DeletePlan(id string) error
@@ -42,8 +45,9 @@ type Store interface {
 // FileStore implements Store using local file system.
 // baseDir should be the root persistent dir (e.g. .druppie)
 type FileStore struct {
-	baseDir string
-	mu      sync.RWMutex
+	baseDir       string
+	mu            sync.RWMutex
+	revisionHeads map[string]revisionHead // planID -> newest revision
 }
 
 func NewFileStore(baseDir string) (*FileStore, error) {
@@ -63,6 +67,54 @@ func (s *FileStore) SavePlan(plan model.ExecutionPlan) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	version, err := s.storedVersion(plan.ID)
+	if err != nil {
+		return err
+	}
+	plan.Version = version + 1
+	return s.writePlan(plan)
+}
+
+func (s *FileStore) CompareAndSwapPlan(plan *model.ExecutionPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := s.storedVersion(plan.ID)
+	if err != nil {
+		return err
+	}
+	if version != plan.Version {
+		return ErrConflict
+	}
+	saved := *plan
+	saved.Version = version + 1
+	if err := s.writePlan(saved); err != nil {
+		return err
+	}
+	plan.Version = saved.Version
+	return nil
+}
+
+// storedVersion returns the version of the saved plan, 0 when there is none; the caller holds the lock
+func (s *FileStore) storedVersion(planID string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(s.baseDir, "plans", planID, "plan.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read plan file: %w", err)
+	}
+	var stored struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal plan: %w", err)
+	}
+	return stored.Version, nil
+}
+
+// writePlan writes plan.json and records the revision; the caller holds the lock
+func (s *FileStore) writePlan(plan model.ExecutionPlan) error {
 	data, err := json.MarshalIndent(plan, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal plan: %w", err)
@@ -78,6 +130,9 @@ func (s *FileStore) SavePlan(plan model.ExecutionPlan) error {
 		return fmt.Errorf("failed to write plan file: %w", err)
 	}
 
+	if err := s.appendRevision(plan); err != nil {
+		return fmt.Errorf("failed to record plan revision: %w", err)
+	}
 	return nil
 }
 
@@ -147,6 +202,7 @@ func (s *FileStore) DeletePlan(id string) error {
 	if err := os.RemoveAll(planDir); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete plan directory: %w", err)
 	}
+	delete(s.revisionHeads, id)
 
 	return nil
 }
@@ -173,6 +229,7 @@ func (s *FileStore) CleanupOldPlans(days int) (int, error) {
 				// Delete dir directly since we have lock
 				dirPath := filepath.Join(plansDir, id)
 				if err := os.RemoveAll(dirPath); err == nil {
+					delete(s.revisionHeads, id)
 					count++
 					fmt.Printf("[Store] Deleted old plan: %s (Age: %s)\n", id, time.Since(info.ModTime()))
 				} else {
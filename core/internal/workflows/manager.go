@@ -24,6 +24,16 @@ type WorkflowContext struct {
 	AppendStep        func(step model.Step) int // Callback to add a executed step to the plan log
 	FindCompletedStep func(action string, paramKey string, paramValue interface{}) *model.Step
 	GetAgent          func(id string) (model.AgentDefinition, error)
+	Stream            llm.StreamHandler                     // Optional: receives the partial output of CallLLM as it arrives
+	AcquireSlots      func(step model.Step) (func(), error) // Optional: waits for a free execution slot, returns its release
+}
+
+// acquireSlots waits until the step may run under the concurrency limits of the context
+func (wc *WorkflowContext) acquireSlots(step model.Step) (func(), error) {
+	if wc.AcquireSlots == nil {
+		return func() {}, nil
+	}
+	return wc.AcquireSlots(step)
 }
 
 // Workflow defines the interface for a hard-coded process
@@ -58,14 +68,37 @@ func (m *Manager) GetWorkflow(name string) (Workflow, bool) {
 // It does NOT update the global plan usage automatically; the caller must attach it to a Step or call UpdateTokenUsage.
 // opts[0] (optional) specifies the provider name (e.g. "gemini", "ollama").
 func (wc *WorkflowContext) CallLLM(prompt string, systemPrompt string, opts ...string) (string, *model.TokenUsage, error) {
+	return wc.callLLM(wc.Ctx, prompt, systemPrompt, opts...)
+}
+
+// CallStructured is CallLLM for a JSON answer matching the schema, decoded into out (see
+// llm.GenerateStructured). The usage covers the repair rounds.
+func (wc *WorkflowContext) CallStructured(prompt string, systemPrompt string, schema map[string]interface{}, out interface{}, opts ...string) (string, *model.TokenUsage, error) {
+	generate := llm.GenerateFunc(func(ctx context.Context, prompt string, systemPrompt string) (string, model.TokenUsage, error) {
+		resp, usage, err := wc.callLLM(ctx, prompt, systemPrompt, opts...)
+		if usage == nil {
+			return resp, model.TokenUsage{}, err
+		}
+		return resp, *usage, err
+	})
+	resp, usage, err := llm.GenerateStructured(wc.Ctx, generate, prompt, systemPrompt, schema, out)
+	return resp, &usage, err
+}
+
+func (wc *WorkflowContext) callLLM(ctx context.Context, prompt string, systemPrompt string, opts ...string) (string, *model.TokenUsage, error) {
 	providerName := ""
 	if len(opts) > 0 {
 		providerName = opts[0]
 	}
 
+	if wc.Stream != nil {
+		ctx = llm.WithStream(ctx, wc.Stream)
+		defer wc.Stream("\n") // Ends the partial output of this call
+	}
+
 	if providerName != "" {
 		if mgr, ok := wc.LLM.(*llm.Manager); ok {
-			resp, usage, err := mgr.GenerateWithProvider(wc.Ctx, providerName, prompt, systemPrompt)
+			resp, usage, err := mgr.GenerateWithProvider(ctx, providerName, prompt, systemPrompt)
 			if wc.Store != nil {
 				_ = wc.Store.LogInteraction(wc.PlanID, "Workflow ("+systemPrompt+") ["+providerName+"]",
 					fmt.Sprintf("--- PROMPT ---\n%s\n--- END PROMPT ---", prompt),
@@ -79,7 +112,7 @@ func (wc *WorkflowContext) CallLLM(prompt string, systemPrompt string, opts ...s
 		return "", nil, fmt.Errorf("provider selection '%s' failed: underlying LLM is not a Manager", providerName)
 	}
 
-	resp, usage, err := wc.LLM.Generate(wc.Ctx, prompt, systemPrompt)
+	resp, usage, err := wc.LLM.Generate(ctx, prompt, systemPrompt)
 	if wc.Store != nil {
 		_ = wc.Store.LogInteraction(wc.PlanID, "Workflow ("+systemPrompt+")",
 			fmt.Sprintf("--- PROMPT ---\n%s\n--- END PROMPT ---", prompt),
@@ -1,11 +1,11 @@
 package workflows
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 
+	"github.com/sjhoeksma/druppie/core/internal/executor"
+	"github.com/sjhoeksma/druppie/core/internal/llm"
 	"github.com/sjhoeksma/druppie/core/internal/model"
 )
 
@@ -36,7 +36,7 @@ func (w *SkillExecutionWorkflow) Run(wc *WorkflowContext, initialPrompt string)
 		Usage:   &usage,
 	})
 
-	executor, err := wc.Dispatcher.GetExecutor(action)
+	exec, err := wc.Dispatcher.GetExecutor(action)
 	if err != nil {
 		wc.AppendStep(model.Step{
 			ID:      stepID,
@@ -49,8 +49,14 @@ func (w *SkillExecutionWorkflow) Run(wc *WorkflowContext, initialPrompt string)
 		return err
 	}
 
-	execChan := make(chan string, 100)
-	var capturedResult string
+	release, err := wc.acquireSlots(model.Step{ID: stepID, AgentID: "skill_executor", Action: action})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	execChan := make(chan executor.Event, 100)
+	var result executor.StepResult
 
 	go func() {
 		defer close(execChan)
@@ -60,51 +66,28 @@ func (w *SkillExecutionWorkflow) Run(wc *WorkflowContext, initialPrompt string)
 		}
 		params["plan_id"] = wc.PlanID
 
-		_ = executor.Execute(wc.Ctx, model.Step{
+		_ = exec.Execute(wc.Ctx, model.Step{
 			Action:  action,
 			Params:  params,
 			AgentID: "skill_executor",
 		}, execChan)
 	}()
 
-	for msg := range execChan {
-		// Log normal messages
-		wc.OutputChan <- fmt.Sprintf("  %s", msg)
-
-		// Capture structured results
-		if strings.HasPrefix(msg, "RESULT_TOKEN_USAGE=") {
-			parts := strings.Split(strings.TrimPrefix(msg, "RESULT_TOKEN_USAGE="), ",")
-			if len(parts) >= 3 {
-				// Parse
-				pt, _ := strconv.Atoi(parts[0])
-				ct, _ := strconv.Atoi(parts[1])
-				tt, _ := strconv.Atoi(parts[2])
-				cost := 0.0
-				if len(parts) >= 4 {
-					cost, _ = strconv.ParseFloat(parts[3], 64)
-				}
-
-				// Accumulate
-				usage.PromptTokens += pt
-				usage.CompletionTokens += ct
-				usage.TotalTokens += tt
-				usage.EstimatedCost += cost
-
-				// Update global
-				if wc.UpdateTokenUsage != nil {
-					wc.UpdateTokenUsage(model.TokenUsage{
-						PromptTokens:     pt,
-						CompletionTokens: ct,
-						TotalTokens:      tt,
-						EstimatedCost:    cost,
-					})
-				}
-			}
-		} else if strings.HasPrefix(msg, "RESULT_") {
-			if capturedResult != "" {
-				capturedResult += "\n"
+	for ev := range execChan {
+		if !result.Apply(ev) {
+			// Log normal messages
+			wc.OutputChan <- fmt.Sprintf("  %s", ev.Message)
+			continue
+		}
+
+		if ev.Type == executor.EventUsage && ev.Usage != nil {
+			// Accumulate
+			usage.Add(*ev.Usage)
+
+			// Update global
+			if wc.UpdateTokenUsage != nil {
+				wc.UpdateTokenUsage(*ev.Usage)
 			}
-			capturedResult += msg
 		}
 	}
 
@@ -116,13 +99,24 @@ func (w *SkillExecutionWorkflow) Run(wc *WorkflowContext, initialPrompt string)
 		Action:  action,
 		Status:  "completed",
 		Params:  params,
-		Result:  capturedResult,
+		Result:  result.Summary(),
+		Outputs: result.Outputs,
 		Usage:   &usage, // Ensure usage persists in final state
 	})
 
 	return nil
 }
 
+// skillIntentSchema is the answer of analyzeSkillIntent
+var skillIntentSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "action": {"type": "string"},
+    "params": {"type": "object"}
+  },
+  "required": ["action"]
+}`)
+
 func (w *SkillExecutionWorkflow) analyzeSkillIntent(wc *WorkflowContext, prompt string) (string, map[string]interface{}, model.TokenUsage, error) {
 	// Simple analysis: Ask LLM to extract action and params
 	// This makes it generic for any skill supported by the system
@@ -137,32 +131,22 @@ Output JSON: { "action": "action_name", "params": { "key": "value" } }`
 		}
 	}
 
-	resp, usage, err := wc.LLM.Generate(wc.Ctx, "Analyze Skill", sysPrompt+"\nRequest: "+prompt)
+	var raw struct {
+		Action string                 `json:"action"`
+		Params map[string]interface{} `json:"params"`
+	}
+	_, usage, err := llm.GenerateStructured(wc.Ctx, wc.LLM, "Analyze Skill", sysPrompt+"\nRequest: "+prompt, skillIntentSchema, &raw)
 	if wc.UpdateTokenUsage != nil {
 		wc.UpdateTokenUsage(usage)
 	}
 
 	if err != nil {
+		var invalid *llm.StructuredError
+		if errors.As(err, &invalid) {
+			return "", nil, usage, fmt.Errorf("failed to parse skill intent: %w", err)
+		}
 		return "", nil, usage, err
 	}
 
-	// Clean JSON
-	clean := strings.TrimSpace(resp)
-	if idx := strings.Index(clean, "{"); idx != -1 {
-		clean = clean[idx:]
-	}
-	if idx := strings.LastIndex(clean, "}"); idx != -1 {
-		clean = clean[:idx+1]
-	}
-
-	var raw struct {
-		Action string                 `json:"action"`
-		Params map[string]interface{} `json:"params"`
-	}
-
-	if err := json.Unmarshal([]byte(clean), &raw); err != nil {
-		return "", nil, usage, fmt.Errorf("failed to parse skill intent: %w", err)
-	}
-
 	return raw.Action, raw.Params, usage, nil
 }
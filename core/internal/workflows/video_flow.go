@@ -2,13 +2,14 @@ package workflows
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
-	"strconv"
-
+	"github.com/sjhoeksma/druppie/core/internal/executor"
+	"github.com/sjhoeksma/druppie/core/internal/llm"
 	"github.com/sjhoeksma/druppie/core/internal/model"
 )
 
@@ -41,6 +42,40 @@ type AVScript struct {
 	Scenes []Scene `json:"av_script"`
 }
 
+// intentSchema is the answer of refine_intent: a question or the refined project details
+var intentSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "needs_clarification": {"type": "boolean"},
+    "question": {"type": "string"},
+    "refined_prompt": {"type": "string"},
+    "language": {"type": "string"},
+    "target_audience": {"type": "string"}
+  },
+  "required": ["needs_clarification"]
+}`)
+
+// scriptSchema is the answer of draft_scenes, an AVScript
+var scriptSchema = llm.MustParseSchema(`{
+  "type": "object",
+  "properties": {
+    "av_script": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "scene_id": {"type": "integer"},
+          "audio_text": {"type": "string"},
+          "visual_prompt": {"type": "string"},
+          "duration": {"type": "integer"}
+        },
+        "required": ["scene_id", "audio_text", "visual_prompt", "duration"]
+      }
+    }
+  },
+  "required": ["av_script"]
+}`)
+
 func (w *VideoCreationWorkflow) Run(wc *WorkflowContext, initialPrompt string) error {
 	wc.OutputChan <- fmt.Sprintf("🎥 [VideoWorkflow] Starting Video Creation Workflow: %s", initialPrompt)
 
@@ -165,7 +200,8 @@ func (w *VideoCreationWorkflow) Run(wc *WorkflowContext, initialPrompt string) e
 		Action:  "content_merge",
 		Status:  "completed",
 		Params:  map[string]interface{}{"av_script": script.Scenes},
-		Result:  fmt.Sprintf("RESULT_VIDEO_FILE=%s", finalVideo),
+		Result:  fmt.Sprintf("VIDEO_FILE: %s", finalVideo),
+		Outputs: map[string]interface{}{"video_file": finalVideo},
 		Usage:   usage,
 	})
 
@@ -373,12 +409,16 @@ Output JSON: { "needs_clarification": true, "question": "..." } OR { "needs_clar
 			providerName = agent.Provider
 		}
 
-		resp, usagePtr, err := wc.CallLLM(sysPrompt+"\nUser Request: "+prompt, "Refine Intent", providerName)
+		var raw map[string]interface{}
+		_, usagePtr, err := wc.CallStructured(sysPrompt+"\nUser Request: "+prompt, "Refine Intent", intentSchema, &raw, providerName)
+		var structErr *llm.StructuredError
+		if errors.As(err, &structErr) {
+			// Go on without refined details, like a request that needs no clarification
+			wc.OutputChan <- fmt.Sprintf("⚠️ [Intent] Could not parse the analysis: %v", err)
+			err = nil
+		}
 		if usagePtr != nil {
-			cumulativeUsage.PromptTokens += usagePtr.PromptTokens
-			cumulativeUsage.CompletionTokens += usagePtr.CompletionTokens
-			cumulativeUsage.TotalTokens += usagePtr.TotalTokens
-			cumulativeUsage.EstimatedCost += usagePtr.EstimatedCost
+			cumulativeUsage.Add(*usagePtr)
 		}
 
 		if wc.UpdateTokenUsage != nil && usagePtr != nil {
@@ -393,18 +433,6 @@ Output JSON: { "needs_clarification": true, "question": "..." } OR { "needs_clar
 			return ProjectIntent{}, err
 		}
 
-		clean := strings.TrimSpace(resp)
-
-		if idx := strings.Index(clean, "{"); idx != -1 {
-			clean = clean[idx:]
-		}
-		if idx := strings.LastIndex(clean, "}"); idx != -1 {
-			clean = clean[:idx+1]
-		}
-
-		var raw map[string]interface{}
-		_ = json.Unmarshal([]byte(clean), &raw)
-
 		if needs, _ := raw["needs_clarification"].(bool); needs {
 			question, _ := raw["question"].(string)
 			wc.OutputChan <- fmt.Sprintf("🤔 [Intent] Question: %s", question)
@@ -554,17 +582,20 @@ Key Rules:
 			reqPrompt += "\nTarget Audience: " + intent.TargetAudience
 		}
 
-		resp, usagePtr, err := wc.CallLLM(sysPrompt+"\nRequest: "+reqPrompt, "Draft Script", providerName)
+		var script AVScript
+		resp, usagePtr, err := wc.CallStructured(sysPrompt+"\nRequest: "+reqPrompt, "Draft Script", scriptSchema, &script, providerName)
 		if usagePtr != nil {
-			cumulativeUsage.PromptTokens += usagePtr.PromptTokens
-			cumulativeUsage.CompletionTokens += usagePtr.CompletionTokens
-			cumulativeUsage.TotalTokens += usagePtr.TotalTokens
-			cumulativeUsage.EstimatedCost += usagePtr.EstimatedCost
+			cumulativeUsage.Add(*usagePtr)
 		}
 
 		if wc.UpdateTokenUsage != nil && usagePtr != nil {
 			wc.UpdateTokenUsage(*usagePtr)
 		}
+		var structErr *llm.StructuredError
+		if errors.As(err, &structErr) {
+			wc.OutputChan <- "⚠️ [VideoWorkflow] Script parse error. Retrying..."
+			continue
+		}
 		if err != nil {
 			usageVal := model.TokenUsage{}
 			if usagePtr != nil {
@@ -574,20 +605,6 @@ Key Rules:
 			return AVScript{}, err
 		}
 
-		clean := strings.TrimSpace(resp)
-		if idx := strings.Index(clean, "{"); idx != -1 {
-			clean = clean[idx:]
-		}
-		if idx := strings.LastIndex(clean, "}"); idx != -1 {
-			clean = clean[:idx+1]
-		}
-
-		var script AVScript
-		if err := json.Unmarshal([]byte(clean), &script); err != nil {
-			wc.OutputChan <- "⚠️ [VideoWorkflow] Script parse error. Retrying..."
-			continue
-		}
-
 		if len(script.Scenes) == 0 {
 			wc.OutputChan <- "⚠️ [VideoWorkflow] Script generated with 0 scenes. Retrying..."
 			continue
@@ -646,7 +663,7 @@ Key Rules:
 				Status:  "rejected",
 				Result:  fmt.Sprintf("Rejected: %s", input),
 			})
-			currentPrompt = fmt.Sprintf("Fix: %s. Prev: %s", input, clean)
+			currentPrompt = fmt.Sprintf("Fix: %s. Prev: %s", input, llm.ExtractJSON(resp))
 		}
 	}
 }
@@ -660,156 +677,94 @@ func (w *VideoCreationWorkflow) formatScript(script AVScript) string {
 }
 
 func (w *VideoCreationWorkflow) generateAudio(wc *WorkflowContext, s Scene, language string) (Scene, *model.TokenUsage, error) {
-	executor, _ := wc.Dispatcher.GetExecutor("text-to-speech")
-	execChan := make(chan string, 100)
-	var capturedFile string
-	var usage *model.TokenUsage
-	go func() {
-		params := map[string]interface{}{
-			"audio_text": s.AudioText,
-			"scene_id":   s.ID,
-			"plan_id":    wc.PlanID,
-		}
-		if language != "" {
-			params["language"] = language
-		}
-		_ = executor.Execute(wc.Ctx, model.Step{Action: "text_to_speech", Params: params}, execChan)
-		close(execChan)
-	}()
-	for msg := range execChan {
-		wc.OutputChan <- fmt.Sprintf("  %s", msg)
-		if strings.HasPrefix(msg, "RESULT_AUDIO_FILE=") {
-			capturedFile = strings.TrimPrefix(msg, "RESULT_AUDIO_FILE=")
-		}
-		if strings.HasPrefix(msg, "RESULT_DURATION=") {
-			dStr := strings.TrimPrefix(msg, "RESULT_DURATION=")
-			dStr = strings.TrimSuffix(dStr, "s")
-			if dInt, err := strconv.Atoi(dStr); err == nil {
-				s.Duration = dInt
-			}
-		}
-		if u := parseUsage(msg); u != nil {
-			usage = u
-		}
+	exec, _ := wc.Dispatcher.GetExecutor("text-to-speech")
+	params := map[string]interface{}{
+		"audio_text": s.AudioText,
+		"scene_id":   s.ID,
+		"plan_id":    wc.PlanID,
+	}
+	if language != "" {
+		params["language"] = language
+	}
+	result := runExecutor(wc, exec, model.Step{Action: "text_to_speech", Params: params})
+
+	if d, ok := result.Outputs["duration"].(float64); ok {
+		s.Duration = int(d)
 	}
-	if capturedFile != "" {
-		s.AudioFile = capturedFile
+	if f, ok := result.Outputs["audio_file"].(string); ok && f != "" {
+		s.AudioFile = f
 	} else {
 		s.AudioFile = fmt.Sprintf("audio_scene_%d.mp3", s.ID)
 	}
-	return s, usage, nil
+	return s, result.Usage, nil
 }
 
 func (w *VideoCreationWorkflow) generateImage(wc *WorkflowContext, s Scene) (Scene, *model.TokenUsage, error) {
-	executor, _ := wc.Dispatcher.GetExecutor("image_generation")
-	execChan := make(chan string, 100)
-	var capturedFile string
-	var usage *model.TokenUsage
-	go func() {
-		_ = executor.Execute(wc.Ctx, model.Step{Action: "image_generation", Params: map[string]interface{}{"visual_prompt": s.VisualPrompt, "scene_id": s.ID, "plan_id": wc.PlanID}}, execChan)
-		close(execChan)
-	}()
-	for msg := range execChan {
-		wc.OutputChan <- fmt.Sprintf("  %s", msg)
-		if strings.HasPrefix(msg, "RESULT_IMAGE_FILE=") {
-			capturedFile = strings.TrimPrefix(msg, "RESULT_IMAGE_FILE=")
-		}
-		if u := parseUsage(msg); u != nil {
-			usage = u
-		}
-	}
-	if capturedFile != "" {
-		s.ImageFile = capturedFile
+	exec, _ := wc.Dispatcher.GetExecutor("image_generation")
+	result := runExecutor(wc, exec, model.Step{Action: "image_generation", Params: map[string]interface{}{"visual_prompt": s.VisualPrompt, "scene_id": s.ID, "plan_id": wc.PlanID}})
+
+	if f, ok := result.Outputs["image_file"].(string); ok && f != "" {
+		s.ImageFile = f
 	} else {
 		s.ImageFile = fmt.Sprintf("image_scene_%d.png", s.ID)
 	}
-	return s, usage, nil
+	return s, result.Usage, nil
 }
 
 func (w *VideoCreationWorkflow) generateVideo(wc *WorkflowContext, s Scene) (Scene, *model.TokenUsage, error) {
-	executor, _ := wc.Dispatcher.GetExecutor("video_generation")
-	execChan := make(chan string, 100)
-	var capturedFile string
-	var usage *model.TokenUsage
-	go func() {
-		_ = executor.Execute(wc.Ctx, model.Step{Action: "video_generation", Params: map[string]interface{}{"visual_prompt": s.VisualPrompt, "audio_file": s.AudioFile, "image_file": s.ImageFile, "duration": s.Duration, "scene_id": s.ID, "plan_id": wc.PlanID}}, execChan)
-		close(execChan)
-	}()
-	for msg := range execChan {
-		wc.OutputChan <- fmt.Sprintf("  %s", msg)
-		if strings.HasPrefix(msg, "RESULT_VIDEO_FILE=") {
-			capturedFile = strings.TrimPrefix(msg, "RESULT_VIDEO_FILE=")
-		}
-		if u := parseUsage(msg); u != nil {
-			usage = u
-		}
-	}
-	if capturedFile != "" {
-		s.VideoFile = capturedFile
+	exec, _ := wc.Dispatcher.GetExecutor("video_generation")
+	result := runExecutor(wc, exec, model.Step{Action: "video_generation", Params: map[string]interface{}{"visual_prompt": s.VisualPrompt, "audio_file": s.AudioFile, "image_file": s.ImageFile, "duration": s.Duration, "scene_id": s.ID, "plan_id": wc.PlanID}})
+
+	if f, ok := result.Outputs["video_file"].(string); ok && f != "" {
+		s.VideoFile = f
 	} else {
 		s.VideoFile = fmt.Sprintf("video_scene_%d.mp4", s.ID)
 	}
-	return s, usage, nil
+	return s, result.Usage, nil
 }
 
-func parseUsage(msg string) *model.TokenUsage {
-	if strings.HasPrefix(msg, "RESULT_TOKEN_USAGE=") {
-		parts := strings.Split(strings.TrimPrefix(msg, "RESULT_TOKEN_USAGE="), ",")
-		if len(parts) >= 3 {
-			p, _ := strconv.Atoi(parts[0])
-			c, _ := strconv.Atoi(parts[1])
-			t, _ := strconv.Atoi(parts[2])
-			var cost float64
-			if len(parts) >= 4 {
-				cost, _ = strconv.ParseFloat(parts[3], 64)
-			}
-			return &model.TokenUsage{
-				PromptTokens:     p,
-				CompletionTokens: c,
-				TotalTokens:      t,
-				EstimatedCost:    cost,
-			}
+// runExecutor executes a single step, forwards its log lines and collects its outputs
+func runExecutor(wc *WorkflowContext, exec executor.Executor, step model.Step) executor.StepResult {
+	var result executor.StepResult
+	release, err := wc.acquireSlots(step)
+	if err != nil {
+		return result
+	}
+	defer release()
+
+	execChan := make(chan executor.Event, 100)
+	go func() {
+		_ = exec.Execute(wc.Ctx, step, execChan)
+		close(execChan)
+	}()
+	for ev := range execChan {
+		if !result.Apply(ev) {
+			wc.OutputChan <- fmt.Sprintf("  %s", ev.Message)
 		}
 	}
-	return nil
+	return result
 }
 
 func (w *VideoCreationWorkflow) mergeVideo(wc *WorkflowContext, scenes []Scene) (string, *model.TokenUsage, error) {
-	executor, err := wc.Dispatcher.GetExecutor("content_merge")
+	exec, err := wc.Dispatcher.GetExecutor("content_merge")
 	if err != nil {
 		return "", nil, err
 	}
 
-	execChan := make(chan string, 100)
-	var capturedFile string
-	var usage *model.TokenUsage
-
-	go func() {
-		_ = executor.Execute(wc.Ctx, model.Step{
-			Action: "content_merge",
-			Params: map[string]interface{}{
-				"av_script": scenes,
-				"plan_id":   wc.PlanID,
-			},
-		}, execChan)
-		close(execChan)
-	}()
-
-	for msg := range execChan {
-		wc.OutputChan <- fmt.Sprintf("  %s", msg)
-		if strings.HasPrefix(msg, "RESULT_VIDEO_FILE=") {
-			capturedFile = strings.TrimPrefix(msg, "RESULT_VIDEO_FILE=")
-		}
-		if u := parseUsage(msg); u != nil {
-			usage = u
-		}
-	}
+	result := runExecutor(wc, exec, model.Step{
+		Action: "content_merge",
+		Params: map[string]interface{}{
+			"av_script": scenes,
+			"plan_id":   wc.PlanID,
+		},
+	})
 
-	if usage != nil && wc.UpdateTokenUsage != nil {
-		wc.UpdateTokenUsage(*usage)
+	if result.Usage != nil && wc.UpdateTokenUsage != nil {
+		wc.UpdateTokenUsage(*result.Usage)
 	}
 
-	return capturedFile, usage, nil
+	videoFile, _ := result.Outputs["video_file"].(string)
+	return videoFile, result.Usage, nil
 }
 
 func (w *VideoCreationWorkflow) formatReviewData(phaseName string, scenes []Scene) string {
@@ -0,0 +1,124 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Commit identity of the workspace repositories
+const (
+	authorName  = "Druppie"
+	authorEmail = "druppie@localhost"
+)
+
+// ignored keeps runtime state and bulky build output out of the workspace history;
+// builds are tracked through builds/manifest.json
+const ignored = `# Managed by Druppie
+builds/*/
+vector_store/
+node_modules/
+memory.json
+revisions.jsonl
+`
+
+// Repo is a git repository driven through the git CLI
+type Repo struct {
+	Dir string
+}
+
+// run executes a git command in the repository and returns its trimmed output
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	return r.runEnv(ctx, nil, args...)
+}
+
+// runEnv is run with extra environment variables
+func (r *Repo) runEnv(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Init makes the directory a repository, once, with the workspace .gitignore
+func (r *Repo) Init(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+	if _, err := r.run(ctx, "init", "-q", "-b", "main"); err != nil {
+		return err
+	}
+	gitignore := filepath.Join(r.Dir, ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		return os.WriteFile(gitignore, []byte(ignored), 0644)
+	}
+	return nil
+}
+
+// CommitAll stages every change and commits it. It returns the commit hash, or an empty
+// hash when there was nothing to commit.
+func (r *Repo) CommitAll(ctx context.Context, message string) (string, error) {
+	if _, err := r.run(ctx, "add", "-A"); err != nil {
+		return "", err
+	}
+	if status, err := r.run(ctx, "status", "--porcelain"); err != nil || status == "" {
+		return "", err
+	}
+	if _, err := r.run(ctx, "-c", "user.name="+authorName, "-c", "user.email="+authorEmail, "commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	return r.run(ctx, "rev-parse", "--short", "HEAD")
+}
+
+// Remote returns the URL of the origin remote (empty without one)
+func (r *Repo) Remote(ctx context.Context) string {
+	url, err := r.run(ctx, "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+// SetRemote points origin at url
+func (r *Repo) SetRemote(ctx context.Context, url string) error {
+	if r.Remote(ctx) == "" {
+		_, err := r.run(ctx, "remote", "add", "origin", url)
+		return err
+	}
+	_, err := r.run(ctx, "remote", "set-url", "origin", url)
+	return err
+}
+
+// Push pushes main to origin. The credentials are passed as a request header through the
+// environment, so the token is neither written to the repository config nor visible in the
+// command line of the process.
+func (r *Repo) Push(ctx context.Context, user, token string) error {
+	var env []string
+	if token != "" {
+		if user == "" {
+			user = "oauth2"
+		}
+		cred := base64.StdEncoding.EncodeToString([]byte(user + ":" + token))
+		env = []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + cred,
+		}
+	}
+	_, err := r.runEnv(ctx, env, "push", "-q", "-u", "origin", "main")
+	return err
+}
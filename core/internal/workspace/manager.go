@@ -0,0 +1,215 @@
+// Package workspace keeps the history of plan workspaces (.druppie/plans/<id>) in git
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+	"github.com/sjhoeksma/druppie/core/internal/model"
+	"github.com/sjhoeksma/druppie/core/internal/paths"
+)
+
+// SnapshotFile is the copy of the plan committed with each workspace change
+const SnapshotFile = "plan.snapshot.json"
+
+// Manager commits plan workspaces. Commits of one plan are serialized.
+type Manager struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // planID -> lock
+}
+
+// NewManager creates a workspace manager
+func NewManager() *Manager {
+	return &Manager{locks: make(map[string]*sync.Mutex)}
+}
+
+// Available reports whether the git CLI is installed
+func Available() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+func (m *Manager) lock(planID string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[planID]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[planID] = l
+	}
+	return l
+}
+
+// Dir returns the workspace directory of a plan
+func Dir(planID string) (string, error) {
+	return paths.ResolvePath(".druppie", "plans", planID)
+}
+
+// Commit writes a snapshot of the plan (plan.snapshot.json) and the builds manifest into the
+// workspace of the plan and commits all changes with message. It returns the short commit hash, empty when nothing changed.
+func (m *Manager) Commit(ctx context.Context, plan model.ExecutionPlan, message string) (string, error) {
+	l := m.lock(plan.ID)
+	l.Lock()
+	defer l.Unlock()
+
+	dir, err := Dir(plan.ID)
+	if err != nil {
+		return "", err
+	}
+	repo := &Repo{Dir: dir}
+	if err := repo.Init(ctx); err != nil {
+		return "", fmt.Errorf("failed to init workspace repository: %w", err)
+	}
+
+	// A snapshot gives SQL backed stores the same history. It is not plan.json: the FileStore
+	// keeps the plan there, and this copy may already be older than the stored version.
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, SnapshotFile), data, 0644); err != nil {
+		return "", err
+	}
+	if err := writeBuildsManifest(filepath.Join(dir, "builds")); err != nil {
+		return "", fmt.Errorf("failed to write builds manifest: %w", err)
+	}
+	return repo.CommitAll(ctx, message)
+}
+
+// Push creates the remote repository of the plan on the configured provider when the
+// workspace has no origin yet, and pushes the workspace to it
+func (m *Manager) Push(ctx context.Context, cfg config.GitConfig, plan model.ExecutionPlan) error {
+	l := m.lock(plan.ID)
+	l.Lock()
+	defer l.Unlock()
+
+	dir, err := Dir(plan.ID)
+	if err != nil {
+		return err
+	}
+	repo := &Repo{Dir: dir}
+	if repo.Remote(ctx) == "" {
+		description := "Druppie plan " + plan.ID
+		if prompt := plan.Intent.InitialPrompt; prompt != "" {
+			if len(prompt) > 200 {
+				prompt = prompt[:200] + "..."
+			}
+			description += ": " + prompt
+		}
+		cloneURL, err := CreateRemote(ctx, cfg, RepoName(plan.ID), description)
+		if err != nil {
+			return fmt.Errorf("failed to create remote: %w", err)
+		}
+		if err := repo.SetRemote(ctx, cloneURL); err != nil {
+			return err
+		}
+	}
+	return repo.Push(ctx, cfg.User, cfg.Token)
+}
+
+// RepoName returns the remote repository name of a plan
+func RepoName(planID string) string {
+	return "druppie-" + strings.ToLower(planID)
+}
+
+// CommitMessage describes completed steps: the subject names the step and agent, a batch
+// of parallel steps lists each of them in the body
+func CommitMessage(steps []model.Step) string {
+	if len(steps) == 1 {
+		s := steps[0]
+		return fmt.Sprintf("Step %d: %s by %s\n\nStep-ID: %d\nAgent: %s\n", s.ID, s.Action, s.AgentID, s.ID, s.AgentID)
+	}
+	var sb strings.Builder
+	ids := make([]string, len(steps))
+	for i, s := range steps {
+		ids[i] = fmt.Sprint(s.ID)
+	}
+	sb.WriteString(fmt.Sprintf("Steps %s completed\n\n", strings.Join(ids, ", ")))
+	for _, s := range steps {
+		sb.WriteString(fmt.Sprintf("- Step %d: %s by %s\n", s.ID, s.Action, s.AgentID))
+	}
+	return sb.String()
+}
+
+// buildEntry describes one build output in builds/manifest.json
+type buildEntry struct {
+	ID       string            `json:"id"`
+	Modified time.Time         `json:"modified"`
+	Files    map[string]string `json:"files"` // Relative path -> sha256
+}
+
+// writeBuildsManifest records the files of every build with their hashes; the build
+// output itself is not committed
+func writeBuildsManifest(buildsDir string) error {
+	data, err := BuildsManifest(buildsDir)
+	if err != nil || data == nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(buildsDir, "manifest.json"), data, 0644)
+}
+
+// BuildsManifest returns the JSON list of the builds in buildsDir with the hash of
+// every file, nil when there is no builds directory
+func BuildsManifest(buildsDir string) ([]byte, error) {
+	entries, err := os.ReadDir(buildsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var builds []buildEntry
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, _ := entry.Info()
+		build := buildEntry{ID: entry.Name(), Files: make(map[string]string)}
+		if info != nil {
+			build.Modified = info.ModTime().UTC()
+		}
+		root := filepath.Join(buildsDir, entry.Name())
+		_ = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if fi.IsDir() {
+				if fi.Name() == "node_modules" || fi.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			build.Files[filepath.ToSlash(rel)] = fileHash(path)
+			return nil
+		})
+		builds = append(builds, build)
+	}
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	return json.MarshalIndent(builds, "", "  ")
+}
+
+func fileHash(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sjhoeksma/druppie/core/internal/config"
+)
+
+// CreateRemote returns the clone URL of the repository name on the configured git
+// provider (gitea, github or gitlab), creating a private repository when it does not exist yet
+func CreateRemote(ctx context.Context, cfg config.GitConfig, name, description string) (string, error) {
+	if cfg.Token == "" {
+		return "", fmt.Errorf("git token is required to create remotes")
+	}
+	switch strings.ToLower(cfg.Provider) {
+	case "gitea":
+		return createGiteaRepo(ctx, cfg, name, description)
+	case "github":
+		return createGitHubRepo(ctx, cfg, name, description)
+	case "gitlab":
+		return createGitLabRepo(ctx, cfg, name, description)
+	default:
+		return "", fmt.Errorf("unknown git provider: %s", cfg.Provider)
+	}
+}
+
+// owner returns the organization or user owning the plan repositories
+func owner(cfg config.GitConfig) string {
+	if cfg.Owner != "" {
+		return cfg.Owner
+	}
+	return cfg.User
+}
+
+// apiRequest sends a JSON request and decodes the response into out. It returns the status code.
+func apiRequest(ctx context.Context, method, endpoint string, headers map[string]string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, endpoint, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %s: %w", endpoint, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func createGiteaRepo(ctx context.Context, cfg config.GitConfig, name, description string) (string, error) {
+	if cfg.URL == "" {
+		return "", fmt.Errorf("gitea url is required")
+	}
+	api := strings.TrimSuffix(cfg.URL, "/") + "/api/v1"
+	headers := map[string]string{"Authorization": "token " + cfg.Token}
+	var repo struct {
+		CloneURL string `json:"clone_url"`
+	}
+	if owner := owner(cfg); owner != "" {
+		if _, err := apiRequest(ctx, "GET", fmt.Sprintf("%s/repos/%s/%s", api, url.PathEscape(owner), url.PathEscape(name)), headers, nil, &repo); err == nil {
+			return repo.CloneURL, nil
+		}
+	}
+
+	endpoint := api + "/user/repos"
+	if cfg.Owner != "" && cfg.Owner != cfg.User {
+		endpoint = fmt.Sprintf("%s/orgs/%s/repos", api, url.PathEscape(cfg.Owner))
+	}
+	body := map[string]interface{}{"name": name, "description": description, "private": true}
+	if _, err := apiRequest(ctx, "POST", endpoint, headers, body, &repo); err != nil {
+		return "", err
+	}
+	return repo.CloneURL, nil
+}
+
+func createGitHubRepo(ctx context.Context, cfg config.GitConfig, name, description string) (string, error) {
+	api := "https://api.github.com"
+	if cfg.URL != "" {
+		api = strings.TrimSuffix(cfg.URL, "/") // GitHub Enterprise: https://<host>/api/v3
+	}
+	headers := map[string]string{"Authorization": "Bearer " + cfg.Token, "Accept": "application/vnd.github+json"}
+	var repo struct {
+		CloneURL string `json:"clone_url"`
+	}
+	if owner := owner(cfg); owner != "" {
+		if _, err := apiRequest(ctx, "GET", fmt.Sprintf("%s/repos/%s/%s", api, url.PathEscape(owner), url.PathEscape(name)), headers, nil, &repo); err == nil {
+			return repo.CloneURL, nil
+		}
+	}
+
+	endpoint := api + "/user/repos"
+	if cfg.Owner != "" && cfg.Owner != cfg.User {
+		endpoint = fmt.Sprintf("%s/orgs/%s/repos", api, url.PathEscape(cfg.Owner))
+	}
+	body := map[string]interface{}{"name": name, "description": description, "private": true, "auto_init": false}
+	if _, err := apiRequest(ctx, "POST", endpoint, headers, body, &repo); err != nil {
+		return "", err
+	}
+	return repo.CloneURL, nil
+}
+
+func createGitLabRepo(ctx context.Context, cfg config.GitConfig, name, description string) (string, error) {
+	base := "https://gitlab.com"
+	if cfg.URL != "" {
+		base = strings.TrimSuffix(cfg.URL, "/")
+	}
+	api := base + "/api/v4"
+	headers := map[string]string{"PRIVATE-TOKEN": cfg.Token}
+	var project struct {
+		HTTPURL string `json:"http_url_to_repo"`
+	}
+	if owner := owner(cfg); owner != "" {
+		if _, err := apiRequest(ctx, "GET", fmt.Sprintf("%s/projects/%s", api, url.PathEscape(owner+"/"+name)), headers, nil, &project); err == nil {
+			return project.HTTPURL, nil
+		}
+	}
+
+	body := map[string]interface{}{"name": name, "path": name, "description": description, "visibility": "private"}
+	if cfg.Owner != "" && cfg.Owner != cfg.User {
+		// Projects of a group need the namespace id
+		var ns struct {
+			ID int `json:"id"`
+		}
+		if _, err := apiRequest(ctx, "GET", fmt.Sprintf("%s/namespaces/%s", api, url.PathEscape(cfg.Owner)), headers, nil, &ns); err != nil {
+			return "", err
+		}
+		body["namespace_id"] = ns.ID
+	}
+	if _, err := apiRequest(ctx, "POST", api+"/projects", headers, body, &project); err != nil {
+		return "", err
+	}
+	return project.HTTPURL, nil
+}